@@ -0,0 +1,209 @@
+package apikeys_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/go-bolo/bolo/acl"
+	"github.com/go-bolo/bolo/apikeys"
+	"github.com/go-bolo/bolo/users"
+	"github.com/go-bolo/clock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestApp(t *testing.T) (bolo.App, *clock.Mock) {
+	os.Setenv("TEMPLATE_DISABLE", "true")
+	os.Setenv("DB_URI", filepath.Join(t.TempDir(), "apikeys.sqlite"))
+	t.Cleanup(func() {
+		os.Unsetenv("TEMPLATE_DISABLE")
+		os.Unsetenv("DB_URI")
+	})
+
+	app := bolo.NewApp(&bolo.DefaultAppOptions{})
+
+	c := clock.NewMock()
+	now, _ := time.Parse("2006-01-02", "2023-07-16")
+	c.Set(now)
+	app.SetClock(c)
+
+	app.AddPlugin(users.NewPlugin(&users.PluginOpts{PasswordHasher: &users.BcryptHasher{Cost: 4}}))
+	app.AddPlugin(apikeys.NewPlugin(&apikeys.PluginOpts{}))
+
+	require.Nil(t, app.Bootstrap())
+	require.Nil(t, app.SyncDB())
+
+	app.GetAcl().SetRole("authenticated", acl.Role{
+		Name: "authenticated",
+		Permissions: []string{
+			"find_api_key", "findOne_api_key", "create_api_key", "update_api_key", "delete_api_key",
+			"find_content", "create_content",
+		},
+	})
+
+	return app, c
+}
+
+func createKey(t *testing.T, app bolo.App, userID string, permissions []string, expiresAt *time.Time) (*apikeys.APIKey, string) {
+	record := apikeys.APIKey{
+		UserID:      userID,
+		Name:        "integration",
+		Permissions: permissions,
+		ExpiresAt:   expiresAt,
+	}
+
+	key, err := record.Generate("bolo")
+	require.Nil(t, err)
+	require.Nil(t, record.Save(app))
+
+	return &record, key
+}
+
+func createUser(t *testing.T, app bolo.App) *users.User {
+	u := users.NewUser(app)
+	u.SetEmail("alice@example.com")
+	require.Nil(t, u.Save(app))
+	return u
+}
+
+func do(app bolo.App, method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	rec := httptest.NewRecorder()
+	app.GetRouter().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	app, clk := newTestApp(t)
+	user := createUser(t, app)
+
+	var can map[string]bool
+	var currentUser bolo.User
+	app.GetRouter().GET("/check", func(c echo.Context) error {
+		currentUser = bolo.GetAuthenticatedUser(c)
+		can = map[string]bool{
+			"find_content":   bolo.Can(c, "find_content"),
+			"create_content": bolo.Can(c, "create_content"),
+		}
+		return c.NoContent(http.StatusOK)
+	})
+
+	record, key := createKey(t, app, user.GetID(), []string{"find_content"}, nil)
+	assert.True(t, strings.HasPrefix(key, record.Prefix+"_"))
+	assert.NotContains(t, record.KeyHash, key)
+
+	t.Run("Should authenticate with X-API-Key and limit permissions", func(t *testing.T) {
+		rec := do(app, http.MethodGet, "/check", "", map[string]string{"X-API-Key": key})
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NotNil(t, currentUser)
+		assert.Equal(t, user.GetID(), currentUser.GetID())
+		assert.True(t, can["find_content"])
+		// allowed by the user role but not by the key:
+		assert.False(t, can["create_content"])
+
+		saved, err := apikeys.FindOne(app, record.GetID())
+		require.Nil(t, err)
+		require.NotNil(t, saved.LastUsedAt)
+	})
+
+	t.Run("Should authenticate with Authorization Bearer", func(t *testing.T) {
+		currentUser = nil
+		rec := do(app, http.MethodGet, "/check", "", map[string]string{"Authorization": "Bearer " + key})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.NotNil(t, currentUser)
+	})
+
+	t.Run("Should ignore bearer tokens without the key prefix", func(t *testing.T) {
+		currentUser = nil
+		rec := do(app, http.MethodGet, "/check", "", map[string]string{"Authorization": "Bearer other-token"})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Nil(t, currentUser)
+	})
+
+	t.Run("Should reject invalid keys", func(t *testing.T) {
+		rec := do(app, http.MethodGet, "/check", "", map[string]string{"X-API-Key": key[:len(key)-2] + "00"})
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		rec = do(app, http.MethodGet, "/check", "", map[string]string{"X-API-Key": "invalid"})
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("Should reject expired keys", func(t *testing.T) {
+		expiresAt := clk.Now().Add(time.Hour)
+		_, expiringKey := createKey(t, app, user.GetID(), []string{"find_content"}, &expiresAt)
+
+		rec := do(app, http.MethodGet, "/check", "", map[string]string{"X-API-Key": expiringKey})
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		clk.Add(2 * time.Hour)
+
+		rec = do(app, http.MethodGet, "/check", "", map[string]string{"X-API-Key": expiringKey})
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+func TestAPIKeyResource(t *testing.T) {
+	app, _ := newTestApp(t)
+	user := createUser(t, app)
+
+	// one key allowed to manage keys, used as the request credential:
+	_, adminKey := createKey(t, app, user.GetID(), []string{"create_api_key", "find_api_key", "delete_api_key", "find_content"}, nil)
+	headers := map[string]string{"X-API-Key": adminKey}
+
+	t.Run("Should require authentication", func(t *testing.T) {
+		rec := do(app, http.MethodGet, "/api/v1/api-keys", "", nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("Should not create keys with permissions outside the current scope", func(t *testing.T) {
+		rec := do(app, http.MethodPost, "/api/v1/api-keys", `{"apiKey":{"name":"sync","permissions":["create_content"]}}`, headers)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	rec := do(app, http.MethodPost, "/api/v1/api-keys", `{"apiKey":{"name":"sync","permissions":["find_content"]}}`, headers)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	created := apikeys.CreateJSONResponse{}
+	require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	assert.True(t, strings.HasPrefix(created.Key, "bolo_"))
+	assert.Equal(t, user.GetID(), created.APIKey.UserID)
+	assert.NotContains(t, rec.Body.String(), "keyHash")
+
+	rec = do(app, http.MethodGet, "/api/v1/api-keys", "", headers)
+	require.Equal(t, http.StatusOK, rec.Code)
+	list := apikeys.ListJSONResponse{}
+	require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	assert.Equal(t, int64(2), list.Meta.Count)
+
+	t.Run("Should find keys with only the find permissions", func(t *testing.T) {
+		_, readKey := createKey(t, app, user.GetID(), []string{"find_api_key", "findOne_api_key"}, nil)
+		readHeaders := map[string]string{"X-API-Key": readKey}
+
+		rec := do(app, http.MethodGet, "/api/v1/api-keys", "", readHeaders)
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		rec = do(app, http.MethodGet, "/api/v1/api-keys/"+created.APIKey.GetID(), "", readHeaders)
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Contains(t, rec.Body.String(), `"name":"sync"`)
+	})
+
+	rec = do(app, http.MethodDelete, "/api/v1/api-keys/"+created.APIKey.GetID(), "", headers)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = do(app, http.MethodGet, "/check-deleted", "", map[string]string{"X-API-Key": created.Key})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -0,0 +1,250 @@
+package apikeys
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// PermissionManageAll - Allow to manage API keys from all users
+const PermissionManageAll = "manage_all_api_key"
+
+type APIKeyController struct {
+	Plugin *Plugin
+}
+
+type ListJSONResponse struct {
+	bolo.BaseListReponse
+	APIKeys []*APIKey `json:"apiKey"`
+}
+
+type CountJSONResponse struct {
+	bolo.BaseMetaResponse
+}
+
+type FindOneJSONResponse struct {
+	APIKey *APIKey `json:"apiKey"`
+}
+
+// CreateJSONResponse - The plain Key is only returned on create
+type CreateJSONResponse struct {
+	APIKey *APIKey `json:"apiKey"`
+	Key    string  `json:"key"`
+}
+
+type BodyRequest struct {
+	APIKey *APIKeyBody `json:"apiKey"`
+}
+
+type APIKeyBody struct {
+	Name        string     `json:"name" validate:"required"`
+	Permissions []string   `json:"permissions" validate:"required,min=1"`
+	ExpiresAt   *time.Time `json:"expiresAt"`
+}
+
+func (ctl *APIKeyController) Find(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	limit := bolo.GetLimit(c)
+	if limit <= 0 {
+		limit = 50
+	}
+
+	var count int64
+	records := []*APIKey{}
+
+	query := ctl.scopedQuery(c, bolo.GetDB(c).Model(&APIKey{}))
+
+	err := query.Count(&count).Error
+	if err != nil {
+		return nil, fmt.Errorf("APIKeyController.Find: %w", err)
+	}
+
+	err = query.Order("id DESC").Limit(limit).Offset(bolo.GetOffset(c)).Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("APIKeyController.Find: %w", err)
+	}
+
+	bolo.GetPager(c).Count = count
+
+	resp := ListJSONResponse{APIKeys: records}
+	resp.Meta.Count = count
+
+	return &bolo.DefaultResponse{Data: &resp}, nil
+}
+
+func (ctl *APIKeyController) Count(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	err := ctl.scopedQuery(c, bolo.GetDB(c).Model(&APIKey{})).Count(&count).Error
+	if err != nil {
+		return nil, fmt.Errorf("APIKeyController.Count: %w", err)
+	}
+
+	return &bolo.DefaultResponse{
+		Data: &CountJSONResponse{BaseMetaResponse: bolo.BaseMetaResponse{Count: count}},
+	}, nil
+}
+
+func (ctl *APIKeyController) FindOne(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	record, err := ctl.loadRecord(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bolo.DefaultResponse{Data: &FindOneJSONResponse{APIKey: record}}, nil
+}
+
+func (ctl *APIKeyController) Create(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	app := bolo.GetApp(c)
+
+	body, err := bindBody(c)
+	if err != nil {
+		return nil, err
+	}
+
+	record := APIKey{
+		UserID:      bolo.GetAuthenticatedUser(c).GetID(),
+		Name:        body.Name,
+		Permissions: body.Permissions,
+		ExpiresAt:   body.ExpiresAt,
+	}
+
+	key, err := record.Generate(ctl.Plugin.KeyPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("APIKeyController.Create: %w", err)
+	}
+
+	err = record.Save(app)
+	if err != nil {
+		return nil, fmt.Errorf("APIKeyController.Create: %w", err)
+	}
+
+	return &bolo.DefaultResponse{
+		Status: http.StatusCreated,
+		Data:   &CreateJSONResponse{APIKey: &record, Key: key},
+	}, nil
+}
+
+func (ctl *APIKeyController) Update(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	record, err := ctl.loadRecord(c)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := bindBody(c)
+	if err != nil {
+		return nil, err
+	}
+
+	record.Name = body.Name
+	record.Permissions = body.Permissions
+	record.ExpiresAt = body.ExpiresAt
+
+	err = record.Save(bolo.GetApp(c))
+	if err != nil {
+		return nil, fmt.Errorf("APIKeyController.Update: %w", err)
+	}
+
+	return &bolo.DefaultResponse{Data: &FindOneJSONResponse{APIKey: record}}, nil
+}
+
+func (ctl *APIKeyController) Delete(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	record, err := ctl.loadRecord(c)
+	if err != nil {
+		return nil, err
+	}
+
+	err = bolo.GetDB(c).Delete(record).Error
+	if err != nil {
+		return nil, fmt.Errorf("APIKeyController.Delete: %w", err)
+	}
+
+	return &bolo.DefaultResponse{Status: http.StatusNoContent}, nil
+}
+
+// scopedQuery - Users only access their own keys
+func (ctl *APIKeyController) scopedQuery(c echo.Context, query *gorm.DB) *gorm.DB {
+	if bolo.Can(c, PermissionManageAll) {
+		return query
+	}
+
+	return query.Where("userId = ?", bolo.GetAuthenticatedUser(c).GetID())
+}
+
+func (ctl *APIKeyController) loadRecord(c echo.Context) (*APIKey, error) {
+	record := APIKey{}
+	err := ctl.scopedQuery(c, bolo.GetDB(c)).First(&record, "id = ?", c.Param("id")).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &bolo.HTTPError{Code: http.StatusNotFound, Message: "Not found", Internal: err}
+		}
+
+		return nil, fmt.Errorf("APIKeyController.loadRecord: %w", err)
+	}
+
+	return &record, nil
+}
+
+func checkAccess(c echo.Context) error {
+	if !bolo.IsAuthenticated(c) {
+		return &bolo.HTTPError{Code: http.StatusUnauthorized, Message: "Unauthorized"}
+	}
+
+	route := bolo.GetRoute(c)
+	if route.Permission != "" && !bolo.Can(c, route.Permission) {
+		return &bolo.HTTPError{Code: http.StatusForbidden, Message: "Forbidden"}
+	}
+
+	return nil
+}
+
+// bindBody - Parse and validate the body, the key permissions must be a subset of the current request permissions
+func bindBody(c echo.Context) (*APIKeyBody, error) {
+	body := BodyRequest{}
+	if err := c.Bind(&body); err != nil {
+		return nil, &bolo.HTTPError{Code: http.StatusBadRequest, Message: "Invalid body data", Internal: err}
+	}
+
+	if body.APIKey == nil {
+		return nil, &bolo.HTTPError{Code: http.StatusBadRequest, Message: "apiKey is required"}
+	}
+
+	if err := c.Validate(body.APIKey); err != nil {
+		return nil, err
+	}
+
+	for _, permission := range body.APIKey.Permissions {
+		if !bolo.Can(c, permission) {
+			return nil, &bolo.HTTPError{Code: http.StatusForbidden, Message: "Permission not allowed: " + permission}
+		}
+	}
+
+	return body.APIKey, nil
+}
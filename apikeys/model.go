@@ -0,0 +1,161 @@
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	ErrExpiredAPIKey = errors.New("expired api key")
+)
+
+// APIKey - Long lived credential issued for one user and limited to a set of ACL permissions.
+// Only the sha256 of the key is stored, the Prefix is public and used to find the key
+type APIKey struct {
+	ID          uint64     `gorm:"primary_key;column:id;" json:"id"`
+	UserID      string     `gorm:"column:userId;type:varchar(64);index" json:"userId"`
+	Name        string     `gorm:"column:name;type:varchar(255)" json:"name" validate:"required"`
+	Prefix      string     `gorm:"column:prefix;type:varchar(40);uniqueIndex" json:"prefix"`
+	KeyHash     string     `gorm:"column:keyHash;type:varchar(64)" json:"-"`
+	Permissions []string   `gorm:"column:permissions;serializer:json" json:"permissions" validate:"required,min=1"`
+	ExpiresAt   *time.Time `gorm:"column:expiresAt" json:"expiresAt"`
+	LastUsedAt  *time.Time `gorm:"column:lastUsedAt" json:"lastUsedAt"`
+	LastUsedIP  string     `gorm:"column:lastUsedIp;type:varchar(64)" json:"lastUsedIp"`
+	CreatedAt   time.Time  `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt   time.Time  `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+func (r *APIKey) TableName() string {
+	return "api_keys"
+}
+
+func (r *APIKey) GetID() string {
+	return strconv.FormatUint(r.ID, 10)
+}
+
+func (r *APIKey) LoadData() error {
+	return nil
+}
+
+func (r *APIKey) LoadTeaserData() error {
+	return nil
+}
+
+func (r *APIKey) Save(app bolo.App) error {
+	now := app.GetClock().Now()
+	r.UpdatedAt = now
+
+	if r.ID == 0 {
+		r.CreatedAt = now
+		return app.GetDB().Create(r).Error
+	}
+
+	return app.GetDB().Save(r).Error
+}
+
+func (r *APIKey) IsExpired(now time.Time) bool {
+	return r.ExpiresAt != nil && !r.ExpiresAt.After(now)
+}
+
+// Generate - Set one new random key in the record and returns the plain key.
+// The plain key format is <keyPrefix>_<id>_<secret>
+func (r *APIKey) Generate(keyPrefix string) (string, error) {
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	r.Prefix = keyPrefix + "_" + hex.EncodeToString(id)
+	key := r.Prefix + "_" + hex.EncodeToString(secret)
+	r.KeyHash = hashKey(key)
+
+	return key, nil
+}
+
+// Verify - Constant time check of one plain key against the stored hash
+func (r *APIKey) Verify(key string) bool {
+	return subtle.ConstantTimeCompare([]byte(r.KeyHash), []byte(hashKey(key))) == 1
+}
+
+// FindOneByKey - Find and validate the record of one plain key
+func FindOneByKey(app bolo.App, key string) (*APIKey, error) {
+	prefix, err := parseKeyPrefix(key)
+	if err != nil {
+		return nil, err
+	}
+
+	record := APIKey{}
+	err = app.GetDB().First(&record, "prefix = ?", prefix).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+
+		return nil, fmt.Errorf("apikeys.FindOneByKey: %w", err)
+	}
+
+	if !record.Verify(key) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if record.IsExpired(app.GetClock().Now()) {
+		return nil, ErrExpiredAPIKey
+	}
+
+	return &record, nil
+}
+
+func FindOne(app bolo.App, id string) (*APIKey, error) {
+	record := APIKey{}
+	err := app.GetDB().First(&record, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// TouchLastUsed - Update the last used information, at most once per interval to avoid one write per request
+func TouchLastUsed(app bolo.App, r *APIKey, ip string, interval time.Duration) error {
+	now := app.GetClock().Now()
+	if r.LastUsedAt != nil && now.Sub(*r.LastUsedAt) < interval {
+		return nil
+	}
+
+	r.LastUsedAt = &now
+	r.LastUsedIP = ip
+
+	return app.GetDB().Model(&APIKey{}).
+		Where("id = ?", r.ID).
+		UpdateColumns(map[string]interface{}{"lastUsedAt": now, "lastUsedIp": ip}).Error
+}
+
+func parseKeyPrefix(key string) (string, error) {
+	i := strings.LastIndex(key, "_")
+	if i <= 0 || i == len(key)-1 {
+		return "", ErrInvalidAPIKey
+	}
+
+	return key[:i], nil
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
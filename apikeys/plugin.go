@@ -0,0 +1,182 @@
+package apikeys
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/go-bolo/bolo/users"
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const HeaderAPIKey = "X-API-Key"
+
+// UserLoader - Load the owner of one key
+type UserLoader func(app bolo.App, id string) (bolo.User, error)
+
+// Plugin - Scoped API keys authentication and management
+type Plugin struct {
+	bolo.Plugin
+
+	Name       string
+	App        bolo.App
+	Controller *APIKeyController
+	// Public prefix of the generated keys, like bolo_1a2b3c4d5e6f_<secret>
+	KeyPrefix string
+	LoadUser  UserLoader
+	// Minimum interval between last used updates
+	TouchInterval time.Duration
+}
+
+type PluginOpts struct {
+	KeyPrefix string
+	LoadUser  UserLoader
+}
+
+func NewPlugin(opts *PluginOpts) *Plugin {
+	return &Plugin{
+		Name:      "apikeys",
+		KeyPrefix: opts.KeyPrefix,
+		LoadUser:  opts.LoadUser,
+	}
+}
+
+func (p *Plugin) GetName() string {
+	return p.Name
+}
+
+func (p *Plugin) Init(app bolo.App) error {
+	app.GetLogger().Debug(p.GetName() + " Init")
+
+	p.App = app
+	cfg := app.GetConfiguration()
+
+	if p.KeyPrefix == "" {
+		p.KeyPrefix = cfg.GetF("API_KEY_PREFIX", "bolo")
+	}
+
+	if p.LoadUser == nil {
+		p.LoadUser = func(app bolo.App, id string) (bolo.User, error) {
+			return users.FindOneByID(app, id)
+		}
+	}
+
+	if p.TouchInterval == 0 {
+		p.TouchInterval = time.Minute
+	}
+
+	p.Controller = &APIKeyController{Plugin: p}
+
+	app.SetModel("api_key", &APIKey{})
+
+	// after the session middlewares, the API key overrides the session user
+	app.GetEvents().On("bindMiddlewares", event.ListenerFunc(func(e event.Event) error {
+		app.GetRouter().Use(p.Middleware())
+		return nil
+	}), event.Low)
+
+	app.SetResource(&bolo.Resource{
+		Name:       "api_key",
+		Prefix:     "/api/v1",
+		Path:       "/api-keys",
+		Controller: p.Controller,
+		Model:      &APIKey{},
+		// API keys don't have HTML pages
+		SkipHTMLEndpoints: true,
+	})
+
+	return nil
+}
+
+// Middleware - Authenticate the request with one API key from the X-API-Key or Authorization: Bearer headers.
+// The request roles are the key owner roles and the permissions are limited to the key permissions
+func (p *Plugin) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := p.GetRequestKey(c.Request())
+			if key == "" {
+				return next(c)
+			}
+
+			err := p.Authenticate(c, key)
+			if err != nil {
+				return err
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// GetRequestKey - Returns the plain API key from the request headers.
+// Bearer tokens without the key prefix are ignored to allow other token types
+func (p *Plugin) GetRequestKey(req *http.Request) string {
+	if key := req.Header.Get(HeaderAPIKey); key != "" {
+		return strings.TrimSpace(key)
+	}
+
+	auth := req.Header.Get(echo.HeaderAuthorization)
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		token := strings.TrimSpace(auth[7:])
+		if strings.HasPrefix(token, p.KeyPrefix+"_") {
+			return token
+		}
+	}
+
+	return ""
+}
+
+// Authenticate - Validate the key and set the request user, roles and permissions scope
+func (p *Plugin) Authenticate(c echo.Context, key string) error {
+	l := bolo.GetLogger(c)
+
+	record, err := FindOneByKey(p.App, key)
+	if err != nil {
+		if errors.Is(err, ErrInvalidAPIKey) || errors.Is(err, ErrExpiredAPIKey) {
+			return bolo.NewHTTPError(http.StatusUnauthorized, err.Error(), nil)
+		}
+
+		return err
+	}
+
+	user, err := p.LoadUser(p.App, record.UserID)
+	if err != nil || user == nil {
+		l.Info("apikeys.Authenticate key user not found", zap.String("prefix", record.Prefix), zap.Error(err))
+		return bolo.NewHTTPError(http.StatusUnauthorized, ErrInvalidAPIKey.Error(), nil)
+	}
+
+	if user.IsBlocked() || !user.IsActive() {
+		return bolo.NewHTTPError(http.StatusUnauthorized, ErrInvalidAPIKey.Error(), nil)
+	}
+
+	err = TouchLastUsed(p.App, record, c.RealIP(), p.TouchInterval)
+	if err != nil {
+		l.Warn("apikeys.Authenticate error on update last used", zap.Error(err))
+	}
+
+	bolo.SetAuthenticatedUser(c, user)
+	bolo.SetRoles(c, append([]string{"authenticated"}, user.GetRoles()...))
+	bolo.SetPermissionsScope(c, record.Permissions)
+	bolo.SetRateLimitIdentity(c, "api_key:"+record.Prefix)
+	SetAPIKey(c, record)
+
+	return nil
+}
+
+// GetAPIKey - Returns the API key used to authenticate the request or nil
+func GetAPIKey(c echo.Context) *APIKey {
+	key := c.Get("api_key")
+	if key == nil {
+		return nil
+	}
+
+	return key.(*APIKey)
+}
+
+func SetAPIKey(c echo.Context, key *APIKey) {
+	c.Set("api_key", key)
+}
@@ -1,6 +1,8 @@
 package bolo
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
@@ -8,17 +10,22 @@ import (
 	"net/http"
 	"os"
 	"path"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-bolo/bolo/acl"
 	"github.com/go-bolo/bolo/configuration"
 	"github.com/go-bolo/bolo/helpers"
+	"github.com/go-bolo/bolo/i18n"
+	"github.com/go-bolo/bolo/storage"
 	"github.com/go-bolo/clock"
 	"github.com/go-playground/validator/v10"
 	"github.com/microcosm-cc/bluemonday"
+	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/gookit/event"
 	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/sqlite"
@@ -49,6 +56,7 @@ type App interface {
 	SetDB(dbName string, db *gorm.DB) error
 	SetModel(name string, model Model) error
 	GetModel(name string) Model
+	GetModels() map[string]Model
 	// Run gorm migrate for each registered model
 	SyncDB() error
 
@@ -61,9 +69,42 @@ type App interface {
 	SetRouterGroup(name, path string) *echo.Group
 	GetRouterGroup(name string) *echo.Group
 	SetResource(r *Resource) error
+	GetResources() map[string]*Resource
 
 	BindRoute(routeName string, r *Route) echo.HandlerFunc
 	SetRoute(routeName string, route *Route) error
+	GetRoutes() map[string]*Route
+
+	// Sitemap:
+	AddSitemapSource(name string, source SitemapSource) error
+	GetSitemapSources() map[string]SitemapSource
+
+	// Background jobs:
+	SetJobType(t *JobType) error
+	GetJobTypes() map[string]*JobType
+	GetJobQueue() JobQueue
+	SetJobQueue(q JobQueue) error
+
+	// Scheduled tasks:
+	SetScheduledTask(t *ScheduledTask) error
+	GetScheduledTasks() map[string]*ScheduledTask
+	GetScheduler() Scheduler
+	SetScheduler(s Scheduler) error
+
+	// Domain events:
+	GetDomainEvents() *DomainEventBus
+
+	// Outgoing requests client:
+	GetHTTPClient() *HTTPClient
+	SetHTTPClient(c *HTTPClient) error
+
+	// File storage:
+	GetStorage() storage.Storage
+	SetStorage(s storage.Storage) error
+
+	// Health checks, used in the /health/ready route:
+	AddHealthCheck(name string, check HealthChecker) error
+	GetHealthChecks() map[string]HealthChecker
 
 	GetDefaultContentType() string
 	GetContentTypes() []string
@@ -72,6 +113,9 @@ type App interface {
 	SetResponseFormatter(accept string, rf responseFormatter) error
 
 	StartHTTPServer() error
+	// Shutdown - Graceful shutdown, the readiness check fails while the server finishes the running requests
+	Shutdown(ctx context.Context) error
+	IsShuttingDown() bool
 
 	// Theme / view methods
 	GetTheme() string
@@ -92,6 +136,25 @@ type App interface {
 	// HTML / Text sanitizer:
 	GetSanitizer() *bluemonday.Policy
 	SetSanitizer(policy *bluemonday.Policy) error
+	// Translations:
+	GetI18n() i18n.I18n
+	SetI18n(i i18n.I18n) error
+	LoadLocales() error
+	// SEO site-wide defaults:
+	GetSEODefaults() *SEO
+	SetSEODefaults(seo *SEO) error
+	// Rate limits:
+	GetRateLimiter() *RateLimiter
+	SetRateLimiter(rl *RateLimiter) error
+	// Metrics registry, used by plugins to register counters, gauges and histograms:
+	GetMetrics() *prometheus.Registry
+	SetMetrics(r *prometheus.Registry) error
+	// Tracing, one noop provider if tracing is disabled:
+	GetTracerProvider() trace.TracerProvider
+	SetTracerProvider(tp trace.TracerProvider) error
+	// Security headers, nil if disabled:
+	GetSecurityHeaders() *SecurityHeaders
+	SetSecurityHeaders(h *SecurityHeaders) error
 	// Start and close:
 	Bootstrap() error
 	Close() error
@@ -122,6 +185,7 @@ func NewApp(opts *DefaultAppOptions) App {
 	app := &DefaultApp{
 		Acl:                acl.NewAcl(&acl.NewAclOpts{Logger: logger}),
 		Clock:              clock.New(),
+		I18n:               i18n.NewI18n(&i18n.NewI18nOpts{DefaultLocale: cfg.GetF(DEFAULT_LOCALE, "en")}),
 		Options:            opts,
 		Plugins:            make(map[string]Plugin),
 		Events:             event.NewManager("app"),
@@ -129,20 +193,43 @@ func NewApp(opts *DefaultAppOptions) App {
 		Configuration:      cfg,
 		DefaultDB:          "default",
 		DBs:                make(map[string]*gorm.DB),
+		Metrics:            prometheus.NewRegistry(),
+		TracerProvider:     trace.NewNoopTracerProvider(),
 		Models:             make(map[string]Model),
 		Resources:          make(map[string]*Resource),
 		ResponseFormatters: make(map[string]responseFormatter),
+		SitemapSources:     make(map[string]SitemapSource),
+		HealthChecks:       make(map[string]HealthChecker),
+		JobTypes:           make(map[string]*JobType),
+		ScheduledTasks:     make(map[string]*ScheduledTask),
 		router:             echo.New(),
 		Routes:             make(map[string]*Route),
 		Theme:              cfg.GetF(THEME, "site"),
 		Layout:             "layouts/default",
 		templateFunctions:  make(template.FuncMap),
 	}
+	app.SEODefaults = NewSEOFromConfiguration(app)
+	app.DomainEvents = NewDomainEventBus(app)
+	app.HTTPClient = NewHTTPClientFromConfiguration(app)
+	if s, err := NewStorageFromConfiguration(app); err != nil {
+		logger.Warn("NewApp: storage disabled", zap.Error(err))
+	} else {
+		app.Storage = s
+	}
+	if !cfg.GetBool(SECURITY_HEADERS_DISABLE) {
+		app.SecurityHeaders = NewSecurityHeadersFromConfiguration(app)
+	}
+	app.AddSitemapSource("routes", SitemapSourceFunc(RoutesSitemapSource))
+	app.AddHealthCheck("templates", HealthCheckerFunc(TemplatesHealthCheck))
+	app.AddHealthCheck("tables", HealthCheckerFunc(TablesHealthCheck))
 	// Default police:
 	app.Sanitizer = bluemonday.UGCPolicy()
 	app.Sanitizer.AllowDataURIImages()
 
 	app.router.GET("/health", HealthCheckHandler)
+	app.router.GET("/health/live", HealthLiveHandler)
+	app.router.GET("/health/ready", HealthReadyHandler)
+	app.router.GET(storageLocalPath(app)+"/*", StorageHandler)
 	app.router.Pre(func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			SetDefaultValues(c, app)
@@ -178,11 +265,35 @@ type DefaultApp struct {
 	Routes             map[string]*Route
 	Resources          map[string]*Resource
 	ResponseFormatters map[string]responseFormatter `json:"-"`
+	SitemapSources     map[string]SitemapSource     `json:"-"`
+	HealthChecks       map[string]HealthChecker     `json:"-"`
+	JobTypes           map[string]*JobType          `json:"-"`
+	JobQueue           JobQueue                     `json:"-"`
+	ScheduledTasks     map[string]*ScheduledTask    `json:"-"`
+	Scheduler          Scheduler                    `json:"-"`
+	DomainEvents       *DomainEventBus              `json:"-"`
+	HTTPClient         *HTTPClient                  `json:"-"`
+	Storage            storage.Storage              `json:"-"`
 
 	routerGroups map[string]*echo.Group
 
 	Sanitizer *bluemonday.Policy
 
+	I18n i18n.I18n `json:"-"`
+
+	SEODefaults *SEO `json:"-"`
+
+	RateLimiter *RateLimiter `json:"-"`
+
+	SecurityHeaders *SecurityHeaders `json:"-"`
+
+	Metrics *prometheus.Registry `json:"-"`
+
+	TracerProvider trace.TracerProvider `json:"-"`
+
+	server       *http.Server
+	shuttingDown atomic.Bool
+
 	// default theme for HTML responses
 	Theme string
 	// default layout for HTML responses
@@ -231,15 +342,41 @@ func (app *DefaultApp) SetResource(r *Resource) error {
 	return nil
 }
 
+func (app *DefaultApp) GetResources() map[string]*Resource {
+	return app.Resources
+}
+
 func (app *DefaultApp) BindRoute(routeName string, r *Route) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		c.Set("route", r)
+		c.Set("route_name", routeName)
+
+		if r.Feed {
+			SetFeedAccept(app, c)
+		}
+
+		if r.RateLimit != nil {
+			err := CheckRateLimit(c, "route:"+routeName, r.RateLimit)
+			if err != nil {
+				return err
+			}
+		}
 
 		res, err := r.Action(c)
 
 		if err != nil {
 			return err
 		}
+		// the action already wrote the response, like in redirects
+		if res == nil {
+			return nil
+		}
+
+		if r.Feed {
+			if rf := GetFeedFormatter(GetAccept(c)); rf != nil {
+				return rf(app, c, r, res)
+			}
+		}
 
 		return app.GetResponseFormatter(GetAccept(c))(app, c, r, res)
 	}
@@ -276,14 +413,154 @@ func (app *DefaultApp) SetRoute(routeName string, route *Route) error {
 	return nil
 }
 
+func (app *DefaultApp) GetRoutes() map[string]*Route {
+	return app.Routes
+}
+
+func (app *DefaultApp) AddSitemapSource(name string, source SitemapSource) error {
+	if name == "" {
+		return fmt.Errorf("AddSitemapSource: name is required")
+	}
+
+	app.SitemapSources[name] = source
+	return nil
+}
+
+func (app *DefaultApp) GetSitemapSources() map[string]SitemapSource {
+	return app.SitemapSources
+}
+
+func (app *DefaultApp) AddHealthCheck(name string, check HealthChecker) error {
+	if name == "" {
+		return fmt.Errorf("AddHealthCheck: name is required")
+	}
+
+	app.HealthChecks[name] = check
+	return nil
+}
+
+func (app *DefaultApp) GetHealthChecks() map[string]HealthChecker {
+	return app.HealthChecks
+}
+
+func (app *DefaultApp) SetJobType(t *JobType) error {
+	if t.Name == "" {
+		return fmt.Errorf("SetJobType: name is required")
+	}
+
+	if t.Handler == nil {
+		return fmt.Errorf("SetJobType: handler is required: %s", t.Name)
+	}
+
+	if t.Queue == "" {
+		t.Queue = DefaultJobQueue
+	}
+
+	app.JobTypes[t.Name] = t
+	return nil
+}
+
+func (app *DefaultApp) GetJobTypes() map[string]*JobType {
+	return app.JobTypes
+}
+
+func (app *DefaultApp) GetJobQueue() JobQueue {
+	return app.JobQueue
+}
+
+func (app *DefaultApp) SetJobQueue(q JobQueue) error {
+	app.JobQueue = q
+	return nil
+}
+
+func (app *DefaultApp) SetScheduledTask(t *ScheduledTask) error {
+	if err := validateScheduledTask(t); err != nil {
+		return err
+	}
+
+	app.ScheduledTasks[t.Name] = t
+	return nil
+}
+
+func (app *DefaultApp) GetScheduledTasks() map[string]*ScheduledTask {
+	return app.ScheduledTasks
+}
+
+func (app *DefaultApp) GetScheduler() Scheduler {
+	return app.Scheduler
+}
+
+func (app *DefaultApp) SetScheduler(s Scheduler) error {
+	app.Scheduler = s
+	return nil
+}
+
+func (app *DefaultApp) GetDomainEvents() *DomainEventBus {
+	return app.DomainEvents
+}
+
+func (app *DefaultApp) GetHTTPClient() *HTTPClient {
+	return app.HTTPClient
+}
+
+func (app *DefaultApp) SetHTTPClient(c *HTTPClient) error {
+	app.HTTPClient = c
+	return nil
+}
+
+func (app *DefaultApp) GetStorage() storage.Storage {
+	return app.Storage
+}
+
+func (app *DefaultApp) SetStorage(s storage.Storage) error {
+	app.Storage = s
+	return nil
+}
+
 func (app *DefaultApp) StartHTTPServer() error {
 	port := app.Configuration.Get(PORT)
 	if port == "" {
 		port = "8080"
 	}
 
+	app.server = &http.Server{Addr: ":" + port, Handler: app.GetRouter()}
+
 	app.GetLogger().Info("Server listening on port " + port)
-	return http.ListenAndServe(":"+port, app.GetRouter())
+	err := app.server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// Shutdown - Mark the app as shutting down, wait the HEALTH_SHUTDOWN_DELAY for the load balancers
+// to stop sending requests, stop the HTTP server and close the app
+func (app *DefaultApp) Shutdown(ctx context.Context) error {
+	app.shuttingDown.Store(true)
+
+	delay := time.Duration(app.Configuration.GetInt64F(HEALTH_SHUTDOWN_DELAY, 0)) * time.Millisecond
+	if delay > 0 {
+		app.GetLogger().Info("Shutdown: waiting the readiness delay", zap.Duration("delay", delay))
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+	}
+
+	if app.server != nil {
+		err := app.server.Shutdown(ctx)
+		if err != nil {
+			return fmt.Errorf("Shutdown: error on stop http server: %w", err)
+		}
+	}
+
+	return app.Close()
+}
+
+func (app *DefaultApp) IsShuttingDown() bool {
+	return app.shuttingDown.Load()
 }
 
 func (app *DefaultApp) GetTheme() string {
@@ -379,6 +656,60 @@ func (app *DefaultApp) SetSanitizer(sanitizer *bluemonday.Policy) error {
 	return nil
 }
 
+func (app *DefaultApp) GetI18n() i18n.I18n {
+	return app.I18n
+}
+
+func (app *DefaultApp) SetI18n(i i18n.I18n) error {
+	app.I18n = i
+	return nil
+}
+
+func (app *DefaultApp) GetSEODefaults() *SEO {
+	return app.SEODefaults
+}
+
+func (app *DefaultApp) SetSEODefaults(seo *SEO) error {
+	app.SEODefaults = seo
+	return nil
+}
+
+func (app *DefaultApp) GetRateLimiter() *RateLimiter {
+	return app.RateLimiter
+}
+
+func (app *DefaultApp) SetRateLimiter(rl *RateLimiter) error {
+	app.RateLimiter = rl
+	return nil
+}
+
+func (app *DefaultApp) GetMetrics() *prometheus.Registry {
+	return app.Metrics
+}
+
+func (app *DefaultApp) SetMetrics(r *prometheus.Registry) error {
+	app.Metrics = r
+	return nil
+}
+
+func (app *DefaultApp) GetTracerProvider() trace.TracerProvider {
+	return app.TracerProvider
+}
+
+func (app *DefaultApp) SetTracerProvider(tp trace.TracerProvider) error {
+	app.TracerProvider = tp
+	return nil
+}
+
+func (app *DefaultApp) GetSecurityHeaders() *SecurityHeaders {
+	return app.SecurityHeaders
+}
+
+func (app *DefaultApp) SetSecurityHeaders(h *SecurityHeaders) error {
+	app.SecurityHeaders = h
+	return nil
+}
+
 func (app *DefaultApp) AddPlugin(p Plugin) error {
 	app.Plugins[p.GetName()] = p
 	return nil
@@ -410,8 +741,28 @@ func (app *DefaultApp) GetDBByName(dbName string) *gorm.DB {
 	return app.DBs[dbName]
 }
 
+// SetDB - Set one named connection with the metrics, tracing and domain events callbacks
 func (app *DefaultApp) SetDB(dbName string, db *gorm.DB) error {
+	// connections set again, like with other name, already have the callbacks
+	if db.Callback().Create().Get("bolo:metrics_before_create") == nil {
+		err := InstrumentDB(app, dbName, db)
+		if err != nil {
+			return fmt.Errorf("SetDB error on add metrics: %w", err)
+		}
+
+		err = TraceDB(app, dbName, db)
+		if err != nil {
+			return fmt.Errorf("SetDB error on add tracing: %w", err)
+		}
+
+		err = EmitDomainEvents(app, db)
+		if err != nil {
+			return fmt.Errorf("SetDB error on add domain events: %w", err)
+		}
+	}
+
 	app.DBs[dbName] = db
+	app.AddHealthCheck("db:"+dbName, DBHealthCheck(dbName))
 	return nil
 }
 
@@ -481,7 +832,7 @@ func (app *DefaultApp) InitDatabase(name string, engine string, isDefault bool)
 		return fmt.Errorf("InitDatabase error on database connectio: %w", err)
 	}
 
-	return app.SetDB(app.DefaultDB, db)
+	return app.SetDB(name, db)
 }
 
 func (app *DefaultApp) SetModel(name string, m Model) error {
@@ -493,6 +844,10 @@ func (app *DefaultApp) GetModel(name string) Model {
 	return app.Models[name]
 }
 
+func (app *DefaultApp) GetModels() map[string]Model {
+	return app.Models
+}
+
 func (app *DefaultApp) Bootstrap() error {
 	var err error
 
@@ -504,6 +859,11 @@ func (app *DefaultApp) Bootstrap() error {
 		return fmt.Errorf("DefaultApp.Bootstrap: Error loading roles: %w", err)
 	}
 
+	err = InitTimezone(app)
+	if err != nil {
+		return fmt.Errorf("DefaultApp.Bootstrap: %w", err)
+	}
+
 	for _, p := range app.Plugins {
 		err = p.Init(app)
 		if err != nil {
@@ -513,6 +873,22 @@ func (app *DefaultApp) Bootstrap() error {
 
 	app.Events.MustTrigger("configuration", event.M{"app": app})
 
+	err = InitTracing(app)
+	if err != nil {
+		return fmt.Errorf("DefaultApp.Bootstrap: Error on init tracing: %w", err)
+	}
+
+	// the next events run in one span, with the listeners spans as children
+	ctx, span := Tracer(app).Start(context.Background(), "bolo.Bootstrap")
+	defer span.End()
+
+	fire := func(name string) error {
+		if err := FireEvent(app, ctx, name, event.M{"app": app}); err != nil {
+			return fmt.Errorf("DefaultApp.Bootstrap: Error on %s event: %w", name, err)
+		}
+		return nil
+	}
+
 	err = app.InitDatabase(app.DefaultDB, configuration.GetEnv("DB_ENGINE", "sqlite"), true)
 	if err != nil {
 		return fmt.Errorf("DefaultApp.Bootstrap: Error on init database connection: %w", err)
@@ -524,16 +900,32 @@ func (app *DefaultApp) Bootstrap() error {
 	}
 
 	HttpClientInit()
+	InstrumentHttpClient(app)
+	TraceHttpClient(app)
 
-	app.Events.MustTrigger("bindMiddlewares", event.M{"app": app})
-	app.Events.MustTrigger("bindRoutes", event.M{"app": app})
-	app.Events.MustTrigger("setResponseFormats", event.M{"app": app})
+	err = InitRateLimiter(app)
+	if err != nil {
+		return fmt.Errorf("DefaultApp.Bootstrap: Error on init rate limiter: %w", err)
+	}
+
+	for _, name := range []string{"bindMiddlewares", "bindRoutes", "setResponseFormats"} {
+		if err := fire(name); err != nil {
+			return err
+		}
+	}
 
 	err = SetCoreTemplateFunctions(app)
 	if err != nil {
 		return err
 	}
-	app.Events.MustTrigger("setTemplateFunctions", event.M{"app": app})
+	if err := fire("setTemplateFunctions"); err != nil {
+		return err
+	}
+
+	err = app.LoadLocales()
+	if err != nil {
+		return fmt.Errorf("DefaultApp.Bootstrap Error on LoadLocales: %w", err)
+	}
 
 	err = app.LoadTemplates()
 	if err != nil {
@@ -575,17 +967,20 @@ func (app *DefaultApp) Bootstrap() error {
 		}
 	}
 
-	app.Events.MustTrigger("bootstrap", event.M{"app": app})
-
-	return nil
+	return fire("bootstrap")
 }
 
 func (app *DefaultApp) Close() error {
-	err, _ := app.Events.Fire("close", event.M{"app": app})
-	if err != nil {
-		app.GetLogger().Debug("Close error", zap.Error(err))
-		return err
+	app.shuttingDown.Store(true)
+
+	// the domain event workers are stopped even if one close listener fails
+	closeErr := FireEvent(app, context.Background(), "close", event.M{"app": app})
+	if closeErr != nil {
+		app.GetLogger().Debug("Close error", zap.Error(closeErr))
 	}
 
-	return nil
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(app.Configuration.GetInt64F(DOMAIN_EVENTS_STOP_TIMEOUT, 30))*time.Second)
+	defer cancel()
+
+	return errors.Join(closeErr, app.DomainEvents.Stop(ctx))
 }
@@ -0,0 +1,424 @@
+// Package cassette records real HTTP interactions in testdata files and replays them in tests,
+// with redaction of secrets in headers, query params and JSON bodies
+package cassette
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// ModeReplay - Only replay the recorded interactions, unknown requests fail
+	ModeReplay = "replay"
+	// ModeRecord - Send all requests and record them, replacing the cassette file
+	ModeRecord = "record"
+	// ModeAuto - Replay if the cassette file exists, record otherwise
+	ModeAuto = "auto"
+
+	// ModeEnv - Environment variable to force the mode in all tests, like: HTTP_CASSETTE_MODE=record go test ./...
+	ModeEnv = "HTTP_CASSETTE_MODE"
+
+	Redacted = "[REDACTED]"
+)
+
+var (
+	ErrInteractionNotFound = errors.New("cassette interaction not found")
+	ErrInvalidMode         = errors.New("invalid cassette mode")
+
+	// DefaultRedactHeaders - Headers always redacted
+	DefaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+)
+
+// Doer - Client used to send the requests in record mode
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Request - Recorded request
+type Request struct {
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"bodyEncoding,omitempty"`
+}
+
+// Response - Recorded response
+type Response struct {
+	StatusCode   int         `json:"statusCode"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"bodyEncoding,omitempty"`
+}
+
+// Interaction - One request and its response
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Cassette - Cassette file content
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// MatcherFunc - Check if one recorded request matches the current request, both already redacted
+type MatcherFunc func(r *Request, recorded *Request) bool
+
+// DefaultMatcher - Match the method and URL
+func DefaultMatcher(r *Request, recorded *Request) bool {
+	return r.Method == recorded.Method && r.URL == recorded.URL
+}
+
+// BodyMatcher - Match the method, URL and body
+func BodyMatcher(r *Request, recorded *Request) bool {
+	return DefaultMatcher(r, recorded) && r.Body == recorded.Body
+}
+
+// Recorder - CustomHTTPClient that records or replays the cassette in Path.
+// Replayed interactions are used in order, each one only once
+type Recorder struct {
+	Path string
+	Mode string
+	// Real client used in record mode, default http.DefaultClient
+	Client  Doer
+	Matcher MatcherFunc
+	// Redacted headers, with DefaultRedactHeaders
+	RedactHeaders []string
+	// Redacted URL query params, like: api_key
+	RedactQueryParams []string
+	// Redacted fields in JSON bodies at any depth, like: access_token
+	RedactJSONFields []string
+	// Custom redaction, ran after the other redactions when recording
+	Filter func(i *Interaction)
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// New - Create one recorder and load the cassette in replay mode
+func New(path, mode string) (*Recorder, error) {
+	if mode == ModeAuto {
+		mode = ModeRecord
+		if _, err := os.Stat(path); err == nil {
+			mode = ModeReplay
+		}
+	}
+
+	r := Recorder{Path: path, Mode: mode, cassette: &Cassette{}}
+
+	switch mode {
+	case ModeRecord:
+	case ModeReplay:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("cassette.New: %w", err)
+		}
+
+		if err := json.Unmarshal(data, r.cassette); err != nil {
+			return nil, fmt.Errorf("cassette.New: invalid cassette %s: %w", path, err)
+		}
+		r.used = make([]bool, len(r.cassette.Interactions))
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMode, mode)
+	}
+
+	return &r, nil
+}
+
+// TB - Subset of testing.TB used by Use
+type TB interface {
+	Helper()
+	Cleanup(f func())
+	Fatalf(format string, args ...any)
+}
+
+// Use - Recorder for one test with the cassette testdata/cassettes/<name>.json, saved in the test cleanup.
+// The mode is ModeAuto or the HTTP_CASSETTE_MODE value
+func Use(t TB, name string, opts ...func(r *Recorder)) *Recorder {
+	t.Helper()
+
+	mode := os.Getenv(ModeEnv)
+	if mode == "" {
+		mode = ModeAuto
+	}
+
+	r, err := New(filepath.Join("testdata", "cassettes", name+".json"), mode)
+	if err != nil {
+		t.Fatalf("cassette.Use: %s", err)
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	t.Cleanup(func() {
+		if err := r.Save(); err != nil {
+			t.Fatalf("cassette.Use: %s", err)
+		}
+	})
+
+	return r
+}
+
+// Interactions - Returns the recorded or loaded interactions
+func (r *Recorder) Interactions() []*Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*Interaction{}, r.cassette.Interactions...)
+}
+
+// Do - Replay or record one request
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	recordedReq := Request{Method: req.Method, URL: req.URL.String(), Header: req.Header.Clone()}
+	recordedReq.Body, recordedReq.BodyEncoding = encodeBody(reqBody)
+	r.redactRequest(&recordedReq)
+
+	if r.Mode == ModeReplay {
+		return r.replay(req, &recordedReq)
+	}
+
+	return r.record(req, &recordedReq)
+}
+
+func (r *Recorder) replay(req *http.Request, recordedReq *Request) (*http.Response, error) {
+	matcher := r.Matcher
+	if matcher == nil {
+		matcher = DefaultMatcher
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] || !matcher(recordedReq, &interaction.Request) {
+			continue
+		}
+
+		r.used[i] = true
+
+		body, err := decodeBody(interaction.Response.Body, interaction.Response.BodyEncoding)
+		if err != nil {
+			return nil, err
+		}
+
+		status := interaction.Response.StatusCode
+		return &http.Response{
+			Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+			StatusCode:    status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        interaction.Response.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s %s in %s", ErrInteractionNotFound, recordedReq.Method, recordedReq.URL, r.Path)
+}
+
+func (r *Recorder) record(req *http.Request, recordedReq *Request) (*http.Response, error) {
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	interaction := Interaction{
+		Request:  *recordedReq,
+		Response: Response{StatusCode: res.StatusCode, Header: res.Header.Clone()},
+	}
+	interaction.Response.Body, interaction.Response.BodyEncoding = encodeBody(body)
+	r.redactResponse(&interaction.Response)
+
+	if r.Filter != nil {
+		r.Filter(&interaction)
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, &interaction)
+	r.mu.Unlock()
+
+	return res, nil
+}
+
+// Save - Write the cassette file in record mode, replay mode is a noop
+func (r *Recorder) Save() error {
+	if r.Mode != ModeRecord {
+		return nil
+	}
+
+	var data bytes.Buffer
+	enc := json.NewEncoder(&data)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+
+	r.mu.Lock()
+	err := enc.Encode(r.cassette)
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("cassette.Save: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.Path), 0o755); err != nil {
+		return fmt.Errorf("cassette.Save: %w", err)
+	}
+
+	if err := os.WriteFile(r.Path, data.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("cassette.Save: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Recorder) redactRequest(req *Request) {
+	r.redactHeader(req.Header)
+
+	if len(r.RedactQueryParams) > 0 {
+		if u, err := url.Parse(req.URL); err == nil {
+			query := u.Query()
+			for _, name := range r.RedactQueryParams {
+				if query.Has(name) {
+					query.Set(name, Redacted)
+				}
+			}
+			u.RawQuery = query.Encode()
+			req.URL = u.String()
+		}
+	}
+
+	req.Body = r.redactJSON(req.Body, req.BodyEncoding)
+}
+
+func (r *Recorder) redactResponse(res *Response) {
+	r.redactHeader(res.Header)
+	res.Body = r.redactJSON(res.Body, res.BodyEncoding)
+}
+
+func (r *Recorder) redactHeader(header http.Header) {
+	for _, name := range append(DefaultRedactHeaders, r.RedactHeaders...) {
+		if values := header.Values(name); len(values) > 0 {
+			header.Set(name, Redacted)
+		}
+	}
+}
+
+func (r *Recorder) redactJSON(body, encoding string) string {
+	if len(r.RedactJSONFields) == 0 || encoding != "" || body == "" {
+		return body
+	}
+
+	var data interface{}
+	if err := json.Unmarshal([]byte(body), &data); err != nil {
+		return body
+	}
+
+	if !redactFields(data, r.RedactJSONFields) {
+		return body
+	}
+
+	redacted, err := json.Marshal(data)
+	if err != nil {
+		return body
+	}
+
+	return string(redacted)
+}
+
+func redactFields(data interface{}, fields []string) bool {
+	changed := false
+
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if containsFold(fields, key) {
+				v[key] = Redacted
+				changed = true
+				continue
+			}
+
+			changed = redactFields(value, fields) || changed
+		}
+	case []interface{}:
+		for _, value := range v {
+			changed = redactFields(value, fields) || changed
+		}
+	}
+
+	return changed
+}
+
+func containsFold(values []string, v string) bool {
+	for _, item := range values {
+		if strings.EqualFold(item, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readBody - Read the request body and restore it to be sent in record mode
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
+
+// encodeBody - Text bodies are stored as is and binary bodies in base64
+func encodeBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+func decodeBody(body, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(body)
+	}
+
+	return []byte(body), nil
+}
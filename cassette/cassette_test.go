@@ -0,0 +1,122 @@
+package cassette_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-bolo/bolo/cassette"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"secret-token","echo":` + string(body) + `}`))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassettes", "token.json")
+
+	send := func(r *cassette.Recorder) (*http.Response, string) {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/token?client_secret=s1&grant=code", bytes.NewBufferString(`{"code":"c1"}`))
+		require.Nil(t, err)
+		req.Header.Set("Authorization", "Bearer secret")
+
+		res, err := r.Do(req)
+		require.Nil(t, err)
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		require.Nil(t, err)
+		return res, string(body)
+	}
+
+	configure := func(r *cassette.Recorder) {
+		r.Client = server.Client()
+		r.Matcher = cassette.BodyMatcher
+		r.RedactQueryParams = []string{"client_secret"}
+		r.RedactJSONFields = []string{"access_token"}
+	}
+
+	t.Run("should record the interactions with the secrets redacted", func(t *testing.T) {
+		r, err := cassette.New(path, cassette.ModeAuto)
+		require.Nil(t, err)
+		assert.Equal(t, cassette.ModeRecord, r.Mode)
+		configure(r)
+
+		res, body := send(r)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, `{"access_token":"secret-token","echo":{"code":"c1"}}`, body)
+		require.Nil(t, r.Save())
+
+		data, err := os.ReadFile(path)
+		require.Nil(t, err)
+		assert.NotContains(t, string(data), "secret-token")
+		assert.NotContains(t, string(data), "Bearer secret")
+		assert.NotContains(t, string(data), "session=secret")
+		assert.NotContains(t, string(data), "client_secret=s1")
+
+		c := cassette.Cassette{}
+		require.Nil(t, json.Unmarshal(data, &c))
+		require.Len(t, c.Interactions, 1)
+		i := c.Interactions[0]
+		assert.Equal(t, server.URL+"/token?client_secret=%5BREDACTED%5D&grant=code", i.Request.URL)
+		assert.Equal(t, cassette.Redacted, i.Request.Header.Get("Authorization"))
+		assert.Equal(t, cassette.Redacted, i.Response.Header.Get("Set-Cookie"))
+		assert.True(t, strings.Contains(i.Response.Body, `"access_token":"[REDACTED]"`))
+	})
+
+	t.Run("should replay the recorded interactions without requests", func(t *testing.T) {
+		r, err := cassette.New(path, cassette.ModeAuto)
+		require.Nil(t, err)
+		assert.Equal(t, cassette.ModeReplay, r.Mode)
+		configure(r)
+
+		res, body := send(r)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+		assert.Equal(t, `{"access_token":"[REDACTED]","echo":{"code":"c1"}}`, body)
+		assert.Equal(t, 1, calls)
+
+		// each interaction is replayed once:
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/token?client_secret=s1&grant=code", bytes.NewBufferString(`{"code":"c1"}`))
+		_, err = r.Do(req)
+		assert.ErrorIs(t, err, cassette.ErrInteractionNotFound)
+	})
+}
+
+func TestUse(t *testing.T) {
+	r := cassette.Use(t, "example", func(r *cassette.Recorder) {
+		r.RedactQueryParams = []string{"api_key"}
+	})
+	assert.Equal(t, cassette.ModeReplay, r.Mode)
+
+	req, err := http.NewRequest(http.MethodGet, "https://api.example.test/v1/items?api_key=k1", nil)
+	require.Nil(t, err)
+	req.Header.Set("Authorization", "Bearer k2")
+
+	res, err := r.Do(req)
+	require.Nil(t, err)
+	defer res.Body.Close()
+
+	data := struct {
+		Items []struct {
+			Name string `json:"name"`
+		} `json:"items"`
+	}{}
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&data))
+	require.Len(t, data.Items, 1)
+	assert.Equal(t, "first", data.Items[0].Name)
+}
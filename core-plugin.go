@@ -16,6 +16,25 @@ func (p *CorePlugin) Init(app App) error {
 	app.GetEvents().On("bindMiddlewares", event.ListenerFunc(func(e event.Event) error {
 		return p.BindMiddlewares(app)
 	}), event.High)
+	// the global rate limit runs after the authentication middlewares to group requests by user or API key
+	app.GetEvents().On("bindMiddlewares", event.ListenerFunc(func(e event.Event) error {
+		app.GetRouter().Use(RateLimitMiddleware(app))
+		return nil
+	}), event.Min)
+
+	app.GetEvents().On("bindRoutes", event.ListenerFunc(func(e event.Event) error {
+		err := BindSecurityRoutes(app)
+		if err != nil {
+			return err
+		}
+
+		err = BindMetricsRoutes(app)
+		if err != nil {
+			return err
+		}
+
+		return BindSitemapRoutes(app)
+	}), event.Normal)
 
 	return nil
 }
@@ -0,0 +1,134 @@
+package bolo
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"html/template"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+var (
+	CSRFCookieName = "_csrf"
+	CSRFHeaderName = "X-CSRF-Token"
+	CSRFFormField  = "_csrf"
+)
+
+// CSRFMiddleware - Double submit cookie CSRF protection.
+// State changing requests that can carry ambient credentials (cookies or browser forms) must send the token
+// from the _csrf cookie in the X-CSRF-Token header or in the _csrf form field.
+// Requests authenticated with the Authorization or X-API-Key headers are skipped, browsers don't send these automatically
+func CSRFMiddleware(app App) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if cookie, err := c.Cookie(CSRFCookieName); err == nil && cookie.Value != "" {
+				c.Set("csrf_token", cookie.Value)
+			}
+
+			if !csrfRequired(c.Request()) {
+				return next(c)
+			}
+
+			if ValidCSRFToken(c) || isCSRFExemptRoute(app, c) {
+				return next(c)
+			}
+
+			GetLogger(c).Info("CSRFMiddleware invalid csrf token", zap.String("path", c.Path()), zap.String("method", c.Request().Method))
+
+			return &HTTPError{Code: http.StatusForbidden, Message: "Invalid CSRF token"}
+		}
+	}
+}
+
+// GetCSRFToken - Returns the request CSRF token, the token and cookie are created on the first use
+func GetCSRFToken(c echo.Context) string {
+	if token, ok := c.Get("csrf_token").(string); ok && token != "" {
+		return token
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	c.Set("csrf_token", token)
+	// not HttpOnly: JavaScript clients need to read the cookie to send the header
+	c.SetCookie(&http.Cookie{
+		Name:     CSRFCookieName,
+		Value:    token,
+		Path:     "/",
+		Secure:   c.Scheme() == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return token
+}
+
+// ValidCSRFToken - Check the token sent in the header or form field against the cookie token
+func ValidCSRFToken(c echo.Context) bool {
+	expected, _ := c.Get("csrf_token").(string)
+	if expected == "" {
+		return false
+	}
+
+	sent := c.Request().Header.Get(CSRFHeaderName)
+	if sent == "" {
+		sent = c.FormValue(CSRFFormField)
+	}
+
+	return sent != "" && subtle.ConstantTimeCompare([]byte(sent), []byte(expected)) == 1
+}
+
+// CSRFField - Template function with the hidden form input
+func CSRFField(c echo.Context) template.HTML {
+	return template.HTML(`<input type="hidden" name="` + CSRFFormField + `" value="` + template.HTMLEscapeString(GetCSRFToken(c)) + `">`)
+}
+
+func csrfRequired(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+
+	if req.Header.Get(echo.HeaderAuthorization) != "" || req.Header.Get("X-API-Key") != "" {
+		return false
+	}
+
+	if len(req.Cookies()) > 0 {
+		return true
+	}
+
+	// without cookies only the content types that browsers can send cross site in forms need the token
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get(echo.HeaderContentType))
+	switch mediaType {
+	case "", echo.MIMEApplicationForm, echo.MIMEMultipartForm, echo.MIMETextPlain:
+		return true
+	}
+
+	return false
+}
+
+// isCSRFExemptRoute - Routes with SkipCSRF, like webhooks receivers
+func isCSRFExemptRoute(app App, c echo.Context) bool {
+	method := c.Request().Method
+	path := c.Path()
+
+	// browsers send the CSP violation reports without the CSRF token
+	if s := app.GetSecurityHeaders(); s.cspReportEnabled() && method == http.MethodPost && path == s.CSPReportPath {
+		return true
+	}
+
+	for _, r := range app.GetRoutes() {
+		if r.SkipCSRF && r.Path == path && strings.EqualFold(r.Method, method) {
+			return true
+		}
+	}
+
+	return false
+}
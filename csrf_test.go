@@ -0,0 +1,101 @@
+package bolo_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	bolo "github.com/go-bolo/bolo"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSRFMiddleware(t *testing.T) {
+	app := GetTestApp()
+	action := func(c echo.Context) (bolo.Response, error) {
+		return &bolo.DefaultResponse{Data: map[string]string{"ok": "true"}}, nil
+	}
+
+	app.SetRoute("csrf_form", &bolo.Route{Method: http.MethodGet, Path: "/csrf-form", Action: func(c echo.Context) (bolo.Response, error) {
+		return &bolo.DefaultResponse{Data: map[string]string{"field": string(bolo.CSRFField(c))}}, nil
+	}})
+	app.SetRoute("csrf_save", &bolo.Route{Method: http.MethodPost, Path: "/csrf-save", Action: action})
+	app.SetRoute("csrf_hook", &bolo.Route{Method: http.MethodPost, Path: "/csrf-hook", Action: action, SkipCSRF: true})
+
+	err := app.Bootstrap()
+	assert.Nil(t, err)
+
+	request := func(method, path, contentType, body string, headers map[string]string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+		if contentType != "" {
+			req.Header.Set(echo.HeaderContentType, contentType)
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	session := &http.Cookie{Name: "session", Value: "1"}
+
+	t.Run("should create the token cookie with the form field", func(t *testing.T) {
+		rec := request(http.MethodGet, "/csrf-form", "", "", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var token string
+		for _, c := range rec.Result().Cookies() {
+			if c.Name == bolo.CSRFCookieName {
+				token = c.Value
+			}
+		}
+		assert.NotEmpty(t, token)
+		assert.Contains(t, rec.Body.String(), `value=\"`+token+`\"`)
+	})
+
+	t.Run("should reject cookie authenticated requests without token", func(t *testing.T) {
+		rec := request(http.MethodPost, "/csrf-save", echo.MIMEApplicationJSON, "{}", nil, session)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Invalid CSRF token")
+	})
+
+	t.Run("should reject form posts without cookies", func(t *testing.T) {
+		rec := request(http.MethodPost, "/csrf-save", echo.MIMEApplicationForm, "a=1", nil)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("should accept the token in the header or form field", func(t *testing.T) {
+		csrf := &http.Cookie{Name: bolo.CSRFCookieName, Value: "token-1"}
+
+		rec := request(http.MethodPost, "/csrf-save", echo.MIMEApplicationJSON, "{}", map[string]string{bolo.CSRFHeaderName: "token-1"}, session, csrf)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		form := url.Values{bolo.CSRFFormField: {"token-1"}}
+		rec = request(http.MethodPost, "/csrf-save", echo.MIMEApplicationForm, form.Encode(), nil, session, csrf)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = request(http.MethodPost, "/csrf-save", echo.MIMEApplicationJSON, "{}", map[string]string{bolo.CSRFHeaderName: "token-2"}, session, csrf)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("should skip token authenticated and cookieless JSON requests", func(t *testing.T) {
+		rec := request(http.MethodPost, "/csrf-save", echo.MIMEApplicationJSON, "{}", map[string]string{"Authorization": "Bearer abc"}, session)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = request(http.MethodPost, "/csrf-save", echo.MIMEApplicationJSON, "{}", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("should skip routes with SkipCSRF", func(t *testing.T) {
+		rec := request(http.MethodPost, "/csrf-hook", echo.MIMEApplicationJSON, "{}", nil, session)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
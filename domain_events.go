@@ -0,0 +1,467 @@
+package bolo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	EventModelCreated = "model.created"
+	EventModelUpdated = "model.updated"
+	EventModelDeleted = "model.deleted"
+)
+
+var (
+	ErrDomainEventBusClosed = errors.New("domain event bus closed")
+)
+
+// DomainEvent - One typed application event, like one resource record change.
+// Unlike the app.Events lifecycle hooks, domain events can be stored in the outbox and delivered later
+type DomainEvent struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Resource name in model events
+	Model    string `json:"model,omitempty"`
+	RecordID string `json:"recordId,omitempty"`
+	// JSON encoded event data, the record in model events
+	Data       json.RawMessage `json:"data,omitempty"`
+	RequestID  string          `json:"requestId,omitempty"`
+	OccurredAt time.Time       `json:"occurredAt"`
+}
+
+// NewDomainEvent - Build one event with the data encoded as JSON
+func NewDomainEvent(app App, name string, data interface{}) (*DomainEvent, error) {
+	e := DomainEvent{
+		ID:         uuid.New().String(),
+		Name:       name,
+		OccurredAt: app.GetClock().Now(),
+	}
+
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("NewDomainEvent error on encode %s data: %w", name, err)
+		}
+
+		e.Data = raw
+	}
+
+	return &e, nil
+}
+
+// Decode - Decode the event data in v, like one record in model events
+func (e *DomainEvent) Decode(v interface{}) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+// DomainEventHandler - Handle one domain event
+type DomainEventHandler func(ctx context.Context, app App, e *DomainEvent) error
+
+// DomainEventOutbox - Persistent storage of events, set by the outbox plugin
+type DomainEventOutbox interface {
+	// Add - Store one event, runs in the same transaction of the record change
+	Add(tx *gorm.DB, e *DomainEvent) error
+	// Notify - Called after the commit of stored events, to deliver them without wait the next poll
+	Notify()
+}
+
+type domainEventListener struct {
+	pattern string
+	handler DomainEventHandler
+	async   bool
+}
+
+// DomainEventBus - Deliver domain events to the subscribed listeners.
+// Sync listeners run after the transaction commit in the caller goroutine.
+// Async listeners run in one worker pool or, if one outbox is set, from the outbox relay with retries
+type DomainEventBus struct {
+	App App
+	// Async workers, default DOMAIN_EVENTS_WORKERS or 4
+	Workers int
+	// Async queue size, events are dropped if the queue is full. Default DOMAIN_EVENTS_QUEUE_SIZE or 1000
+	QueueSize int
+
+	mu        sync.RWMutex
+	listeners []*domainEventListener
+	outbox    DomainEventOutbox
+	queue     chan *domainEventDelivery
+	wg        sync.WaitGroup
+	started   bool
+	closed    bool
+}
+
+type domainEventDelivery struct {
+	ctx   context.Context
+	event *DomainEvent
+}
+
+func NewDomainEventBus(app App) *DomainEventBus {
+	return &DomainEventBus{App: app}
+}
+
+// Subscribe - Run the handler after each event with one name that matches the pattern.
+// Patterns are one event name, one prefix like model.* or * for all events
+func (b *DomainEventBus) Subscribe(pattern string, handler DomainEventHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.listeners = append(b.listeners, &domainEventListener{pattern: pattern, handler: handler})
+}
+
+// SubscribeAsync - Run the handler outside the request, in the worker pool or from the outbox.
+// With the outbox the delivery is at least once and the handlers should be idempotent
+func (b *DomainEventBus) SubscribeAsync(pattern string, handler DomainEventHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.listeners = append(b.listeners, &domainEventListener{pattern: pattern, handler: handler, async: true})
+}
+
+func (b *DomainEventBus) SetOutbox(outbox DomainEventOutbox) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.outbox = outbox
+}
+
+func (b *DomainEventBus) GetOutbox() DomainEventOutbox {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.outbox
+}
+
+// Publish - Store the event in the outbox, if set, and deliver it.
+// Use PublishTx to store the event in the same transaction of one change
+func (b *DomainEventBus) Publish(ctx context.Context, e *DomainEvent) error {
+	return b.PublishTx(b.App.GetDB().WithContext(ctx), e)
+}
+
+// PublishTx - Store the event in the outbox with one transaction and deliver it.
+// The listeners don't wait the tx commit, model events use gorm callbacks to deliver after the commit
+func (b *DomainEventBus) PublishTx(tx *gorm.DB, e *DomainEvent) error {
+	if e.RequestID == "" {
+		e.RequestID = RequestIDFromContext(tx.Statement.Context)
+	}
+
+	if outbox := b.GetOutbox(); outbox != nil {
+		if err := outbox.Add(tx, e); err != nil {
+			return fmt.Errorf("DomainEventBus.Publish error on add %s in outbox: %w", e.Name, err)
+		}
+	}
+
+	return b.deliver(tx.Statement.Context, e)
+}
+
+// deliver - Run the sync listeners and send the event to the async ones
+func (b *DomainEventBus) deliver(ctx context.Context, e *DomainEvent) error {
+	errs := []error{}
+	hasAsync := false
+
+	for _, l := range b.match(e.Name) {
+		if l.async {
+			hasAsync = true
+			continue
+		}
+
+		if err := b.handle(ctx, l, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// the outbox relay delivers to the async listeners
+	if outbox := b.GetOutbox(); outbox != nil {
+		outbox.Notify()
+	} else if hasAsync {
+		if err := b.enqueue(ctx, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Dispatch - Run the async listeners of one event and returns all errors, used by the outbox relay
+func (b *DomainEventBus) Dispatch(ctx context.Context, e *DomainEvent) error {
+	errs := []error{}
+
+	for _, l := range b.match(e.Name) {
+		if !l.async {
+			continue
+		}
+
+		if err := b.handle(ctx, l, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (b *DomainEventBus) match(name string) []*domainEventListener {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	listeners := []*domainEventListener{}
+	for _, l := range b.listeners {
+		if MatchDomainEventName(l.pattern, name) {
+			listeners = append(listeners, l)
+		}
+	}
+
+	return listeners
+}
+
+func (b *DomainEventBus) handle(ctx context.Context, l *domainEventListener, e *DomainEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+
+	err = l.handler(ctx, b.App, e)
+	if err != nil {
+		return fmt.Errorf("%s listener %s: %w", e.Name, l.pattern, err)
+	}
+
+	return nil
+}
+
+func (b *DomainEventBus) enqueue(ctx context.Context, e *DomainEvent) error {
+	// the send doesn't block, the lock avoids one send after Stop closes the queue
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrDomainEventBusClosed
+	}
+
+	if !b.started {
+		b.start()
+	}
+
+	select {
+	case b.queue <- &domainEventDelivery{ctx: ctx, event: e}:
+		return nil
+	default:
+		return fmt.Errorf("DomainEventBus: async queue full, %s %s dropped", e.Name, e.ID)
+	}
+}
+
+// start - Start the async workers, called with the lock
+func (b *DomainEventBus) start() {
+	cfg := b.App.GetConfiguration()
+
+	if b.Workers <= 0 {
+		b.Workers = cfg.GetIntF(DOMAIN_EVENTS_WORKERS, 4)
+	}
+	if b.QueueSize <= 0 {
+		b.QueueSize = cfg.GetIntF(DOMAIN_EVENTS_QUEUE_SIZE, 1000)
+	}
+
+	b.queue = make(chan *domainEventDelivery, b.QueueSize)
+	b.started = true
+
+	for i := 0; i < b.Workers; i++ {
+		b.wg.Add(1)
+		go b.work(b.queue)
+	}
+}
+
+func (b *DomainEventBus) work(queue chan *domainEventDelivery) {
+	defer b.wg.Done()
+
+	for d := range queue {
+		// the request ctx is canceled after the response, only its values are used
+		ctx := ContextWithRequestID(context.Background(), RequestIDFromContext(d.ctx))
+
+		if err := b.Dispatch(ctx, d.event); err != nil {
+			b.App.GetLogger().Error("DomainEventBus: async listener error", zap.Error(err), zap.String("event", d.event.Name), zap.String("eventID", d.event.ID))
+		}
+	}
+}
+
+// Stop - Stop the async workers after the queued events, ran in app Close
+func (b *DomainEventBus) Stop(ctx context.Context) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+
+	b.closed = true
+	if b.started {
+		close(b.queue)
+	}
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("DomainEventBus.Stop: %w", ctx.Err())
+	}
+}
+
+// MatchDomainEventName - Check one event name with one listener pattern: one name, one prefix like model.* or *
+func MatchDomainEventName(pattern, name string) bool {
+	if pattern == "*" || pattern == name {
+		return true
+	}
+
+	if strings.HasSuffix(pattern, ".*") {
+		return strings.HasPrefix(name, pattern[:len(pattern)-1])
+	}
+
+	return false
+}
+
+// EmitDomainEvents - Register the gorm callbacks that emit the model events of resource records.
+// The events are added in the outbox in the same transaction and delivered after the commit.
+// Only changes of records with primary key emit events, batch updates and deletes with conditions don't.
+// Inside one explicit db.Transaction the listeners run before the commit, use the outbox async listeners if they
+// should only see committed changes
+func EmitDomainEvents(app App, db *gorm.DB) error {
+	collect := func(name string) func(tx *gorm.DB) {
+		return func(tx *gorm.DB) {
+			if tx.Error != nil || tx.Statement.Schema == nil || tx.RowsAffected == 0 {
+				return
+			}
+
+			r := resourceForSchema(app, tx.Statement.Schema)
+			if r == nil || r.SkipEvents {
+				return
+			}
+
+			events, err := modelDomainEvents(app, tx, name, r.Name)
+			if err != nil {
+				tx.AddError(err)
+				return
+			}
+
+			if len(events) == 0 {
+				return
+			}
+
+			if outbox := app.GetDomainEvents().GetOutbox(); outbox != nil {
+				// same connection of the change transaction
+				session := tx.Session(&gorm.Session{NewDB: true})
+
+				for _, e := range events {
+					if err := outbox.Add(session, e); err != nil {
+						tx.AddError(fmt.Errorf("error on add %s in outbox: %w", e.Name, err))
+						return
+					}
+				}
+			}
+
+			tx.InstanceSet("bolo:domain_events", events)
+		}
+	}
+
+	publish := func(tx *gorm.DB) {
+		v, ok := tx.InstanceGet("bolo:domain_events")
+		if !ok || tx.Error != nil {
+			return
+		}
+
+		bus := app.GetDomainEvents()
+		for _, e := range v.([]*DomainEvent) {
+			// the change is saved, listener errors don't change the statement result
+			if err := bus.deliver(tx.Statement.Context, e); err != nil {
+				app.GetLogger().Error("EmitDomainEvents: listener error", zap.Error(err), zap.String("event", e.Name), zap.String("model", e.Model), zap.String("recordID", e.RecordID))
+			}
+		}
+	}
+
+	cb := db.Callback()
+
+	errs := []error{
+		cb.Create().After("gorm:create").Register("bolo:domain_events_create", collect(EventModelCreated)),
+		cb.Create().After("gorm:commit_or_rollback_transaction").Register("bolo:domain_events_publish_create", publish),
+		cb.Update().After("gorm:update").Register("bolo:domain_events_update", collect(EventModelUpdated)),
+		cb.Update().After("gorm:commit_or_rollback_transaction").Register("bolo:domain_events_publish_update", publish),
+		cb.Delete().After("gorm:delete").Register("bolo:domain_events_delete", collect(EventModelDeleted)),
+		cb.Delete().After("gorm:commit_or_rollback_transaction").Register("bolo:domain_events_publish_delete", publish),
+	}
+
+	return errors.Join(errs...)
+}
+
+func resourceForSchema(app App, s *schema.Schema) *Resource {
+	for _, r := range app.GetResources() {
+		if r.Model == nil {
+			continue
+		}
+
+		if reflect.Indirect(reflect.ValueOf(r.Model)).Type() == s.ModelType {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// modelDomainEvents - Build one event for each record with primary key in the statement
+func modelDomainEvents(app App, tx *gorm.DB, name, model string) ([]*DomainEvent, error) {
+	stmt := tx.Statement
+	pk := stmt.Schema.PrioritizedPrimaryField
+	if pk == nil {
+		return nil, nil
+	}
+
+	values := []reflect.Value{}
+	rv := reflect.Indirect(stmt.ReflectValue)
+
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			values = append(values, reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		values = append(values, rv)
+	}
+
+	events := []*DomainEvent{}
+	for _, v := range values {
+		if v.Kind() != reflect.Struct || v.Type() != stmt.Schema.ModelType {
+			continue
+		}
+
+		id, zero := pk.ValueOf(stmt.Context, v)
+		if zero {
+			continue
+		}
+
+		e, err := NewDomainEvent(app, name, v.Addr().Interface())
+		if err != nil {
+			return nil, err
+		}
+
+		e.Model = model
+		e.RecordID = fmt.Sprint(id)
+		e.RequestID = RequestIDFromContext(stmt.Context)
+
+		events = append(events, e)
+	}
+
+	return events, nil
+}
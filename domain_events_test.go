@@ -0,0 +1,162 @@
+package bolo_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	bolo "github.com/go-bolo/bolo"
+	"github.com/gookit/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDomainEventBus(t *testing.T) {
+	app := GetTestApp()
+	require.Nil(t, app.Bootstrap())
+
+	bus := app.GetDomainEvents()
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	received := map[string][]string{}
+	record := func(listener string) bolo.DomainEventHandler {
+		return func(ctx context.Context, app bolo.App, e *bolo.DomainEvent) error {
+			mu.Lock()
+			defer mu.Unlock()
+			received[listener] = append(received[listener], e.Name)
+			return nil
+		}
+	}
+
+	bus.Subscribe("order.paid", record("exact"))
+	bus.Subscribe("order.*", record("prefix"))
+	bus.Subscribe("*", record("all"))
+	bus.SubscribeAsync("order.paid", record("async"))
+	bus.Subscribe("order.failed", func(ctx context.Context, app bolo.App, e *bolo.DomainEvent) error {
+		return errors.New("listener failed")
+	})
+
+	t.Run("should deliver to the listeners with matching patterns", func(t *testing.T) {
+		e, err := bolo.NewDomainEvent(app, "order.paid", map[string]int{"total": 10})
+		require.Nil(t, err)
+		assert.NotEmpty(t, e.ID)
+		assert.Equal(t, app.GetClock().Now(), e.OccurredAt)
+
+		require.Nil(t, bus.Publish(ctx, e))
+
+		e, _ = bolo.NewDomainEvent(app, "user.created", nil)
+		require.Nil(t, bus.Publish(ctx, e))
+
+		assert.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(received["async"]) == 1
+		}, time.Second, 5*time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, []string{"order.paid"}, received["exact"])
+		assert.Equal(t, []string{"order.paid"}, received["prefix"])
+		assert.Equal(t, []string{"order.paid", "user.created"}, received["all"])
+	})
+
+	t.Run("should return the sync listener errors", func(t *testing.T) {
+		e, _ := bolo.NewDomainEvent(app, "order.failed", nil)
+		err := bus.Publish(ctx, e)
+		assert.ErrorContains(t, err, "listener failed")
+	})
+
+	t.Run("should decode the event data", func(t *testing.T) {
+		e, _ := bolo.NewDomainEvent(app, "order.paid", map[string]int{"total": 10})
+		data := struct {
+			Total int `json:"total"`
+		}{}
+		require.Nil(t, e.Decode(&data))
+		assert.Equal(t, 10, data.Total)
+	})
+
+	t.Run("should not accept async events after close", func(t *testing.T) {
+		require.Nil(t, app.Close())
+
+		e, _ := bolo.NewDomainEvent(app, "order.paid", nil)
+		assert.ErrorIs(t, bus.Publish(ctx, e), bolo.ErrDomainEventBusClosed)
+	})
+}
+
+func TestCloseWithListenerError(t *testing.T) {
+	app := GetTestApp()
+	require.Nil(t, app.Bootstrap())
+
+	closeErr := errors.New("close failed")
+	app.GetEvents().On("close", event.ListenerFunc(func(e event.Event) error {
+		return closeErr
+	}))
+
+	bus := app.GetDomainEvents()
+	bus.SubscribeAsync("order.paid", func(ctx context.Context, app bolo.App, e *bolo.DomainEvent) error { return nil })
+
+	assert.ErrorIs(t, app.Close(), closeErr)
+
+	e, _ := bolo.NewDomainEvent(app, "order.paid", nil)
+	assert.ErrorIs(t, bus.Publish(context.Background(), e), bolo.ErrDomainEventBusClosed)
+}
+
+func TestModelDomainEvents(t *testing.T) {
+	app := GetTestApp()
+	app.AddPlugin(&URLShortenerPlugin{Name: "url-shortener"})
+	require.Nil(t, app.Bootstrap())
+	require.Nil(t, app.SyncDB())
+
+	events := []*bolo.DomainEvent{}
+	app.GetDomainEvents().Subscribe("model.*", func(ctx context.Context, app bolo.App, e *bolo.DomainEvent) error {
+		events = append(events, e)
+		return nil
+	})
+
+	ctx := bolo.ContextWithRequestID(context.Background(), "rid-1")
+	db := app.GetDB().WithContext(ctx)
+
+	url := URLModel{Title: "Bolo", Path: "/bolo"}
+	require.Nil(t, db.Create(&url).Error)
+
+	require.Len(t, events, 1)
+	assert.Equal(t, bolo.EventModelCreated, events[0].Name)
+	assert.Equal(t, "urls", events[0].Model)
+	assert.Equal(t, url.GetID(), events[0].RecordID)
+	assert.Equal(t, "rid-1", events[0].RequestID)
+
+	saved := URLModel{}
+	require.Nil(t, events[0].Decode(&saved))
+	assert.Equal(t, "Bolo", saved.Title)
+
+	url.Title = "Bolo framework"
+	require.Nil(t, db.Save(&url).Error)
+	require.Len(t, events, 2)
+	assert.Equal(t, bolo.EventModelUpdated, events[1].Name)
+	assert.Contains(t, string(events[1].Data), "Bolo framework")
+
+	// batch changes without primary key don't emit events:
+	require.Nil(t, db.Model(&URLModel{}).Where("title = ?", "other").Update("path", "/other").Error)
+	assert.Len(t, events, 2)
+
+	// failed statements don't emit events:
+	assert.NotNil(t, db.Create(&URLModel{ID: url.ID, Title: "duplicated"}).Error)
+	assert.Len(t, events, 2)
+
+	require.Nil(t, db.Delete(&url).Error)
+	require.Len(t, events, 3)
+	assert.Equal(t, bolo.EventModelDeleted, events[2].Name)
+	assert.Equal(t, url.GetID(), events[2].RecordID)
+
+	t.Run("should skip resources with SkipEvents", func(t *testing.T) {
+		app.GetResources()["urls"].SkipEvents = true
+		defer func() { app.GetResources()["urls"].SkipEvents = false }()
+
+		require.Nil(t, db.Create(&URLModel{Title: "skipped"}).Error)
+		assert.Len(t, events, 3)
+	})
+
+}
@@ -0,0 +1,422 @@
+package bolo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrDownloadTooLarge = errors.New("download too large")
+	ErrChecksumMismatch = errors.New("download checksum mismatch")
+)
+
+// DownloadOptions - Download limits and verification, all optional
+type DownloadOptions struct {
+	Headers http.Header
+	// Max file size in bytes, 0 for no limit
+	MaxSize int64
+	// Expected SHA-256 hex checksum
+	SHA256 string
+	// Keep the partial file in <dest>.part after failures and resume it with one Range request.
+	// The response ETag or Last-Modified is saved in <dest>.part.validator and sent in If-Range,
+	// partial files of other versions are downloaded again
+	Resume bool
+	// Called after each write
+	Progress func(p DownloadProgress)
+}
+
+// DownloadProgress - Downloaded bytes, Total is -1 if the size is unknown
+type DownloadProgress struct {
+	Downloaded int64
+	Total      int64
+}
+
+// DownloadResult - Downloaded file info
+type DownloadResult struct {
+	Path        string
+	Size        int64
+	SHA256      string
+	ContentType string
+	// True if one partial file was resumed
+	Resumed bool
+
+	readErr error
+	// ETag or Last-Modified of the part file content
+	validator string
+}
+
+// Download - Download url in dest with one temporary file renamed after the size and checksum checks.
+// Body read failures are retried with Range requests if opts.Resume is true.
+// Responses with status outside the 2xx range return one *HTTPStatusError
+func (c *HTTPClient) Download(ctx context.Context, url, dest string, opts *DownloadOptions) (*DownloadResult, error) {
+	if opts == nil {
+		opts = &DownloadOptions{}
+	}
+
+	part, err := c.openPartFile(dest, opts.Resume)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.downloadPart(ctx, url, part, opts)
+	if err != nil {
+		part.Close()
+		// resumable failures keep the partial file for the next call
+		if !opts.Resume || errors.Is(err, ErrDownloadTooLarge) || errors.Is(err, ErrChecksumMismatch) {
+			removePart(part)
+		}
+		return nil, err
+	}
+
+	if err := part.Sync(); err != nil {
+		part.Close()
+		removePart(part)
+		return nil, fmt.Errorf("Download: %w", err)
+	}
+
+	if err := part.Close(); err != nil {
+		removePart(part)
+		return nil, fmt.Errorf("Download: %w", err)
+	}
+
+	if err := os.Rename(part.Name(), dest); err != nil {
+		removePart(part)
+		return nil, fmt.Errorf("Download: %w", err)
+	}
+	os.Remove(validatorPath(part))
+
+	result.Path = dest
+	return result, nil
+}
+
+func (c *HTTPClient) openPartFile(dest string, resume bool) (*os.File, error) {
+	if resume {
+		f, err := os.OpenFile(dest+".part", os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("Download: %w", err)
+		}
+		return f, nil
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".*.part")
+	if err != nil {
+		return nil, fmt.Errorf("Download: %w", err)
+	}
+	return f, nil
+}
+
+// downloadPart - Fill the part file, retrying interrupted bodies from the current size
+func (c *HTTPClient) downloadPart(ctx context.Context, url string, part *os.File, opts *DownloadOptions) (*DownloadResult, error) {
+	result := DownloadResult{}
+
+	if opts.Resume {
+		if data, err := os.ReadFile(validatorPath(part)); err == nil {
+			result.validator = string(data)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		offset, err := part.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, fmt.Errorf("Download: %w", err)
+		}
+		if offset > 0 {
+			result.Resumed = true
+		}
+
+		done, err := c.downloadRange(ctx, url, part, offset, opts, &result)
+		if done || err != nil {
+			return &result, err
+		}
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		if !opts.Resume || attempt >= c.MaxRetries {
+			return nil, result.err()
+		}
+
+		if c.App != nil {
+			c.App.GetLogger().Debug("Download: resume", zap.String("url", url), zap.Int64("offset", result.Size), zap.Error(result.err()))
+		}
+	}
+}
+
+// downloadRange - Request the bytes after offset, done is false with one resumable body read error in result
+func (c *HTTPClient) downloadRange(ctx context.Context, url string, part *os.File, offset int64, opts *DownloadOptions, result *DownloadResult) (bool, error) {
+	req, err := newRequest(ctx, http.MethodGet, url, nil, opts.Headers)
+	if err != nil {
+		return false, err
+	}
+
+	if offset > 0 && result.validator == "" {
+		// the part file version is unknown, like parts saved without one ETag or Last-Modified
+		if err := restartPart(part); err != nil {
+			return false, err
+		}
+		offset = 0
+		result.Resumed = false
+	}
+
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+		req.Header.Set("If-Range", result.validator)
+	}
+
+	// downloads skip the response cache
+	res, err := c.do(req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	total := int64(-1)
+
+	switch {
+	case offset > 0 && res.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// the partial file is complete if the range starts at the file size
+		if size, ok := contentRangeSize(res.Header.Get("Content-Range")); ok && size == offset {
+			return true, result.complete(part, offset, res, opts)
+		}
+
+		if err := restartPart(part); err != nil {
+			return false, err
+		}
+		return false, nil
+	case offset > 0 && res.StatusCode == http.StatusPartialContent:
+		start, ok := contentRangeStart(res.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			return false, fmt.Errorf("Download: invalid Content-Range %q for offset %d", res.Header.Get("Content-Range"), offset)
+		}
+
+		// servers without If-Range support can send one range of other version
+		if !matchesValidator(res, result.validator) {
+			if err := restartPart(part); err != nil {
+				return false, err
+			}
+			result.Resumed = false
+			result.validator = ""
+			return false, nil
+		}
+
+		if size, ok := contentRangeSize(res.Header.Get("Content-Range")); ok {
+			total = size
+		}
+	default:
+		if err := CheckResponse(res); err != nil {
+			return false, err
+		}
+
+		// servers without range support and changed files send the full file
+		if offset > 0 {
+			if err := restartPart(part); err != nil {
+				return false, err
+			}
+			offset = 0
+			result.Resumed = false
+		}
+
+		result.validator = responseValidator(res)
+		if opts.Resume {
+			if err := os.WriteFile(validatorPath(part), []byte(result.validator), 0o644); err != nil {
+				return false, fmt.Errorf("Download: %w", err)
+			}
+		}
+
+		if res.ContentLength >= 0 {
+			total = res.ContentLength
+		}
+	}
+
+	if opts.MaxSize > 0 && total > opts.MaxSize {
+		return false, fmt.Errorf("%w: %d bytes, max %d", ErrDownloadTooLarge, total, opts.MaxSize)
+	}
+
+	body := io.Reader(res.Body)
+	if opts.MaxSize > 0 {
+		body = io.LimitReader(res.Body, opts.MaxSize-offset+1)
+	}
+
+	w := &progressWriter{w: part, downloaded: offset, total: total, progress: opts.Progress}
+	_, err = io.Copy(w, body)
+	result.Size = w.downloaded
+
+	if opts.MaxSize > 0 && w.downloaded > opts.MaxSize {
+		return false, fmt.Errorf("%w: max %d bytes", ErrDownloadTooLarge, opts.MaxSize)
+	}
+
+	if err != nil {
+		// write errors are not resumable
+		if w.writeErr != nil {
+			return false, fmt.Errorf("Download: %w", w.writeErr)
+		}
+
+		result.readErr = err
+		return false, nil
+	}
+
+	return true, result.complete(part, w.downloaded, res, opts)
+}
+
+// complete - Fill the result and check the checksum of the full part file
+func (r *DownloadResult) complete(part *os.File, size int64, res *http.Response, opts *DownloadOptions) error {
+	r.Size = size
+	r.ContentType = res.Header.Get("Content-Type")
+
+	sum, err := fileSHA256(part)
+	if err != nil {
+		return fmt.Errorf("Download: %w", err)
+	}
+	r.SHA256 = sum
+
+	if opts.SHA256 != "" && !strings.EqualFold(opts.SHA256, sum) {
+		return fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, opts.SHA256, sum)
+	}
+
+	return nil
+}
+
+func (r *DownloadResult) err() error {
+	if r.readErr != nil {
+		return fmt.Errorf("Download: %w", r.readErr)
+	}
+
+	return errors.New("Download: interrupted")
+}
+
+func fileSHA256(f *os.File) (string, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// responseValidator - Strong ETag or Last-Modified of one response, If-Range only accepts strong ETags
+func responseValidator(res *http.Response) string {
+	if etag := res.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+
+	return res.Header.Get("Last-Modified")
+}
+
+// matchesValidator - Check the validator of one partial response, responses without the header are accepted
+func matchesValidator(res *http.Response, validator string) bool {
+	if strings.HasPrefix(validator, `"`) {
+		etag := res.Header.Get("ETag")
+		return etag == "" || etag == validator
+	}
+
+	lastModified := res.Header.Get("Last-Modified")
+	return lastModified == "" || lastModified == validator
+}
+
+func validatorPath(part *os.File) string {
+	return part.Name() + ".validator"
+}
+
+// removePart - Remove the part file and its validator
+func removePart(part *os.File) {
+	os.Remove(part.Name())
+	os.Remove(validatorPath(part))
+}
+
+func restartPart(part *os.File) error {
+	if err := part.Truncate(0); err != nil {
+		return fmt.Errorf("Download: %w", err)
+	}
+
+	_, err := part.Seek(0, io.SeekStart)
+	return err
+}
+
+// contentRangeStart - Parse the first byte of one Content-Range header, like: bytes 100-199/200
+func contentRangeStart(v string) (int64, bool) {
+	v, ok := strings.CutPrefix(v, "bytes ")
+	if !ok {
+		return 0, false
+	}
+
+	start, _, ok := strings.Cut(v, "-")
+	if !ok {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(start, 10, 64)
+	return n, err == nil
+}
+
+// contentRangeSize - Parse the full size of one Content-Range header, like: bytes */200
+func contentRangeSize(v string) (int64, bool) {
+	_, size, ok := strings.Cut(v, "/")
+	if !ok || size == "*" {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(size, 10, 64)
+	return n, err == nil
+}
+
+type progressWriter struct {
+	w          io.Writer
+	downloaded int64
+	total      int64
+	progress   func(p DownloadProgress)
+	writeErr   error
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if err != nil {
+		w.writeErr = err
+	}
+
+	w.downloaded += int64(n)
+	if w.progress != nil && n > 0 {
+		w.progress(DownloadProgress{Downloaded: w.downloaded, Total: w.total})
+	}
+
+	return n, err
+}
+
+// DownloadFile - Download one file in dest, responses with status outside the 2xx range return one *HTTPStatusError.
+// dest is not closed
+//
+// Deprecated: use app.GetHTTPClient().Download with one context
+func DownloadFile(app App, url string, dest *os.File, headers http.Header) (bool, error) {
+	l := app.GetLogger().With(zap.String("func", "DownloadFile"))
+	l.Debug("will download", zap.String("url", url), zap.String("dest", dest.Name()))
+
+	res, err := app.GetHTTPClient().Get(context.Background(), url, headers)
+	if err != nil {
+		l.Warn("error on request", zap.Error(err), zap.String("url", url))
+		return false, err
+	}
+	defer res.Body.Close()
+
+	_, err = io.Copy(dest, res.Body)
+	if err != nil {
+		l.Error("error on copy body", zap.Error(err), zap.String("url", url), zap.String("dest", dest.Name()))
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,205 @@
+package bolo_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	bolo "github.com/go-bolo/bolo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPClientDownload(t *testing.T) {
+	app := GetTestApp()
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+
+	var interrupt int32
+	var ranges []string
+	etag := `"v1"`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/file.txt":
+			ranges = append(ranges, r.Header.Get("Range"))
+			w.Header().Set("ETag", etag)
+
+			if atomic.CompareAndSwapInt32(&interrupt, 1, 0) {
+				// send half of the body and close the connection
+				w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+				w.Write(content[:len(content)/2])
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+				return
+			}
+
+			w.Header().Set("Content-Type", "text/plain")
+			http.ServeContent(w, r, "file.txt", time.Time{}, bytes.NewReader(content))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := bolo.NewHTTPClientFromConfiguration(app)
+	c.Client = server.Client()
+	c.RetryWaitMin = time.Millisecond
+	c.RetryWaitMax = 5 * time.Millisecond
+
+	partFiles := func(dir string) []string {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.part*"))
+		hidden, _ := filepath.Glob(filepath.Join(dir, ".*.part*"))
+		return append(files, hidden...)
+	}
+
+	t.Run("should download with checksum and progress", func(t *testing.T) {
+		dir := t.TempDir()
+		dest := filepath.Join(dir, "file.txt")
+		progress := []bolo.DownloadProgress{}
+
+		res, err := c.Download(context.Background(), server.URL+"/file.txt", dest, &bolo.DownloadOptions{
+			SHA256:   checksum,
+			Progress: func(p bolo.DownloadProgress) { progress = append(progress, p) },
+		})
+		require.Nil(t, err)
+		assert.Equal(t, dest, res.Path)
+		assert.Equal(t, int64(len(content)), res.Size)
+		assert.Equal(t, checksum, res.SHA256)
+		assert.Equal(t, "text/plain", res.ContentType)
+		assert.False(t, res.Resumed)
+
+		require.NotEmpty(t, progress)
+		assert.Equal(t, bolo.DownloadProgress{Downloaded: int64(len(content)), Total: int64(len(content))}, progress[len(progress)-1])
+
+		data, err := os.ReadFile(dest)
+		require.Nil(t, err)
+		assert.Equal(t, content, data)
+		assert.Empty(t, partFiles(dir))
+	})
+
+	t.Run("should not replace the destination with invalid downloads", func(t *testing.T) {
+		dir := t.TempDir()
+		dest := filepath.Join(dir, "file.txt")
+
+		_, err := c.Download(context.Background(), server.URL+"/file.txt", dest, &bolo.DownloadOptions{SHA256: "abc"})
+		assert.True(t, errors.Is(err, bolo.ErrChecksumMismatch))
+
+		_, err = c.Download(context.Background(), server.URL+"/file.txt", dest, &bolo.DownloadOptions{MaxSize: 100})
+		assert.True(t, errors.Is(err, bolo.ErrDownloadTooLarge))
+
+		_, err = c.Download(context.Background(), server.URL+"/missing", dest, nil)
+		assert.True(t, bolo.IsHTTPStatus(err, http.StatusNotFound))
+
+		_, err = os.Stat(dest)
+		assert.True(t, os.IsNotExist(err))
+		assert.Empty(t, partFiles(dir))
+	})
+
+	t.Run("should resume one partial file", func(t *testing.T) {
+		dir := t.TempDir()
+		dest := filepath.Join(dir, "file.txt")
+		require.Nil(t, os.WriteFile(dest+".part", content[:4000], 0o644))
+		require.Nil(t, os.WriteFile(dest+".part.validator", []byte(`"v1"`), 0o644))
+		ranges = nil
+
+		res, err := c.Download(context.Background(), server.URL+"/file.txt", dest, &bolo.DownloadOptions{SHA256: checksum, Resume: true})
+		require.Nil(t, err)
+		assert.True(t, res.Resumed)
+		assert.Equal(t, []string{"bytes=4000-"}, ranges)
+
+		data, err := os.ReadFile(dest)
+		require.Nil(t, err)
+		assert.Equal(t, content, data)
+		assert.Empty(t, partFiles(dir))
+	})
+
+	t.Run("should download again partial files of other versions", func(t *testing.T) {
+		dir := t.TempDir()
+		dest := filepath.Join(dir, "file.txt")
+		require.Nil(t, os.WriteFile(dest+".part", []byte("old content"), 0o644))
+		require.Nil(t, os.WriteFile(dest+".part.validator", []byte(`"v0"`), 0o644))
+		ranges = nil
+
+		res, err := c.Download(context.Background(), server.URL+"/file.txt", dest, &bolo.DownloadOptions{SHA256: checksum, Resume: true})
+		require.Nil(t, err)
+		assert.False(t, res.Resumed)
+		assert.Equal(t, []string{"bytes=11-"}, ranges)
+
+		data, err := os.ReadFile(dest)
+		require.Nil(t, err)
+		assert.Equal(t, content, data)
+		assert.Empty(t, partFiles(dir))
+	})
+
+	t.Run("should download again partial files without validator", func(t *testing.T) {
+		dir := t.TempDir()
+		dest := filepath.Join(dir, "file.txt")
+		require.Nil(t, os.WriteFile(dest+".part", []byte("old content"), 0o644))
+		ranges = nil
+
+		res, err := c.Download(context.Background(), server.URL+"/file.txt", dest, &bolo.DownloadOptions{SHA256: checksum, Resume: true})
+		require.Nil(t, err)
+		assert.False(t, res.Resumed)
+		assert.Equal(t, []string{""}, ranges)
+
+		data, err := os.ReadFile(dest)
+		require.Nil(t, err)
+		assert.Equal(t, content, data)
+	})
+
+	t.Run("should resume interrupted bodies", func(t *testing.T) {
+		dir := t.TempDir()
+		dest := filepath.Join(dir, "file.txt")
+		ranges = nil
+		atomic.StoreInt32(&interrupt, 1)
+
+		res, err := c.Download(context.Background(), server.URL+"/file.txt", dest, &bolo.DownloadOptions{SHA256: checksum, Resume: true})
+		require.Nil(t, err)
+		assert.True(t, res.Resumed)
+		assert.Equal(t, []string{"", "bytes=5000-"}, ranges)
+
+		data, err := os.ReadFile(dest)
+		require.Nil(t, err)
+		assert.Equal(t, content, data)
+	})
+
+	t.Run("should stop on context cancel", func(t *testing.T) {
+		dest := filepath.Join(t.TempDir(), "file.txt")
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := c.Download(ctx, server.URL+"/file.txt", dest, nil)
+		assert.True(t, errors.Is(err, context.Canceled))
+	})
+
+	t.Run("DownloadFile should check the status and keep dest open", func(t *testing.T) {
+		app.GetHTTPClient().Client = server.Client()
+
+		dest, err := os.Create(filepath.Join(t.TempDir(), "file.txt"))
+		require.Nil(t, err)
+		defer dest.Close()
+
+		ok, err := bolo.DownloadFile(app, server.URL+"/file.txt", dest, nil)
+		require.Nil(t, err)
+		assert.True(t, ok)
+
+		_, err = dest.Seek(0, 0)
+		require.Nil(t, err, "dest should stay open")
+
+		ok, err = bolo.DownloadFile(app, server.URL+"/missing", dest, nil)
+		assert.False(t, ok)
+		assert.True(t, bolo.IsHTTPStatus(err, http.StatusNotFound))
+	})
+}
@@ -5,18 +5,25 @@ import (
 	"net/http"
 	"net/http/httptest"
 
+	"github.com/go-bolo/bolo/helpers"
 	"github.com/go-bolo/bolo/pagination"
 	"github.com/go-bolo/query_parser_to_db"
-	"github.com/google/uuid"
 	"github.com/gookit/event"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 func GetApp(c echo.Context) App {
 	return c.Get("app").(App)
 }
 
+// GetDB - Returns the app database with the request context, the queries are canceled with the request and traced
+// as children of the request span
+func GetDB(c echo.Context) *gorm.DB {
+	return GetApp(c).GetDB().WithContext(c.Request().Context())
+}
+
 func NewContext(app App) echo.Context {
 	req, _ := http.NewRequest(http.MethodGet, "/", nil)
 	res := httptest.NewRecorder()
@@ -32,8 +39,11 @@ func SetDefaultValues(c echo.Context, app App) error {
 	protocol := cfg.GetF("PROTOCOL", "http")
 	domain := cfg.GetF("DOMAIN", "localhost")
 
+	requestID := resolveRequestID(c.Request())
+	SetRequestID(c, requestID)
+
 	c.Set("app", app)
-	c.Set("logger", app.GetLogger().With(zap.String("RID", uuid.New().String())))
+	c.Set("logger", app.GetLogger().With(zap.String("RID", requestID)))
 	c.Set("theme", cfg.GetF("THEME", app.GetTheme()))
 	c.Set("base_url", cfg.GetF("BASE_URL", protocol+"://"+domain+":"+port))
 
@@ -117,9 +127,32 @@ func IsAuthenticated(c echo.Context) bool {
 func Can(c echo.Context, permission string) bool {
 	app := GetApp(c)
 	roles := GetRoles(c)
+
+	scope := GetPermissionsScope(c)
+	if scope != nil && !helpers.SliceContains(scope, permission) {
+		return false
+	}
+
 	return app.GetAcl().Can(permission, roles)
 }
 
+// GetPermissionsScope - Returns the permissions allowed for the current request credential, like one scoped API key.
+// nil means that the request is only limited by the roles
+func GetPermissionsScope(c echo.Context) []string {
+	scope := c.Get("permissions_scope")
+	if scope == nil {
+		return nil
+	}
+
+	return scope.([]string)
+}
+
+// SetPermissionsScope - Limit the current request permissions to the scope, checked in Can
+func SetPermissionsScope(c echo.Context, scope []string) error {
+	c.Set("permissions_scope", scope)
+	return nil
+}
+
 func GetQueryParser(c echo.Context) query_parser_to_db.QueryInterface {
 	queryParser := c.Get("query_parser")
 	if queryParser == nil {
@@ -1,14 +1,94 @@
 package bolo
 
 var (
-	THEME                  = "THEME"
-	ENV_VARIABLE_NAME      = "GO_ENV"
-	TEMPLATE_FOLDER        = "TEMPLATE_FOLDER"
-	TEMPLATE_DISABLE       = "TEMPLATE_DISABLE"
-	DB_URI                 = "DB_URI"
-	LOG_QUERY              = "LOG_QUERY"
-	DB_SLOW_THRESHOLD      = "DB_SLOW_THRESHOLD"
-	CORS_ALLOW_CREDENTIALS = "CORS_ALLOW_CREDENTIALS"
-	CORS_MAX_AGE           = "CORS_MAX_AGE"
-	PORT                   = "PORT"
+	THEME                         = "THEME"
+	ENV_VARIABLE_NAME             = "GO_ENV"
+	TEMPLATE_FOLDER               = "TEMPLATE_FOLDER"
+	TEMPLATE_DISABLE              = "TEMPLATE_DISABLE"
+	DB_URI                        = "DB_URI"
+	LOG_QUERY                     = "LOG_QUERY"
+	DB_SLOW_THRESHOLD             = "DB_SLOW_THRESHOLD"
+	CORS_ALLOW_CREDENTIALS        = "CORS_ALLOW_CREDENTIALS"
+	CORS_MAX_AGE                  = "CORS_MAX_AGE"
+	PORT                          = "PORT"
+	DEFAULT_LOCALE                = "DEFAULT_LOCALE"
+	LOCALE_COOKIE_NAME            = "LOCALE_COOKIE_NAME"
+	SITE_TIMEZONE                 = "SITE_TIMEZONE"
+	TIMEZONE_COOKIE_NAME          = "TIMEZONE_COOKIE_NAME"
+	SITE_NAME                     = "SITE_NAME"
+	SEO_TITLE_TEMPLATE            = "SEO_TITLE_TEMPLATE"
+	SEO_DESCRIPTION               = "SEO_DESCRIPTION"
+	SEO_IMAGE                     = "SEO_IMAGE"
+	SEO_TWITTER_SITE              = "SEO_TWITTER_SITE"
+	SEO_ROBOTS                    = "SEO_ROBOTS"
+	SITEMAP_DISABLE               = "SITEMAP_DISABLE"
+	SITEMAP_GZIP                  = "SITEMAP_GZIP"
+	SITEMAP_CACHE_TTL             = "SITEMAP_CACHE_TTL"
+	ROBOTS_TXT_FILE               = "ROBOTS_TXT_FILE"
+	ROBOTS_DISALLOW               = "ROBOTS_DISALLOW"
+	FEED_SUMMARY_LENGTH           = "FEED_SUMMARY_LENGTH"
+	CSRF_DISABLE                  = "CSRF_DISABLE"
+	RATE_LIMIT                    = "RATE_LIMIT"
+	RATE_LIMIT_ALGORITHM          = "RATE_LIMIT_ALGORITHM"
+	RATE_LIMIT_BY                 = "RATE_LIMIT_BY"
+	RATE_LIMIT_STORE              = "RATE_LIMIT_STORE"
+	SECURITY_HEADERS_DISABLE      = "SECURITY_HEADERS_DISABLE"
+	HSTS_MAX_AGE                  = "HSTS_MAX_AGE"
+	HSTS_INCLUDE_SUBDOMAINS       = "HSTS_INCLUDE_SUBDOMAINS"
+	HSTS_PRELOAD                  = "HSTS_PRELOAD"
+	REFERRER_POLICY               = "REFERRER_POLICY"
+	PERMISSIONS_POLICY            = "PERMISSIONS_POLICY"
+	FRAME_OPTIONS                 = "FRAME_OPTIONS"
+	CSP_MODE                      = "CSP_MODE"
+	CSP_POLICY                    = "CSP_POLICY"
+	CSP_REPORT_PATH               = "CSP_REPORT_PATH"
+	ACCESS_LOG_DISABLE            = "ACCESS_LOG_DISABLE"
+	ACCESS_LOG_SAMPLE_RATE        = "ACCESS_LOG_SAMPLE_RATE"
+	ACCESS_LOG_SKIP_PATHS         = "ACCESS_LOG_SKIP_PATHS"
+	METRICS_DISABLE               = "METRICS_DISABLE"
+	METRICS_PATH                  = "METRICS_PATH"
+	METRICS_TOKEN                 = "METRICS_TOKEN"
+	METRICS_PUBLIC                = "METRICS_PUBLIC"
+	TRACING_EXPORTER              = "TRACING_EXPORTER"
+	TRACING_FILE                  = "TRACING_FILE"
+	TRACING_SERVICE_NAME          = "TRACING_SERVICE_NAME"
+	TRACING_SAMPLE_RATIO          = "TRACING_SAMPLE_RATIO"
+	HEALTH_CHECK_TIMEOUT          = "HEALTH_CHECK_TIMEOUT"
+	HEALTH_SHUTDOWN_DELAY         = "HEALTH_SHUTDOWN_DELAY"
+	HEALTH_TOKEN                  = "HEALTH_TOKEN"
+	DOMAIN_EVENTS_WORKERS         = "DOMAIN_EVENTS_WORKERS"
+	DOMAIN_EVENTS_QUEUE_SIZE      = "DOMAIN_EVENTS_QUEUE_SIZE"
+	DOMAIN_EVENTS_STOP_TIMEOUT    = "DOMAIN_EVENTS_STOP_TIMEOUT"
+	HTTP_CLIENT_TIMEOUT           = "HTTP_CLIENT_TIMEOUT"
+	HTTP_CLIENT_MAX_RETRIES       = "HTTP_CLIENT_MAX_RETRIES"
+	HTTP_CLIENT_RETRY_WAIT_MIN    = "HTTP_CLIENT_RETRY_WAIT_MIN"
+	HTTP_CLIENT_RETRY_WAIT_MAX    = "HTTP_CLIENT_RETRY_WAIT_MAX"
+	HTTP_CLIENT_BREAKER_THRESHOLD = "HTTP_CLIENT_BREAKER_THRESHOLD"
+	HTTP_CLIENT_BREAKER_COOLDOWN  = "HTTP_CLIENT_BREAKER_COOLDOWN"
+	HTTP_CLIENT_CACHE             = "HTTP_CLIENT_CACHE"
+	HTTP_CLIENT_CACHE_DIR         = "HTTP_CLIENT_CACHE_DIR"
+	HTTP_CLIENT_CACHE_MAX_ENTRIES = "HTTP_CLIENT_CACHE_MAX_ENTRIES"
+	UPLOAD_MAX_SIZE               = "UPLOAD_MAX_SIZE"
+	STORAGE_DRIVER                = "STORAGE_DRIVER"
+	STORAGE_SIGNING_SECRET        = "STORAGE_SIGNING_SECRET"
+	SESSION_SECRET                = "SESSION_SECRET"
+	SESSION_STORE                 = "SESSION_STORE"
+	SESSION_TTL                   = "SESSION_TTL"
+	SESSION_COOKIE_NAME           = "SESSION_COOKIE_NAME"
+	SESSION_COOKIE_SECURE         = "SESSION_COOKIE_SECURE"
+	SESSION_COOKIE_DOMAIN         = "SESSION_COOKIE_DOMAIN"
+	USERS_PASSWORD_HASHER         = "USERS_PASSWORD_HASHER"
+	USERS_TOKEN_TTL               = "USERS_TOKEN_TTL"
+	USERS_MIN_PASSWORD_LENGTH     = "USERS_MIN_PASSWORD_LENGTH"
+	USERS_LOGIN_RATE_LIMIT        = "USERS_LOGIN_RATE_LIMIT"
+	USERS_LOGIN_MAX_FAILURES      = "USERS_LOGIN_MAX_FAILURES"
+	USERS_LOGIN_LOCKOUT           = "USERS_LOGIN_LOCKOUT"
+	STORAGE_LOCAL_DIR             = "STORAGE_LOCAL_DIR"
+	STORAGE_LOCAL_URL             = "STORAGE_LOCAL_URL"
+	STORAGE_S3_ENDPOINT           = "STORAGE_S3_ENDPOINT"
+	STORAGE_S3_REGION             = "STORAGE_S3_REGION"
+	STORAGE_S3_BUCKET             = "STORAGE_S3_BUCKET"
+	STORAGE_S3_ACCESS_KEY         = "STORAGE_S3_ACCESS_KEY"
+	STORAGE_S3_SECRET_KEY         = "STORAGE_S3_SECRET_KEY"
+	STORAGE_S3_PATH_STYLE         = "STORAGE_S3_PATH_STYLE"
 )
@@ -0,0 +1,388 @@
+package bolo
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-bolo/bolo/helpers"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	MIMEApplicationRSS  = "application/rss+xml"
+	MIMEApplicationAtom = "application/atom+xml"
+)
+
+// FeedItem - Interface for models that can be listed in RSS and Atom feeds
+type FeedItem interface {
+	GetFeedTitle() string
+	// Item url or path, paths are prefixed with the request base url
+	GetFeedLink() string
+	// Item summary, HTML tags are removed and the text is truncated
+	GetFeedSummary() string
+	GetFeedAuthor() string
+	GetFeedPublishedAt() time.Time
+	GetFeedUpdatedAt() time.Time
+}
+
+// FeedItemsProvider - Response data with custom feed items. Response data without this interface are searched for
+// one slice of FeedItem
+type FeedItemsProvider interface {
+	GetFeedItems() []FeedItem
+}
+
+// GetFeedItems - Returns the feed items from one response data, with support to FeedItemsProvider, slices and
+// structs with one slice field of FeedItem
+func GetFeedItems(data interface{}) ([]FeedItem, bool) {
+	if p, ok := data.(FeedItemsProvider); ok {
+		return p.GetFeedItems(), true
+	}
+
+	return feedItemsFromValue(reflect.ValueOf(data), 2)
+}
+
+func feedItemsFromValue(v reflect.Value, depth int) ([]FeedItem, bool) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		itemType := reflect.TypeOf((*FeedItem)(nil)).Elem()
+		if !v.Type().Elem().Implements(itemType) {
+			return nil, false
+		}
+
+		items := make([]FeedItem, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if item, ok := v.Index(i).Interface().(FeedItem); ok && !v.Index(i).IsZero() {
+				items = append(items, item)
+			}
+		}
+		return items, true
+	case reflect.Struct:
+		if depth == 0 {
+			return nil, false
+		}
+
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+
+			if items, ok := feedItemsFromValue(v.Field(i), depth-1); ok {
+				return items, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+var feedFormatters = map[string]responseFormatter{
+	MIMEApplicationRSS:  RSSFormatter,
+	MIMEApplicationAtom: AtomFormatter,
+}
+
+// GetFeedFormatter - Returns the feed formatter of one content type or nil for other content types
+func GetFeedFormatter(accept string) responseFormatter {
+	return feedFormatters[accept]
+}
+
+// SetFeedAccept - Negotiate the response format with the app content types and the feed content types, used by the
+// routes with Feed enabled. The other routes only negotiate the app content types
+func SetFeedAccept(app App, c echo.Context) {
+	offers := append([]string{}, app.GetContentTypes()...)
+	offers = append(offers, MIMEApplicationRSS, MIMEApplicationAtom)
+
+	SetAccept(c, NegotiateContentType(c.Request(), offers, GetAccept(c)))
+}
+
+// FeedSuffixMiddleware - Resolve the .rss and .atom url suffixes as feed content negotiation. Ex: /blog.rss
+// Only paths of GET routes with Feed enabled are changed, static files like /public/feed.rss and the other routes
+// are served with the original path
+func FeedSuffixMiddleware(app App) echo.MiddlewareFunc {
+	suffixes := map[string]string{
+		".rss":  MIMEApplicationRSS,
+		".atom": MIMEApplicationAtom,
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+
+			for suffix, mime := range suffixes {
+				if strings.HasSuffix(req.URL.Path, suffix) && isFeedRoutePath(app, strings.TrimSuffix(req.URL.Path, suffix)) {
+					req.URL.Path = strings.TrimSuffix(req.URL.Path, suffix)
+					req.URL.RawPath = ""
+					req.Header.Set(echo.HeaderAccept, mime)
+					SetAccept(c, mime)
+					break
+				}
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// isFeedRoutePath - Check if the path matches one GET app route with Feed enabled, params match one path segment
+func isFeedRoutePath(app App, path string) bool {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	for _, r := range app.GetRoutes() {
+		if !r.Feed || r.Method != http.MethodGet || strings.Contains(r.Path, "*") {
+			continue
+		}
+
+		routeSegments := strings.Split(strings.Trim(r.Path, "/"), "/")
+		if len(routeSegments) != len(segments) {
+			continue
+		}
+
+		match := true
+		for i, s := range routeSegments {
+			if s != segments[i] && (!strings.HasPrefix(s, ":") || segments[i] == "") {
+				match = false
+				break
+			}
+		}
+
+		if match {
+			return true
+		}
+	}
+
+	return false
+}
+
+type feedChannel struct {
+	Title       string
+	Link        string
+	SelfLink    string
+	Description string
+	Language    string
+	Updated     time.Time
+}
+
+func newFeedChannel(c echo.Context, items []FeedItem) *feedChannel {
+	seo := GetResolvedSEO(c)
+
+	ch := &feedChannel{
+		Title:       seo.GetPageTitle(),
+		Link:        GetCanonicalURL(c),
+		SelfLink:    absoluteURL(c, c.Request().URL.RequestURI()),
+		Description: seo.Description,
+		Language:    GetLocale(c),
+		Updated:     GetApp(c).GetClock().Now(),
+	}
+
+	if len(items) > 0 {
+		ch.Updated = time.Time{}
+		for _, item := range items {
+			if u := feedItemUpdatedAt(item); u.After(ch.Updated) {
+				ch.Updated = u
+			}
+		}
+	}
+
+	return ch
+}
+
+func feedItemUpdatedAt(item FeedItem) time.Time {
+	if u := item.GetFeedUpdatedAt(); !u.IsZero() {
+		return u
+	}
+
+	return item.GetFeedPublishedAt()
+}
+
+func feedItemSummary(app App, item FeedItem) string {
+	length := app.GetConfiguration().GetIntF(FEED_SUMMARY_LENGTH, 300)
+	return helpers.StripTagsAndTruncate(item.GetFeedSummary(), length, "...")
+}
+
+func getFeedResponseItems(c echo.Context, resp Response) ([]FeedItem, error) {
+	items, ok := GetFeedItems(resp.GetData())
+	if !ok {
+		return nil, &HTTPError{
+			Code:    http.StatusNotAcceptable,
+			Message: "Not Acceptable",
+		}
+	}
+
+	limit := GetLimit(c)
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+
+	return items, nil
+}
+
+type rssXML struct {
+	XMLName   xml.Name      `xml:"rss"`
+	Version   string        `xml:"version,attr"`
+	AtomXMLNS string        `xml:"xmlns:atom,attr"`
+	DCXMLNS   string        `xml:"xmlns:dc,attr"`
+	Channel   rssChannelXML `xml:"channel"`
+}
+
+type rssChannelXML struct {
+	Title         string       `xml:"title"`
+	Link          string       `xml:"link"`
+	AtomLink      atomLinkXML  `xml:"atom:link"`
+	Description   string       `xml:"description"`
+	Language      string       `xml:"language,omitempty"`
+	LastBuildDate string       `xml:"lastBuildDate"`
+	Items         []rssItemXML `xml:"item"`
+}
+
+type rssItemXML struct {
+	Title       string     `xml:"title"`
+	Link        string     `xml:"link"`
+	GUID        rssGUIDXML `xml:"guid"`
+	Description string     `xml:"description,omitempty"`
+	Author      string     `xml:"dc:creator,omitempty"`
+	PubDate     string     `xml:"pubDate,omitempty"`
+}
+
+type rssGUIDXML struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// RSSFormatter - Render list responses with FeedItem records as RSS 2.0
+func RSSFormatter(app App, c echo.Context, r *Route, resp Response) error {
+	items, err := getFeedResponseItems(c, resp)
+	if err != nil {
+		return err
+	}
+
+	ch := newFeedChannel(c, items)
+
+	feed := rssXML{
+		Version:   "2.0",
+		AtomXMLNS: "http://www.w3.org/2005/Atom",
+		DCXMLNS:   "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannelXML{
+			Title:         ch.Title,
+			Link:          ch.Link,
+			AtomLink:      atomLinkXML{Href: ch.SelfLink, Rel: "self", Type: MIMEApplicationRSS},
+			Description:   ch.Description,
+			Language:      ch.Language,
+			LastBuildDate: ch.Updated.UTC().Format(time.RFC1123Z),
+		},
+	}
+
+	for _, item := range items {
+		link := absoluteURL(c, item.GetFeedLink())
+		x := rssItemXML{
+			Title:       item.GetFeedTitle(),
+			Link:        link,
+			GUID:        rssGUIDXML{IsPermaLink: "true", Value: link},
+			Description: feedItemSummary(app, item),
+			Author:      item.GetFeedAuthor(),
+		}
+
+		if p := item.GetFeedPublishedAt(); !p.IsZero() {
+			x.PubDate = p.UTC().Format(time.RFC1123Z)
+		}
+
+		feed.Channel.Items = append(feed.Channel.Items, x)
+	}
+
+	return feedBlob(c, resp.GetStatusCode(), MIMEApplicationRSS, &feed)
+}
+
+type atomXML struct {
+	XMLName xml.Name       `xml:"feed"`
+	XMLNS   string         `xml:"xmlns,attr"`
+	Title   string         `xml:"title"`
+	ID      string         `xml:"id"`
+	Updated string         `xml:"updated"`
+	Links   []atomLinkXML  `xml:"link"`
+	Entries []atomEntryXML `xml:"entry"`
+}
+
+type atomLinkXML struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntryXML struct {
+	Title     string         `xml:"title"`
+	ID        string         `xml:"id"`
+	Link      atomLinkXML    `xml:"link"`
+	Updated   string         `xml:"updated"`
+	Published string         `xml:"published,omitempty"`
+	Summary   string         `xml:"summary,omitempty"`
+	Author    *atomAuthorXML `xml:"author,omitempty"`
+}
+
+type atomAuthorXML struct {
+	Name string `xml:"name"`
+}
+
+// AtomFormatter - Render list responses with FeedItem records as Atom
+func AtomFormatter(app App, c echo.Context, r *Route, resp Response) error {
+	items, err := getFeedResponseItems(c, resp)
+	if err != nil {
+		return err
+	}
+
+	ch := newFeedChannel(c, items)
+
+	feed := atomXML{
+		XMLNS:   "http://www.w3.org/2005/Atom",
+		Title:   ch.Title,
+		ID:      ch.Link,
+		Updated: ch.Updated.UTC().Format(time.RFC3339),
+		Links: []atomLinkXML{
+			{Href: ch.Link, Rel: "alternate", Type: "text/html"},
+			{Href: ch.SelfLink, Rel: "self", Type: MIMEApplicationAtom},
+		},
+	}
+
+	for _, item := range items {
+		link := absoluteURL(c, item.GetFeedLink())
+		x := atomEntryXML{
+			Title:   item.GetFeedTitle(),
+			ID:      link,
+			Link:    atomLinkXML{Href: link, Rel: "alternate"},
+			Updated: feedItemUpdatedAt(item).UTC().Format(time.RFC3339),
+			Summary: feedItemSummary(app, item),
+		}
+
+		if p := item.GetFeedPublishedAt(); !p.IsZero() {
+			x.Published = p.UTC().Format(time.RFC3339)
+		}
+
+		if author := item.GetFeedAuthor(); author != "" {
+			x.Author = &atomAuthorXML{Name: author}
+		}
+
+		feed.Entries = append(feed.Entries, x)
+	}
+
+	return feedBlob(c, resp.GetStatusCode(), MIMEApplicationAtom, &feed)
+}
+
+func feedBlob(c echo.Context, code int, contentType string, feed interface{}) error {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+
+	err := xml.NewEncoder(&b).Encode(feed)
+	if err != nil {
+		return err
+	}
+
+	return c.Blob(code, contentType+"; charset=UTF-8", b.Bytes())
+}
@@ -0,0 +1,136 @@
+package bolo_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	bolo "github.com/go-bolo/bolo"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type PostMock struct {
+	ID        string
+	Title     string
+	Body      string
+	Author    string
+	CreatedAt time.Time
+}
+
+func (p *PostMock) GetFeedTitle() string          { return p.Title }
+func (p *PostMock) GetFeedLink() string           { return "/posts/" + p.ID }
+func (p *PostMock) GetFeedSummary() string        { return p.Body }
+func (p *PostMock) GetFeedAuthor() string         { return p.Author }
+func (p *PostMock) GetFeedPublishedAt() time.Time { return p.CreatedAt }
+func (p *PostMock) GetFeedUpdatedAt() time.Time   { return time.Time{} }
+
+type PostsListResponse struct {
+	bolo.BaseListReponse
+	Posts []*PostMock `json:"posts"`
+}
+
+func TestFeedFormatters(t *testing.T) {
+	app := GetTestApp()
+
+	createdAt := time.Date(2023, time.July, 16, 10, 0, 0, 0, time.UTC)
+
+	app.SetRoute("find_posts", &bolo.Route{
+		Method: http.MethodGet,
+		Path:   "/posts",
+		Feed:   true,
+		Action: func(c echo.Context) (bolo.Response, error) {
+			return &bolo.DefaultResponse{Data: &PostsListResponse{
+				Posts: []*PostMock{
+					{ID: "1", Title: "First & best", Body: "<p>Hello <b>world</b></p>", Author: "Alberto", CreatedAt: createdAt},
+					{ID: "2", Title: "Second", Body: "Other", CreatedAt: createdAt.Add(time.Hour)},
+				},
+			}}, nil
+		},
+	})
+	app.SetRoute("findOne_post", &bolo.Route{
+		Method: http.MethodGet,
+		Path:   "/post",
+		Feed:   true,
+		Action: func(c echo.Context) (bolo.Response, error) {
+			return &bolo.DefaultResponse{Data: struct{ Name string }{"oi"}}, nil
+		},
+	})
+	app.SetRoute("find_drafts", &bolo.Route{
+		Method: http.MethodGet,
+		Path:   "/drafts",
+		Action: func(c echo.Context) (bolo.Response, error) {
+			return &bolo.DefaultResponse{Data: &PostsListResponse{
+				Posts: []*PostMock{{ID: "3", Title: "Draft", CreatedAt: createdAt}},
+			}}, nil
+		},
+	})
+
+	err := app.Bootstrap()
+	assert.Nil(t, err)
+
+	request := func(url, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		if accept != "" {
+			req.Header.Set(echo.HeaderAccept, accept)
+		}
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("should render RSS with the .rss suffix", func(t *testing.T) {
+		rec := request("/posts.rss", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/rss+xml; charset=UTF-8", rec.Header().Get(echo.HeaderContentType))
+
+		body := rec.Body.String()
+		assert.Contains(t, body, `<rss version="2.0"`)
+		assert.Contains(t, body, `<atom:link href="http://localhost:8080/posts" rel="self" type="application/rss+xml"></atom:link>`)
+		assert.Contains(t, body, "<title>First &amp; best</title>")
+		assert.Contains(t, body, "<link>http://localhost:8080/posts/1</link>")
+		assert.Contains(t, body, "<description>Hello world</description>")
+		assert.Contains(t, body, "<dc:creator>Alberto</dc:creator>")
+		assert.Contains(t, body, "<pubDate>Sun, 16 Jul 2023 10:00:00 +0000</pubDate>")
+		assert.Contains(t, body, "<lastBuildDate>Sun, 16 Jul 2023 11:00:00 +0000</lastBuildDate>")
+	})
+
+	t.Run("should render Atom with content negotiation", func(t *testing.T) {
+		rec := request("/posts", "application/atom+xml")
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		body := rec.Body.String()
+		assert.Contains(t, body, `<feed xmlns="http://www.w3.org/2005/Atom">`)
+		assert.Contains(t, body, "<updated>2023-07-16T11:00:00Z</updated>")
+		assert.Contains(t, body, `<link href="http://localhost:8080/posts/2" rel="alternate"></link>`)
+		assert.Contains(t, body, "<author><name>Alberto</name></author>")
+	})
+
+	t.Run("should return not acceptable for responses without feed items", func(t *testing.T) {
+		rec := request("/post.atom", "")
+		assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+		assert.Equal(t, echo.MIMEApplicationJSONCharsetUTF8, rec.Header().Get(echo.HeaderContentType))
+		assert.JSONEq(t, `{"code":406,"message":"Not Acceptable"}`, rec.Body.String())
+	})
+
+	t.Run("should not render feeds in routes without Feed", func(t *testing.T) {
+		rec := request("/drafts.rss", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = request("/drafts", "application/rss+xml")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, echo.MIMEApplicationJSONCharsetUTF8, rec.Header().Get(echo.HeaderContentType))
+	})
+
+	t.Run("should keep the suffix of paths outside the app routes", func(t *testing.T) {
+		app.GetRouter().GET("/static/*", func(c echo.Context) error {
+			return c.String(http.StatusOK, c.Request().URL.Path)
+		})
+
+		rec := request("/static/feed.rss", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "/static/feed.rss", rec.Body.String())
+		assert.Equal(t, "text/plain; charset=UTF-8", rec.Header().Get(echo.HeaderContentType))
+	})
+}
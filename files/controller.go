@@ -0,0 +1,287 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-bolo/bolo"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// PermissionManageAll - Allow to manage the files from all users
+const PermissionManageAll = "manage_all_file"
+
+type FileController struct {
+	Plugin *Plugin
+}
+
+type ListJSONResponse struct {
+	bolo.BaseListReponse
+	Files []*File `json:"file"`
+}
+
+type CountJSONResponse struct {
+	bolo.BaseMetaResponse
+}
+
+type FindOneJSONResponse struct {
+	File *File `json:"file"`
+}
+
+// UploadRequest - Multipart create body, limits are checked with the plugin configuration
+type UploadRequest struct {
+	File        *bolo.UploadedFile `form:"file"`
+	Description string             `form:"description"`
+}
+
+type BodyRequest struct {
+	File *FileBody `json:"file"`
+}
+
+type FileBody struct {
+	Name        string `json:"name" validate:"required,max=255"`
+	Description string `json:"description" validate:"max=255"`
+}
+
+func (ctl *FileController) Find(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	limit := bolo.GetLimit(c)
+	if limit <= 0 {
+		limit = 50
+	}
+
+	var count int64
+	records := []*File{}
+
+	query := scopedQuery(c, bolo.GetDB(c).Model(&File{}))
+
+	err := query.Count(&count).Error
+	if err != nil {
+		return nil, fmt.Errorf("FileController.Find: %w", err)
+	}
+
+	err = query.Order("id DESC").Limit(limit).Offset(bolo.GetOffset(c)).Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("FileController.Find: %w", err)
+	}
+
+	for _, record := range records {
+		if err := ctl.setURL(c, record); err != nil {
+			return nil, err
+		}
+	}
+
+	bolo.GetPager(c).Count = count
+
+	resp := ListJSONResponse{Files: records}
+	resp.Meta.Count = count
+
+	return &bolo.DefaultResponse{Data: &resp}, nil
+}
+
+func (ctl *FileController) Count(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	err := scopedQuery(c, bolo.GetDB(c).Model(&File{})).Count(&count).Error
+	if err != nil {
+		return nil, fmt.Errorf("FileController.Count: %w", err)
+	}
+
+	return &bolo.DefaultResponse{
+		Data: &CountJSONResponse{BaseMetaResponse: bolo.BaseMetaResponse{Count: count}},
+	}, nil
+}
+
+func (ctl *FileController) FindOne(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	record, err := loadRecord(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctl.setURL(c, record); err != nil {
+		return nil, err
+	}
+
+	return &bolo.DefaultResponse{Data: &FindOneJSONResponse{File: record}}, nil
+}
+
+// Create - Upload one file with one multipart/form-data body
+func (ctl *FileController) Create(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	body := UploadRequest{}
+	if err := c.Bind(&body); err != nil {
+		var he *bolo.HTTPError
+		if errors.As(err, &he) {
+			return nil, he
+		}
+
+		return nil, &bolo.HTTPError{Code: http.StatusBadRequest, Message: "Invalid body data", Internal: err}
+	}
+
+	if body.File == nil {
+		return nil, &bolo.HTTPError{Code: http.StatusBadRequest, Message: "file is required"}
+	}
+
+	if err := ctl.Plugin.Limits.Check(body.File); err != nil {
+		return nil, err
+	}
+
+	app := bolo.GetApp(c)
+
+	record, err := Upload(c.Request().Context(), app, bolo.GetAuthenticatedUser(c).GetID(), body.File)
+	if err != nil {
+		return nil, fmt.Errorf("FileController.Create: %w", err)
+	}
+
+	if body.Description != "" {
+		record.Description = body.Description
+		if err := record.Save(app); err != nil {
+			return nil, fmt.Errorf("FileController.Create: %w", err)
+		}
+	}
+
+	if err := ctl.setURL(c, record); err != nil {
+		return nil, err
+	}
+
+	return &bolo.DefaultResponse{
+		Status: http.StatusCreated,
+		Data:   &FindOneJSONResponse{File: record},
+	}, nil
+}
+
+// Update - Only the name and description are updated, upload one new file to change the content
+func (ctl *FileController) Update(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	record, err := loadRecord(c)
+	if err != nil {
+		return nil, err
+	}
+
+	body := BodyRequest{}
+	if err := c.Bind(&body); err != nil {
+		return nil, &bolo.HTTPError{Code: http.StatusBadRequest, Message: "Invalid body data", Internal: err}
+	}
+
+	if body.File == nil {
+		return nil, &bolo.HTTPError{Code: http.StatusBadRequest, Message: "file is required"}
+	}
+
+	if err := c.Validate(body.File); err != nil {
+		return nil, err
+	}
+
+	record.Name = body.File.Name
+	record.Description = body.File.Description
+
+	err = record.Save(bolo.GetApp(c))
+	if err != nil {
+		return nil, fmt.Errorf("FileController.Update: %w", err)
+	}
+
+	if err := ctl.setURL(c, record); err != nil {
+		return nil, err
+	}
+
+	return &bolo.DefaultResponse{Data: &FindOneJSONResponse{File: record}}, nil
+}
+
+func (ctl *FileController) Delete(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	record, err := loadRecord(c)
+	if err != nil {
+		return nil, err
+	}
+
+	err = record.Delete(c.Request().Context(), bolo.GetApp(c))
+	if err != nil {
+		return nil, fmt.Errorf("FileController.Delete: %w", err)
+	}
+
+	return &bolo.DefaultResponse{Status: http.StatusNoContent}, nil
+}
+
+// Download - Redirect to one signed URL of the file
+func (ctl *FileController) Download(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	record, err := loadRecord(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctl.setURL(c, record); err != nil {
+		return nil, err
+	}
+
+	return nil, c.Redirect(http.StatusFound, record.URL)
+}
+
+func (ctl *FileController) setURL(c echo.Context, record *File) error {
+	u, err := record.SignedURL(c.Request().Context(), bolo.GetApp(c), ctl.Plugin.URLExpires)
+	if err != nil {
+		return fmt.Errorf("FileController: %w", err)
+	}
+
+	record.URL = u
+	return nil
+}
+
+// scopedQuery - Users only access their own files
+func scopedQuery(c echo.Context, query *gorm.DB) *gorm.DB {
+	if bolo.Can(c, PermissionManageAll) {
+		return query
+	}
+
+	return query.Where("userId = ?", bolo.GetAuthenticatedUser(c).GetID())
+}
+
+func loadRecord(c echo.Context) (*File, error) {
+	record := File{}
+	err := scopedQuery(c, bolo.GetDB(c)).First(&record, "id = ?", c.Param("id")).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &bolo.HTTPError{Code: http.StatusNotFound, Message: "Not found", Internal: err}
+		}
+
+		return nil, fmt.Errorf("FileController.loadRecord: %w", err)
+	}
+
+	return &record, nil
+}
+
+func checkAccess(c echo.Context) error {
+	if !bolo.IsAuthenticated(c) {
+		return &bolo.HTTPError{Code: http.StatusUnauthorized, Message: "Unauthorized"}
+	}
+
+	route := bolo.GetRoute(c)
+	if route.Permission != "" && !bolo.Can(c, route.Permission) {
+		return &bolo.HTTPError{Code: http.StatusForbidden, Message: "Forbidden"}
+	}
+
+	return nil
+}
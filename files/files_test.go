@@ -0,0 +1,226 @@
+package files_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/go-bolo/bolo/acl"
+	"github.com/go-bolo/bolo/files"
+	"github.com/go-bolo/bolo/users"
+	"github.com/go-bolo/clock"
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pngContent = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 64)...)
+
+func newTestApp(t *testing.T) (bolo.App, string) {
+	dir := t.TempDir()
+
+	os.Setenv("TEMPLATE_DISABLE", "true")
+	os.Setenv("DB_URI", filepath.Join(dir, "files.sqlite"))
+	os.Setenv("STORAGE_LOCAL_DIR", filepath.Join(dir, "uploads"))
+	os.Setenv("STORAGE_SIGNING_SECRET", "secret")
+	os.Setenv("FILES_MAX_SIZE", "1KB")
+	t.Cleanup(func() {
+		os.Unsetenv("TEMPLATE_DISABLE")
+		os.Unsetenv("DB_URI")
+		os.Unsetenv("STORAGE_LOCAL_DIR")
+		os.Unsetenv("STORAGE_SIGNING_SECRET")
+		os.Unsetenv("FILES_MAX_SIZE")
+	})
+
+	app := bolo.NewApp(&bolo.DefaultAppOptions{})
+
+	c := clock.NewMock()
+	now, _ := time.Parse("2006-01-02", "2023-07-16")
+	c.Set(now)
+	app.SetClock(c)
+
+	app.AddPlugin(files.NewPlugin(&files.PluginOpts{}))
+
+	// authenticate the requests with the X-Test-User header:
+	app.GetEvents().On("bindMiddlewares", event.ListenerFunc(func(e event.Event) error {
+		app.GetRouter().Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				if id := c.Request().Header.Get("X-Test-User"); id != "" {
+					u := users.NewUser(app)
+					u.ID = 1
+					if id == "2" {
+						u.ID = 2
+					}
+					bolo.SetAuthenticatedUser(c, u)
+					bolo.SetRoles(c, []string{"authenticated"})
+				}
+				return next(c)
+			}
+		})
+		return nil
+	}), event.Low)
+
+	require.Nil(t, app.Bootstrap())
+	require.Nil(t, app.SyncDB())
+
+	app.GetAcl().SetRole("authenticated", acl.Role{
+		Name: "authenticated",
+		Permissions: []string{
+			"find_file", "findOne_file", "create_file", "update_file", "delete_file", "download_file",
+		},
+	})
+
+	return app, filepath.Join(dir, "uploads")
+}
+
+func upload(t *testing.T, app bolo.App, filename string, content []byte, userID string) *httptest.ResponseRecorder {
+	body := bytes.Buffer{}
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("file", filename)
+	require.Nil(t, err)
+	part.Write(content)
+	w.WriteField("description", "One test file")
+	require.Nil(t, w.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/files", &body)
+	req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+	req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+	// multipart bodies can be sent cross site, the CSRF token is required:
+	req.AddCookie(&http.Cookie{Name: bolo.CSRFCookieName, Value: "token"})
+	req.Header.Set(bolo.CSRFHeaderName, "token")
+	if userID != "" {
+		req.Header.Set("X-Test-User", userID)
+	}
+
+	rec := httptest.NewRecorder()
+	app.GetRouter().ServeHTTP(rec, req)
+	return rec
+}
+
+func do(app bolo.App, method, path, body, userID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+	if userID != "" {
+		req.Header.Set("X-Test-User", userID)
+	}
+
+	rec := httptest.NewRecorder()
+	app.GetRouter().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestFileResource(t *testing.T) {
+	app, dir := newTestApp(t)
+
+	t.Run("Should require authentication", func(t *testing.T) {
+		rec := upload(t, app, "image.png", pngContent, "")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("Should reject types not allowed and large files", func(t *testing.T) {
+		rec := upload(t, app, "page.png", []byte("<html><body>not one image</body></html>"), "1")
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+
+		rec = upload(t, app, "large.png", append(pngContent, bytes.Repeat([]byte{1}, 2048)...), "1")
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+		var count int64
+		app.GetDB().Model(&files.File{}).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	rec := upload(t, app, "../image.png", pngContent, "1")
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	created := files.FindOneJSONResponse{}
+	require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &created))
+
+	sum := sha256.Sum256(pngContent)
+	record := created.File
+	assert.Equal(t, "1", record.UserID)
+	assert.Equal(t, "image.png", record.Name)
+	assert.Equal(t, "One test file", record.Description)
+	assert.Equal(t, "image/png", record.MIME)
+	assert.Equal(t, int64(len(pngContent)), record.Size)
+	assert.Equal(t, hex.EncodeToString(sum[:]), record.SHA256)
+	assert.True(t, strings.HasPrefix(record.Key, "2023/07/"))
+	assert.True(t, strings.HasSuffix(record.Key, ".png"))
+	assert.True(t, strings.HasPrefix(record.URL, "/storage/2023/07/"))
+
+	stored, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(record.Key)))
+	require.Nil(t, err)
+	assert.Equal(t, pngContent, stored)
+
+	t.Run("Should serve the signed URL", func(t *testing.T) {
+		rec := do(app, http.MethodGet, record.URL, "", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, pngContent, rec.Body.Bytes())
+
+		u, _ := url.Parse(record.URL)
+		rec = do(app, http.MethodGet, u.Path, "", "")
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+
+	t.Run("Should redirect the download to the signed URL", func(t *testing.T) {
+		rec := do(app, http.MethodGet, "/api/v1/files/"+record.GetID()+"/download", "", "1")
+		assert.Equal(t, http.StatusFound, rec.Code)
+		assert.True(t, strings.HasPrefix(rec.Header().Get("Location"), "/storage/"+record.Key+"?expires="))
+	})
+
+	t.Run("Should only list the user files", func(t *testing.T) {
+		rec := do(app, http.MethodGet, "/api/v1/files", "", "1")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		list := files.ListJSONResponse{}
+		require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &list))
+		assert.Equal(t, int64(1), list.Meta.Count)
+		assert.Len(t, list.Files, 1)
+
+		rec = do(app, http.MethodGet, "/api/v1/files", "", "2")
+		require.Equal(t, http.StatusOK, rec.Code)
+		list = files.ListJSONResponse{}
+		require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &list))
+		assert.Equal(t, int64(0), list.Meta.Count)
+
+		rec = do(app, http.MethodGet, "/api/v1/files/"+record.GetID(), "", "2")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("Should update the metadata", func(t *testing.T) {
+		rec := do(app, http.MethodPost, "/api/v1/files/"+record.GetID(), `{"file":{"name":"logo.png","description":"Logo"}}`, "1")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		updated, err := files.FindOne(app, record.GetID())
+		require.Nil(t, err)
+		assert.Equal(t, "logo.png", updated.Name)
+		assert.Equal(t, "Logo", updated.Description)
+		assert.Equal(t, record.Key, updated.Key)
+	})
+
+	t.Run("Should delete the record and the object", func(t *testing.T) {
+		rec := do(app, http.MethodDelete, "/api/v1/files/"+record.GetID(), "", "2")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = do(app, http.MethodDelete, "/api/v1/files/"+record.GetID(), "", "1")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		_, err := files.FindOne(app, record.GetID())
+		assert.ErrorIs(t, err, files.ErrFileNotFound)
+
+		_, err = os.Stat(filepath.Join(dir, filepath.FromSlash(record.Key)))
+		assert.True(t, os.IsNotExist(err))
+	})
+}
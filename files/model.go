@@ -0,0 +1,163 @@
+package files
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/go-bolo/bolo/storage"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrFileNotFound = errors.New("file not found")
+)
+
+// File - Metadata of one file in the app storage, content plugins reference files by ID
+type File struct {
+	ID     uint64 `gorm:"primary_key;column:id;" json:"id"`
+	UserID string `gorm:"column:userId;type:varchar(64);index" json:"userId"`
+	// Storage key
+	Key string `gorm:"column:storageKey;type:varchar(255);uniqueIndex" json:"key"`
+	// Original file name
+	Name        string `gorm:"column:name;type:varchar(255)" json:"name"`
+	Description string `gorm:"column:description;type:varchar(255)" json:"description"`
+	// Type detected from the content
+	MIME      string    `gorm:"column:mime;type:varchar(100)" json:"mime"`
+	Size      int64     `gorm:"column:size" json:"size"`
+	SHA256    string    `gorm:"column:sha256;type:varchar(64)" json:"sha256"`
+	CreatedAt time.Time `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"column:updatedAt" json:"updatedAt"`
+
+	// Signed URL, set in the API responses
+	URL string `gorm:"-" json:"url,omitempty"`
+}
+
+func (r *File) TableName() string {
+	return "files"
+}
+
+func (r *File) GetID() string {
+	return strconv.FormatUint(r.ID, 10)
+}
+
+func (r *File) LoadData() error {
+	return nil
+}
+
+func (r *File) LoadTeaserData() error {
+	return nil
+}
+
+func (r *File) Save(app bolo.App) error {
+	now := app.GetClock().Now()
+	r.UpdatedAt = now
+
+	if r.ID == 0 {
+		r.CreatedAt = now
+		return app.GetDB().Create(r).Error
+	}
+
+	return app.GetDB().Save(r).Error
+}
+
+// SignedURL - Temporary URL to download the file
+func (r *File) SignedURL(ctx context.Context, app bolo.App, expires time.Duration) (string, error) {
+	return app.GetStorage().SignedURL(ctx, r.Key, expires)
+}
+
+// Open - Read the file content from the storage
+func (r *File) Open(ctx context.Context, app bolo.App) (io.ReadCloser, error) {
+	return app.GetStorage().Get(ctx, r.Key)
+}
+
+// Delete - Delete the record and the storage object
+func (r *File) Delete(ctx context.Context, app bolo.App) error {
+	if err := app.GetDB().Delete(r).Error; err != nil {
+		return fmt.Errorf("File.Delete: %w", err)
+	}
+
+	// orphan objects only waste space, the record is already deleted
+	if err := app.GetStorage().Delete(ctx, r.Key); err != nil {
+		app.GetLogger().Warn("File.Delete: error on delete the storage object", zap.Error(err), zap.String("key", r.Key))
+	}
+
+	return nil
+}
+
+// Upload - Store one uploaded file and save its record, the object is removed if the record is not saved
+func Upload(ctx context.Context, app bolo.App, userID string, f *bolo.UploadedFile) (*File, error) {
+	src, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("files.Upload: %w", err)
+	}
+	defer src.Close()
+
+	now := app.GetClock().Now()
+	record := File{
+		UserID: userID,
+		Key:    now.Format("2006/01/") + uuid.New().String() + extension(f.ContentType),
+		Name:   f.Filename,
+		MIME:   f.ContentType,
+		Size:   f.Size,
+	}
+
+	h := sha256.New()
+	err = app.GetStorage().Put(ctx, record.Key, io.TeeReader(src, h), &storage.PutOptions{ContentType: f.ContentType, Size: f.Size})
+	if err != nil {
+		return nil, fmt.Errorf("files.Upload: %w", err)
+	}
+	record.SHA256 = hex.EncodeToString(h.Sum(nil))
+
+	if err := record.Save(app); err != nil {
+		app.GetStorage().Delete(ctx, record.Key)
+		return nil, fmt.Errorf("files.Upload: %w", err)
+	}
+
+	return &record, nil
+}
+
+func FindOne(app bolo.App, id string) (*File, error) {
+	record := File{}
+	err := app.GetDB().First(&record, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFileNotFound
+		}
+
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// extension - Storage key extension from the detected type, never from the client file name
+func extension(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch mediaType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	case "application/pdf":
+		return ".pdf"
+	case "text/plain":
+		return ".txt"
+	}
+
+	return ""
+}
@@ -0,0 +1,89 @@
+package files
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-bolo/bolo"
+)
+
+// Plugin - Uploaded files stored in the app storage, with one file resource for the metadata.
+// Content plugins reference the files by ID and link them with File.SignedURL
+type Plugin struct {
+	bolo.Plugin
+
+	Name       string
+	App        bolo.App
+	Controller *FileController
+	// Size and types allowed in the file resource uploads
+	Limits *bolo.UploadLimits
+	// Duration of the signed URLs in the API responses
+	URLExpires time.Duration
+}
+
+type PluginOpts struct {
+	Limits *bolo.UploadLimits
+}
+
+func NewPlugin(opts *PluginOpts) *Plugin {
+	return &Plugin{
+		Name:   "files",
+		Limits: opts.Limits,
+	}
+}
+
+func (p *Plugin) GetName() string {
+	return p.Name
+}
+
+func (p *Plugin) Init(app bolo.App) error {
+	app.GetLogger().Debug(p.GetName() + " Init")
+
+	if app.GetStorage() == nil {
+		return errors.New("files: the app storage is not configured, check the STORAGE_* configurations")
+	}
+
+	p.App = app
+	cfg := app.GetConfiguration()
+
+	if p.Limits == nil {
+		maxSize, err := bolo.ParseByteSize(cfg.GetF("FILES_MAX_SIZE", "10MB"))
+		if err != nil {
+			return err
+		}
+
+		p.Limits = &bolo.UploadLimits{
+			MaxSize: maxSize,
+			Types:   strings.Split(cfg.GetF("FILES_ALLOWED_TYPES", "image/jpeg|image/png|image/gif|image/webp|application/pdf|text/plain"), "|"),
+		}
+	}
+
+	if p.URLExpires == 0 {
+		p.URLExpires = time.Duration(cfg.GetInt64F("FILES_URL_EXPIRES", 3600)) * time.Second
+	}
+
+	p.Controller = &FileController{Plugin: p}
+
+	app.SetModel("file", &File{})
+
+	app.SetResource(&bolo.Resource{
+		Name:       "file",
+		Prefix:     "/api/v1",
+		Path:       "/files",
+		Controller: p.Controller,
+		Model:      &File{},
+		// files don't have HTML pages
+		SkipHTMLEndpoints: true,
+	})
+
+	app.SetRoute("download_file", &bolo.Route{
+		Method:     http.MethodGet,
+		Path:       "/api/v1/files/:id/download",
+		Action:     p.Controller.Download,
+		Permission: "download_file",
+	})
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package bolo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-bolo/bolo/helpers"
+	"github.com/go-bolo/bolo/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cast"
+)
+
+// InitTimezone - Validate and set the SITE_TIMEZONE used by the date helpers. Ran once at bootstrap
+func InitTimezone(app App) error {
+	timeZone := app.GetConfiguration().GetF(SITE_TIMEZONE, "")
+
+	loc, err := helpers.LoadLocation(timeZone)
+	if err != nil {
+		return fmt.Errorf("InitTimezone: invalid %s %s: %w", SITE_TIMEZONE, timeZone, err)
+	}
+
+	helpers.SetLocation(loc)
+
+	return nil
+}
+
+// GetTimezone - Returns the current request timezone from the timezone cookie or the site timezone
+func GetTimezone(c echo.Context) *time.Location {
+	if loc, ok := c.Get("timezone").(*time.Location); ok && loc != nil {
+		return loc
+	}
+
+	loc := helpers.GetLocation()
+
+	cookieName := GetApp(c).GetConfiguration().GetF(TIMEZONE_COOKIE_NAME, "timezone")
+	if cookie, err := c.Cookie(cookieName); err == nil && cookie.Value != "" {
+		if cookieLoc, err := helpers.LoadLocation(cookie.Value); err == nil {
+			loc = cookieLoc
+		}
+	}
+
+	SetTimezone(c, loc)
+
+	return loc
+}
+
+func SetTimezone(c echo.Context, loc *time.Location) {
+	c.Set("timezone", loc)
+}
+
+// FormatNumber - Format one number with the request locale separators, places < 0 keeps all decimal places
+func FormatNumber(c echo.Context, value interface{}, places int) string {
+	return i18n.FormatDecimal(GetLocale(c), toDecimal(value), int32(places))
+}
+
+// FormatCurrency - Format one amount in the request locale with the currency symbol. Ex: R$ 1.234,50
+func FormatCurrency(c echo.Context, value interface{}, currencyCode string) string {
+	return i18n.FormatCurrency(GetLocale(c), toDecimal(value), currencyCode)
+}
+
+// FormatPercent - Format one ratio as percentage in the request locale. Ex: 0.25 -> 25%
+func FormatPercent(c echo.Context, value interface{}, places int) string {
+	return i18n.FormatPercent(GetLocale(c), toDecimal(value), int32(places))
+}
+
+// FormatDate - Format one date in the request locale and timezone.
+// Style can be short, medium, long, full, time, datetime or a Go layout
+func FormatDate(c echo.Context, date interface{}, style string) string {
+	t, ok := toTime(date)
+	if !ok {
+		return ""
+	}
+
+	return i18n.FormatDate(GetApp(c).GetI18n(), GetLocale(c), t.In(GetTimezone(c)), style)
+}
+
+// FormatRelativeTime - Format the distance between the date and the app clock time. Ex: 3 days ago
+func FormatRelativeTime(c echo.Context, date interface{}) string {
+	t, ok := toTime(date)
+	if !ok {
+		return ""
+	}
+
+	app := GetApp(c)
+	return i18n.FormatRelativeTime(app.GetI18n(), GetLocale(c), t, app.GetClock().Now())
+}
+
+func toDecimal(value interface{}) decimal.Decimal {
+	switch v := value.(type) {
+	case decimal.Decimal:
+		return v
+	case *decimal.Decimal:
+		if v == nil {
+			return decimal.Zero
+		}
+		return *v
+	case float32:
+		return decimal.NewFromFloat32(v)
+	case float64:
+		return decimal.NewFromFloat(v)
+	case string:
+		d, err := decimal.NewFromString(v)
+		if err != nil {
+			return decimal.Zero
+		}
+		return d
+	default:
+		return decimal.NewFromInt(cast.ToInt64(v))
+	}
+}
+
+func toTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, !v.IsZero()
+	case *time.Time:
+		if v == nil || v.IsZero() {
+			return time.Time{}, false
+		}
+		return *v, true
+	default:
+		return time.Time{}, false
+	}
+}
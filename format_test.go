@@ -0,0 +1,48 @@
+package bolo_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	bolo "github.com/go-bolo/bolo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitTimezone(t *testing.T) {
+	app := GetTestApp()
+
+	os.Setenv("SITE_TIMEZONE", "Invalid/Zone")
+	defer os.Unsetenv("SITE_TIMEZONE")
+
+	err := bolo.InitTimezone(app)
+	assert.NotNil(t, err)
+
+	os.Setenv("SITE_TIMEZONE", "America/Sao_Paulo")
+	err = bolo.InitTimezone(app)
+	assert.Nil(t, err)
+}
+
+func TestFormatDate(t *testing.T) {
+	os.Setenv("SITE_TIMEZONE", "America/Sao_Paulo")
+	defer os.Unsetenv("SITE_TIMEZONE")
+
+	app := GetTestApp()
+	err := app.Bootstrap()
+	assert.Nil(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", "pt-BR")
+	c := app.GetRouter().NewContext(req, httptest.NewRecorder())
+	bolo.SetDefaultValues(c, app)
+
+	date := time.Date(2023, time.July, 14, 1, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, "13/07/2023 22:30", bolo.FormatDate(c, date, "datetime"))
+	assert.Equal(t, "", bolo.FormatDate(c, nil, "short"))
+	assert.Equal(t, "há 1 dia", bolo.FormatRelativeTime(c, &date))
+	assert.Equal(t, "R$\u00a010,00", bolo.FormatCurrency(c, 10, "BRL"))
+	assert.Equal(t, "1.000,5", bolo.FormatNumber(c, 1000.5, -1))
+}
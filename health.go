@@ -0,0 +1,211 @@
+package bolo
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	HealthStatusOK           = "ok"
+	HealthStatusError        = "error"
+	HealthStatusTimeout      = "timeout"
+	HealthStatusShuttingDown = "shutting_down"
+)
+
+// HealthChecker - One readiness check registered by the app or plugins
+type HealthChecker interface {
+	CheckHealth(ctx context.Context, app App) error
+}
+
+// HealthCheckerFunc - Function adapter for HealthChecker
+type HealthCheckerFunc func(ctx context.Context, app App) error
+
+func (f HealthCheckerFunc) CheckHealth(ctx context.Context, app App) error {
+	return f(ctx, app)
+}
+
+// HealthCheckResult - Status of one check in the readiness response
+type HealthCheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// HealthResponse - /health/live and /health/ready response body
+type HealthResponse struct {
+	Status string                        `json:"status"`
+	Checks map[string]*HealthCheckResult `json:"checks,omitempty"`
+}
+
+// DBHealthCheck - Ping the database with the name
+func DBHealthCheck(dbName string) HealthChecker {
+	return HealthCheckerFunc(func(ctx context.Context, app App) error {
+		db := app.GetDBByName(dbName)
+		if db == nil {
+			return fmt.Errorf("database %s not found", dbName)
+		}
+
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+
+		return sqlDB.PingContext(ctx)
+	})
+}
+
+// TemplatesHealthCheck - Check if the templates are loaded, skipped with TEMPLATE_DISABLE
+func TemplatesHealthCheck(ctx context.Context, app App) error {
+	if app.GetConfiguration().GetBool(TEMPLATE_DISABLE) {
+		return nil
+	}
+
+	tpls := app.GetTemplates()
+	if tpls == nil || len(tpls.Templates()) == 0 {
+		return errors.New("templates not loaded")
+	}
+
+	return nil
+}
+
+// TablesHealthCheck - Report the registered models without table in the default database
+func TablesHealthCheck(ctx context.Context, app App) error {
+	db := app.GetDB()
+	if db == nil {
+		return errors.New("default database not found")
+	}
+
+	migrator := db.WithContext(ctx).Migrator()
+
+	missing := []string{}
+	for name, m := range app.GetModels() {
+		if !migrator.HasTable(m) {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("missing tables for models: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// RunHealthChecks - Run all the app checks in parallel, each one with the timeout
+func RunHealthChecks(ctx context.Context, app App, timeout time.Duration) *HealthResponse {
+	checks := app.GetHealthChecks()
+
+	res := HealthResponse{
+		Status: HealthStatusOK,
+		Checks: make(map[string]*HealthCheckResult, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for name, check := range checks {
+		wg.Add(1)
+
+		go func(name string, check HealthChecker) {
+			defer wg.Done()
+
+			r := runHealthCheck(ctx, app, check, timeout)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			res.Checks[name] = r
+			if r.Status != HealthStatusOK {
+				res.Status = HealthStatusError
+			}
+		}(name, check)
+	}
+
+	wg.Wait()
+
+	return &res
+}
+
+func runHealthCheck(ctx context.Context, app App, check HealthChecker, timeout time.Duration) *HealthCheckResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	// buffered to not block the check goroutine after one timeout
+	done := make(chan error, 1)
+
+	go func() {
+		done <- check.CheckHealth(ctx, app)
+	}()
+
+	r := HealthCheckResult{Status: HealthStatusOK}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			r.Status = HealthStatusError
+			r.Error = err.Error()
+		}
+	case <-ctx.Done():
+		r.Status = HealthStatusTimeout
+		r.Error = ctx.Err().Error()
+	}
+
+	r.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+
+	return &r
+}
+
+// HealthLiveHandler - Liveness probe, the process is running and can answer requests
+func HealthLiveHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, &HealthResponse{Status: HealthStatusOK})
+}
+
+// HealthReadyHandler - Readiness probe, runs the registered checks. Returns 503 if one check fails or during shutdown.
+// The checks are only in the response of requests with the HEALTH_TOKEN bearer token, the failures are logged
+func HealthReadyHandler(c echo.Context) error {
+	app := GetApp(c)
+
+	if app.IsShuttingDown() {
+		return c.JSON(http.StatusServiceUnavailable, &HealthResponse{Status: HealthStatusShuttingDown})
+	}
+
+	timeout := time.Duration(app.GetConfiguration().GetInt64F(HEALTH_CHECK_TIMEOUT, 2000)) * time.Millisecond
+
+	res := RunHealthChecks(c.Request().Context(), app, timeout)
+	for name, r := range res.Checks {
+		if r.Status != HealthStatusOK {
+			app.GetLogger().Warn("health check failed", zap.String("check", name), zap.String("status", r.Status), zap.String("error", r.Error))
+		}
+	}
+
+	if !canSeeHealthChecks(c, app.GetConfiguration().Get(HEALTH_TOKEN)) {
+		res = &HealthResponse{Status: res.Status}
+	}
+
+	if res.Status != HealthStatusOK {
+		return c.JSON(http.StatusServiceUnavailable, res)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+func canSeeHealthChecks(c echo.Context, token string) bool {
+	if token == "" {
+		return false
+	}
+
+	auth := c.Request().Header.Get(echo.HeaderAuthorization)
+	return subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+token)) == 1
+}
@@ -0,0 +1,106 @@
+package bolo_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	bolo "github.com/go-bolo/bolo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthChecks(t *testing.T) {
+	os.Setenv("HEALTH_CHECK_TIMEOUT", "50")
+	os.Setenv("HEALTH_TOKEN", "health-token")
+	defer os.Unsetenv("HEALTH_CHECK_TIMEOUT")
+	defer os.Unsetenv("HEALTH_TOKEN")
+
+	app := GetTestApp()
+	app.AddPlugin(&URLShortenerPlugin{Name: "url-shortener"})
+	require.Nil(t, app.Bootstrap())
+
+	request := func(url string) (*httptest.ResponseRecorder, *bolo.HealthResponse) {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("Authorization", "Bearer health-token")
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		res := bolo.HealthResponse{}
+		require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		return rec, &res
+	}
+
+	t.Run("should return ok in the liveness check", func(t *testing.T) {
+		rec, res := request("/health/live")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "ok", res.Status)
+	})
+
+	t.Run("should report the models without tables", func(t *testing.T) {
+		rec, res := request("/health/ready")
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "error", res.Status)
+		assert.Equal(t, "ok", res.Checks["db:default"].Status)
+		assert.Equal(t, "ok", res.Checks["templates"].Status)
+		assert.Equal(t, "error", res.Checks["tables"].Status)
+		assert.Equal(t, "missing tables for models: url", res.Checks["tables"].Error)
+	})
+
+	t.Run("should only return the status without the token", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/health/ready", nil)
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.JSONEq(t, `{"status":"error"}`, rec.Body.String())
+	})
+
+	require.Nil(t, app.SyncDB())
+
+	t.Run("should be ready with all checks ok", func(t *testing.T) {
+		rec, res := request("/health/ready")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "ok", res.Status)
+		assert.Len(t, res.Checks, 3)
+	})
+
+	t.Run("should fail with plugin checks errors and timeouts", func(t *testing.T) {
+		app.AddHealthCheck("queue", bolo.HealthCheckerFunc(func(ctx context.Context, app bolo.App) error {
+			return errors.New("queue unavailable")
+		}))
+		app.AddHealthCheck("hung", bolo.HealthCheckerFunc(func(ctx context.Context, app bolo.App) error {
+			time.Sleep(time.Second)
+			return nil
+		}))
+
+		start := time.Now()
+		rec, res := request("/health/ready")
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "queue unavailable", res.Checks["queue"].Error)
+		assert.Equal(t, "timeout", res.Checks["hung"].Status)
+		assert.Equal(t, "ok", res.Checks["db:default"].Status)
+
+		delete(app.GetHealthChecks(), "queue")
+		delete(app.GetHealthChecks(), "hung")
+	})
+
+	t.Run("should not be ready during shutdown", func(t *testing.T) {
+		require.Nil(t, app.Shutdown(context.Background()))
+		assert.True(t, app.IsShuttingDown())
+
+		rec, res := request("/health/ready")
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "shutting_down", res.Status)
+
+		rec, _ = request("/health/live")
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
@@ -0,0 +1,392 @@
+package helpers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	ErrArchiveFormat       = errors.New("archive: unsupported format")
+	ErrArchiveTooLarge     = errors.New("archive: uncompressed size over the limit")
+	ErrArchiveTooManyFiles = errors.New("archive: too many entries")
+	ErrArchiveRatio        = errors.New("archive: compression ratio over the limit")
+	ErrArchiveInvalidPath  = errors.New("archive: invalid entry path")
+	ErrArchiveInvalidEntry = errors.New("archive: only files and directories are allowed")
+)
+
+type ArchiveFormat string
+
+const (
+	ArchiveZip    ArchiveFormat = "zip"
+	ArchiveTar    ArchiveFormat = "tar"
+	ArchiveTarGz  ArchiveFormat = "tar.gz"
+	ArchiveTarZst ArchiveFormat = "tar.zst"
+)
+
+const (
+	DefaultArchiveMaxSize  = 100 << 20
+	DefaultArchiveMaxFiles = 10000
+	DefaultArchiveMaxRatio = 100
+	// small archives of text files compress a lot, the ratio is checked after this size
+	archiveRatioMinSize = 1 << 20
+	// max zstd window, larger windows need more memory than one archive upload is allowed to use
+	archiveZstdMaxWindow = 64 << 20
+)
+
+// ExtractOptions - Archive extraction limits, zero values use the defaults
+type ExtractOptions struct {
+	// Format of the archive, empty detects the format from the content
+	Format ArchiveFormat
+	// Max uncompressed bytes of all files
+	MaxSize int64
+	// Max entries, including directories
+	MaxFiles int
+	// Max uncompressed bytes for each compressed byte of the archive
+	MaxRatio float64
+}
+
+// Extract - Extract one zip, tar, tar.gz or tar.zst archive in the dest directory.
+// Only regular files and directories are extracted, entries with links, absolute paths or paths outside dest are
+// rejected. Returns the extracted paths, with the overwritten files and the existing directories of the archive.
+// On errors the files and directories created by the extraction are removed
+func Extract(ctx context.Context, src, dest string, opts *ExtractOptions) ([]string, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	e := extractor{ctx: ctx, dest: filepath.Clean(dest), compressed: info.Size()}
+	if opts != nil {
+		e.opts = *opts
+	}
+	if e.opts.MaxSize <= 0 {
+		e.opts.MaxSize = DefaultArchiveMaxSize
+	}
+	if e.opts.MaxFiles <= 0 {
+		e.opts.MaxFiles = DefaultArchiveMaxFiles
+	}
+	if e.opts.MaxRatio <= 0 {
+		e.opts.MaxRatio = DefaultArchiveMaxRatio
+	}
+
+	format := e.opts.Format
+	if format == "" {
+		format, err = DetectArchiveFormat(f)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.MkdirAll(e.dest, 0o755); err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case ArchiveZip:
+		err = e.extractZip(f, info.Size())
+	case ArchiveTar:
+		err = e.extractTar(f)
+	case ArchiveTarGz:
+		var gz *gzip.Reader
+		gz, err = gzip.NewReader(bufio.NewReader(f))
+		if err == nil {
+			defer gz.Close()
+			err = e.extractTar(gz)
+		}
+	case ArchiveTarZst:
+		var zr *zstd.Decoder
+		zr, err = zstd.NewReader(bufio.NewReader(f), zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(archiveZstdMaxWindow))
+		if err == nil {
+			defer zr.Close()
+			err = e.extractTar(zr)
+		}
+	default:
+		err = fmt.Errorf("%w: %s", ErrArchiveFormat, format)
+	}
+
+	if err != nil {
+		e.cleanup()
+		return nil, err
+	}
+
+	return e.paths, nil
+}
+
+// DetectArchiveFormat - Detect the archive format from the first bytes, the reader is read from the start
+func DetectArchiveFormat(r io.ReaderAt) (ArchiveFormat, error) {
+	head := make([]byte, 512)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return ArchiveZip, nil
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return ArchiveTarGz, nil
+	case bytes.HasPrefix(head, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return ArchiveTarZst, nil
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return ArchiveTar, nil
+	}
+
+	return "", ErrArchiveFormat
+}
+
+type extractor struct {
+	ctx        context.Context
+	dest       string
+	opts       ExtractOptions
+	compressed int64
+	written    int64
+	entries    int
+	// files and directories of the archive, created or existing, in extraction order
+	paths []string
+	// files and directories created by the extraction, removed on errors
+	created []string
+}
+
+func (e *extractor) extractZip(r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrArchiveFormat, err)
+	}
+
+	// the headers can lie, the written bytes are also counted
+	if len(zr.File) > e.opts.MaxFiles {
+		return ErrArchiveTooManyFiles
+	}
+	var declared uint64
+	for _, f := range zr.File {
+		declared += f.UncompressedSize64
+		if declared > uint64(e.opts.MaxSize) {
+			return ErrArchiveTooLarge
+		}
+	}
+
+	for _, f := range zr.File {
+		mode := f.Mode()
+
+		switch {
+		case mode.IsDir():
+			if err := e.dir(f.Name); err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			err = e.file(f.Name, rc, mode)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%w: %s", ErrArchiveInvalidEntry, f.Name)
+		}
+	}
+
+	return nil
+}
+
+func (e *extractor) extractTar(r io.Reader) error {
+	tr := tar.NewReader(r)
+
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch h.Typeflag {
+		case tar.TypeDir:
+			err = e.dir(h.Name)
+		case tar.TypeReg:
+			err = e.file(h.Name, tr, h.FileInfo().Mode())
+		case tar.TypeXGlobalHeader:
+			continue
+		default:
+			err = fmt.Errorf("%w: %s", ErrArchiveInvalidEntry, h.Name)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// path - Path in dest of one entry name
+func (e *extractor) path(name string) (string, error) {
+	if err := e.ctx.Err(); err != nil {
+		return "", err
+	}
+
+	e.entries++
+	if e.entries > e.opts.MaxFiles {
+		return "", ErrArchiveTooManyFiles
+	}
+
+	clean := strings.TrimSuffix(name, "/")
+	if strings.Contains(clean, `\`) || path.IsAbs(clean) || !filepath.IsLocal(filepath.FromSlash(clean)) {
+		return "", fmt.Errorf("%w: %s", ErrArchiveInvalidPath, name)
+	}
+
+	return filepath.FromSlash(clean), nil
+}
+
+func (e *extractor) dir(name string) error {
+	rel, err := e.path(name)
+	if err != nil {
+		return err
+	}
+
+	if err := e.mkdirAll(rel); err != nil {
+		return err
+	}
+
+	// the directories created by mkdirAll are already in the paths
+	target := filepath.Join(e.dest, rel)
+	if rel != "." && (len(e.created) == 0 || e.created[len(e.created)-1] != target) {
+		e.paths = append(e.paths, target)
+	}
+
+	return nil
+}
+
+func (e *extractor) file(name string, r io.Reader, mode fs.FileMode) error {
+	rel, err := e.path(name)
+	if err != nil {
+		return err
+	}
+
+	if err := e.mkdirAll(filepath.Dir(rel)); err != nil {
+		return err
+	}
+
+	target := filepath.Join(e.dest, rel)
+
+	created := false
+	info, err := os.Lstat(target)
+	switch {
+	case err == nil && !info.Mode().IsRegular():
+		// links in dest are not followed
+		return fmt.Errorf("%w: %s", ErrArchiveInvalidPath, name)
+	case os.IsNotExist(err):
+		created = true
+	case err != nil:
+		return err
+	}
+
+	// the archive permissions are ignored, only the execute bit is kept
+	perm := fs.FileMode(0o644)
+	if mode&0o111 != 0 {
+		perm = 0o755
+	}
+
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if created {
+		e.created = append(e.created, target)
+	}
+	e.paths = append(e.paths, target)
+
+	_, err = io.Copy(&extractWriter{e: e, w: f}, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// mkdirAll - Create the directories of one relative path, existing links in dest are not followed
+func (e *extractor) mkdirAll(rel string) error {
+	if rel == "." {
+		return nil
+	}
+
+	current := e.dest
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+
+		info, err := os.Lstat(current)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%w: %s", ErrArchiveInvalidPath, rel)
+			}
+			continue
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+
+		if err := os.Mkdir(current, 0o755); err != nil {
+			return err
+		}
+		e.created = append(e.created, current)
+		e.paths = append(e.paths, current)
+	}
+
+	return nil
+}
+
+// cleanup - Remove the created paths, the last ones first
+func (e *extractor) cleanup() {
+	for i := len(e.created) - 1; i >= 0; i-- {
+		os.Remove(e.created[i])
+	}
+	e.created = nil
+	e.paths = nil
+}
+
+// extractWriter - Count the written bytes and check the limits before each write
+type extractWriter struct {
+	e *extractor
+	w io.Writer
+}
+
+func (w *extractWriter) Write(p []byte) (int, error) {
+	e := w.e
+
+	if err := e.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	e.written += int64(len(p))
+	if e.written > e.opts.MaxSize {
+		return 0, ErrArchiveTooLarge
+	}
+
+	if e.written > archiveRatioMinSize && float64(e.written) > float64(e.compressed)*e.opts.MaxRatio {
+		return 0, ErrArchiveRatio
+	}
+
+	return w.w.Write(p)
+}
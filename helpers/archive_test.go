@@ -0,0 +1,247 @@
+package helpers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type archiveEntry struct {
+	name     string
+	body     string
+	typeflag byte
+	mode     int64
+}
+
+var themeEntries = []archiveEntry{
+	{name: "theme/", typeflag: tar.TypeDir},
+	{name: "theme/layouts/default.html", body: "<html>{{ .Content }}</html>"},
+	{name: "theme/assets/app.js", body: "console.log('theme')"},
+	{name: "theme/bin/build.sh", body: "#!/bin/sh", mode: 0o755},
+}
+
+func writeArchive(t *testing.T, format ArchiveFormat, entries []archiveEntry) string {
+	buf := bytes.Buffer{}
+
+	if format == ArchiveZip {
+		zw := zip.NewWriter(&buf)
+		for _, e := range entries {
+			h := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+			switch e.typeflag {
+			case tar.TypeSymlink:
+				h.SetMode(os.ModeSymlink | 0o777)
+			case tar.TypeDir:
+				h.SetMode(os.ModeDir | 0o755)
+			default:
+				h.SetMode(0o644)
+			}
+			w, err := zw.CreateHeader(h)
+			require.Nil(t, err)
+			w.Write([]byte(e.body))
+		}
+		require.Nil(t, zw.Close())
+	} else {
+		var w io.WriteCloser = nopWriteCloser{&buf}
+		switch format {
+		case ArchiveTarGz:
+			w = gzip.NewWriter(&buf)
+		case ArchiveTarZst:
+			zw, err := zstd.NewWriter(&buf)
+			require.Nil(t, err)
+			w = zw
+		}
+
+		tw := tar.NewWriter(w)
+		for _, e := range entries {
+			typeflag := e.typeflag
+			if typeflag == 0 {
+				typeflag = tar.TypeReg
+			}
+			mode := e.mode
+			if mode == 0 {
+				mode = 0o644
+			}
+			h := &tar.Header{Name: e.name, Typeflag: typeflag, Mode: mode, Size: int64(len(e.body))}
+			if typeflag != tar.TypeReg {
+				h.Size = 0
+				h.Linkname = e.body
+			}
+			require.Nil(t, tw.WriteHeader(h))
+			if typeflag == tar.TypeReg {
+				tw.Write([]byte(e.body))
+			}
+		}
+		require.Nil(t, tw.Close())
+		require.Nil(t, w.Close())
+	}
+
+	path := filepath.Join(t.TempDir(), "archive")
+	require.Nil(t, os.WriteFile(path, buf.Bytes(), 0o644))
+	return path
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestExtract(t *testing.T) {
+	ctx := context.Background()
+
+	for _, format := range []ArchiveFormat{ArchiveZip, ArchiveTar, ArchiveTarGz, ArchiveTarZst} {
+		t.Run("Should extract "+string(format), func(t *testing.T) {
+			src := writeArchive(t, format, themeEntries)
+			dest := filepath.Join(t.TempDir(), "themes")
+
+			detected, err := os.Open(src)
+			require.Nil(t, err)
+			f, err := DetectArchiveFormat(detected)
+			detected.Close()
+			require.Nil(t, err)
+			assert.Equal(t, format, f)
+
+			paths, err := Extract(ctx, src, dest, nil)
+			require.Nil(t, err)
+			assert.Contains(t, paths, filepath.Join(dest, "theme", "layouts", "default.html"))
+
+			data, err := os.ReadFile(filepath.Join(dest, "theme", "layouts", "default.html"))
+			require.Nil(t, err)
+			assert.Equal(t, "<html>{{ .Content }}</html>", string(data))
+
+			if format != ArchiveZip {
+				info, err := os.Stat(filepath.Join(dest, "theme", "bin", "build.sh"))
+				require.Nil(t, err)
+				assert.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+			}
+		})
+	}
+
+	t.Run("Should return the overwritten files and existing directories", func(t *testing.T) {
+		src := writeArchive(t, ArchiveTar, themeEntries)
+		dest := t.TempDir()
+		require.Nil(t, os.MkdirAll(filepath.Join(dest, "theme", "layouts"), 0o755))
+		require.Nil(t, os.WriteFile(filepath.Join(dest, "theme", "layouts", "default.html"), []byte("old"), 0o644))
+		require.Nil(t, os.WriteFile(filepath.Join(dest, "theme", "README"), []byte("kept"), 0o644))
+
+		paths, err := Extract(ctx, src, dest, nil)
+		require.Nil(t, err)
+		assert.Equal(t, []string{
+			filepath.Join(dest, "theme"),
+			filepath.Join(dest, "theme", "layouts", "default.html"),
+			filepath.Join(dest, "theme", "assets"),
+			filepath.Join(dest, "theme", "assets", "app.js"),
+			filepath.Join(dest, "theme", "bin"),
+			filepath.Join(dest, "theme", "bin", "build.sh"),
+		}, paths)
+
+		// the existing files are not removed on errors
+		src = writeArchive(t, ArchiveTar, append(themeEntries, archiveEntry{name: "../evil.sh", body: "evil"}))
+		_, err = Extract(ctx, src, dest, nil)
+		assert.ErrorIs(t, err, ErrArchiveInvalidPath)
+
+		data, err := os.ReadFile(filepath.Join(dest, "theme", "README"))
+		require.Nil(t, err)
+		assert.Equal(t, "kept", string(data))
+		_, err = os.Stat(filepath.Join(dest, "theme", "layouts", "default.html"))
+		assert.Nil(t, err)
+		_, err = os.Stat(filepath.Join(dest, "theme", "assets"))
+		assert.Nil(t, err)
+	})
+
+	t.Run("Should reject paths outside dest", func(t *testing.T) {
+		for _, name := range []string{"../evil.sh", "theme/../../evil.sh", "/etc/evil", `..\evil.sh`, ""} {
+			for _, format := range []ArchiveFormat{ArchiveZip, ArchiveTar} {
+				src := writeArchive(t, format, append(themeEntries, archiveEntry{name: name, body: "evil"}))
+				dest := t.TempDir()
+
+				_, err := Extract(ctx, src, dest, nil)
+				assert.ErrorIs(t, err, ErrArchiveInvalidPath, name)
+
+				// the files extracted before the error are removed
+				entries, _ := os.ReadDir(dest)
+				assert.Empty(t, entries)
+			}
+		}
+	})
+
+	t.Run("Should reject links", func(t *testing.T) {
+		for _, e := range []archiveEntry{
+			{name: "theme/passwd", body: "/etc/passwd", typeflag: tar.TypeSymlink},
+			{name: "theme/hosts", body: "theme/layouts/default.html", typeflag: tar.TypeLink},
+		} {
+			src := writeArchive(t, ArchiveTar, append(themeEntries, e))
+			_, err := Extract(ctx, src, t.TempDir(), nil)
+			assert.ErrorIs(t, err, ErrArchiveInvalidEntry)
+		}
+
+		src := writeArchive(t, ArchiveZip, append(themeEntries, archiveEntry{name: "theme/passwd", body: "/etc/passwd", typeflag: tar.TypeSymlink}))
+		_, err := Extract(ctx, src, t.TempDir(), nil)
+		assert.ErrorIs(t, err, ErrArchiveInvalidEntry)
+	})
+
+	t.Run("Should not follow links in dest", func(t *testing.T) {
+		outside := t.TempDir()
+		dest := t.TempDir()
+		require.Nil(t, os.Symlink(outside, filepath.Join(dest, "theme")))
+
+		src := writeArchive(t, ArchiveTar, themeEntries)
+		_, err := Extract(ctx, src, dest, nil)
+		assert.ErrorIs(t, err, ErrArchiveInvalidPath)
+
+		entries, _ := os.ReadDir(outside)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("Should enforce the limits", func(t *testing.T) {
+		src := writeArchive(t, ArchiveTar, themeEntries)
+		_, err := Extract(ctx, src, t.TempDir(), &ExtractOptions{MaxFiles: 3})
+		assert.ErrorIs(t, err, ErrArchiveTooManyFiles)
+
+		_, err = Extract(ctx, src, t.TempDir(), &ExtractOptions{MaxSize: 30})
+		assert.ErrorIs(t, err, ErrArchiveTooLarge)
+
+		src = writeArchive(t, ArchiveZip, themeEntries)
+		_, err = Extract(ctx, src, t.TempDir(), &ExtractOptions{MaxSize: 30})
+		assert.ErrorIs(t, err, ErrArchiveTooLarge)
+
+		// 4MB of zeros compress to some KB:
+		bomb := []archiveEntry{{name: "zeros", body: string(make([]byte, 4<<20))}}
+		for _, format := range []ArchiveFormat{ArchiveTarGz, ArchiveTarZst, ArchiveZip} {
+			src = writeArchive(t, format, bomb)
+			dest := t.TempDir()
+			_, err = Extract(ctx, src, dest, nil)
+			assert.ErrorIs(t, err, ErrArchiveRatio, format)
+
+			_, err = os.Stat(filepath.Join(dest, "zeros"))
+			assert.True(t, os.IsNotExist(err))
+		}
+	})
+
+	t.Run("Should stop with the context", func(t *testing.T) {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		src := writeArchive(t, ArchiveTarGz, themeEntries)
+		_, err := Extract(canceled, src, t.TempDir(), nil)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("Should reject unknown formats", func(t *testing.T) {
+		src := filepath.Join(t.TempDir(), "theme.rar")
+		require.Nil(t, os.WriteFile(src, []byte("Rar!\x1a\x07\x00"), 0o644))
+
+		_, err := Extract(ctx, src, t.TempDir(), nil)
+		assert.ErrorIs(t, err, ErrArchiveFormat)
+	})
+}
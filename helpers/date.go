@@ -1,20 +1,58 @@
 package helpers
 
 import (
+	"log"
 	"regexp"
+	"sync"
 	"time"
 
 	"github.com/go-bolo/bolo/configuration"
 )
 
-func FormatDate(date *time.Time, format string) string {
+var (
+	location   *time.Location
+	locationMu sync.RWMutex
+)
+
+// LoadLocation - Load and validate one timezone. Empty names returns UTC
+func LoadLocation(timeZone string) (*time.Location, error) {
+	return time.LoadLocation(timeZone)
+}
+
+// SetLocation - Set the site timezone used by the date helpers. Usualy set once at app bootstrap
+func SetLocation(loc *time.Location) {
+	locationMu.Lock()
+	defer locationMu.Unlock()
+
+	location = loc
+}
+
+// GetLocation - Returns the site timezone, loaded from the SITE_TIMEZONE variable if not set.
+// Invalid timezones are logged and UTC is used, the app bootstrap fails with one invalid SITE_TIMEZONE in InitTimezone
+func GetLocation() *time.Location {
+	locationMu.RLock()
+	loc := location
+	locationMu.RUnlock()
+
+	if loc != nil {
+		return loc
+	}
+
 	timeZone := configuration.GetEnv("SITE_TIMEZONE", "")
-	loc, err := time.LoadLocation(timeZone)
+
+	loc, err := LoadLocation(timeZone)
 	if err != nil {
-		panic(err)
+		log.Printf("helpers.GetLocation: invalid SITE_TIMEZONE %s, using UTC: %v", timeZone, err)
+		loc = time.UTC
 	}
 
-	return date.In(loc).Format(format)
+	SetLocation(loc)
+
+	return loc
+}
+
+func FormatDate(date *time.Time, format string) string {
+	return date.In(GetLocation()).Format(format)
 }
 
 func ExtractYearFromText(text string) string {
@@ -28,12 +66,5 @@ func ExtractYearFromText(text string) string {
 }
 
 func FormatCurrencyDate(format string) string {
-	date := time.Now()
-	timeZone := configuration.GetEnv("SITE_TIMEZONE", "")
-	loc, err := time.LoadLocation(timeZone)
-	if err != nil {
-		return ""
-	}
-
-	return date.In(loc).Format(format)
+	return time.Now().In(GetLocation()).Format(format)
 }
@@ -1,7 +1,11 @@
 package helpers
 
 import (
+	"bytes"
+	"log"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -39,3 +43,20 @@ func TestFormatCurrencyDate(t *testing.T) {
 	})
 
 }
+
+func TestGetLocation(t *testing.T) {
+	t.Run("Should log the invalid SITE_TIMEZONE and use UTC", func(t *testing.T) {
+		os.Setenv("SITE_TIMEZONE", "Invalid/Zone")
+		defer os.Unsetenv("SITE_TIMEZONE")
+
+		SetLocation(nil)
+		defer SetLocation(nil)
+
+		var out bytes.Buffer
+		log.SetOutput(&out)
+		defer log.SetOutput(os.Stderr)
+
+		assert.Equal(t, time.UTC, GetLocation())
+		assert.Contains(t, out.String(), "invalid SITE_TIMEZONE Invalid/Zone, using UTC")
+	})
+}
@@ -1,30 +1,46 @@
 package helpers
 
 import (
+	"strings"
+
 	"github.com/shopspring/decimal"
 )
 
+// FormatDecimalWithDots - Format one decimal with "." as thousands separator and "," as decimal separator. Ex: 1234567.5 -> 1.234.567,5
 func FormatDecimalWithDots(n decimal.Decimal) string {
-	in := n.String()
-	numOfDigits := len(in)
-	if n.IsNegative() {
-		numOfDigits-- // First character is the - sign (not a digit)
+	return FormatDecimal(n, -1, ".", ",")
+}
+
+// FormatDecimal - Format one decimal with custom separators, places < 0 keeps all decimal places
+func FormatDecimal(n decimal.Decimal, places int32, groupSeparator, decimalSeparator string) string {
+	var in string
+	if places < 0 {
+		in = n.String()
+	} else {
+		in = n.StringFixed(places)
 	}
-	numOfCommas := (numOfDigits - 1) / 3
 
-	out := make([]byte, len(in)+numOfCommas)
-	if n.IsNegative() {
-		in, out[0] = in[1:], '-'
+	negative := strings.HasPrefix(in, "-")
+	in = strings.TrimPrefix(in, "-")
+
+	intPart, fracPart, _ := strings.Cut(in, ".")
+
+	var b strings.Builder
+	if negative {
+		b.WriteByte('-')
 	}
 
-	for i, j, k := len(in)-1, len(out)-1, 0; ; i, j = i-1, j-1 {
-		out[j] = in[i]
-		if i == 0 {
-			return string(out)
-		}
-		if k++; k == 3 {
-			j, k = j-1, 0
-			out[j] = '.'
+	for i := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(groupSeparator)
 		}
+		b.WriteByte(intPart[i])
+	}
+
+	if fracPart != "" {
+		b.WriteString(decimalSeparator)
+		b.WriteString(fracPart)
 	}
+
+	return b.String()
 }
@@ -0,0 +1,32 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatDecimalWithDots(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"0", "0"},
+		{"999", "999"},
+		{"1000", "1.000"},
+		{"-1234567", "-1.234.567"},
+		{"1234567.89", "1.234.567,89"},
+		{"-0.5", "-0,5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			assert.Equal(t, tt.want, FormatDecimalWithDots(decimal.RequireFromString(tt.value)))
+		})
+	}
+}
+
+func TestFormatDecimal(t *testing.T) {
+	assert.Equal(t, "1,234.50", FormatDecimal(decimal.RequireFromString("1234.5"), 2, ",", "."))
+	assert.Equal(t, "1 235", FormatDecimal(decimal.RequireFromString("1234.5"), 0, " ", ","))
+}
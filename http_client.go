@@ -2,16 +2,24 @@ package bolo
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-bolo/bolo/configuration"
+	"github.com/go-bolo/bolo/httpcache"
 	"go.uber.org/zap"
 )
 
@@ -22,49 +30,509 @@ type CustomHTTPClient interface {
 
 var (
 	HttpClient CustomHTTPClient
+
+	ErrCircuitOpen = errors.New("circuit breaker open")
 )
 
+// maxErrorBodySize - Max response body bytes kept in HTTPStatusError
+const maxErrorBodySize = 64 << 10
+
+// maxErrorMessageBodySize - Max response body bytes included in the HTTPStatusError message
+const maxErrorMessageBodySize = 512
+
 func HttpClientInit() {
-	httpClientTimeout := configuration.GetInt64Env("HTTP_CLIENT_TIMEOUT", 120)
+	httpClientTimeout := configuration.GetInt64Env(HTTP_CLIENT_TIMEOUT, 120)
 
 	timeout := time.Second * time.Duration(httpClientTimeout)
-	HttpClient = &http.Client{Timeout: timeout}
+	HttpClient = &http.Client{Timeout: timeout, Transport: &RequestIDTransport{}}
 }
 
-// DownloadFile - Download one file
-func DownloadFile(app App, url string, dest *os.File, headers http.Header) (bool, error) {
-	l := app.GetLogger().With(zap.String("func", "DownloadFile"))
-	l.Debug("will download", zap.String("url", url), zap.String("dest", dest.Name()))
+// HTTPStatusError - Response with one status code outside the 2xx range
+type HTTPStatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	// Response body, limited to 64KB
+	Body []byte
+}
+
+// Error - The message includes only the first 512 bytes of the body, the full body is in Body
+func (e *HTTPStatusError) Error() string {
+	body := e.Body
+	suffix := ""
+	if len(body) > maxErrorMessageBodySize {
+		body = body[:maxErrorMessageBodySize]
+		suffix = "..."
+	}
+
+	return fmt.Sprintf("unexpected status code %d from %s %s: %s%s", e.StatusCode, e.Method, e.URL, string(body), suffix)
+}
 
-	var err error
+// IsHTTPStatus - Check if the err is one HTTPStatusError with the status code
+func IsHTTPStatus(err error, statusCode int) bool {
+	var se *HTTPStatusError
+	return errors.As(err, &se) && se.StatusCode == statusCode
+}
 
-	defer dest.Close()
+// CheckResponse - Returns one *HTTPStatusError and closes the body if the response status is not 2xx
+func CheckResponse(res *http.Response) error {
+	if res.StatusCode >= 200 && res.StatusCode <= 299 {
+		return nil
+	}
 
-	req, err := http.NewRequest("GET", url, nil)
+	defer res.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBodySize))
+
+	se := HTTPStatusError{StatusCode: res.StatusCode, Body: body}
+	if res.Request != nil {
+		se.Method = res.Request.Method
+		se.URL = res.Request.URL.Redacted()
+	}
+
+	return &se
+}
+
+// DecodeJSON - Check the response status and decode the JSON body in target, the body is closed.
+// A nil target only checks the status
+func DecodeJSON(res *http.Response, target interface{}) error {
+	if err := CheckResponse(res); err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if target == nil {
+		_, err := io.Copy(io.Discard, res.Body)
+		return err
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(target); err != nil {
+		return fmt.Errorf("error on decode response body: %w", err)
+	}
+
+	return nil
+}
+
+// HTTPClient - App HTTP client with per attempt timeouts, retries with jitter on idempotent requests
+// and one circuit breaker per host. Requests are sent with the Client or the global HttpClient
+type HTTPClient struct {
+	App App
+	// Base client, if nil the bolo.HttpClient is used
+	Client CustomHTTPClient
+	// Max duration of each attempt
+	Timeout time.Duration
+	// Retries after the first attempt, only for idempotent requests
+	MaxRetries   int
+	RetryWaitMin time.Duration
+	RetryWaitMax time.Duration
+	// Consecutive failures that open the host circuit, 0 disables the circuit breaker
+	BreakerThreshold int
+	// Time with the circuit open before one trial request
+	BreakerCooldown time.Duration
+	// Response cache, nil to disable. Cache hits skip the retries and the circuit breaker
+	Cache *httpcache.Cache
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+// NewHTTPClientFromConfiguration - Build one client with the HTTP_CLIENT_* variables
+func NewHTTPClientFromConfiguration(app App) *HTTPClient {
+	cfg := app.GetConfiguration()
+
+	c := HTTPClient{
+		App:              app,
+		Timeout:          time.Duration(cfg.GetInt64F(HTTP_CLIENT_TIMEOUT, 120)) * time.Second,
+		MaxRetries:       cfg.GetIntF(HTTP_CLIENT_MAX_RETRIES, 2),
+		RetryWaitMin:     time.Duration(cfg.GetInt64F(HTTP_CLIENT_RETRY_WAIT_MIN, 100)) * time.Millisecond,
+		RetryWaitMax:     time.Duration(cfg.GetInt64F(HTTP_CLIENT_RETRY_WAIT_MAX, 2000)) * time.Millisecond,
+		BreakerThreshold: cfg.GetIntF(HTTP_CLIENT_BREAKER_THRESHOLD, 5),
+		BreakerCooldown:  time.Duration(cfg.GetInt64F(HTTP_CLIENT_BREAKER_COOLDOWN, 30)) * time.Second,
+		breakers:         make(map[string]*circuitBreaker),
+	}
+
+	var store httpcache.Store
+	switch cfg.Get(HTTP_CLIENT_CACHE) {
+	case "":
+	case "memory":
+		store = httpcache.NewMemoryStore(cfg.GetIntF(HTTP_CLIENT_CACHE_MAX_ENTRIES, 1000))
+	case "disk":
+		store = httpcache.NewDiskStore(cfg.GetF(HTTP_CLIENT_CACHE_DIR, filepath.Join(os.TempDir(), "bolo-http-cache")))
+	default:
+		app.GetLogger().Warn("NewHTTPClientFromConfiguration: invalid cache store, the cache is disabled", zap.String("store", cfg.Get(HTTP_CLIENT_CACHE)))
+	}
+
+	if store != nil {
+		c.Cache = httpcache.New(store)
+		c.Cache.Now = func() time.Time { return app.GetClock().Now() }
+	}
+
+	return &c
+}
+
+func (c *HTTPClient) client() CustomHTTPClient {
+	if c.Client != nil {
+		return c.Client
+	}
+
+	if HttpClient != nil {
+		return HttpClient
+	}
+
+	return http.DefaultClient
+}
+
+// Do - Send the request with the cache, retry and circuit breaker policies.
+// Like http.Client, responses with error status codes are returned without error, use CheckResponse or DecodeJSON
+func (c *HTTPClient) Do(req *http.Request) (*http.Response, error) {
+	if c.Cache != nil {
+		return c.Cache.Do(req, httpcache.DoerFunc(c.do))
+	}
+
+	return c.do(req)
+}
+
+func (c *HTTPClient) do(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	retries := 0
+	if isIdempotent(req) {
+		retries = c.MaxRetries
+	}
+
+	for attempt := 0; ; attempt++ {
+		breaker := c.breaker(host)
+		if breaker != nil {
+			if err := breaker.allow(c.now()); err != nil {
+				return nil, fmt.Errorf("%s: %w", host, err)
+			}
+		}
+
+		res, err := c.attempt(req)
+
+		if breaker != nil {
+			breaker.record(err == nil && res.StatusCode < 500, c.now(), c.BreakerThreshold)
+		}
+
+		if attempt >= retries || !shouldRetry(req, res, err) {
+			return res, err
+		}
+
+		wait := c.backoff(attempt, res)
+
+		if res != nil {
+			// drain the body to reuse the connection
+			io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+			res.Body.Close()
+		}
+
+		if c.App != nil {
+			c.App.GetLogger().Debug("HTTPClient: retry", zap.String("method", req.Method), zap.String("url", req.URL.Redacted()), zap.Int("attempt", attempt+1), zap.Duration("wait", wait), zap.Error(err))
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt - Send one attempt with the Timeout, the timeout context is canceled on body close
+func (c *HTTPClient) attempt(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	cancel := context.CancelFunc(func() {})
+	if c.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+	}
+
+	r := req.Clone(ctx)
+	if req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		r.Body = body
+	}
+
+	res, err := c.client().Do(r)
 	if err != nil {
-		return false, err
+		cancel()
+		return nil, err
 	}
 
-	req.Header = headers
+	res.Body = &cancelOnCloseBody{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+// backoff - Wait before the next attempt: exponential with jitter or the Retry-After header, limited to RetryWaitMax
+func (c *HTTPClient) backoff(attempt int, res *http.Response) time.Duration {
+	if res != nil {
+		if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			wait := time.Duration(seconds) * time.Second
+			if wait > c.RetryWaitMax {
+				wait = c.RetryWaitMax
+			}
+			return wait
+		}
+	}
 
-	res, err := HttpClient.Do(req)
+	wait := float64(c.RetryWaitMin) * math.Pow(2, float64(attempt))
+	if wait > float64(c.RetryWaitMax) {
+		wait = float64(c.RetryWaitMax)
+	}
+
+	// equal jitter, half fixed and half random
+	half := wait / 2
+	return time.Duration(half + rand.Float64()*half)
+}
+
+func (c *HTTPClient) breaker(host string) *circuitBreaker {
+	if c.BreakerThreshold <= 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.breakers == nil {
+		c.breakers = make(map[string]*circuitBreaker)
+	}
+
+	b, ok := c.breakers[host]
+	if !ok {
+		b = &circuitBreaker{cooldown: c.BreakerCooldown}
+		c.breakers[host] = b
+	}
+
+	return b
+}
+
+// CircuitState - Returns the circuit state of one host: closed, open or half-open
+func (c *HTTPClient) CircuitState(host string) string {
+	b := c.breaker(host)
+	if b == nil {
+		return circuitClosed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+func (c *HTTPClient) now() time.Time {
+	if c.App != nil {
+		return c.App.GetClock().Now()
+	}
+
+	return time.Now()
+}
+
+// Get - Send one GET request and returns the response, non 2xx responses return one *HTTPStatusError
+func (c *HTTPClient) Get(ctx context.Context, url string, headers http.Header) (*http.Response, error) {
+	req, err := newRequest(ctx, http.MethodGet, url, nil, headers)
 	if err != nil {
-		l.Error("error on request", zap.Error(err), zap.String("url", url))
-		return false, err
+		return nil, err
 	}
 
-	defer res.Body.Close()
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := CheckResponse(res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// DoJSON - Send the request and decode the JSON response in target
+func (c *HTTPClient) DoJSON(req *http.Request, target interface{}) error {
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	res, err := c.Do(req)
+	if err != nil {
+		return err
+	}
 
-	_, err = io.Copy(dest, res.Body)
+	return DecodeJSON(res, target)
+}
+
+// GetJSON - Send one GET request and decode the JSON response in target
+func (c *HTTPClient) GetJSON(ctx context.Context, url string, headers http.Header, target interface{}) error {
+	req, err := newRequest(ctx, http.MethodGet, url, nil, headers)
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	l.Error("error on copy body", zap.Error(err), zap.String("url", url), zap.String("dest", dest.Name()))
-	return true, err
+	return c.DoJSON(req, target)
+}
+
+// PostJSON - Send the body encoded as JSON and decode the JSON response in target.
+// POST requests are only retried with one Idempotency-Key header
+func (c *HTTPClient) PostJSON(ctx context.Context, url string, body interface{}, headers http.Header, target interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("error on encode request body: %w", err)
+	}
+
+	req, err := newRequest(ctx, http.MethodPost, url, bytes.NewReader(data), headers)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return c.DoJSON(req, target)
+}
+
+// PostForm - Send the form url encoded body and decode the JSON response in target
+func (c *HTTPClient) PostForm(ctx context.Context, url string, body url.Values, headers http.Header, target interface{}) error {
+	req, err := newRequest(ctx, http.MethodPost, url, strings.NewReader(body.Encode()), headers)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return c.DoJSON(req, target)
+}
+
+func newRequest(ctx context.Context, method, url string, body io.Reader, headers http.Header) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range headers {
+		req.Header[k] = v
+	}
+
+	return req, nil
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+func shouldRetry(req *http.Request, res *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+
+	// bodies without GetBody can not be sent again
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	if err != nil {
+		return !errors.Is(err, ErrCircuitOpen)
+	}
+
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half-open"
+)
+
+type circuitBreaker struct {
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	cooldown time.Duration
+	trial    bool
+}
+
+// allow - Closed circuits allow all requests, open circuits allow one trial after the cooldown
+func (b *circuitBreaker) allow(now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if now.Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+
+		b.state = circuitHalfOpen
+		b.trial = true
+		return nil
+	case circuitHalfOpen:
+		if b.trial {
+			return ErrCircuitOpen
+		}
+
+		b.trial = true
+		return nil
+	}
+
+	return nil
+}
+
+func (b *circuitBreaker) record(success bool, now time.Time, threshold int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		b.state = circuitClosed
+		b.failures = 0
+		b.trial = false
+		return
+	}
+
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= threshold {
+		b.state = circuitOpen
+		b.openedAt = now
+		b.trial = false
+	}
+}
+
+// GetWithContext - Same as Get but with one context, used to propagate the request id and cancelation
+func GetWithContext(ctx context.Context, url string, headers http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if headers != nil {
+		req.Header = headers
+	}
+
+	return HttpClient.Do(req)
 }
 
 // Get - Start a Get response and returns the http.Response without parse data.
+//
+// Deprecated: use app.GetHTTPClient().Get with one context
 func Get(url string, headers http.Header) (*http.Response, error) {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -79,12 +547,11 @@ func Get(url string, headers http.Header) (*http.Response, error) {
 func GetPageHTML(app App, url string, headers http.Header) (string, error) {
 	l := app.GetLogger().With(zap.String("func", "GetPageHTML"))
 
-	resp, err := Get(url, headers)
+	resp, err := app.GetHTTPClient().Get(context.Background(), url, headers)
 	if err != nil {
 		l.Error("error on request", zap.Error(err), zap.String("url", url), zap.Any("headers", headers))
 		return "", fmt.Errorf("error on request: %w", err)
 	}
-
 	defer resp.Body.Close()
 
 	rdrBody := io.Reader(resp.Body)
@@ -98,6 +565,8 @@ func GetPageHTML(app App, url string, headers http.Header) (string, error) {
 }
 
 // Post sends a post request to the URL with the body
+//
+// Deprecated: use app.GetHTTPClient().PostJSON with one context
 func Post(url string, body interface{}, headers http.Header) (*http.Response, error) {
 	jsonBytes, err := json.Marshal(body)
 	if err != nil {
@@ -112,6 +581,8 @@ func Post(url string, body interface{}, headers http.Header) (*http.Response, er
 }
 
 // PostFormURLEncoded - Send a post request with form url encoded request body
+//
+// Deprecated: use app.GetHTTPClient().PostForm with one context
 func PostFormURLEncoded(url string, body url.Values, target interface{}) error {
 	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body.Encode()))
 	if err != nil {
@@ -122,12 +593,6 @@ func PostFormURLEncoded(url string, body url.Values, target interface{}) error {
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
 
-	return json.Unmarshal(bodyBytes, target)
+	return DecodeJSON(resp, target)
 }
@@ -0,0 +1,207 @@
+package bolo_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	bolo "github.com/go-bolo/bolo"
+	"github.com/go-bolo/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPClient(t *testing.T) {
+	app := GetTestApp()
+
+	newClient := func(server *httptest.Server) *bolo.HTTPClient {
+		c := bolo.NewHTTPClientFromConfiguration(app)
+		c.Client = server.Client()
+		c.RetryWaitMin = time.Millisecond
+		c.RetryWaitMax = 5 * time.Millisecond
+		return c
+	}
+
+	t.Run("should retry idempotent requests with retryable status", func(t *testing.T) {
+		var calls int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(`{"name":"bolo"}`))
+		}))
+		defer server.Close()
+
+		res := struct {
+			Name string `json:"name"`
+		}{}
+		err := newClient(server).GetJSON(context.Background(), server.URL, nil, &res)
+		require.Nil(t, err)
+		assert.Equal(t, "bolo", res.Name)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("should return one typed status error after the retries", func(t *testing.T) {
+		var calls int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("upstream down"))
+		}))
+		defer server.Close()
+
+		c := newClient(server)
+		c.BreakerThreshold = 0
+		_, err := c.Get(context.Background(), server.URL+"/path", nil)
+		require.NotNil(t, err)
+
+		var se *bolo.HTTPStatusError
+		require.True(t, errors.As(err, &se))
+		assert.Equal(t, http.StatusBadGateway, se.StatusCode)
+		assert.Equal(t, http.MethodGet, se.Method)
+		assert.Equal(t, server.URL+"/path", se.URL)
+		assert.Equal(t, "upstream down", string(se.Body))
+		assert.True(t, bolo.IsHTTPStatus(err, http.StatusBadGateway))
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("should truncate the body in the status error message", func(t *testing.T) {
+		body := strings.Repeat("a", 4096)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(body))
+		}))
+		defer server.Close()
+
+		_, err := newClient(server).Get(context.Background(), server.URL+"/path", nil)
+		require.NotNil(t, err)
+
+		var se *bolo.HTTPStatusError
+		require.True(t, errors.As(err, &se))
+		assert.Equal(t, body, string(se.Body))
+		assert.Equal(t, "unexpected status code 404 from GET "+server.URL+"/path: "+body[:512]+"...", err.Error())
+	})
+
+	t.Run("should not retry post requests without idempotency key", func(t *testing.T) {
+		var calls int32
+		var body string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		c := newClient(server)
+		err := c.PostJSON(context.Background(), server.URL, map[string]string{"a": "b"}, nil, nil)
+		assert.True(t, bolo.IsHTTPStatus(err, http.StatusServiceUnavailable))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+		atomic.StoreInt32(&calls, 0)
+		server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) < 2 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			r.ParseForm()
+			body = r.PostForm.Get("a")
+			w.Write([]byte(`{}`))
+		})
+
+		headers := http.Header{"Idempotency-Key": []string{"k1"}}
+		err = c.PostForm(context.Background(), server.URL, url.Values{"a": []string{"b"}}, headers, nil)
+		require.Nil(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+		assert.Equal(t, "b", body)
+	})
+
+	t.Run("should open the circuit after consecutive failures", func(t *testing.T) {
+		var calls int32
+		var fail int32 = 1
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			if atomic.LoadInt32(&fail) == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Write([]byte(`{}`))
+		}))
+		defer server.Close()
+
+		c := newClient(server)
+		c.BreakerThreshold = 2
+		c.BreakerCooldown = time.Minute
+		host := strings.TrimPrefix(server.URL, "http://")
+
+		for i := 0; i < 2; i++ {
+			_, err := c.Get(context.Background(), server.URL, nil)
+			assert.True(t, bolo.IsHTTPStatus(err, http.StatusInternalServerError))
+		}
+		assert.Equal(t, "open", c.CircuitState(host))
+
+		_, err := c.Get(context.Background(), server.URL, nil)
+		assert.True(t, errors.Is(err, bolo.ErrCircuitOpen))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+		app.GetClock().(*clock.Mock).Add(2 * time.Minute)
+		atomic.StoreInt32(&fail, 0)
+
+		require.Nil(t, c.GetJSON(context.Background(), server.URL, nil, nil))
+		assert.Equal(t, "closed", c.CircuitState(host))
+	})
+
+	t.Run("should stop the retries on context cancel", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+
+		c := newClient(server)
+		c.RetryWaitMax = time.Minute
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := c.Get(ctx, server.URL, nil)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+}
+
+func TestHTTPClientCache(t *testing.T) {
+	os.Setenv("HTTP_CLIENT_CACHE", "memory")
+	defer os.Unsetenv("HTTP_CLIENT_CACHE")
+
+	app := GetTestApp()
+	require.NotNil(t, app.GetHTTPClient().Cache)
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("<html>page</html>"))
+	}))
+	defer server.Close()
+
+	app.GetHTTPClient().Client = server.Client()
+
+	for i := 0; i < 2; i++ {
+		html, err := bolo.GetPageHTML(app, server.URL, nil)
+		require.Nil(t, err)
+		assert.Equal(t, "<html>page</html>", html)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	app.GetClock().(*clock.Mock).Add(time.Minute)
+
+	_, err := bolo.GetPageHTML(app, server.URL, nil)
+	require.Nil(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
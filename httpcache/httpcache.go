@@ -0,0 +1,458 @@
+// Package httpcache implements one HTTP cache for outgoing requests with the RFC 7234 freshness
+// and validation rules and pluggable stores. The cache can be shared by the requests of many users,
+// responses to requests with credentials are only stored with the shared cache rules
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// XCacheHeader - Response header with the cache result: HIT, MISS or REVALIDATED
+	XCacheHeader = "X-Cache"
+
+	CacheHit         = "HIT"
+	CacheMiss        = "MISS"
+	CacheRevalidated = "REVALIDATED"
+)
+
+var (
+	ErrNotFound = errors.New("cache entry not found")
+)
+
+// Doer - Client used to send the requests not served from the cache
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DoerFunc - Use one function as Doer
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Entry - Stored response
+type Entry struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	// Request header values listed in the response Vary header
+	VaryHeader   http.Header `json:"varyHeader"`
+	RequestTime  time.Time   `json:"requestTime"`
+	ResponseTime time.Time   `json:"responseTime"`
+}
+
+// Store - Cache entries storage, Get returns ErrNotFound for missing keys
+type Store interface {
+	Get(ctx context.Context, key string) (*Entry, error)
+	Set(ctx context.Context, key string, e *Entry) error
+	Delete(ctx context.Context, key string) error
+}
+
+// Cache - HTTP cache, only GET requests are cached and unsafe requests invalidate the URL
+type Cache struct {
+	Store Store
+	// Responses with bigger bodies are not stored
+	MaxBodySize int64
+	// Current time, default time.Now
+	Now func() time.Time
+}
+
+func New(store Store) *Cache {
+	return &Cache{Store: store, MaxBodySize: 10 << 20, Now: time.Now}
+}
+
+func (c *Cache) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+
+	return time.Now()
+}
+
+// credentialHeaders - Request headers with user credentials
+var credentialHeaders = []string{"Authorization", "Cookie"}
+
+// Key - Cache key of one request. Requests with the Authorization or Cookie headers have one key for each
+// credential, their responses are never served to requests with other or without credentials
+func Key(req *http.Request) string {
+	return key(req.Method, req)
+}
+
+func key(method string, req *http.Request) string {
+	k := method + " " + req.URL.String()
+	if !hasCredentials(req) {
+		return k
+	}
+
+	h := sha256.New()
+	for _, name := range credentialHeaders {
+		for _, v := range req.Header.Values(name) {
+			h.Write([]byte(name + ": " + v + "\n"))
+		}
+	}
+
+	return k + " " + hex.EncodeToString(h.Sum(nil))
+}
+
+func hasCredentials(req *http.Request) bool {
+	for _, name := range credentialHeaders {
+		if req.Header.Get(name) != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Do - Serve the request from the cache or with next, storing cacheable responses
+func (c *Cache) Do(req *http.Request, next Doer) (*http.Response, error) {
+	ctx := req.Context()
+
+	if req.Method != http.MethodGet {
+		res, err := next.Do(req)
+		if err == nil && !isSafe(req.Method) && res.StatusCode < 400 {
+			c.Store.Delete(ctx, http.MethodGet+" "+req.URL.String())
+			if hasCredentials(req) {
+				c.Store.Delete(ctx, key(http.MethodGet, req))
+			}
+		}
+		return res, err
+	}
+
+	reqCC := parseCacheControl(req.Header)
+	if _, ok := reqCC["no-store"]; ok {
+		return next.Do(req)
+	}
+
+	key := Key(req)
+	entry, err := c.Store.Get(ctx, key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	if entry != nil && !entry.matchesVary(req) {
+		entry = nil
+	}
+
+	if entry != nil && c.isFresh(entry, reqCC) {
+		return entry.response(req, c.age(entry), CacheHit), nil
+	}
+
+	outReq := req
+	if entry != nil {
+		outReq = conditionalRequest(req, entry)
+	}
+
+	requestTime := c.now()
+	res, err := next.Do(outReq)
+	if err != nil {
+		return nil, err
+	}
+	responseTime := c.now()
+
+	if entry != nil && res.StatusCode == http.StatusNotModified {
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+
+		// RFC 7234 4.3.4, update the stored headers with the 304 headers
+		for k, v := range res.Header {
+			entry.Header[k] = v
+		}
+		entry.RequestTime = requestTime
+		entry.ResponseTime = responseTime
+
+		if err := c.Store.Set(ctx, key, entry); err != nil {
+			return nil, err
+		}
+
+		return entry.response(req, c.age(entry), CacheRevalidated), nil
+	}
+
+	if !c.isCacheable(req, res) {
+		if entry != nil {
+			c.Store.Delete(ctx, key)
+		}
+
+		res.Header.Set(XCacheHeader, CacheMiss)
+		return res, nil
+	}
+
+	reader := io.Reader(res.Body)
+	if c.MaxBodySize > 0 {
+		reader = io.LimitReader(res.Body, c.MaxBodySize+1)
+	}
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+
+	res.Header.Set(XCacheHeader, CacheMiss)
+
+	if c.MaxBodySize > 0 && int64(len(body)) > c.MaxBodySize {
+		res.Body = &readCloser{Reader: io.MultiReader(bytes.NewReader(body), res.Body), Closer: res.Body}
+		return res, nil
+	}
+	res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	entry = &Entry{
+		StatusCode:   res.StatusCode,
+		Header:       res.Header.Clone(),
+		Body:         body,
+		VaryHeader:   varyHeader(req, res.Header),
+		RequestTime:  requestTime,
+		ResponseTime: responseTime,
+	}
+	entry.Header.Del(XCacheHeader)
+
+	if err := c.Store.Set(ctx, key, entry); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// isCacheable - RFC 7234 section 3, responses need one freshness lifetime or one validator
+func (c *Cache) isCacheable(req *http.Request, res *http.Response) bool {
+	switch res.StatusCode {
+	case 200, 203, 204, 300, 301, 404, 405, 410, 414, 501:
+	default:
+		return false
+	}
+
+	cc := parseCacheControl(res.Header)
+	if _, ok := cc["no-store"]; ok {
+		return false
+	}
+
+	if strings.TrimSpace(res.Header.Get("Vary")) == "*" {
+		return false
+	}
+
+	// RFC 7234 3.2, responses to requests with credentials are only stored if one directive allows it
+	if hasCredentials(req) {
+		_, public := cc["public"]
+		_, sMaxAge := cc["s-maxage"]
+		_, mustRevalidate := cc["must-revalidate"]
+		if !public && !sMaxAge && !mustRevalidate {
+			return false
+		}
+	}
+
+	if _, ok := cc["max-age"]; ok {
+		return true
+	}
+
+	if res.Header.Get("Expires") != "" || res.Header.Get("ETag") != "" || res.Header.Get("Last-Modified") != "" {
+		return true
+	}
+
+	_, ok := cc["no-cache"]
+	return ok
+}
+
+// isFresh - Compare the entry freshness lifetime with its age and the request directives
+func (c *Cache) isFresh(e *Entry, reqCC cacheControl) bool {
+	resCC := parseCacheControl(e.Header)
+	if _, ok := resCC["no-cache"]; ok {
+		return false
+	}
+
+	if _, ok := reqCC["no-cache"]; ok {
+		return false
+	}
+
+	if strings.Contains(e.Header.Get("Pragma"), "no-cache") && len(resCC) == 0 {
+		return false
+	}
+
+	lifetime := e.freshnessLifetime()
+	age := c.age(e)
+
+	if maxAge, ok := reqCC.duration("max-age"); ok && age > maxAge {
+		return false
+	}
+
+	if minFresh, ok := reqCC.duration("min-fresh"); ok {
+		age += minFresh
+	}
+
+	if age < lifetime {
+		return true
+	}
+
+	if _, ok := resCC["must-revalidate"]; ok {
+		return false
+	}
+
+	if maxStale, ok := reqCC["max-stale"]; ok {
+		if maxStale == "" {
+			return true
+		}
+
+		if d, ok := reqCC.duration("max-stale"); ok && age-lifetime <= d {
+			return true
+		}
+	}
+
+	return false
+}
+
+// age - RFC 7234 4.2.3 simplified: Age header plus the time in the cache
+func (c *Cache) age(e *Entry) time.Duration {
+	age := c.now().Sub(e.ResponseTime)
+	if seconds, err := strconv.ParseInt(e.Header.Get("Age"), 10, 64); err == nil && seconds > 0 {
+		age += time.Duration(seconds) * time.Second
+	}
+
+	if age < 0 {
+		return 0
+	}
+
+	return age
+}
+
+// freshnessLifetime - RFC 7234 4.2.1: max-age, Expires or 10% of the Last-Modified age
+func (e *Entry) freshnessLifetime() time.Duration {
+	cc := parseCacheControl(e.Header)
+	if d, ok := cc.duration("max-age"); ok {
+		return d
+	}
+
+	date, err := http.ParseTime(e.Header.Get("Date"))
+	if err != nil {
+		date = e.ResponseTime
+	}
+
+	if v := e.Header.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return 0
+		}
+
+		return expires.Sub(date)
+	}
+
+	if v := e.Header.Get("Last-Modified"); v != "" {
+		lastModified, err := http.ParseTime(v)
+		if err == nil && date.After(lastModified) {
+			return date.Sub(lastModified) / 10
+		}
+	}
+
+	return 0
+}
+
+func (e *Entry) matchesVary(req *http.Request) bool {
+	for name, values := range e.VaryHeader {
+		if strings.Join(req.Header.Values(name), ", ") != strings.Join(values, ", ") {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (e *Entry) response(req *http.Request, age time.Duration, status string) *http.Response {
+	header := e.Header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+	header.Set(XCacheHeader, status)
+
+	return &http.Response{
+		Status:        strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+func conditionalRequest(req *http.Request, e *Entry) *http.Request {
+	r := req.Clone(req.Context())
+
+	if etag := e.Header.Get("ETag"); etag != "" {
+		r.Header.Set("If-None-Match", etag)
+	}
+
+	if lastModified := e.Header.Get("Last-Modified"); lastModified != "" {
+		r.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	return r
+}
+
+func varyHeader(req *http.Request, header http.Header) http.Header {
+	vary := http.Header{}
+	for _, v := range header.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name != "" {
+				vary[name] = req.Header.Values(name)
+			}
+		}
+	}
+
+	return vary
+}
+
+func isSafe(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+
+	return false
+}
+
+type cacheControl map[string]string
+
+func parseCacheControl(header http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, v := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(v, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name != "" {
+				cc[name] = strings.Trim(strings.TrimSpace(value), `"`)
+			}
+		}
+	}
+
+	return cc
+}
+
+func (cc cacheControl) duration(name string) (time.Duration, bool) {
+	v, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+
+	seconds, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+
+	return time.Duration(seconds) * time.Second, true
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,223 @@
+package httpcache_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-bolo/bolo/httpcache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2023, time.July, 16, 10, 0, 0, 0, time.UTC)
+
+func stores(t *testing.T) map[string]httpcache.Store {
+	return map[string]httpcache.Store{
+		"memory": httpcache.NewMemoryStore(100),
+		"disk":   httpcache.NewDiskStore(t.TempDir()),
+	}
+}
+
+type testCache struct {
+	*httpcache.Cache
+	now   time.Time
+	calls int32
+}
+
+func newTestCache(store httpcache.Store) *testCache {
+	c := testCache{Cache: httpcache.New(store), now: start}
+	c.Cache.Now = func() time.Time { return c.now }
+	return &c
+}
+
+func (c *testCache) get(t *testing.T, server *httptest.Server, path string, header http.Header) (*http.Response, string) {
+	req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+	require.Nil(t, err)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	res, err := c.Do(req, httpcache.DoerFunc(func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&c.calls, 1)
+		return server.Client().Do(r)
+	}))
+	require.Nil(t, err)
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	require.Nil(t, err)
+	return res, string(body)
+}
+
+func TestCache(t *testing.T) {
+	var version int32 = 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := strconv.Itoa(int(atomic.LoadInt32(&version)))
+
+		switch r.URL.Path {
+		case "/max-age":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("ETag", `"v`+v+`"`)
+			w.Header().Set("Cache-Control", "no-cache")
+			if r.Header.Get("If-None-Match") == `"v`+v+`"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/vary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+			v = r.Header.Get("Accept-Language") + v
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/public":
+			w.Header().Set("Cache-Control", "public, max-age=60")
+			v = r.Header.Get("Authorization") + v
+		}
+
+		w.Write([]byte("v" + v))
+	}))
+	defer server.Close()
+
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			atomic.StoreInt32(&version, 1)
+
+			t.Run("should serve fresh responses from the cache", func(t *testing.T) {
+				c := newTestCache(store)
+
+				res, body := c.get(t, server, "/max-age", nil)
+				assert.Equal(t, httpcache.CacheMiss, res.Header.Get(httpcache.XCacheHeader))
+				assert.Equal(t, "v1", body)
+
+				atomic.StoreInt32(&version, 2)
+				c.now = start.Add(30 * time.Second)
+
+				res, body = c.get(t, server, "/max-age", nil)
+				assert.Equal(t, httpcache.CacheHit, res.Header.Get(httpcache.XCacheHeader))
+				assert.Equal(t, "30", res.Header.Get("Age"))
+				assert.Equal(t, "v1", body)
+				assert.Equal(t, int32(1), atomic.LoadInt32(&c.calls))
+
+				res, body = c.get(t, server, "/max-age", http.Header{"Cache-Control": []string{"no-cache"}})
+				assert.Equal(t, httpcache.CacheMiss, res.Header.Get(httpcache.XCacheHeader))
+				assert.Equal(t, "v2", body)
+
+				c.now = start.Add(2 * time.Minute)
+				atomic.StoreInt32(&version, 3)
+				_, body = c.get(t, server, "/max-age", nil)
+				assert.Equal(t, "v3", body)
+				assert.Equal(t, int32(3), atomic.LoadInt32(&c.calls))
+			})
+
+			t.Run("should revalidate with the etag", func(t *testing.T) {
+				atomic.StoreInt32(&version, 1)
+				c := newTestCache(store)
+
+				res, body := c.get(t, server, "/etag", nil)
+				assert.Equal(t, httpcache.CacheMiss, res.Header.Get(httpcache.XCacheHeader))
+				assert.Equal(t, "v1", body)
+
+				res, body = c.get(t, server, "/etag", nil)
+				assert.Equal(t, httpcache.CacheRevalidated, res.Header.Get(httpcache.XCacheHeader))
+				assert.Equal(t, http.StatusOK, res.StatusCode)
+				assert.Equal(t, "v1", body)
+
+				atomic.StoreInt32(&version, 2)
+				res, body = c.get(t, server, "/etag", nil)
+				assert.Equal(t, httpcache.CacheMiss, res.Header.Get(httpcache.XCacheHeader))
+				assert.Equal(t, "v2", body)
+			})
+
+			t.Run("should respect the vary header", func(t *testing.T) {
+				atomic.StoreInt32(&version, 1)
+				c := newTestCache(store)
+
+				_, body := c.get(t, server, "/vary", http.Header{"Accept-Language": []string{"en"}})
+				assert.Equal(t, "ven1", body)
+
+				_, body = c.get(t, server, "/vary", http.Header{"Accept-Language": []string{"pt"}})
+				assert.Equal(t, "vpt1", body)
+				assert.Equal(t, int32(2), atomic.LoadInt32(&c.calls))
+
+				res, body := c.get(t, server, "/vary", http.Header{"Accept-Language": []string{"pt"}})
+				assert.Equal(t, httpcache.CacheHit, res.Header.Get(httpcache.XCacheHeader))
+				assert.Equal(t, "vpt1", body)
+			})
+
+			t.Run("should not store no-store responses", func(t *testing.T) {
+				c := newTestCache(store)
+
+				c.get(t, server, "/no-store", nil)
+				c.get(t, server, "/no-store", nil)
+				assert.Equal(t, int32(2), atomic.LoadInt32(&c.calls))
+
+				_, err := store.Get(context.Background(), "GET "+server.URL+"/no-store")
+				assert.ErrorIs(t, err, httpcache.ErrNotFound)
+			})
+
+			t.Run("should not share responses of requests with credentials", func(t *testing.T) {
+				atomic.StoreInt32(&version, 1)
+				c := newTestCache(store)
+				alice := http.Header{"Authorization": []string{"Bearer alice"}}
+				bob := http.Header{"Authorization": []string{"Bearer bob"}}
+
+				// private responses are not stored
+				c.get(t, server, "/max-age", alice)
+				res, _ := c.get(t, server, "/max-age", http.Header{"Cookie": []string{"session=alice"}})
+				assert.Equal(t, httpcache.CacheMiss, res.Header.Get(httpcache.XCacheHeader))
+				assert.Equal(t, int32(2), atomic.LoadInt32(&c.calls))
+
+				_, body := c.get(t, server, "/public", alice)
+				assert.Equal(t, "vBearer alice1", body)
+
+				res, body = c.get(t, server, "/public", alice)
+				assert.Equal(t, httpcache.CacheHit, res.Header.Get(httpcache.XCacheHeader))
+				assert.Equal(t, "vBearer alice1", body)
+
+				res, body = c.get(t, server, "/public", bob)
+				assert.Equal(t, httpcache.CacheMiss, res.Header.Get(httpcache.XCacheHeader))
+				assert.Equal(t, "vBearer bob1", body)
+
+				_, body = c.get(t, server, "/public", nil)
+				assert.Equal(t, "v1", body)
+				assert.Equal(t, int32(5), atomic.LoadInt32(&c.calls))
+			})
+
+			t.Run("should invalidate the url after unsafe requests", func(t *testing.T) {
+				c := newTestCache(store)
+				c.get(t, server, "/max-age", nil)
+
+				req, _ := http.NewRequest(http.MethodPost, server.URL+"/max-age", nil)
+				res, err := c.Do(req, server.Client())
+				require.Nil(t, err)
+				res.Body.Close()
+
+				_, err = store.Get(context.Background(), "GET "+server.URL+"/max-age")
+				assert.ErrorIs(t, err, httpcache.ErrNotFound)
+			})
+		})
+	}
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+	ctx := context.Background()
+	store := httpcache.NewMemoryStore(2)
+
+	require.Nil(t, store.Set(ctx, "a", &httpcache.Entry{StatusCode: 200}))
+	require.Nil(t, store.Set(ctx, "b", &httpcache.Entry{StatusCode: 200}))
+	_, err := store.Get(ctx, "a")
+	require.Nil(t, err)
+	require.Nil(t, store.Set(ctx, "c", &httpcache.Entry{StatusCode: 200}))
+
+	_, err = store.Get(ctx, "b")
+	assert.ErrorIs(t, err, httpcache.ErrNotFound)
+	_, err = store.Get(ctx, "a")
+	assert.Nil(t, err)
+}
@@ -0,0 +1,159 @@
+package httpcache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// MemoryStore - In process store with least recently used eviction
+type MemoryStore struct {
+	// Max stored entries, 0 for no limit
+	MaxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+type memoryEntry struct {
+	key   string
+	entry *Entry
+}
+
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	return &MemoryStore{
+		MaxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	s.lru.MoveToFront(el)
+	e := *el.Value.(*memoryEntry).entry
+	e.Header = e.Header.Clone()
+	return &e, nil
+}
+
+func (s *MemoryStore) Set(ctx context.Context, key string, e *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[key]; ok {
+		el.Value.(*memoryEntry).entry = e
+		s.lru.MoveToFront(el)
+		return nil
+	}
+
+	s.entries[key] = s.lru.PushFront(&memoryEntry{key: key, entry: e})
+
+	if s.MaxEntries > 0 && s.lru.Len() > s.MaxEntries {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryEntry).key)
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[key]; ok {
+		s.lru.Remove(el)
+		delete(s.entries, key)
+	}
+
+	return nil
+}
+
+// DiskStore - One JSON file per entry in Dir, shared between processes in the same host
+type DiskStore struct {
+	Dir string
+}
+
+func NewDiskStore(dir string) *DiskStore {
+	return &DiskStore{Dir: dir}
+}
+
+func (s *DiskStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (s *DiskStore) Get(ctx context.Context, key string) (*Entry, error) {
+	data, err := os.ReadFile(s.path(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("httpcache.DiskStore.Get: %w", err)
+	}
+
+	e := Entry{}
+	if err := json.Unmarshal(data, &e); err != nil {
+		// corrupted files are equal to missing entries
+		return nil, ErrNotFound
+	}
+
+	return &e, nil
+}
+
+// Set - Write the entry in one temporary file and rename it, readers never see partial files
+func (s *DiskStore) Set(ctx context.Context, key string, e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("httpcache.DiskStore.Set: %w", err)
+	}
+
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return fmt.Errorf("httpcache.DiskStore.Set: %w", err)
+	}
+
+	f, err := os.CreateTemp(s.Dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("httpcache.DiskStore.Set: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("httpcache.DiskStore.Set: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("httpcache.DiskStore.Set: %w", err)
+	}
+
+	if err := os.Rename(f.Name(), s.path(key)); err != nil {
+		return fmt.Errorf("httpcache.DiskStore.Set: %w", err)
+	}
+
+	return nil
+}
+
+func (s *DiskStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("httpcache.DiskStore.Delete: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package bolo
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/go-bolo/bolo/i18n"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"golang.org/x/text/language"
+)
+
+// PluginWithLocales - Plugins with message catalogs (.json or .po) to load on bootstrap
+type PluginWithLocales interface {
+	GetLocalesDir() string
+}
+
+// LoadLocales - Load the message catalogs from plugins and the active theme.
+// Plugins are loaded sorted by name and the theme is loaded after plugins to allow override plugin messages
+func (app *DefaultApp) LoadLocales() error {
+	l := app.GetLogger().With(zap.String("func", "LoadLocales"))
+
+	names := []string{}
+	for name := range app.Plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := app.Plugins[name]
+		if lp, ok := p.(PluginWithLocales); ok && lp.GetLocalesDir() != "" {
+			err := app.I18n.LoadDir(lp.GetLocalesDir())
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// only the active theme, the messages of other themes can use the same keys
+	if theme := app.GetTheme(); theme != "" {
+		rootDir := app.Configuration.GetF(TEMPLATE_FOLDER, "./themes")
+
+		err := app.I18n.LoadDir(filepath.Join(rootDir, theme, "locales"))
+		if err != nil {
+			return err
+		}
+	}
+
+	l.Debug("locales loaded", zap.Strings("locales", app.I18n.GetLocales()))
+
+	return nil
+}
+
+// GetLocale - Returns the current request locale, resolved on first use from the
+// authenticated user language, the locale cookie, the Accept-Language header or the default locale
+func GetLocale(c echo.Context) string {
+	if locale, ok := c.Get("locale").(string); ok && locale != "" {
+		return locale
+	}
+
+	locale := ResolveLocale(c)
+	SetLocale(c, locale)
+	return locale
+}
+
+func SetLocale(c echo.Context, locale string) {
+	c.Set("locale", i18n.NormalizeLocale(locale))
+}
+
+func ResolveLocale(c echo.Context) string {
+	app := GetApp(c)
+	t := app.GetI18n()
+
+	if user := GetAuthenticatedUser(c); user != nil && user.GetLanguage() != "" {
+		if locale := t.MatchLocale(user.GetLanguage()); locale != "" {
+			return locale
+		}
+	}
+
+	cookieName := app.GetConfiguration().GetF(LOCALE_COOKIE_NAME, "locale")
+	if cookie, err := c.Cookie(cookieName); err == nil && cookie.Value != "" {
+		if locale := t.MatchLocale(cookie.Value); locale != "" {
+			return locale
+		}
+	}
+
+	// tags sorted by quality, * and invalid tags are ignored
+	tags, qualities, _ := language.ParseAcceptLanguage(c.Request().Header.Get("Accept-Language"))
+
+	candidates := []string{}
+	for n, tag := range tags {
+		if qualities[n] > 0 && tag != language.Und {
+			candidates = append(candidates, tag.String())
+		}
+	}
+
+	if locale := t.MatchLocale(candidates...); locale != "" {
+		return locale
+	}
+
+	return t.GetDefaultLocale()
+}
+
+// Translate - Translate one message to the current request locale
+func Translate(c echo.Context, key string, args ...interface{}) string {
+	return GetApp(c).GetI18n().Translate(GetLocale(c), key, args...)
+}
+
+// TranslatePlural - Translate one message with plural forms to the current request locale
+func TranslatePlural(c echo.Context, singular, plural string, n int, args ...interface{}) string {
+	return GetApp(c).GetI18n().TranslatePlural(GetLocale(c), singular, plural, n, args...)
+}
+
+// TranslateValidationError - Returns the validation error message in the request locale.
+// Messages use the "validation.[tag]" keys with the field name and param as arguments
+func TranslateValidationError(c echo.Context, fe validator.FieldError) string {
+	t := GetApp(c).GetI18n()
+	locale := GetLocale(c)
+
+	// Lookup falls back to the base language and the default locale
+	for _, key := range []string{"validation." + fe.Tag(), "validation.default"} {
+		if _, ok := t.Lookup(locale, key); ok {
+			return t.Translate(locale, key, fe.Field(), fe.Param())
+		}
+	}
+
+	return fe.Error()
+}
+
+func translateTemplateFunc(c echo.Context, key string, args ...interface{}) string {
+	return Translate(c, key, args...)
+}
+
+func translatePluralTemplateFunc(c echo.Context, singular, plural string, n int, args ...interface{}) string {
+	return TranslatePlural(c, singular, plural, n, args...)
+}
+
+func localeTemplateFunc(c echo.Context) string {
+	return GetLocale(c)
+}
@@ -0,0 +1,246 @@
+package i18n
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-bolo/bolo/helpers"
+	"github.com/shopspring/decimal"
+)
+
+// NumberFormat - Number symbols and patterns of one locale. In patterns "#" is replaced with the number and "¤" with the currency symbol
+type NumberFormat struct {
+	Decimal         string
+	Group           string
+	CurrencyPattern string
+	PercentPattern  string
+}
+
+// DateFormat - Go time layouts for each date style of one locale. Month and week day names are translated with the
+// "date.month.[1-12]", "date.month_short.[1-12]" and "date.weekday.[0-6]" messages
+type DateFormat struct {
+	Short  string
+	Medium string
+	Long   string
+	Full   string
+	Time   string
+}
+
+const nbsp = "\u00a0"
+
+var NumberFormats = map[string]*NumberFormat{
+	"en":    {Decimal: ".", Group: ",", CurrencyPattern: "¤#", PercentPattern: "#%"},
+	"pt":    {Decimal: ",", Group: ".", CurrencyPattern: "¤" + nbsp + "#", PercentPattern: "#%"},
+	"pt-PT": {Decimal: ",", Group: nbsp, CurrencyPattern: "#" + nbsp + "¤", PercentPattern: "#%"},
+	"es":    {Decimal: ",", Group: ".", CurrencyPattern: "#" + nbsp + "¤", PercentPattern: "#" + nbsp + "%"},
+	"es-MX": {Decimal: ".", Group: ",", CurrencyPattern: "¤#", PercentPattern: "#" + nbsp + "%"},
+	"fr":    {Decimal: ",", Group: " ", CurrencyPattern: "#" + nbsp + "¤", PercentPattern: "#" + nbsp + "%"},
+	"de":    {Decimal: ",", Group: ".", CurrencyPattern: "#" + nbsp + "¤", PercentPattern: "#" + nbsp + "%"},
+	"it":    {Decimal: ",", Group: ".", CurrencyPattern: "#" + nbsp + "¤", PercentPattern: "#%"},
+	"ja":    {Decimal: ".", Group: ",", CurrencyPattern: "¤#", PercentPattern: "#%"},
+}
+
+var DateFormats = map[string]*DateFormat{
+	"en": {Short: "1/2/06", Medium: "Jan 2, 2006", Long: "January 2, 2006", Full: "Monday, January 2, 2006", Time: "3:04 PM"},
+	"pt": {Short: "02/01/2006", Medium: "2 de Jan de 2006", Long: "2 de January de 2006", Full: "Monday, 2 de January de 2006", Time: "15:04"},
+	"es": {Short: "2/1/06", Medium: "2 Jan 2006", Long: "2 de January de 2006", Full: "Monday, 2 de January de 2006", Time: "15:04"},
+}
+
+var currencySymbols = map[string]string{
+	"USD": "US$",
+	"EUR": "€",
+	"GBP": "£",
+	"BRL": "R$",
+	"JPY": "¥",
+	"CNY": "CN¥",
+	"MXN": "MX$",
+	"ARS": "ARS",
+	"CAD": "CA$",
+	"AUD": "A$",
+}
+
+// currency symbols used only in one locale. Ex: USD is "$" in en-US and "US$" in pt-BR
+var localCurrencySymbols = map[string]map[string]string{
+	"en":    {"USD": "$"},
+	"en-US": {"USD": "$"},
+	"en-CA": {"CAD": "$"},
+	"en-AU": {"AUD": "$"},
+	"es-MX": {"MXN": "$"},
+	"es-AR": {"ARS": "$"},
+}
+
+var currencyPlaces = map[string]int32{
+	"JPY": 0,
+}
+
+// GetNumberFormat - Returns the number format of the locale with fallback to the base language and then english
+func GetNumberFormat(locale string) *NumberFormat {
+	if f, ok := NumberFormats[NormalizeLocale(locale)]; ok {
+		return f
+	}
+
+	if f, ok := NumberFormats[BaseLanguage(locale)]; ok {
+		return f
+	}
+
+	return NumberFormats["en"]
+}
+
+// GetDateFormat - Returns the date format of the locale with fallback to the base language and then english
+func GetDateFormat(locale string) *DateFormat {
+	if f, ok := DateFormats[NormalizeLocale(locale)]; ok {
+		return f
+	}
+
+	if f, ok := DateFormats[BaseLanguage(locale)]; ok {
+		return f
+	}
+
+	return DateFormats["en"]
+}
+
+// CurrencySymbol - Returns the symbol of one ISO 4217 currency code for the locale
+func CurrencySymbol(locale, code string) string {
+	code = strings.ToUpper(code)
+
+	if s, ok := localCurrencySymbols[NormalizeLocale(locale)][code]; ok {
+		return s
+	}
+
+	if s, ok := currencySymbols[code]; ok {
+		return s
+	}
+
+	return code
+}
+
+// FormatDecimal - Format one number with the locale separators, places < 0 keeps all decimal places
+func FormatDecimal(locale string, value decimal.Decimal, places int32) string {
+	f := GetNumberFormat(locale)
+	return helpers.FormatDecimal(value, places, f.Group, f.Decimal)
+}
+
+// FormatCurrency - Format one amount with the currency symbol and the locale pattern. Ex: R$ 1.234,50
+func FormatCurrency(locale string, value decimal.Decimal, currencyCode string) string {
+	f := GetNumberFormat(locale)
+
+	places, ok := currencyPlaces[strings.ToUpper(currencyCode)]
+	if !ok {
+		places = 2
+	}
+
+	negative := value.IsNegative()
+	number := helpers.FormatDecimal(value.Abs(), places, f.Group, f.Decimal)
+
+	out := strings.Replace(f.CurrencyPattern, "#", number, 1)
+	out = strings.Replace(out, "¤", CurrencySymbol(locale, currencyCode), 1)
+
+	if negative {
+		return "-" + out
+	}
+
+	return out
+}
+
+// FormatPercent - Format one ratio as percentage. Ex: 0.255 -> 25,5%
+func FormatPercent(locale string, value decimal.Decimal, places int32) string {
+	f := GetNumberFormat(locale)
+	number := helpers.FormatDecimal(value.Shift(2), places, f.Group, f.Decimal)
+	return strings.Replace(f.PercentPattern, "#", number, 1)
+}
+
+// FormatDate - Format one date with one locale style (short, medium, long, full, time or datetime) or a Go layout.
+func FormatDate(tr I18n, locale string, date time.Time, style string) string {
+	f := GetDateFormat(locale)
+
+	layout := style
+	switch style {
+	case "", "medium":
+		layout = f.Medium
+	case "short":
+		layout = f.Short
+	case "long":
+		layout = f.Long
+	case "full":
+		layout = f.Full
+	case "time":
+		layout = f.Time
+	case "datetime":
+		layout = f.Short + " " + f.Time
+	}
+
+	return translateDateNames(tr, locale, date, date.Format(layout), layout)
+}
+
+// translateDateNames replace the english month and week day names generated by the Go layout
+func translateDateNames(tr I18n, locale string, date time.Time, text, layout string) string {
+	if tr == nil {
+		return text
+	}
+
+	replacements := []struct {
+		token, english, key string
+	}{
+		{"January", date.Month().String(), "date.month." + strconv.Itoa(int(date.Month()))},
+		{"Monday", date.Weekday().String(), "date.weekday." + strconv.Itoa(int(date.Weekday()))},
+		{"Jan", date.Month().String()[:3], "date.month_short." + strconv.Itoa(int(date.Month()))},
+		{"Mon", date.Weekday().String()[:3], "date.weekday_short." + strconv.Itoa(int(date.Weekday()))},
+	}
+
+	for _, r := range replacements {
+		if !strings.Contains(layout, r.token) {
+			continue
+		}
+
+		// avoid replace the short name inside the long one
+		if (r.token == "Jan" && strings.Contains(layout, "January")) || (r.token == "Mon" && strings.Contains(layout, "Monday")) {
+			continue
+		}
+
+		if m, ok := tr.Lookup(locale, r.key); ok {
+			text = strings.Replace(text, r.english, m.Get(PluralOther), 1)
+		}
+	}
+
+	return text
+}
+
+// relative time units from the biggest to the smallest
+var relativeUnits = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"year", 365 * 24 * time.Hour},
+	{"month", 30 * 24 * time.Hour},
+	{"week", 7 * 24 * time.Hour},
+	{"day", 24 * time.Hour},
+	{"hour", time.Hour},
+	{"minute", time.Minute},
+	{"second", time.Second},
+}
+
+// FormatRelativeTime - Format the distance between date and now. Ex: "3 days ago" or "in 2 hours".
+// Messages are translated with the "relative.[unit].past" and "relative.[unit].future" plural keys
+func FormatRelativeTime(tr I18n, locale string, date, now time.Time) string {
+	diff := now.Sub(date)
+	direction := "past"
+	if diff < 0 {
+		direction = "future"
+		diff = -diff
+	}
+
+	if diff < 45*time.Second {
+		return tr.Translate(locale, "relative.now")
+	}
+
+	for _, u := range relativeUnits {
+		if diff >= u.duration {
+			n := int(diff / u.duration)
+			key := "relative." + u.name + "." + direction
+			return tr.TranslatePlural(locale, key, key, n, n)
+		}
+	}
+
+	return tr.Translate(locale, "relative.now")
+}
@@ -0,0 +1,45 @@
+package i18n_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-bolo/bolo/i18n"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatNumbers(t *testing.T) {
+	value := decimal.RequireFromString("-1234567.891")
+
+	assert.Equal(t, "-1,234,567.89", i18n.FormatDecimal("en-US", value, 2))
+	assert.Equal(t, "-1.234.567,891", i18n.FormatDecimal("pt-BR", value, -1))
+	assert.Equal(t, "R$\u00a01.234,50", i18n.FormatCurrency("pt-BR", decimal.RequireFromString("1234.5"), "BRL"))
+	assert.Equal(t, "$1,234.50", i18n.FormatCurrency("en-US", decimal.RequireFromString("1234.5"), "usd"))
+	assert.Equal(t, "-1.234,50\u00a0€", i18n.FormatCurrency("es", decimal.RequireFromString("-1234.5"), "EUR"))
+	assert.Equal(t, "¥1,235", i18n.FormatCurrency("ja", decimal.RequireFromString("1234.5"), "JPY"))
+	assert.Equal(t, "25,5%", i18n.FormatPercent("pt-BR", decimal.RequireFromString("0.255"), 1))
+	assert.Equal(t, "26\u00a0%", i18n.FormatPercent("es", decimal.RequireFromString("0.255"), 0))
+}
+
+func TestFormatDates(t *testing.T) {
+	tr := i18n.NewI18n(&i18n.NewI18nOpts{})
+	date := time.Date(2023, time.July, 16, 14, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, "Jul 16, 2023", i18n.FormatDate(tr, "en", date, "medium"))
+	assert.Equal(t, "16/07/2023", i18n.FormatDate(tr, "pt-BR", date, "short"))
+	assert.Equal(t, "domingo, 16 de julio de 2023", i18n.FormatDate(tr, "es", date, "full"))
+	assert.Equal(t, "16 de jul. de 2023", i18n.FormatDate(tr, "pt-BR", date, "medium"))
+	assert.Equal(t, "2023-07-16", i18n.FormatDate(tr, "pt-BR", date, "2006-01-02"))
+}
+
+func TestFormatRelativeTime(t *testing.T) {
+	tr := i18n.NewI18n(&i18n.NewI18nOpts{})
+	now := time.Date(2023, time.July, 16, 14, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, "3 days ago", i18n.FormatRelativeTime(tr, "en", now.Add(-72*time.Hour), now))
+	assert.Equal(t, "in 1 hour", i18n.FormatRelativeTime(tr, "en", now.Add(61*time.Minute), now))
+	assert.Equal(t, "há 2 meses", i18n.FormatRelativeTime(tr, "pt-BR", now.Add(-65*24*time.Hour), now))
+	assert.Equal(t, "hace 1 año", i18n.FormatRelativeTime(tr, "es", now.Add(-366*24*time.Hour), now))
+	assert.Equal(t, "agora mesmo", i18n.FormatRelativeTime(tr, "pt", now.Add(-10*time.Second), now))
+}
@@ -0,0 +1,352 @@
+package i18n
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/language"
+)
+
+//go:embed locales/*.json
+var builtinLocales embed.FS
+
+type I18n interface {
+	GetDefaultLocale() string
+	SetDefaultLocale(locale string) error
+	// Locales with at least one loaded message
+	GetLocales() []string
+
+	AddMessages(locale string, messages map[string]*Message) error
+	// LoadFile - Load one .json or .po catalog, the locale is the file name. Ex: pt-BR.json
+	LoadFile(path string) error
+	// LoadDir - Load all catalogs from one folder, missing folders are ignored
+	LoadDir(dir string) error
+
+	Lookup(locale, key string) (*Message, bool)
+	Translate(locale, key string, args ...interface{}) string
+	TranslatePlural(locale, singular, plural string, n int, args ...interface{}) string
+	// MatchLocale returns the best available locale for the candidates or "" if none matches
+	MatchLocale(candidates ...string) string
+}
+
+// Message - One translated message with the plural forms indexed by CLDR category (one, few, many, other...)
+type Message struct {
+	Forms map[string]string
+}
+
+func NewMessage(text string) *Message {
+	return &Message{Forms: map[string]string{PluralOther: text}}
+}
+
+// Get returns the text for one plural category with fallback to "other"
+func (m *Message) Get(category string) string {
+	if v, ok := m.Forms[category]; ok && v != "" {
+		return v
+	}
+
+	return m.Forms[PluralOther]
+}
+
+type NewI18nOpts struct {
+	DefaultLocale string
+	// Skip the messages embedded in this package (validation messages, relative times...)
+	DisableBuiltinMessages bool
+}
+
+func NewI18n(opts *NewI18nOpts) I18n {
+	i := &DefaultI18n{
+		DefaultLocale: NormalizeLocale(opts.DefaultLocale),
+		Catalogs:      make(map[string]map[string]*Message),
+	}
+
+	if i.DefaultLocale == "" {
+		i.DefaultLocale = "en"
+	}
+
+	if !opts.DisableBuiltinMessages {
+		i.loadBuiltinMessages()
+	}
+
+	return i
+}
+
+type DefaultI18n struct {
+	DefaultLocale string
+	// Messages indexed by normalized locale and key
+	Catalogs map[string]map[string]*Message
+
+	mu sync.RWMutex
+}
+
+func (i *DefaultI18n) GetDefaultLocale() string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.DefaultLocale
+}
+
+func (i *DefaultI18n) SetDefaultLocale(locale string) error {
+	if locale == "" {
+		return fmt.Errorf("SetDefaultLocale: locale cannot be empty")
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.DefaultLocale = NormalizeLocale(locale)
+	return nil
+}
+
+// GetLocales - Returns the loaded locales, sorted
+func (i *DefaultI18n) GetLocales() []string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.locales()
+}
+
+// locales - Sorted catalog locales, called with the lock
+func (i *DefaultI18n) locales() []string {
+	locales := []string{}
+	for l := range i.Catalogs {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+
+	return locales
+}
+
+func (i *DefaultI18n) AddMessages(locale string, messages map[string]*Message) error {
+	if locale == "" {
+		return fmt.Errorf("AddMessages: locale is required")
+	}
+
+	locale = NormalizeLocale(locale)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	catalog := i.Catalogs[locale]
+	if catalog == nil {
+		catalog = make(map[string]*Message)
+		i.Catalogs[locale] = catalog
+	}
+
+	for k, m := range messages {
+		catalog[k] = m
+	}
+
+	return nil
+}
+
+func (i *DefaultI18n) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("i18n.LoadFile: %w", err)
+	}
+
+	ext := filepath.Ext(path)
+	locale := strings.TrimSuffix(filepath.Base(path), ext)
+
+	var messages map[string]*Message
+
+	switch ext {
+	case ".json":
+		messages, err = ParseJSON(data)
+	case ".po":
+		messages, err = ParsePO(data, locale)
+	default:
+		return fmt.Errorf("i18n.LoadFile: unsupported catalog format: %s", path)
+	}
+
+	if err != nil {
+		return fmt.Errorf("i18n.LoadFile: error on parse %s: %w", path, err)
+	}
+
+	return i.AddMessages(locale, messages)
+}
+
+func (i *DefaultI18n) LoadDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return fmt.Errorf("i18n.LoadDir: %w", err)
+	}
+
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+
+		ext := filepath.Ext(e.Name())
+		if ext != ".json" && ext != ".po" {
+			continue
+		}
+
+		err := i.LoadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Lookup - Find one message for the locale, with fallback from region to the base language. Ex: pt-BR -> pt
+func (i *DefaultI18n) Lookup(locale, key string) (*Message, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	for _, l := range localeFallbacks(locale, i.DefaultLocale) {
+		if m, ok := i.Catalogs[l][key]; ok {
+			return m, true
+		}
+	}
+
+	return nil, false
+}
+
+func (i *DefaultI18n) Translate(locale, key string, args ...interface{}) string {
+	text := key
+
+	if m, ok := i.Lookup(locale, key); ok {
+		text = m.Get(PluralOther)
+	}
+
+	return format(text, args)
+}
+
+func (i *DefaultI18n) TranslatePlural(locale, singular, plural string, n int, args ...interface{}) string {
+	if len(args) == 0 {
+		args = []interface{}{n}
+	}
+
+	m, ok := i.Lookup(locale, singular)
+	if !ok {
+		// untranslated text use the english rule
+		if n == 1 {
+			return format(singular, args)
+		}
+
+		return format(plural, args)
+	}
+
+	return format(m.Get(PluralCategory(locale, n)), args)
+}
+
+// MatchLocale - Returns the loaded locale that best matches the candidates in preference order, with
+// the BCP 47 matching of golang.org/x/text (regions, scripts and similar languages). Returns "" without match
+func (i *DefaultI18n) MatchLocale(candidates ...string) string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	locales := []string{}
+	supported := []language.Tag{}
+	for _, l := range i.locales() {
+		if tag, err := language.Parse(l); err == nil {
+			locales = append(locales, l)
+			supported = append(supported, tag)
+		}
+	}
+
+	desired := []language.Tag{}
+	for _, c := range candidates {
+		if c = NormalizeLocale(c); c == "" {
+			continue
+		}
+
+		if tag, err := language.Parse(c); err == nil {
+			desired = append(desired, tag)
+		}
+	}
+
+	if len(supported) == 0 || len(desired) == 0 {
+		return ""
+	}
+
+	_, index, confidence := language.NewMatcher(supported).Match(desired...)
+	if confidence == language.No {
+		return ""
+	}
+
+	return locales[index]
+}
+
+func (i *DefaultI18n) loadBuiltinMessages() {
+	entries, _ := builtinLocales.ReadDir("locales")
+	for _, e := range entries {
+		data, err := builtinLocales.ReadFile("locales/" + e.Name())
+		if err != nil {
+			continue
+		}
+
+		messages, err := ParseJSON(data)
+		if err != nil {
+			continue
+		}
+
+		i.AddMessages(strings.TrimSuffix(e.Name(), ".json"), messages)
+	}
+}
+
+// NormalizeLocale - Normalize locale names to the language-REGION format. Ex: pt_br -> pt-BR
+func NormalizeLocale(locale string) string {
+	locale = strings.TrimSpace(strings.Replace(locale, "_", "-", -1))
+	if locale == "" || locale == "*" {
+		return ""
+	}
+
+	parts := strings.Split(locale, "-")
+	parts[0] = strings.ToLower(parts[0])
+	if len(parts) > 1 && len(parts[1]) == 2 {
+		parts[1] = strings.ToUpper(parts[1])
+	}
+
+	return strings.Join(parts, "-")
+}
+
+// BaseLanguage - Returns the language part of one locale. Ex: pt-BR -> pt
+func BaseLanguage(locale string) string {
+	return strings.SplitN(NormalizeLocale(locale), "-", 2)[0]
+}
+
+func localeFallbacks(locale, defaultLocale string) []string {
+	locale = NormalizeLocale(locale)
+	list := []string{}
+
+	for _, l := range []string{locale, BaseLanguage(locale), defaultLocale, BaseLanguage(defaultLocale)} {
+		if l == "" {
+			continue
+		}
+
+		found := false
+		for _, v := range list {
+			if v == l {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			list = append(list, l)
+		}
+	}
+
+	return list
+}
+
+func format(text string, args []interface{}) string {
+	if len(args) == 0 || !strings.Contains(text, "%") {
+		return text
+	}
+
+	return fmt.Sprintf(text, args...)
+}
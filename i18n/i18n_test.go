@@ -0,0 +1,121 @@
+package i18n_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-bolo/bolo/i18n"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPluralCategory(t *testing.T) {
+	tests := []struct {
+		locale string
+		n      int
+		want   string
+	}{
+		{"en", 1, i18n.PluralOne},
+		{"en", 0, i18n.PluralOther},
+		{"pt-BR", 0, i18n.PluralOne},
+		{"pt-PT", 0, i18n.PluralOther},
+		{"es", 2, i18n.PluralOther},
+		{"ru", 21, i18n.PluralOne},
+		{"ru", 22, i18n.PluralFew},
+		{"ru", 12, i18n.PluralMany},
+		{"ja", 1, i18n.PluralOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			assert.Equal(t, tt.want, i18n.PluralCategory(tt.locale, tt.n))
+		})
+	}
+}
+
+func TestParsePO(t *testing.T) {
+	po := `
+# header
+msgid ""
+msgstr ""
+"Plural-Forms: nplurals=2; plural=(n > 1);\n"
+
+msgid "Hello"
+msgstr "Olá"
+
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] "%d arquivo"
+msgstr[1] "%d arquivos"
+
+msgid "Long"
+msgstr ""
+"Texto "
+"longo"
+
+msgid "Untranslated"
+msgstr ""
+`
+	messages, err := i18n.ParsePO([]byte(po), "pt-BR")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(messages))
+	assert.Equal(t, "Olá", messages["Hello"].Get(i18n.PluralOther))
+	assert.Equal(t, "%d arquivo", messages["%d file"].Get(i18n.PluralOne))
+	assert.Equal(t, "%d arquivos", messages["%d file"].Get(i18n.PluralOther))
+	assert.Equal(t, "Texto longo", messages["Long"].Get(i18n.PluralOther))
+}
+
+func TestDefaultI18n(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "pt-BR.json"), []byte(`{
+		"Hello %s": "Olá %s",
+		"%d item": {"one": "%d item", "other": "%d itens"}
+	}`), 0644)
+	assert.Nil(t, err)
+
+	tr := i18n.NewI18n(&i18n.NewI18nOpts{DefaultLocale: "en"})
+	err = tr.LoadDir(dir)
+	assert.Nil(t, err)
+
+	t.Run("translate with region and base language", func(t *testing.T) {
+		assert.Equal(t, "Olá Maria", tr.Translate("pt-BR", "Hello %s", "Maria"))
+		assert.Equal(t, "Olá Maria", tr.Translate("pt_br", "Hello %s", "Maria"))
+		assert.Equal(t, "Hello Maria", tr.Translate("fr", "Hello %s", "Maria"))
+	})
+
+	t.Run("translate plural", func(t *testing.T) {
+		assert.Equal(t, "0 item", tr.TranslatePlural("pt-BR", "%d item", "%d items", 0))
+		assert.Equal(t, "3 itens", tr.TranslatePlural("pt-BR", "%d item", "%d items", 3))
+		assert.Equal(t, "0 items", tr.TranslatePlural("en", "%d item", "%d items", 0))
+	})
+
+	t.Run("match locale", func(t *testing.T) {
+		assert.Equal(t, "pt-BR", tr.MatchLocale("pt-br"))
+		assert.Equal(t, "pt", tr.MatchLocale("pt-PT"))
+		assert.Equal(t, "es", tr.MatchLocale("de", "es-AR"))
+		assert.Equal(t, "", tr.MatchLocale("de"))
+	})
+
+	t.Run("builtin validation messages", func(t *testing.T) {
+		assert.Equal(t, "Nome es obligatorio", tr.Translate("es", "validation.required", "Nome"))
+	})
+
+	t.Run("match the first region of the base language", func(t *testing.T) {
+		tr := i18n.NewI18n(&i18n.NewI18nOpts{DisableBuiltinMessages: true})
+		for _, locale := range []string{"de-CH", "de-AT", "fr-CA"} {
+			assert.Nil(t, tr.AddMessages(locale, map[string]*i18n.Message{"Hello": i18n.NewMessage("Hallo")}))
+		}
+
+		assert.Equal(t, []string{"de-AT", "de-CH", "fr-CA"}, tr.GetLocales())
+		for n := 0; n < 10; n++ {
+			assert.Equal(t, "de-AT", tr.MatchLocale("de-DE"))
+		}
+	})
+
+	t.Run("normalize the default locale", func(t *testing.T) {
+		tr := i18n.NewI18n(&i18n.NewI18nOpts{DefaultLocale: "pt_br"})
+		assert.Equal(t, "pt-BR", tr.GetDefaultLocale())
+
+		assert.Nil(t, tr.SetDefaultLocale("en_us"))
+		assert.Equal(t, "en-US", tr.GetDefaultLocale())
+	})
+}
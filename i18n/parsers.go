@@ -0,0 +1,167 @@
+package i18n
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseJSON - Parse one JSON catalog. Values can be a string or an object with the plural forms:
+//
+//	{"Hello": "Olá", "%d item": {"one": "%d item", "other": "%d itens"}}
+func ParseJSON(data []byte) (map[string]*Message, error) {
+	raw := map[string]json.RawMessage{}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make(map[string]*Message)
+
+	for key, v := range raw {
+		var text string
+		if err := json.Unmarshal(v, &text); err == nil {
+			messages[key] = NewMessage(text)
+			continue
+		}
+
+		forms := map[string]string{}
+		if err := json.Unmarshal(v, &forms); err != nil {
+			return nil, fmt.Errorf("invalid message value for key %s: %w", key, err)
+		}
+
+		messages[key] = &Message{Forms: forms}
+	}
+
+	return messages, nil
+}
+
+// ParsePO - Parse one gettext PO catalog. Plural forms (msgstr[n]) are mapped to the CLDR categories of the locale.
+func ParsePO(data []byte, locale string) (map[string]*Message, error) {
+	messages := make(map[string]*Message)
+	categories := PluralCategories(locale)
+
+	var msgID, msgIDPlural string
+	var msgStr map[int]string
+	// pointer to the last string to support multiline values
+	var last *string
+	var lastIndex = -1
+
+	flush := func() {
+		if msgID != "" && msgStr != nil {
+			m := &Message{Forms: map[string]string{}}
+
+			if msgIDPlural == "" {
+				m.Forms[PluralOther] = msgStr[0]
+			} else {
+				for i, text := range msgStr {
+					if i < len(categories) {
+						m.Forms[categories[i]] = text
+					}
+				}
+			}
+
+			if !isEmptyMessage(m) {
+				messages[msgID] = m
+			}
+		}
+
+		msgID, msgIDPlural, msgStr, last, lastIndex = "", "", nil, nil, -1
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "msgctxt "):
+			flush()
+			last = nil
+		case strings.HasPrefix(line, "msgid_plural "):
+			v, err := unquotePO(line[len("msgid_plural "):])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			msgIDPlural = v
+			last, lastIndex = &msgIDPlural, -1
+		case strings.HasPrefix(line, "msgid "):
+			if msgStr != nil {
+				flush()
+			}
+			v, err := unquotePO(line[len("msgid "):])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			msgID = v
+			last, lastIndex = &msgID, -1
+		case strings.HasPrefix(line, "msgstr"):
+			index := 0
+			rest := line[len("msgstr"):]
+			if strings.HasPrefix(rest, "[") {
+				end := strings.Index(rest, "]")
+				if end == -1 {
+					return nil, fmt.Errorf("line %d: invalid msgstr index", lineNumber)
+				}
+				i, err := strconv.Atoi(rest[1:end])
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid msgstr index: %w", lineNumber, err)
+				}
+				index, rest = i, rest[end+1:]
+			}
+
+			v, err := unquotePO(strings.TrimSpace(rest))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+
+			if msgStr == nil {
+				msgStr = map[int]string{}
+			}
+			msgStr[index] = v
+			last, lastIndex = nil, index
+		case strings.HasPrefix(line, `"`):
+			v, err := unquotePO(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+
+			if last != nil {
+				*last += v
+			} else if lastIndex >= 0 && msgStr != nil {
+				msgStr[lastIndex] += v
+			}
+		default:
+			return nil, fmt.Errorf("line %d: unexpected content", lineNumber)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	flush()
+
+	return messages, nil
+}
+
+func unquotePO(s string) (string, error) {
+	return strconv.Unquote(strings.TrimSpace(s))
+}
+
+func isEmptyMessage(m *Message) bool {
+	for _, v := range m.Forms {
+		if v != "" {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,87 @@
+package i18n
+
+// CLDR plural categories
+const (
+	PluralZero  = "zero"
+	PluralOne   = "one"
+	PluralTwo   = "two"
+	PluralFew   = "few"
+	PluralMany  = "many"
+	PluralOther = "other"
+)
+
+// PluralCategory - Returns the CLDR plural category of one integer for the locale.
+// Only the rules for integers are implemented and languages without a rule use the english one.
+func PluralCategory(locale string, n int) string {
+	if n < 0 {
+		n = -n
+	}
+
+	switch BaseLanguage(locale) {
+	case "ja", "zh", "ko", "vi", "th", "id", "ms", "tr":
+		return PluralOther
+	case "fr":
+		if n == 0 || n == 1 {
+			return PluralOne
+		}
+	case "pt":
+		if NormalizeLocale(locale) == "pt-PT" {
+			if n == 1 {
+				return PluralOne
+			}
+			return PluralOther
+		}
+
+		if n == 0 || n == 1 {
+			return PluralOne
+		}
+	case "ru", "uk", "be":
+		mod10, mod100 := n%10, n%100
+		switch {
+		case mod10 == 1 && mod100 != 11:
+			return PluralOne
+		case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+			return PluralFew
+		default:
+			return PluralMany
+		}
+	case "pl":
+		mod10, mod100 := n%10, n%100
+		switch {
+		case n == 1:
+			return PluralOne
+		case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+			return PluralFew
+		default:
+			return PluralMany
+		}
+	case "cs", "sk":
+		switch {
+		case n == 1:
+			return PluralOne
+		case n >= 2 && n <= 4:
+			return PluralFew
+		}
+	default:
+		if n == 1 {
+			return PluralOne
+		}
+	}
+
+	return PluralOther
+}
+
+// PluralCategories - Returns the plural categories used by the locale in the same order
+// of the gettext msgstr[n] indexes
+func PluralCategories(locale string) []string {
+	switch BaseLanguage(locale) {
+	case "ja", "zh", "ko", "vi", "th", "id", "ms", "tr":
+		return []string{PluralOther}
+	case "ru", "uk", "be", "pl":
+		return []string{PluralOne, PluralFew, PluralMany}
+	case "cs", "sk":
+		return []string{PluralOne, PluralFew, PluralOther}
+	default:
+		return []string{PluralOne, PluralOther}
+	}
+}
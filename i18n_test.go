@@ -0,0 +1,158 @@
+package bolo_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	bolo "github.com/go-bolo/bolo"
+	"github.com/go-bolo/bolo/i18n"
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetLocale(t *testing.T) {
+	app := GetTestApp()
+	err := app.Bootstrap()
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		cookie         string
+		want           string
+	}{
+		{
+			name: "should use the default locale",
+			want: "en",
+		},
+		{
+			name:           "should negotiate with the Accept-Language header",
+			acceptLanguage: "fr;q=0.9, pt-BR, en;q=0.5",
+			want:           "pt-BR",
+		},
+		{
+			name:           "should match the locale with script and region",
+			acceptLanguage: "pt-Latn-BR, en;q=0.5",
+			want:           "pt-BR",
+		},
+		{
+			name:           "should use the default locale with the wildcard",
+			acceptLanguage: "*",
+			want:           "en",
+		},
+		{
+			name:           "should prefer the locale cookie",
+			acceptLanguage: "pt-BR",
+			cookie:         "es",
+			want:           "es",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "locale", Value: tt.cookie})
+			}
+
+			c := app.GetRouter().NewContext(req, httptest.NewRecorder())
+			bolo.SetDefaultValues(c, app)
+
+			assert.Equal(t, tt.want, bolo.GetLocale(c))
+		})
+	}
+
+	t.Run("should translate with theme catalogs", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Language", "pt-BR")
+		c := app.GetRouter().NewContext(req, httptest.NewRecorder())
+		bolo.SetDefaultValues(c, app)
+
+		assert.Equal(t, "Olá", bolo.Translate(c, "Hello"))
+		assert.Equal(t, "2 urls", bolo.TranslatePlural(c, "%d url", "%d urls", 2))
+	})
+
+	t.Run("should translate validation errors", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Language", "pt-BR")
+		c := app.GetRouter().NewContext(req, httptest.NewRecorder())
+		bolo.SetDefaultValues(c, app)
+
+		err := validator.New().Struct(&struct {
+			Title string `validate:"required"`
+		}{})
+		fe := err.(validator.ValidationErrors)[0]
+
+		assert.Equal(t, "Title é obrigatório", bolo.TranslateValidationError(c, fe))
+	})
+}
+
+type localesPlugin struct {
+	Name       string
+	LocalesDir string
+}
+
+func (p *localesPlugin) Init(app bolo.App) error { return nil }
+func (p *localesPlugin) GetName() string         { return p.Name }
+func (p *localesPlugin) GetLocalesDir() string   { return p.LocalesDir }
+
+func TestLoadLocales(t *testing.T) {
+	app := GetTestApp()
+
+	for _, name := range []string{"c", "a", "b"} {
+		dir := t.TempDir()
+		err := os.WriteFile(filepath.Join(dir, "pt-BR.json"), []byte(`{"Plugin message": "Mensagem do plugin `+name+`"}`), 0644)
+		require.Nil(t, err)
+
+		app.AddPlugin(&localesPlugin{Name: "locales-" + name, LocalesDir: dir})
+	}
+
+	require.Nil(t, app.Bootstrap())
+
+	// the plugins are loaded sorted by name, the last one overrides the messages
+	assert.Equal(t, "Mensagem do plugin c", app.GetI18n().Translate("pt-BR", "Plugin message"))
+}
+
+func TestLoadThemeLocales(t *testing.T) {
+	app := GetTestApp()
+
+	dir := t.TempDir()
+	for _, theme := range []string{"blog", "zeta"} {
+		require.Nil(t, os.MkdirAll(filepath.Join(dir, theme, "locales"), 0755))
+		err := os.WriteFile(filepath.Join(dir, theme, "locales", "pt-BR.json"), []byte(`{"Welcome": "Bem-vindo ao `+theme+`"}`), 0644)
+		require.Nil(t, err)
+	}
+
+	t.Setenv("TEMPLATE_FOLDER", dir)
+	app.SetTheme("blog")
+	require.Nil(t, app.LoadLocales())
+
+	// the inactive themes are not loaded
+	assert.Equal(t, "Bem-vindo ao blog", app.GetI18n().Translate("pt-BR", "Welcome"))
+}
+
+func TestTranslateValidationErrorDefaultLocale(t *testing.T) {
+	app := GetTestApp()
+	require.Nil(t, app.Bootstrap())
+	require.Nil(t, app.GetI18n().SetDefaultLocale("pt-BR"))
+	require.Nil(t, app.GetI18n().AddMessages("de", map[string]*i18n.Message{"Hello": i18n.NewMessage("Hallo")}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", "de")
+	c := app.GetRouter().NewContext(req, httptest.NewRecorder())
+	bolo.SetDefaultValues(c, app)
+
+	err := validator.New().Struct(&struct {
+		Title string `validate:"required"`
+	}{})
+	fe := err.(validator.ValidationErrors)[0]
+
+	// de has no validation messages, the default locale is used
+	assert.Equal(t, "Title é obrigatório", bolo.TranslateValidationError(c, fe))
+}
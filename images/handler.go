@@ -0,0 +1,164 @@
+package images
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/go-bolo/bolo/storage"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// Handler - Serve one signed variant, from the disk cache or generated from the storage file
+func (p *Plugin) Handler(c echo.Context) (bolo.Response, error) {
+	req := c.Request()
+
+	key := strings.TrimPrefix(req.URL.Path, p.Path+"/")
+	if err := storage.ValidateKey(key); err != nil {
+		return nil, &bolo.HTTPError{Code: http.StatusNotFound, Message: "Not found", Internal: err}
+	}
+
+	query := req.URL.Query()
+	signature := query.Get("s")
+	query.Del("s")
+
+	opts, err := ParseOptions(query, p.MaxDimension)
+	if err != nil {
+		return nil, &bolo.HTTPError{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+
+	if !hmac.Equal([]byte(p.Sign(key, opts)), []byte(signature)) {
+		return nil, &bolo.HTTPError{Code: http.StatusForbidden, Message: "Forbidden"}
+	}
+
+	name := p.cacheName(key, opts)
+
+	if f, err := os.Open(filepath.Join(p.CacheDir, name)); err == nil {
+		defer f.Close()
+
+		info, err := f.Stat()
+		if err != nil {
+			return nil, fmt.Errorf("images.Handler: %w", err)
+		}
+
+		serve(c, name, info.ModTime(), f)
+		return nil, nil
+	}
+
+	data, err := p.generate(c, key, opts, name)
+	if err != nil {
+		return nil, err
+	}
+
+	serve(c, name, p.App.GetClock().Now(), bytes.NewReader(data))
+	return nil, nil
+}
+
+// generate - Transform the source file and write the variant in the cache
+func (p *Plugin) generate(c echo.Context, key string, opts *Options, name string) ([]byte, error) {
+	ctx := c.Request().Context()
+
+	select {
+	case p.sem <- struct{}{}:
+		defer func() { <-p.sem }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	path := filepath.Join(p.CacheDir, name)
+
+	// generated by other request while this one waited
+	if data, err := os.ReadFile(path); err == nil {
+		return data, nil
+	}
+
+	r, err := p.App.GetStorage().Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, &bolo.HTTPError{Code: http.StatusNotFound, Message: "Not found", Internal: err}
+		}
+
+		return nil, fmt.Errorf("images.generate: %w", err)
+	}
+	defer r.Close()
+
+	source, err := io.ReadAll(io.LimitReader(r, p.MaxSourceSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("images.generate: %w", err)
+	}
+
+	if int64(len(source)) > p.MaxSourceSize {
+		return nil, &bolo.HTTPError{Code: http.StatusUnprocessableEntity, Message: "Image too large", Internal: ErrImageTooLarge}
+	}
+
+	data, err := Transform(source, opts, p.MaxPixels)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUnsupportedImage):
+			return nil, &bolo.HTTPError{Code: http.StatusUnsupportedMediaType, Message: "Unsupported image", Internal: err}
+		case errors.Is(err, ErrImageTooLarge):
+			return nil, &bolo.HTTPError{Code: http.StatusUnprocessableEntity, Message: "Image too large", Internal: err}
+		}
+
+		return nil, fmt.Errorf("images.generate: %w", err)
+	}
+
+	// the variant is served even if the cache is not writable
+	if err := writeFile(path, data); err != nil {
+		bolo.GetLogger(c).Warn("images.generate: error on write the cache", zap.Error(err), zap.String("path", path))
+	}
+
+	return data, nil
+}
+
+// cacheName - Cache file of one variant. Storage keys are immutable, the variants are never invalidated
+func (p *Plugin) cacheName(key string, opts *Options) string {
+	sum := sha256.Sum256([]byte(key + "?" + opts.Encode()))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(name[:2], name)
+}
+
+func serve(c echo.Context, name string, modtime time.Time, content io.ReadSeeker) {
+	h := c.Response().Header()
+	h.Set("Cache-Control", "public, max-age=31536000, immutable")
+	h.Set("ETag", `"`+filepath.Base(name)+`"`)
+	h.Set("X-Content-Type-Options", "nosniff")
+
+	// the cache files don't have extensions, the content type is detected from the content
+	http.ServeContent(c.Response(), c.Request(), "", modtime, content)
+}
+
+// writeFile - Write the file with one temporary file and rename, readers never see partial files
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".variant-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,295 @@
+package images_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/go-bolo/bolo/files"
+	"github.com/go-bolo/bolo/images"
+	"github.com/go-bolo/clock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/webp"
+)
+
+func newTestApp(t *testing.T) (bolo.App, *images.Plugin) {
+	dir := t.TempDir()
+
+	os.Setenv("TEMPLATE_DISABLE", "true")
+	os.Setenv("DB_URI", filepath.Join(dir, "images.sqlite"))
+	os.Setenv("STORAGE_LOCAL_DIR", filepath.Join(dir, "uploads"))
+	os.Setenv("IMAGES_CACHE_DIR", filepath.Join(dir, "cache"))
+	os.Setenv("IMAGES_SIGNING_SECRET", "secret")
+	t.Cleanup(func() {
+		os.Unsetenv("TEMPLATE_DISABLE")
+		os.Unsetenv("DB_URI")
+		os.Unsetenv("STORAGE_LOCAL_DIR")
+		os.Unsetenv("IMAGES_CACHE_DIR")
+		os.Unsetenv("IMAGES_SIGNING_SECRET")
+	})
+
+	app := bolo.NewApp(&bolo.DefaultAppOptions{})
+
+	c := clock.NewMock()
+	now, _ := time.Parse("2006-01-02", "2023-07-16")
+	c.Set(now)
+	app.SetClock(c)
+
+	p := images.NewPlugin(&images.PluginOpts{})
+	app.AddPlugin(p)
+
+	require.Nil(t, app.Bootstrap())
+
+	return app, p
+}
+
+// testImage - Left half red and right half blue, with one transparent corner
+func testImage(w, h int) *image.NRGBA {
+	m := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBA{R: 255, A: 255}
+			if x >= w/2 {
+				c = color.NRGBA{B: 255, A: 255}
+			}
+			m.SetNRGBA(x, y, c)
+		}
+	}
+	m.SetNRGBA(0, 0, color.NRGBA{R: 10, G: 20, B: 30, A: 40})
+	return m
+}
+
+func encode(t *testing.T, m image.Image, format string) []byte {
+	buf := bytes.Buffer{}
+	switch format {
+	case "jpeg":
+		require.Nil(t, jpeg.Encode(&buf, m, &jpeg.Options{Quality: 95}))
+	default:
+		require.Nil(t, png.Encode(&buf, m))
+	}
+	return buf.Bytes()
+}
+
+// withOrientation - Add one EXIF segment with the orientation after the JPEG start of image
+func withOrientation(data []byte, orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry[0:], 0x0112)
+	binary.BigEndian.PutUint16(entry[2:], 3)
+	binary.BigEndian.PutUint32(entry[4:], 1)
+	binary.BigEndian.PutUint16(entry[8:], orientation)
+	tiff = append(append(tiff, entry...), 0, 0, 0, 0)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	return append(append(append([]byte{}, data[:2]...), segment...), data[2:]...)
+}
+
+func TestEncodeWebP(t *testing.T) {
+	gradient := image.NewNRGBA(image.Rect(0, 0, 600, 30))
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 600; x++ {
+			gradient.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y * 8), B: uint8(x * y), A: uint8(255 - x%7)})
+		}
+	}
+
+	solid := image.NewNRGBA(image.Rect(0, 0, 3, 5))
+	for i := range solid.Pix {
+		solid.Pix[i] = 0xff
+	}
+
+	for name, m := range map[string]*image.NRGBA{
+		"gradient": gradient,
+		"solid":    solid,
+		"halves":   testImage(17, 9),
+		"pixel":    testImage(1, 1),
+	} {
+		t.Run(name, func(t *testing.T) {
+			buf := bytes.Buffer{}
+			require.Nil(t, images.EncodeWebP(&buf, m))
+
+			decoded, err := webp.Decode(&buf)
+			require.Nil(t, err)
+			require.Equal(t, m.Bounds(), decoded.Bounds())
+
+			for y := 0; y < m.Bounds().Dy(); y++ {
+				for x := 0; x < m.Bounds().Dx(); x++ {
+					expected := m.NRGBAAt(x, y)
+					got := color.NRGBAModel.Convert(decoded.At(x, y)).(color.NRGBA)
+					if expected.A == 0xff {
+						require.Equal(t, expected, got, "pixel %d,%d", x, y)
+					} else {
+						// decoded in premultiplied RGBA, only alpha is exact
+						require.Equal(t, expected.A, got.A, "pixel %d,%d", x, y)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	opts, err := images.ParseOptions(url.Values{"w": {"300"}, "fit": {"crop"}, "fm": {"jpg"}, "q": {"70"}}, 1000)
+	require.Nil(t, err)
+	assert.Equal(t, &images.Options{Width: 300, Fit: images.FitCrop, Format: images.FormatJPEG, Quality: 70}, opts)
+	assert.Equal(t, "fit=crop&fm=jpeg&q=70&w=300", opts.Encode())
+
+	opts, err = images.ParseOptions(url.Values{"w": {"300"}, "fit": {"fit"}}, 1000)
+	require.Nil(t, err)
+	assert.Equal(t, "w=300", opts.Encode())
+
+	for _, q := range []url.Values{
+		{"w": {"0"}},
+		{"w": {"2000"}},
+		{"h": {"a"}},
+		{"q": {"101"}},
+		{"fit": {"cover"}},
+		{"fm": {"avif"}},
+		{"blur": {"1"}},
+	} {
+		_, err := images.ParseOptions(q, 1000)
+		assert.NotNil(t, err, q.Encode())
+	}
+}
+
+func TestTransform(t *testing.T) {
+	source := encode(t, testImage(400, 200), "png")
+
+	size := func(data []byte) (int, int, string) {
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+		require.Nil(t, err)
+		return cfg.Width, cfg.Height, format
+	}
+
+	for _, tc := range []struct {
+		opts   images.Options
+		width  int
+		height int
+		format string
+	}{
+		{images.Options{}, 400, 200, "png"},
+		{images.Options{Width: 100}, 100, 50, "png"},
+		{images.Options{Width: 100, Height: 100}, 100, 50, "png"},
+		{images.Options{Width: 800, Height: 800}, 400, 200, "png"},
+		{images.Options{Width: 100, Height: 100, Fit: images.FitCrop}, 100, 100, "png"},
+		{images.Options{Width: 100, Height: 100, Fit: images.FitResize}, 100, 100, "png"},
+		{images.Options{Height: 50, Fit: images.FitCrop, Format: images.FormatJPEG}, 100, 50, "jpeg"},
+		{images.Options{Width: 40, Format: images.FormatWebP}, 40, 20, "webp"},
+	} {
+		opts := tc.opts
+		data, err := images.Transform(source, &opts, 0)
+		require.Nil(t, err, opts.Encode())
+
+		w, h, format := size(data)
+		assert.Equal(t, tc.width, w, opts.Encode())
+		assert.Equal(t, tc.height, h, opts.Encode())
+		assert.Equal(t, tc.format, format, opts.Encode())
+	}
+
+	t.Run("Should normalize the EXIF orientation and strip the metadata", func(t *testing.T) {
+		source := withOrientation(encode(t, testImage(40, 20), "jpeg"), 6)
+
+		data, err := images.Transform(source, &images.Options{}, 0)
+		require.Nil(t, err)
+		assert.False(t, bytes.Contains(data, []byte("Exif")))
+
+		m, err := jpeg.Decode(bytes.NewReader(data))
+		require.Nil(t, err)
+		require.Equal(t, image.Rect(0, 0, 20, 40), m.Bounds())
+
+		// rotated 90 degrees clockwise, the left half is on the top
+		r, _, b, _ := m.At(10, 5).RGBA()
+		assert.True(t, r > b)
+		r, _, b, _ = m.At(10, 35).RGBA()
+		assert.True(t, b > r)
+	})
+
+	t.Run("Should reject invalid and large images", func(t *testing.T) {
+		_, err := images.Transform([]byte("<html></html>"), &images.Options{}, 0)
+		assert.ErrorIs(t, err, images.ErrUnsupportedImage)
+
+		_, err = images.Transform(source, &images.Options{}, 1000)
+		assert.ErrorIs(t, err, images.ErrImageTooLarge)
+	})
+}
+
+func TestHandler(t *testing.T) {
+	app, p := newTestApp(t)
+	ctx := context.Background()
+
+	key := "2023/07/photo.png"
+	require.Nil(t, app.GetStorage().Put(ctx, key, bytes.NewReader(encode(t, testImage(400, 200), "png")), nil))
+	require.Nil(t, app.GetStorage().Put(ctx, "2023/07/page.png", strings.NewReader("<html></html>"), nil))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	u := p.ImageURL(key, "w=100", "fm=webp")
+	assert.True(t, strings.HasPrefix(u, "/images/2023/07/photo.png?fm=webp&w=100&s="))
+	assert.Equal(t, u, p.ImageURL(&files.File{Key: key}, "fm=webp&w=100"))
+	assert.Equal(t, "", p.ImageURL(key, "w=invalid"))
+
+	rec := get(u)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "image/webp", rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, "public, max-age=31536000, immutable", rec.Header().Get("Cache-Control"))
+
+	m, err := webp.Decode(rec.Body)
+	require.Nil(t, err)
+	assert.Equal(t, image.Rect(0, 0, 100, 50), m.Bounds())
+
+	t.Run("Should serve the cached variants", func(t *testing.T) {
+		require.Nil(t, app.GetStorage().Delete(ctx, key))
+
+		rec := get(u)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "image/webp", rec.Header().Get(echo.HeaderContentType))
+
+		req := httptest.NewRequest(http.MethodGet, u, nil)
+		req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+		rec = httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+
+		rec = get(p.ImageURL(key, "w=50"))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("Should require one valid signature", func(t *testing.T) {
+		rec := get(strings.Replace(u, "w=100", "w=101", 1))
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = get("/images/" + key + "?w=100")
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+
+		rec = get(u + "&blur=1")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Should reject files that are not images", func(t *testing.T) {
+		rec := get(p.ImageURL("2023/07/page.png", "w=10"))
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	})
+}
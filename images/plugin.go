@@ -0,0 +1,163 @@
+package images
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+
+	"github.com/go-bolo/bolo"
+	"github.com/go-bolo/bolo/files"
+	"go.uber.org/zap"
+)
+
+// Plugin - On demand image variants of the storage files, like /images/2023/07/id.jpg?w=300&fm=webp&s=signature.
+// The URLs are signed to avoid the generation of unlimited variants and the variants are cached on disk
+type Plugin struct {
+	bolo.Plugin
+
+	Name string
+	App  bolo.App
+	// Router path of the variants
+	Path     string
+	Secret   []byte
+	CacheDir string
+	// Max width and height of the variants
+	MaxDimension int
+	// Max pixels and bytes of the source images, larger files are not decoded
+	MaxPixels     int
+	MaxSourceSize int64
+	// Max variants generated at the same time
+	Concurrency int
+
+	sem chan struct{}
+}
+
+type PluginOpts struct {
+	Secret []byte
+}
+
+func NewPlugin(opts *PluginOpts) *Plugin {
+	return &Plugin{
+		Name:   "images",
+		Secret: opts.Secret,
+	}
+}
+
+func (p *Plugin) GetName() string {
+	return p.Name
+}
+
+func (p *Plugin) Init(app bolo.App) error {
+	app.GetLogger().Debug(p.GetName() + " Init")
+
+	if app.GetStorage() == nil {
+		return errors.New("images: the app storage is not configured, check the STORAGE_* configurations")
+	}
+
+	p.App = app
+	cfg := app.GetConfiguration()
+
+	if p.Path == "" {
+		p.Path = strings.TrimSuffix(cfg.GetF("IMAGES_PATH", "/images"), "/")
+	}
+
+	if len(p.Secret) == 0 {
+		secret, err := bolo.SigningSecret(app, "IMAGES_SIGNING_SECRET")
+		if err != nil {
+			return err
+		}
+		p.Secret = secret
+	}
+
+	if p.CacheDir == "" {
+		p.CacheDir = cfg.GetF("IMAGES_CACHE_DIR", "./cache/images")
+	}
+
+	if p.MaxDimension == 0 {
+		p.MaxDimension = cfg.GetIntF("IMAGES_MAX_DIMENSION", 4096)
+	}
+
+	if p.MaxPixels == 0 {
+		p.MaxPixels = cfg.GetIntF("IMAGES_MAX_PIXELS", 40000000)
+	}
+
+	if p.MaxSourceSize == 0 {
+		size, err := bolo.ParseByteSize(cfg.GetF("IMAGES_MAX_SOURCE_SIZE", "50MB"))
+		if err != nil {
+			return err
+		}
+		p.MaxSourceSize = size
+	}
+
+	if p.Concurrency == 0 {
+		p.Concurrency = cfg.GetIntF("IMAGES_CONCURRENCY", runtime.NumCPU())
+	}
+	p.sem = make(chan struct{}, p.Concurrency)
+
+	app.SetRoute("image_variant", &bolo.Route{
+		Method: http.MethodGet,
+		Path:   p.Path + "/*",
+		Action: p.Handler,
+	})
+
+	app.SetTemplateFunction("imageURL", p.ImageURL)
+
+	return nil
+}
+
+// Sign - Signature of one variant URL
+func (p *Plugin) Sign(key string, opts *Options) string {
+	mac := hmac.New(sha256.New, p.Secret)
+	mac.Write([]byte(key + "\n" + opts.Encode()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// URL - Signed URL of one variant of the storage key
+func (p *Plugin) URL(key string, opts *Options) string {
+	query := opts.Encode()
+	if query != "" {
+		query += "&"
+	}
+
+	return p.Path + "/" + (&url.URL{Path: key}).EscapedPath() + "?" + query + "s=" + p.Sign(key, opts)
+}
+
+// ImageURL - imageURL template function, the variant URL of one storage key or file, like:
+//
+//	<img src="{{ imageURL .Image "w=300&h=200&fit=crop" }}">
+func (p *Plugin) ImageURL(src interface{}, params ...string) string {
+	var key string
+	switch v := src.(type) {
+	case string:
+		key = v
+	case *files.File:
+		if v == nil {
+			return ""
+		}
+		key = v.Key
+	default:
+		p.App.GetLogger().Warn("imageURL: invalid source", zap.Any("source", src))
+		return ""
+	}
+
+	if key == "" {
+		return ""
+	}
+
+	q, err := url.ParseQuery(strings.Join(params, "&"))
+	if err == nil {
+		var opts *Options
+		opts, err = ParseOptions(q, p.MaxDimension)
+		if err == nil {
+			return p.URL(key, opts)
+		}
+	}
+
+	p.App.GetLogger().Warn("imageURL: invalid params", zap.Error(err), zap.Strings("params", params))
+	return ""
+}
@@ -0,0 +1,241 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"net/url"
+	"strconv"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	ErrUnsupportedImage = errors.New("images: unsupported image")
+	ErrImageTooLarge    = errors.New("images: image too large")
+)
+
+const (
+	// FitInside - Scale the image to fit inside the size, keeping the aspect ratio. Images are not enlarged
+	FitInside = "fit"
+	// FitCrop - Scale the image to cover the size and crop the center
+	FitCrop = "crop"
+	// FitResize - Resize to the exact size, ignoring the aspect ratio
+	FitResize = "resize"
+
+	FormatWebP = "webp"
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+
+	DefaultQuality = 82
+)
+
+// Options - Parameters of one image variant, in the w, h, fit, fm and q query params
+type Options struct {
+	Width  int
+	Height int
+	Fit    string
+	// Output format, empty keeps the source format (GIF sources are converted to PNG)
+	Format string
+	// JPEG quality, from 1 to 100. WebP variants are lossless
+	Quality int
+}
+
+// ParseOptions - Parse and validate the variant query params, unknown params are invalid
+func ParseOptions(q url.Values, maxDimension int) (*Options, error) {
+	opts := Options{Fit: FitInside}
+
+	for name := range q {
+		v := q.Get(name)
+
+		switch name {
+		case "w", "h", "q":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid %s param: %s", name, v)
+			}
+
+			switch name {
+			case "w":
+				opts.Width = n
+			case "h":
+				opts.Height = n
+			case "q":
+				opts.Quality = n
+			}
+		case "fit":
+			if v != FitInside && v != FitCrop && v != FitResize {
+				return nil, fmt.Errorf("invalid fit param: %s", v)
+			}
+			opts.Fit = v
+		case "fm":
+			if v == "jpg" {
+				v = FormatJPEG
+			}
+			if v != FormatWebP && v != FormatJPEG && v != FormatPNG {
+				return nil, fmt.Errorf("invalid fm param: %s", v)
+			}
+			opts.Format = v
+		default:
+			return nil, fmt.Errorf("invalid param: %s", name)
+		}
+	}
+
+	if opts.Width > maxDimension || opts.Height > maxDimension {
+		return nil, fmt.Errorf("max image size is %d", maxDimension)
+	}
+
+	if opts.Quality > 100 {
+		return nil, fmt.Errorf("invalid q param: %d", opts.Quality)
+	}
+
+	return &opts, nil
+}
+
+// Encode - Canonical query string of the options, used in the URLs and signatures
+func (o *Options) Encode() string {
+	q := url.Values{}
+	if o.Width > 0 {
+		q.Set("w", strconv.Itoa(o.Width))
+	}
+	if o.Height > 0 {
+		q.Set("h", strconv.Itoa(o.Height))
+	}
+	if o.Fit != "" && o.Fit != FitInside {
+		q.Set("fit", o.Fit)
+	}
+	if o.Format != "" {
+		q.Set("fm", o.Format)
+	}
+	if o.Quality > 0 {
+		q.Set("q", strconv.Itoa(o.Quality))
+	}
+
+	return q.Encode()
+}
+
+// Transform - Decode, orient, resize and encode one image. The output has no metadata
+func Transform(data []byte, opts *Options, maxPixels int) ([]byte, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedImage, err)
+	}
+
+	if maxPixels > 0 && cfg.Width*cfg.Height > maxPixels {
+		return nil, fmt.Errorf("%w: %dx%d", ErrImageTooLarge, cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedImage, err)
+	}
+
+	img := toRGBA(src)
+	if format == "jpeg" {
+		img = orient(img, jpegOrientation(data))
+	}
+
+	img = resize(img, opts)
+
+	output := opts.Format
+	if output == "" {
+		output = format
+		if output == "gif" {
+			output = FormatPNG
+		}
+	}
+
+	buf := bytes.Buffer{}
+	switch output {
+	case FormatWebP:
+		err = EncodeWebP(&buf, img)
+	case FormatJPEG:
+		quality := opts.Quality
+		if quality == 0 {
+			quality = DefaultQuality
+		}
+		err = jpeg.Encode(&buf, flatten(img), &jpeg.Options{Quality: quality})
+	default:
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("images.Transform: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func toRGBA(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+// flatten - JPEG doesn't have transparency, transparent pixels are white
+func flatten(src *image.RGBA) *image.RGBA {
+	dst := image.NewRGBA(src.Bounds())
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, image.Point{}, draw.Over)
+	return dst
+}
+
+func resize(src *image.RGBA, opts *Options) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	w, h := opts.Width, opts.Height
+
+	if w == 0 && h == 0 {
+		return src
+	}
+
+	// only one side, the other keeps the aspect ratio
+	if w == 0 {
+		w = scale(sw, float64(h)/float64(sh))
+	}
+	if h == 0 {
+		h = scale(sh, float64(w)/float64(sw))
+	}
+
+	crop := src.Bounds()
+
+	switch opts.Fit {
+	case FitResize:
+	case FitCrop:
+		ratio := math.Max(float64(w)/float64(sw), float64(h)/float64(sh))
+		cw, ch := scale(w, 1/ratio), scale(h, 1/ratio)
+		if cw > sw {
+			cw = sw
+		}
+		if ch > sh {
+			ch = sh
+		}
+		x, y := (sw-cw)/2, (sh-ch)/2
+		crop = image.Rect(x, y, x+cw, y+ch)
+	default:
+		ratio := math.Min(math.Min(float64(w)/float64(sw), float64(h)/float64(sh)), 1)
+		w, h = scale(sw, ratio), scale(sh, ratio)
+	}
+
+	if w == sw && h == sh && crop == src.Bounds() {
+		return src
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Src, nil)
+	return dst
+}
+
+func scale(size int, ratio float64) int {
+	n := int(math.Round(float64(size) * ratio))
+	if n < 1 {
+		return 1
+	}
+	return n
+}
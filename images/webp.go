@@ -0,0 +1,409 @@
+package images
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+
+	"golang.org/x/image/draw"
+)
+
+// VP8L (lossless WebP) encoder. The golang.org/x/image/webp package only decodes, so the WebP variants are written
+// with the subtract green and one gradient predictor transform followed by prefix coded literals.
+// There are no backward references or color cache, the files are larger than the libwebp ones but lossless.
+
+const (
+	vp8lSignature     = 0x2f
+	vp8lMaxSize       = 1 << 14
+	vp8lPredictor     = 0
+	vp8lSubtractGreen = 2
+	// 512x512 predictor blocks, the same mode is used in all blocks
+	vp8lPredictorBits = 9
+	// ClampAddSubtractFull(L, T, TL)
+	vp8lPredictorMode = 12
+	// green, red, blue, alpha and distance alphabets, without color cache
+	vp8lGreenAlphabet    = 256 + 24
+	vp8lDistanceAlphabet = 40
+)
+
+var codeLengthCodeOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// EncodeWebP - Write the image as one lossless WebP
+func EncodeWebP(w io.Writer, m image.Image) error {
+	b := m.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width < 1 || height < 1 || width > vp8lMaxSize || height > vp8lMaxSize {
+		return errors.New("images: invalid WebP image size")
+	}
+
+	src := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(src, src.Bounds(), m, b.Min, draw.Src)
+
+	alpha := false
+	argb := make([][4]byte, width*height)
+	for i := range argb {
+		p := src.Pix[i*4 : i*4+4]
+		// subtract green transform, applied before the predictor
+		argb[i] = [4]byte{p[3], p[0] - p[1], p[1], p[2] - p[1]}
+		if p[3] != 0xff {
+			alpha = true
+		}
+	}
+
+	residuals := predict(argb, width, height)
+
+	bw := bitWriter{}
+	bw.write(vp8lSignature, 8)
+	bw.write(uint32(width-1), 14)
+	bw.write(uint32(height-1), 14)
+	if alpha {
+		bw.write(1, 1)
+	} else {
+		bw.write(0, 1)
+	}
+	bw.write(0, 3)
+
+	bw.write(1, 1)
+	bw.write(vp8lSubtractGreen, 2)
+
+	bw.write(1, 1)
+	bw.write(vp8lPredictor, 2)
+	bw.write(vp8lPredictorBits-2, 3)
+	tiles := make([][4]byte, nTiles(width)*nTiles(height))
+	for i := range tiles {
+		tiles[i] = [4]byte{0, 0, vp8lPredictorMode, 0}
+	}
+	bw.writeImage(tiles, false)
+
+	// no more transforms
+	bw.write(0, 1)
+	bw.writeImage(residuals, true)
+
+	data := bw.bytes()
+	size := len(data)
+	pad := size & 1
+
+	header := make([]byte, 20)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(12+size+pad))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(size))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if pad == 1 {
+		_, err := w.Write([]byte{0})
+		return err
+	}
+
+	return nil
+}
+
+func nTiles(size int) int {
+	return (size + 1<<vp8lPredictorBits - 1) >> vp8lPredictorBits
+}
+
+// predict - Residuals of the predictor transform, with the first row and column rules of the format
+func predict(argb [][4]byte, width, height int) [][4]byte {
+	residuals := make([][4]byte, len(argb))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+
+			var prediction [4]byte
+			switch {
+			case x == 0 && y == 0:
+				prediction = [4]byte{0xff, 0, 0, 0}
+			case y == 0:
+				prediction = argb[i-1]
+			case x == 0:
+				prediction = argb[i-width]
+			default:
+				l, t, tl := argb[i-1], argb[i-width], argb[i-width-1]
+				for c := range prediction {
+					prediction[c] = clampByte(int(l[c]) + int(t[c]) - int(tl[c]))
+				}
+			}
+
+			for c := range prediction {
+				residuals[i][c] = argb[i][c] - prediction[c]
+			}
+		}
+	}
+
+	return residuals
+}
+
+func clampByte(v int) byte {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return byte(v)
+}
+
+// bitWriter - Least significant bit first writer of the VP8L bitstream
+type bitWriter struct {
+	buf   []byte
+	bits  uint64
+	nBits uint
+}
+
+func (w *bitWriter) write(v uint32, n uint) {
+	w.bits |= uint64(v) << w.nBits
+	w.nBits += n
+	for w.nBits >= 8 {
+		w.buf = append(w.buf, byte(w.bits))
+		w.bits >>= 8
+		w.nBits -= 8
+	}
+}
+
+func (w *bitWriter) bytes() []byte {
+	if w.nBits > 0 {
+		w.buf = append(w.buf, byte(w.bits))
+		w.bits, w.nBits = 0, 0
+	}
+
+	return w.buf
+}
+
+// writeImage - Write one entropy coded image with literals only, pixels in ARGB order
+func (w *bitWriter) writeImage(pixels [][4]byte, main bool) {
+	// no color cache
+	w.write(0, 1)
+	if main {
+		// no meta prefix codes
+		w.write(0, 1)
+	}
+
+	histograms := [5][]uint32{
+		make([]uint32, vp8lGreenAlphabet),
+		make([]uint32, 256),
+		make([]uint32, 256),
+		make([]uint32, 256),
+		make([]uint32, vp8lDistanceAlphabet),
+	}
+	for _, p := range pixels {
+		histograms[0][p[2]]++
+		histograms[1][p[1]]++
+		histograms[2][p[3]]++
+		histograms[3][p[0]]++
+	}
+
+	codes := [5]*prefixCode{}
+	for i, h := range histograms {
+		codes[i] = newPrefixCode(h, 15)
+		w.writePrefixCode(codes[i])
+	}
+
+	for _, p := range pixels {
+		codes[0].writeSymbol(w, int(p[2]))
+		codes[1].writeSymbol(w, int(p[1]))
+		codes[2].writeSymbol(w, int(p[3]))
+		codes[3].writeSymbol(w, int(p[0]))
+	}
+}
+
+// prefixCode - Canonical Huffman code of one alphabet
+type prefixCode struct {
+	lengths []uint8
+	// bit reversed codes, the bitstream stores the codes from the most significant bit
+	codes   []uint16
+	symbols []int
+}
+
+func newPrefixCode(histogram []uint32, maxLength int) *prefixCode {
+	pc := prefixCode{}
+
+	for s, count := range histogram {
+		if count > 0 {
+			pc.symbols = append(pc.symbols, s)
+		}
+	}
+
+	counts := append([]uint32(nil), histogram...)
+	if len(pc.symbols) == 0 {
+		counts[0] = 1
+		pc.symbols = []int{0}
+	}
+
+	for {
+		lengths, ok := huffmanLengths(counts, maxLength)
+		if ok {
+			pc.lengths = lengths
+			break
+		}
+
+		// flatten the distribution until the tree fits in the max length
+		for i, c := range counts {
+			if c > 1 {
+				counts[i] = (c + 1) / 2
+			}
+		}
+	}
+
+	pc.codes = canonicalCodes(pc.lengths)
+	return &pc
+}
+
+// simple - Codes with 1 or 2 symbols under 256 are written with the short form
+func (pc *prefixCode) simple() bool {
+	return len(pc.symbols) <= 2 && pc.symbols[len(pc.symbols)-1] < 256
+}
+
+func (pc *prefixCode) writeSymbol(w *bitWriter, s int) {
+	// decoders read the only symbol of one code without bits
+	if len(pc.symbols) == 1 {
+		return
+	}
+
+	w.write(uint32(pc.codes[s]), uint(pc.lengths[s]))
+}
+
+func (w *bitWriter) writePrefixCode(pc *prefixCode) {
+	if pc.simple() {
+		w.write(1, 1)
+		w.write(uint32(len(pc.symbols)-1), 1)
+		if pc.symbols[0] < 2 {
+			w.write(0, 1)
+			w.write(uint32(pc.symbols[0]), 1)
+		} else {
+			w.write(1, 1)
+			w.write(uint32(pc.symbols[0]), 8)
+		}
+		if len(pc.symbols) == 2 {
+			w.write(uint32(pc.symbols[1]), 8)
+		}
+		return
+	}
+
+	histogram := make([]uint32, len(codeLengthCodeOrder))
+	for _, l := range pc.lengths {
+		histogram[l]++
+	}
+	clc := newPrefixCode(histogram, 7)
+
+	n := 4
+	for i, s := range codeLengthCodeOrder {
+		if clc.lengths[s] > 0 && i+1 > n {
+			n = i + 1
+		}
+	}
+
+	w.write(0, 1)
+	w.write(uint32(n-4), 4)
+	for _, s := range codeLengthCodeOrder[:n] {
+		w.write(uint32(clc.lengths[s]), 3)
+	}
+
+	// the lengths of all symbols are written
+	w.write(0, 1)
+	for _, l := range pc.lengths {
+		clc.writeSymbol(w, int(l))
+	}
+}
+
+// huffmanLengths - Code lengths of the symbols with counts, false if the tree is deeper than maxLength
+func huffmanLengths(counts []uint32, maxLength int) ([]uint8, bool) {
+	type node struct {
+		count       uint32
+		left, right int
+	}
+
+	lengths := make([]uint8, len(counts))
+	nodes := []node{}
+	leaves := []int{}
+	for s, c := range counts {
+		if c > 0 {
+			nodes = append(nodes, node{count: c, left: -1, right: -1})
+			leaves = append(leaves, s)
+		}
+	}
+
+	if len(nodes) == 1 {
+		lengths[leaves[0]] = 1
+		return lengths, true
+	}
+
+	active := make([]int, len(nodes))
+	for i := range active {
+		active[i] = i
+	}
+
+	removeMin := func() int {
+		min := 0
+		for i := range active {
+			if nodes[active[i]].count < nodes[active[min]].count {
+				min = i
+			}
+		}
+		n := active[min]
+		active = append(active[:min], active[min+1:]...)
+		return n
+	}
+
+	for len(active) > 1 {
+		a, b := removeMin(), removeMin()
+		nodes = append(nodes, node{count: nodes[a].count + nodes[b].count, left: a, right: b})
+		active = append(active, len(nodes)-1)
+	}
+
+	var walk func(n, depth int) bool
+	walk = func(n, depth int) bool {
+		if nodes[n].left < 0 {
+			if depth > maxLength {
+				return false
+			}
+			lengths[leaves[n]] = uint8(depth)
+			return true
+		}
+
+		return walk(nodes[n].left, depth+1) && walk(nodes[n].right, depth+1)
+	}
+
+	return lengths, walk(active[0], 0)
+}
+
+// canonicalCodes - Canonical codes of the lengths, bit reversed
+func canonicalCodes(lengths []uint8) []uint16 {
+	var count, next [16]uint16
+	for _, l := range lengths {
+		if l > 0 {
+			count[l]++
+		}
+	}
+
+	code := uint16(0)
+	for l := 1; l < 16; l++ {
+		code = (code + count[l-1]) << 1
+		next[l] = code
+	}
+
+	codes := make([]uint16, len(lengths))
+	for s, l := range lengths {
+		if l == 0 {
+			continue
+		}
+
+		c := next[l]
+		next[l]++
+
+		reversed := uint16(0)
+		for i := uint8(0); i < l; i++ {
+			reversed = reversed<<1 | c&1
+			c >>= 1
+		}
+		codes[s] = reversed
+	}
+
+	return codes
+}
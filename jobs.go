@@ -0,0 +1,106 @@
+package bolo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultJobQueue - Queue used by job types without Queue
+const DefaultJobQueue = "default"
+
+var (
+	ErrJobQueueNotConfigured = errors.New("job queue not configured")
+	ErrJobTypeNotFound       = errors.New("job type not found")
+)
+
+// JobHandler - Process one background job, returned errors are retried with backoff
+type JobHandler interface {
+	HandleJob(ctx context.Context, app App, payload []byte) error
+}
+
+// JobHandlerFunc - Function adapter for JobHandler
+type JobHandlerFunc func(ctx context.Context, app App, payload []byte) error
+
+func (f JobHandlerFunc) HandleJob(ctx context.Context, app App, payload []byte) error {
+	return f(ctx, app, payload)
+}
+
+// NewJobHandler - Typed job handler, the JSON payload is decoded in one T before the handler call
+func NewJobHandler[T any](fn func(ctx context.Context, app App, payload *T) error) JobHandler {
+	return JobHandlerFunc(func(ctx context.Context, app App, payload []byte) error {
+		var data T
+		if len(payload) > 0 {
+			if err := json.Unmarshal(payload, &data); err != nil {
+				return fmt.Errorf("error on decode job payload: %w", err)
+			}
+		}
+
+		return fn(ctx, app, &data)
+	})
+}
+
+// JobType - One job type registered by plugins in Init with app.SetJobType
+type JobType struct {
+	Name    string
+	Handler JobHandler
+	// Queue name, default DefaultJobQueue
+	Queue string
+	// Max attempts before the dead state, 0 uses the queue default
+	MaxAttempts int
+	// Max duration of one attempt, 0 uses the queue default
+	Timeout time.Duration
+}
+
+// EnqueueJobOpts - Optional enqueue configuration
+type EnqueueJobOpts struct {
+	// Override the job type queue
+	Queue string
+	// Run the job after this time or after the Delay
+	RunAt       *time.Time
+	Delay       time.Duration
+	MaxAttempts int
+}
+
+// JobQueue - Background jobs storage and workers, set by the jobs plugin
+type JobQueue interface {
+	// Enqueue - Add one job and returns its id, the payload is encoded as JSON
+	Enqueue(ctx context.Context, jobType string, payload interface{}, opts *EnqueueJobOpts) (string, error)
+	// Start - Start the queue workers, ran after Bootstrap
+	Start() error
+	// Stop - Stop the workers and wait the running jobs, ran in Close
+	Stop(ctx context.Context) error
+}
+
+// JobInfo - Current job metadata, available in handlers with GetJobInfo
+type JobInfo struct {
+	ID          string
+	Type        string
+	Queue       string
+	Attempt     int
+	MaxAttempts int
+}
+
+type jobInfoContextKey struct{}
+
+func ContextWithJobInfo(ctx context.Context, info *JobInfo) context.Context {
+	return context.WithValue(ctx, jobInfoContextKey{}, info)
+}
+
+// GetJobInfo - Returns the running job metadata or nil outside job handlers
+func GetJobInfo(ctx context.Context) *JobInfo {
+	info, _ := ctx.Value(jobInfoContextKey{}).(*JobInfo)
+	return info
+}
+
+// EnqueueJob - Add one job in the app job queue
+func EnqueueJob(ctx context.Context, app App, jobType string, payload interface{}, opts *EnqueueJobOpts) (string, error) {
+	q := app.GetJobQueue()
+	if q == nil {
+		return "", ErrJobQueueNotConfigured
+	}
+
+	return q.Enqueue(ctx, jobType, payload, opts)
+}
@@ -0,0 +1,241 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type JobController struct {
+	Plugin *Plugin
+}
+
+type ListJSONResponse struct {
+	bolo.BaseListReponse
+	Jobs []*Job `json:"job"`
+}
+
+type CountJSONResponse struct {
+	bolo.BaseMetaResponse
+}
+
+type FindOneJSONResponse struct {
+	Job *Job `json:"job"`
+}
+
+type BodyRequest struct {
+	Job *JobBody `json:"job"`
+}
+
+type JobBody struct {
+	Type        string          `json:"type" validate:"required"`
+	Payload     json.RawMessage `json:"payload"`
+	Queue       string          `json:"queue"`
+	RunAt       *time.Time      `json:"runAt"`
+	MaxAttempts int             `json:"maxAttempts"`
+}
+
+func (ctl *JobController) Find(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	limit := bolo.GetLimit(c)
+	if limit <= 0 {
+		limit = 50
+	}
+
+	var count int64
+	records := []*Job{}
+
+	query := filterQuery(c, bolo.GetDB(c).Model(&Job{}))
+
+	err := query.Count(&count).Error
+	if err != nil {
+		return nil, fmt.Errorf("JobController.Find: %w", err)
+	}
+
+	err = query.Order("id DESC").Limit(limit).Offset(bolo.GetOffset(c)).Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("JobController.Find: %w", err)
+	}
+
+	bolo.GetPager(c).Count = count
+
+	resp := ListJSONResponse{Jobs: records}
+	resp.Meta.Count = count
+
+	return &bolo.DefaultResponse{Data: &resp}, nil
+}
+
+func (ctl *JobController) Count(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	err := filterQuery(c, bolo.GetDB(c).Model(&Job{})).Count(&count).Error
+	if err != nil {
+		return nil, fmt.Errorf("JobController.Count: %w", err)
+	}
+
+	return &bolo.DefaultResponse{
+		Data: &CountJSONResponse{BaseMetaResponse: bolo.BaseMetaResponse{Count: count}},
+	}, nil
+}
+
+func (ctl *JobController) FindOne(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	record, err := loadRecord(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bolo.DefaultResponse{Data: &FindOneJSONResponse{Job: record}}, nil
+}
+
+// Create - Enqueue one job of one registered type
+func (ctl *JobController) Create(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	body := BodyRequest{}
+	if err := c.Bind(&body); err != nil {
+		return nil, &bolo.HTTPError{Code: http.StatusBadRequest, Message: "Invalid body data", Internal: err}
+	}
+
+	if body.Job == nil {
+		return nil, &bolo.HTTPError{Code: http.StatusBadRequest, Message: "job is required"}
+	}
+
+	if err := c.Validate(body.Job); err != nil {
+		return nil, err
+	}
+
+	var payload interface{}
+	if len(body.Job.Payload) > 0 {
+		payload = body.Job.Payload
+	}
+
+	id, err := ctl.Plugin.Queue.Enqueue(c.Request().Context(), body.Job.Type, payload, &bolo.EnqueueJobOpts{
+		Queue:       body.Job.Queue,
+		RunAt:       body.Job.RunAt,
+		MaxAttempts: body.Job.MaxAttempts,
+	})
+	if err != nil {
+		if errors.Is(err, bolo.ErrJobTypeNotFound) {
+			return nil, &bolo.HTTPError{Code: http.StatusBadRequest, Message: "Invalid job type", Internal: err}
+		}
+
+		return nil, fmt.Errorf("JobController.Create: %w", err)
+	}
+
+	record, err := FindOne(bolo.GetApp(c), id)
+	if err != nil {
+		return nil, fmt.Errorf("JobController.Create: %w", err)
+	}
+
+	return &bolo.DefaultResponse{
+		Status: http.StatusCreated,
+		Data:   &FindOneJSONResponse{Job: record},
+	}, nil
+}
+
+// Update - Jobs are only changed by the workers, use the retry route for dead jobs
+func (ctl *JobController) Update(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	return nil, &bolo.HTTPError{Code: http.StatusMethodNotAllowed, Message: "Jobs can not be updated"}
+}
+
+func (ctl *JobController) Delete(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	record, err := loadRecord(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if record.Status == StatusRunning {
+		return nil, &bolo.HTTPError{Code: http.StatusConflict, Message: "Running jobs can not be deleted"}
+	}
+
+	err = bolo.GetDB(c).Delete(record).Error
+	if err != nil {
+		return nil, fmt.Errorf("JobController.Delete: %w", err)
+	}
+
+	return &bolo.DefaultResponse{Status: http.StatusNoContent}, nil
+}
+
+// Retry - Move one dead job back to the queue
+func (ctl *JobController) Retry(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	record, err := ctl.Plugin.Queue.Retry(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrJobNotFound):
+			return nil, &bolo.HTTPError{Code: http.StatusNotFound, Message: "Not found", Internal: err}
+		case errors.Is(err, ErrJobNotRetried):
+			return nil, &bolo.HTTPError{Code: http.StatusConflict, Message: err.Error()}
+		}
+
+		return nil, fmt.Errorf("JobController.Retry: %w", err)
+	}
+
+	return &bolo.DefaultResponse{Data: &FindOneJSONResponse{Job: record}}, nil
+}
+
+// filterQuery - Filter the jobs by the status, queue and type query params
+func filterQuery(c echo.Context, query *gorm.DB) *gorm.DB {
+	for _, field := range []string{"status", "queue", "type"} {
+		if v := c.QueryParam(field); v != "" {
+			query = query.Where(field+" = ?", v)
+		}
+	}
+
+	return query
+}
+
+func loadRecord(c echo.Context) (*Job, error) {
+	record, err := FindOne(bolo.GetApp(c), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, ErrJobNotFound) {
+			return nil, &bolo.HTTPError{Code: http.StatusNotFound, Message: "Not found", Internal: err}
+		}
+
+		return nil, err
+	}
+
+	return record, nil
+}
+
+func checkAccess(c echo.Context) error {
+	if !bolo.IsAuthenticated(c) {
+		return &bolo.HTTPError{Code: http.StatusUnauthorized, Message: "Unauthorized"}
+	}
+
+	route := bolo.GetRoute(c)
+	if route.Permission != "" && !bolo.Can(c, route.Permission) {
+		return &bolo.HTTPError{Code: http.StatusForbidden, Message: "Forbidden"}
+	}
+
+	return nil
+}
@@ -0,0 +1,418 @@
+package jobs_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/go-bolo/bolo/acl"
+	"github.com/go-bolo/bolo/jobs"
+	"github.com/go-bolo/bolo/users"
+	"github.com/go-bolo/clock"
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type emailPayload struct {
+	To string `json:"to"`
+}
+
+func newTestApp(t *testing.T, setup func(app bolo.App)) (bolo.App, *jobs.Plugin, *clock.Mock) {
+	os.Setenv("TEMPLATE_DISABLE", "true")
+	os.Setenv("JOBS_WORKERS_DISABLE", "true")
+	os.Setenv("DB_URI", filepath.Join(t.TempDir(), "jobs.sqlite"))
+	t.Cleanup(func() {
+		os.Unsetenv("TEMPLATE_DISABLE")
+		os.Unsetenv("JOBS_WORKERS_DISABLE")
+		os.Unsetenv("DB_URI")
+	})
+
+	app := bolo.NewApp(&bolo.DefaultAppOptions{})
+
+	c := clock.NewMock()
+	now, _ := time.Parse("2006-01-02", "2023-07-16")
+	c.Set(now)
+	app.SetClock(c)
+
+	p := jobs.NewPlugin(&jobs.PluginOpts{})
+	app.AddPlugin(p)
+
+	if setup != nil {
+		setup(app)
+	}
+
+	require.Nil(t, app.Bootstrap())
+	require.Nil(t, app.SyncDB())
+
+	return app, p, c
+}
+
+func TestJobQueue(t *testing.T) {
+	ctx := context.Background()
+
+	var received []string
+	var infos []*bolo.JobInfo
+	failures := 0
+
+	app, p, clk := newTestApp(t, func(app bolo.App) {
+		app.SetJobType(&bolo.JobType{
+			Name: "send_email",
+			Handler: bolo.NewJobHandler(func(ctx context.Context, app bolo.App, payload *emailPayload) error {
+				info := bolo.GetJobInfo(ctx)
+				infos = append(infos, info)
+
+				if failures > 0 {
+					failures--
+					return errors.New("smtp unavailable")
+				}
+
+				received = append(received, payload.To)
+				return nil
+			}),
+			Queue:       "emails",
+			MaxAttempts: 3,
+		})
+	})
+
+	q := p.Queue
+
+	t.Run("should run one typed job", func(t *testing.T) {
+		id, err := bolo.EnqueueJob(ctx, app, "send_email", &emailPayload{To: "alice@example.com"}, nil)
+		require.Nil(t, err)
+
+		processed, err := q.RunNext(ctx, "emails")
+		require.Nil(t, err)
+		assert.True(t, processed)
+		assert.Equal(t, []string{"alice@example.com"}, received)
+		assert.Equal(t, &bolo.JobInfo{ID: id, Type: "send_email", Queue: "emails", Attempt: 1, MaxAttempts: 3}, infos[0])
+
+		job, err := jobs.FindOne(app, id)
+		require.Nil(t, err)
+		assert.Equal(t, jobs.StatusSucceeded, job.Status)
+		assert.NotNil(t, job.FinishedAt)
+
+		processed, err = q.RunNext(ctx, "emails")
+		require.Nil(t, err)
+		assert.False(t, processed)
+	})
+
+	t.Run("should not enqueue unknown job types", func(t *testing.T) {
+		_, err := bolo.EnqueueJob(ctx, app, "unknown", nil, nil)
+		assert.ErrorIs(t, err, bolo.ErrJobTypeNotFound)
+	})
+
+	t.Run("should retry failed jobs with exponential backoff", func(t *testing.T) {
+		received = nil
+		failures = 2
+
+		id, err := bolo.EnqueueJob(ctx, app, "send_email", &emailPayload{To: "bob@example.com"}, nil)
+		require.Nil(t, err)
+
+		_, err = q.RunNext(ctx, "emails")
+		require.Nil(t, err)
+
+		job, _ := jobs.FindOne(app, id)
+		assert.Equal(t, jobs.StatusPending, job.Status)
+		assert.Equal(t, 1, job.Attempts)
+		assert.Equal(t, "smtp unavailable", job.LastError)
+		assert.Equal(t, clk.Now().Add(10*time.Second), job.RunAt.In(clk.Now().Location()))
+
+		// not available before the backoff:
+		processed, _ := q.RunNext(ctx, "emails")
+		assert.False(t, processed)
+
+		clk.Add(10 * time.Second)
+		processed, _ = q.RunNext(ctx, "emails")
+		assert.True(t, processed)
+
+		job, _ = jobs.FindOne(app, id)
+		assert.Equal(t, jobs.StatusPending, job.Status)
+		assert.Equal(t, clk.Now().Add(20*time.Second), job.RunAt.In(clk.Now().Location()))
+
+		clk.Add(20 * time.Second)
+		processed, _ = q.RunNext(ctx, "emails")
+		assert.True(t, processed)
+
+		job, _ = jobs.FindOne(app, id)
+		assert.Equal(t, jobs.StatusSucceeded, job.Status)
+		assert.Equal(t, 3, job.Attempts)
+		assert.Equal(t, []string{"bob@example.com"}, received)
+	})
+
+	t.Run("should move jobs to the dead state and retry them", func(t *testing.T) {
+		received = nil
+		failures = 1
+
+		id, err := bolo.EnqueueJob(ctx, app, "send_email", &emailPayload{To: "carol@example.com"}, &bolo.EnqueueJobOpts{MaxAttempts: 1})
+		require.Nil(t, err)
+
+		q.RunNext(ctx, "emails")
+
+		job, _ := jobs.FindOne(app, id)
+		assert.Equal(t, jobs.StatusDead, job.Status)
+
+		processed, _ := q.RunNext(ctx, "emails")
+		assert.False(t, processed)
+
+		_, err = q.Retry(ctx, id)
+		require.Nil(t, err)
+
+		processed, _ = q.RunNext(ctx, "emails")
+		assert.True(t, processed)
+
+		job, _ = jobs.FindOne(app, id)
+		assert.Equal(t, jobs.StatusSucceeded, job.Status)
+		assert.Equal(t, []string{"carol@example.com"}, received)
+
+		_, err = q.Retry(ctx, id)
+		assert.ErrorIs(t, err, jobs.ErrJobNotRetried)
+	})
+
+	t.Run("should run delayed jobs after the delay", func(t *testing.T) {
+		received = nil
+
+		_, err := bolo.EnqueueJob(ctx, app, "send_email", &emailPayload{To: "dave@example.com"}, &bolo.EnqueueJobOpts{Delay: time.Hour})
+		require.Nil(t, err)
+
+		processed, _ := q.RunNext(ctx, "emails")
+		assert.False(t, processed)
+
+		clk.Add(time.Hour)
+		processed, _ = q.RunNext(ctx, "emails")
+		assert.True(t, processed)
+		assert.Equal(t, []string{"dave@example.com"}, received)
+	})
+
+	t.Run("should claim again jobs with stale locks", func(t *testing.T) {
+		received = nil
+
+		id, err := bolo.EnqueueJob(ctx, app, "send_email", &emailPayload{To: "erin@example.com"}, nil)
+		require.Nil(t, err)
+
+		now := clk.Now()
+		require.Nil(t, app.GetDB().Model(&jobs.Job{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"status": jobs.StatusRunning, "attempts": 1, "lockedAt": now, "lockedBy": "crashed",
+		}).Error)
+
+		processed, _ := q.RunNext(ctx, "emails")
+		assert.False(t, processed)
+
+		clk.Add(q.LockTimeout)
+		processed, _ = q.RunNext(ctx, "emails")
+		assert.True(t, processed)
+
+		job, _ := jobs.FindOne(app, id)
+		assert.Equal(t, jobs.StatusSucceeded, job.Status)
+		assert.Equal(t, 2, job.Attempts)
+	})
+}
+
+func TestJobLockRefresh(t *testing.T) {
+	ctx := context.Background()
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	app, p, clk := newTestApp(t, func(app bolo.App) {
+		app.SetJobType(&bolo.JobType{
+			Name: "import",
+			Handler: bolo.JobHandlerFunc(func(ctx context.Context, app bolo.App, payload []byte) error {
+				close(started)
+				<-release
+				return nil
+			}),
+		})
+	})
+
+	q := p.Queue
+	q.LockTimeout = 30 * time.Millisecond
+
+	other := jobs.NewQueueFromConfiguration(app)
+	other.LockTimeout = q.LockTimeout
+
+	id, err := bolo.EnqueueJob(ctx, app, "import", nil, nil)
+	require.Nil(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := q.RunNext(ctx, "default")
+		done <- err
+	}()
+	<-started
+
+	clk.Add(time.Hour)
+	assert.Eventually(t, func() bool {
+		job, err := jobs.FindOne(app, id)
+		return err == nil && job.LockedAt != nil && job.LockedAt.Equal(clk.Now())
+	}, 2*time.Second, 5*time.Millisecond)
+
+	processed, err := other.RunNext(ctx, "default")
+	require.Nil(t, err)
+	assert.False(t, processed)
+
+	close(release)
+	require.Nil(t, <-done)
+
+	job, err := jobs.FindOne(app, id)
+	require.Nil(t, err)
+	assert.Equal(t, jobs.StatusSucceeded, job.Status)
+	assert.Equal(t, 1, job.Attempts)
+}
+
+func TestJobWorkers(t *testing.T) {
+	var running, maxRunning, done int32
+
+	app, p, _ := newTestApp(t, func(app bolo.App) {
+		app.SetJobType(&bolo.JobType{
+			Name:  "slow",
+			Queue: "single",
+			Handler: bolo.JobHandlerFunc(func(ctx context.Context, app bolo.App, payload []byte) error {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					m := atomic.LoadInt32(&maxRunning)
+					if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+						break
+					}
+				}
+
+				time.Sleep(5 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				atomic.AddInt32(&done, 1)
+				return nil
+			}),
+		})
+	})
+
+	q := p.Queue
+	q.PollInterval = 10 * time.Millisecond
+	q.WorkersPerProcess = map[string]int{"single": 1}
+
+	assert.Equal(t, []string{"default", "single"}, q.Queues())
+
+	require.Nil(t, q.Start())
+	assert.ErrorIs(t, q.Start(), jobs.ErrQueueStarted)
+
+	for i := 0; i < 5; i++ {
+		_, err := bolo.EnqueueJob(context.Background(), app, "slow", nil, nil)
+		require.Nil(t, err)
+	}
+
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&done) == 5 }, 2*time.Second, 5*time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&maxRunning))
+
+	require.Nil(t, app.Close())
+
+	var pending int64
+	app.GetDB().Model(&jobs.Job{}).Where("status <> ?", jobs.StatusSucceeded).Count(&pending)
+	assert.Equal(t, int64(0), pending)
+}
+
+func TestJobResource(t *testing.T) {
+	var mu sync.Mutex
+	fail := true
+
+	app, _, _ := newTestApp(t, func(app bolo.App) {
+		app.SetJobType(&bolo.JobType{
+			Name:        "report",
+			MaxAttempts: 1,
+			Handler: bolo.JobHandlerFunc(func(ctx context.Context, app bolo.App, payload []byte) error {
+				mu.Lock()
+				defer mu.Unlock()
+				if fail {
+					return errors.New("report failed")
+				}
+				return nil
+			}),
+		})
+
+		// authenticate the requests with the X-Test-User header:
+		app.GetEvents().On("bindMiddlewares", event.ListenerFunc(func(e event.Event) error {
+			app.GetRouter().Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+				return func(c echo.Context) error {
+					if c.Request().Header.Get("X-Test-User") != "" {
+						u := users.NewUser(app)
+						u.ID = 1
+						bolo.SetAuthenticatedUser(c, u)
+						bolo.SetRoles(c, []string{"authenticated"})
+					}
+					return next(c)
+				}
+			})
+			return nil
+		}), event.Low)
+	})
+
+	app.GetAcl().SetRole("authenticated", acl.Role{
+		Name:        "authenticated",
+		Permissions: []string{"find_job", "findOne_job", "create_job", "update_job", "delete_job", "retry_job"},
+	})
+
+	do := func(method, path, body string, auth bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+		if auth {
+			req.Header.Set("X-Test-User", "1")
+			req.Header.Set(echo.HeaderAuthorization, "Test")
+		}
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/api/v1/jobs", "", false)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = do(http.MethodPost, "/api/v1/jobs", `{"job":{"type":"unknown"}}`, true)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = do(http.MethodPost, "/api/v1/jobs", `{"job":{"type":"report","payload":{"month":7}}}`, true)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	created := jobs.FindOneJSONResponse{}
+	require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	assert.Equal(t, `{"month":7}`, created.Job.Payload)
+	id := created.Job.GetID()
+
+	processed, err := app.GetJobQueue().(*jobs.Queue).RunNext(context.Background(), "default")
+	require.Nil(t, err)
+	require.True(t, processed)
+
+	rec = do(http.MethodGet, "/api/v1/jobs?status=dead", "", true)
+	require.Equal(t, http.StatusOK, rec.Code)
+	list := jobs.ListJSONResponse{}
+	require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	if assert.Len(t, list.Jobs, 1) {
+		assert.Equal(t, "report failed", list.Jobs[0].LastError)
+	}
+
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+
+	rec = do(http.MethodPost, "/api/v1/jobs/"+id+"/retry", "", true)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	processed, _ = app.GetJobQueue().(*jobs.Queue).RunNext(context.Background(), "default")
+	assert.True(t, processed)
+
+	rec = do(http.MethodPost, "/api/v1/jobs/"+id+"/retry", "", true)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = do(http.MethodDelete, "/api/v1/jobs/"+id, "", true)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = do(http.MethodGet, "/api/v1/jobs/"+id, "", true)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,85 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"gorm.io/gorm"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	// StatusDead - Dead letter, the job failed in all attempts and only runs again with one manual retry
+	StatusDead = "dead"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+)
+
+// Job - One background job stored in the jobs table
+type Job struct {
+	ID          uint64     `gorm:"primary_key;column:id;" json:"id"`
+	Queue       string     `gorm:"column:queue;type:varchar(100);index:idx_jobs_claim,priority:1" json:"queue"`
+	Type        string     `gorm:"column:type;type:varchar(150);index" json:"type"`
+	Payload     string     `gorm:"column:payload;type:text" json:"payload"`
+	Status      string     `gorm:"column:status;type:varchar(20);index:idx_jobs_claim,priority:2" json:"status"`
+	Attempts    int        `gorm:"column:attempts;default:0" json:"attempts"`
+	MaxAttempts int        `gorm:"column:maxAttempts" json:"maxAttempts"`
+	RunAt       time.Time  `gorm:"column:runAt;index:idx_jobs_claim,priority:3" json:"runAt"`
+	LockedAt    *time.Time `gorm:"column:lockedAt" json:"lockedAt"`
+	LockedBy    string     `gorm:"column:lockedBy;type:varchar(100)" json:"lockedBy"`
+	LastError   string     `gorm:"column:lastError;type:text" json:"lastError"`
+	FinishedAt  *time.Time `gorm:"column:finishedAt" json:"finishedAt"`
+	CreatedAt   time.Time  `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt   time.Time  `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+func (r *Job) TableName() string {
+	return "jobs"
+}
+
+func (r *Job) GetID() string {
+	return strconv.FormatUint(r.ID, 10)
+}
+
+func (r *Job) LoadData() error {
+	return nil
+}
+
+func (r *Job) LoadTeaserData() error {
+	return nil
+}
+
+func (r *Job) Save(app bolo.App) error {
+	now := app.GetClock().Now()
+	r.UpdatedAt = now
+
+	if r.ID == 0 {
+		r.CreatedAt = now
+		return app.GetDB().Create(r).Error
+	}
+
+	return app.GetDB().Save(r).Error
+}
+
+// FindOne - Find one job by id
+func FindOne(app bolo.App, id string) (*Job, error) {
+	record := Job{}
+
+	err := app.GetDB().First(&record, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrJobNotFound
+		}
+
+		return nil, fmt.Errorf("jobs.FindOne: %w", err)
+	}
+
+	return &record, nil
+}
@@ -0,0 +1,93 @@
+package jobs
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/gookit/event"
+)
+
+// Plugin - Database backed background jobs with one admin resource to inspect and retry jobs
+type Plugin struct {
+	bolo.Plugin
+
+	Name       string
+	App        bolo.App
+	Queue      *Queue
+	Controller *JobController
+	// Max wait for the running jobs in Close
+	StopTimeout time.Duration
+}
+
+type PluginOpts struct {
+	Queue *Queue
+}
+
+func NewPlugin(opts *PluginOpts) *Plugin {
+	return &Plugin{
+		Name:  "jobs",
+		Queue: opts.Queue,
+	}
+}
+
+func (p *Plugin) GetName() string {
+	return p.Name
+}
+
+func (p *Plugin) Init(app bolo.App) error {
+	app.GetLogger().Debug(p.GetName() + " Init")
+
+	p.App = app
+	cfg := app.GetConfiguration()
+
+	if p.Queue == nil {
+		p.Queue = NewQueueFromConfiguration(app)
+	}
+
+	if p.StopTimeout == 0 {
+		p.StopTimeout = time.Duration(cfg.GetInt64F("JOBS_STOP_TIMEOUT", 30)) * time.Second
+	}
+
+	p.Controller = &JobController{Plugin: p}
+
+	app.SetModel("job", &Job{})
+	app.SetJobQueue(p.Queue)
+
+	app.SetResource(&bolo.Resource{
+		Name:       "job",
+		Prefix:     "/api/v1",
+		Path:       "/jobs",
+		Controller: p.Controller,
+		Model:      &Job{},
+		// job state changes are internal to the queue
+		SkipEvents: true,
+		// jobs don't have HTML pages
+		SkipHTMLEndpoints: true,
+	})
+
+	app.SetRoute("retry_job", &bolo.Route{
+		Method:     http.MethodPost,
+		Path:       "/api/v1/jobs/:id/retry",
+		Action:     p.Controller.Retry,
+		Permission: "retry_job",
+	})
+
+	app.GetEvents().On("bootstrap", bolo.TracedListener(app, "jobs.start", event.ListenerFunc(func(e event.Event) error {
+		if cfg.GetBool("JOBS_WORKERS_DISABLE") {
+			return nil
+		}
+
+		return p.Queue.Start()
+	})), event.Normal)
+
+	app.GetEvents().On("close", bolo.TracedListener(app, "jobs.stop", event.ListenerFunc(func(e event.Event) error {
+		ctx, cancel := context.WithTimeout(context.Background(), p.StopTimeout)
+		defer cancel()
+
+		return p.Queue.Stop(ctx)
+	})), event.High)
+
+	return nil
+}
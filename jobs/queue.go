@@ -0,0 +1,513 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrQueueStarted  = errors.New("job queue already started")
+	ErrJobNotRetried = errors.New("only dead jobs can be retried")
+)
+
+// Queue - Database backed bolo.JobQueue, safe to run in many processes
+type Queue struct {
+	App bolo.App
+	// Workers by queue name in this process, queues without configuration have one worker.
+	// This is not one global limit: with N processes one queue runs up to N times this number of jobs
+	WorkersPerProcess  map[string]int
+	PollInterval       time.Duration
+	DefaultMaxAttempts int
+	DefaultTimeout     time.Duration
+	// Running jobs locked for more than LockTimeout are claimed again, like after one crash.
+	// The lock of the running jobs is refreshed every LockTimeout/3
+	LockTimeout time.Duration
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	WorkerID    string
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	notify  map[string]chan struct{}
+	running bool
+}
+
+// NewQueueFromConfiguration - Build one queue with the JOBS_* variables
+func NewQueueFromConfiguration(app bolo.App) *Queue {
+	cfg := app.GetConfiguration()
+
+	hostname, _ := os.Hostname()
+
+	q := Queue{
+		App:                app,
+		WorkersPerProcess:  ParseWorkersPerProcess(cfg.Get("JOBS_WORKERS_PER_PROCESS")),
+		PollInterval:       time.Duration(cfg.GetInt64F("JOBS_POLL_INTERVAL", 1000)) * time.Millisecond,
+		DefaultMaxAttempts: cfg.GetIntF("JOBS_MAX_ATTEMPTS", 5),
+		DefaultTimeout:     time.Duration(cfg.GetInt64F("JOBS_TIMEOUT", 300)) * time.Second,
+		LockTimeout:        time.Duration(cfg.GetInt64F("JOBS_LOCK_TIMEOUT", 900)) * time.Second,
+		BackoffBase:        time.Duration(cfg.GetInt64F("JOBS_BACKOFF_BASE", 10)) * time.Second,
+		BackoffMax:         time.Duration(cfg.GetInt64F("JOBS_BACKOFF_MAX", 3600)) * time.Second,
+		WorkerID:           hostname + ":" + uuid.New().String()[:8],
+	}
+
+	return &q
+}
+
+// ParseWorkersPerProcess - Parse the workers by queue, like: default:4,emails:1
+func ParseWorkersPerProcess(value string) map[string]int {
+	workers := map[string]int{}
+
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		n, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || n < 0 {
+			continue
+		}
+
+		workers[strings.TrimSpace(parts[0])] = n
+	}
+
+	return workers
+}
+
+// Enqueue - Add one job of one registered type
+func (q *Queue) Enqueue(ctx context.Context, jobType string, payload interface{}, opts *bolo.EnqueueJobOpts) (string, error) {
+	t, ok := q.App.GetJobTypes()[jobType]
+	if !ok {
+		return "", fmt.Errorf("jobs.Enqueue %s: %w", jobType, bolo.ErrJobTypeNotFound)
+	}
+
+	if opts == nil {
+		opts = &bolo.EnqueueJobOpts{}
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("jobs.Enqueue error on encode payload: %w", err)
+	}
+
+	now := q.App.GetClock().Now()
+
+	record := Job{
+		Queue:       t.Queue,
+		Type:        jobType,
+		Payload:     string(data),
+		Status:      StatusPending,
+		MaxAttempts: q.maxAttempts(t),
+		RunAt:       now.Add(opts.Delay),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if opts.Queue != "" {
+		record.Queue = opts.Queue
+	}
+	if opts.RunAt != nil {
+		record.RunAt = *opts.RunAt
+	}
+	if opts.MaxAttempts > 0 {
+		record.MaxAttempts = opts.MaxAttempts
+	}
+
+	err = q.App.GetDB().WithContext(ctx).Create(&record).Error
+	if err != nil {
+		return "", fmt.Errorf("jobs.Enqueue error on create job: %w", err)
+	}
+
+	if !record.RunAt.After(now) {
+		q.wakeUp(record.Queue)
+	}
+
+	return record.GetID(), nil
+}
+
+// Queues - Returns the queues with workers, from the job types and the workers configuration
+func (q *Queue) Queues() []string {
+	names := map[string]bool{bolo.DefaultJobQueue: true}
+
+	for _, t := range q.App.GetJobTypes() {
+		names[t.Queue] = true
+	}
+	for name := range q.WorkersPerProcess {
+		names[name] = true
+	}
+
+	queues := []string{}
+	for name := range names {
+		queues = append(queues, name)
+	}
+	sort.Strings(queues)
+
+	return queues
+}
+
+// Start - Start the workers of all queues
+func (q *Queue) Start() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.running {
+		return ErrQueueStarted
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancel = cancel
+	q.running = true
+	q.notify = make(map[string]chan struct{})
+
+	for _, name := range q.Queues() {
+		workers, ok := q.WorkersPerProcess[name]
+		if !ok {
+			workers = 1
+		}
+
+		notify := make(chan struct{}, workers)
+		q.notify[name] = notify
+
+		for i := 0; i < workers; i++ {
+			q.wg.Add(1)
+			go q.work(ctx, name, notify)
+		}
+	}
+
+	q.App.GetLogger().Info("jobs: workers started", zap.String("workerID", q.WorkerID), zap.Strings("queues", q.Queues()))
+
+	return nil
+}
+
+// Stop - Stop the workers and wait the running jobs until the ctx deadline
+func (q *Queue) Stop(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.running {
+		q.mu.Unlock()
+		return nil
+	}
+
+	q.cancel()
+	q.running = false
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("jobs.Stop: %w", ctx.Err())
+	}
+}
+
+func (q *Queue) wakeUp(queue string) {
+	q.mu.Lock()
+	notify := q.notify[queue]
+	q.mu.Unlock()
+
+	if notify == nil {
+		return
+	}
+
+	select {
+	case notify <- struct{}{}:
+	default:
+	}
+}
+
+func (q *Queue) work(ctx context.Context, queue string, notify chan struct{}) {
+	defer q.wg.Done()
+
+	l := q.App.GetLogger().With(zap.String("queue", queue))
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-notify:
+		case <-timer.C:
+		}
+
+		// process all the available jobs before the next poll
+		for ctx.Err() == nil {
+			processed, err := q.RunNext(ctx, queue)
+			if err != nil {
+				l.Error("jobs: error on run job", zap.Error(err))
+				break
+			}
+
+			if !processed {
+				break
+			}
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(q.PollInterval)
+	}
+}
+
+// RunNext - Claim and run the next available job of the queue. Returns false if the queue is empty
+func (q *Queue) RunNext(ctx context.Context, queue string) (bool, error) {
+	job, err := q.claim(ctx, queue)
+	if err != nil || job == nil {
+		return false, err
+	}
+
+	return true, q.run(ctx, job)
+}
+
+// claim - Lock one job with one conditional update, the attempts column works as the row version
+func (q *Queue) claim(ctx context.Context, queue string) (*Job, error) {
+	db := q.App.GetDB().WithContext(ctx)
+	now := q.App.GetClock().Now()
+
+	for {
+		job := Job{}
+
+		err := db.
+			Where("queue = ? AND ((status = ? AND runAt <= ?) OR (status = ? AND lockedAt <= ?))", queue, StatusPending, now, StatusRunning, now.Add(-q.LockTimeout)).
+			Order("runAt ASC, id ASC").
+			First(&job).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, nil
+			}
+
+			return nil, fmt.Errorf("jobs.claim error on find job: %w", err)
+		}
+
+		res := db.Model(&Job{}).
+			Where("id = ? AND status = ? AND attempts = ?", job.ID, job.Status, job.Attempts).
+			Updates(map[string]interface{}{
+				"status":    StatusRunning,
+				"attempts":  job.Attempts + 1,
+				"lockedAt":  now,
+				"lockedBy":  q.WorkerID,
+				"updatedAt": now,
+			})
+		if res.Error != nil {
+			return nil, fmt.Errorf("jobs.claim error on lock job: %w", res.Error)
+		}
+
+		// claimed by other worker, try the next one
+		if res.RowsAffected == 0 {
+			continue
+		}
+
+		job.Status = StatusRunning
+		job.Attempts++
+		job.LockedAt = &now
+		job.LockedBy = q.WorkerID
+
+		return &job, nil
+	}
+}
+
+func (q *Queue) run(ctx context.Context, job *Job) error {
+	l := q.App.GetLogger().With(zap.String("jobID", job.GetID()), zap.String("type", job.Type), zap.Int("attempt", job.Attempts))
+
+	t, ok := q.App.GetJobTypes()[job.Type]
+	if !ok {
+		return q.finish(job, fmt.Errorf("%w: %s", bolo.ErrJobTypeNotFound, job.Type), true)
+	}
+
+	if job.Attempts > job.MaxAttempts {
+		// stale lock of one job that already used all attempts
+		return q.finish(job, errors.New("lock timeout"), true)
+	}
+
+	timeout := t.Timeout
+	if timeout == 0 {
+		timeout = q.DefaultTimeout
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	jobCtx = bolo.ContextWithJobInfo(jobCtx, &bolo.JobInfo{
+		ID:          job.GetID(),
+		Type:        job.Type,
+		Queue:       job.Queue,
+		Attempt:     job.Attempts,
+		MaxAttempts: job.MaxAttempts,
+	})
+
+	stopLock := q.keepLocked(job)
+
+	start := time.Now()
+	err := q.handle(jobCtx, t, job)
+	stopLock()
+	if err != nil {
+		l.Warn("jobs: job failed", zap.Error(err), zap.Duration("duration", time.Since(start)))
+	} else {
+		l.Debug("jobs: job succeeded", zap.Duration("duration", time.Since(start)))
+	}
+
+	return q.finish(job, err, false)
+}
+
+// keepLocked - Refresh the lock of one running job until the returned stop function is called,
+// jobs that run for more than the LockTimeout are not claimed by other workers
+func (q *Queue) keepLocked(job *Job) func() {
+	if q.LockTimeout <= 0 {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(q.LockTimeout / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				err := q.App.GetDB().Model(&Job{}).
+					Where("id = ? AND status = ? AND lockedBy = ?", job.ID, StatusRunning, q.WorkerID).
+					Update("lockedAt", q.App.GetClock().Now()).Error
+				if err != nil {
+					q.App.GetLogger().Warn("jobs: error on refresh job lock", zap.String("jobID", job.GetID()), zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+func (q *Queue) handle(ctx context.Context, t *bolo.JobType, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+
+	return t.Handler.HandleJob(ctx, q.App, []byte(job.Payload))
+}
+
+// finish - Save the attempt result, failed jobs are scheduled with backoff or moved to the dead state
+func (q *Queue) finish(job *Job, jobErr error, dead bool) error {
+	now := q.App.GetClock().Now()
+
+	values := map[string]interface{}{
+		"lockedAt":  nil,
+		"lockedBy":  "",
+		"updatedAt": now,
+	}
+
+	switch {
+	case jobErr == nil:
+		values["status"] = StatusSucceeded
+		values["lastError"] = ""
+		values["finishedAt"] = now
+	case dead || job.Attempts >= job.MaxAttempts:
+		values["status"] = StatusDead
+		values["lastError"] = jobErr.Error()
+		values["finishedAt"] = now
+	default:
+		values["status"] = StatusPending
+		values["lastError"] = jobErr.Error()
+		values["runAt"] = now.Add(q.Backoff(job.Attempts))
+	}
+
+	// the ctx can be canceled in Stop, the result is saved anyway
+	err := q.App.GetDB().Model(&Job{}).
+		Where("id = ? AND lockedBy = ?", job.ID, q.WorkerID).
+		Updates(values).Error
+	if err != nil {
+		return fmt.Errorf("jobs.finish error on update job: %w", err)
+	}
+
+	return nil
+}
+
+// Backoff - Exponential delay before the next attempt: BackoffBase * 2^(attempt-1), limited to BackoffMax
+func (q *Queue) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	d := float64(q.BackoffBase) * math.Pow(2, float64(attempt-1))
+	if d > float64(q.BackoffMax) {
+		return q.BackoffMax
+	}
+
+	return time.Duration(d)
+}
+
+// Retry - Move one dead job back to the pending state with new attempts
+func (q *Queue) Retry(ctx context.Context, id string) (*Job, error) {
+	job, err := FindOne(q.App, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if job.Status != StatusDead {
+		return nil, ErrJobNotRetried
+	}
+
+	now := q.App.GetClock().Now()
+
+	job.Status = StatusPending
+	job.Attempts = 0
+	job.RunAt = now
+	job.FinishedAt = nil
+
+	err = job.Save(q.App)
+	if err != nil {
+		return nil, fmt.Errorf("jobs.Retry: %w", err)
+	}
+
+	q.wakeUp(job.Queue)
+
+	return job, nil
+}
+
+func (q *Queue) maxAttempts(t *bolo.JobType) int {
+	if t.MaxAttempts > 0 {
+		return t.MaxAttempts
+	}
+
+	if q.DefaultMaxAttempts > 0 {
+		return q.DefaultMaxAttempts
+	}
+
+	return 1
+}
@@ -0,0 +1,114 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Mailer - Pluggable email sender
+type Mailer interface {
+	Send(ctx context.Context, m *Message) error
+}
+
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+	Headers map[string]string
+}
+
+// LogMailer - Mailer that only writes the messages in the logger, used in development
+type LogMailer struct {
+	Logger *zap.Logger
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg *Message) error {
+	m.Logger.Info("LogMailer.Send", zap.String("from", msg.From), zap.Strings("to", msg.To), zap.String("subject", msg.Subject), zap.String("text", msg.Text))
+	return nil
+}
+
+// FileMailer - Mailer that writes each message as one .eml file in the Dir folder
+type FileMailer struct {
+	Dir string
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg *Message) error {
+	err := os.MkdirAll(m.Dir, 0755)
+	if err != nil {
+		return fmt.Errorf("FileMailer.Send: error on create dir: %w", err)
+	}
+
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	name := time.Now().UTC().Format("20060102T150405") + "-" + uuid.New().String() + ".eml"
+
+	err = os.WriteFile(filepath.Join(m.Dir, name), data, 0644)
+	if err != nil {
+		return fmt.Errorf("FileMailer.Send: error on write message: %w", err)
+	}
+
+	return nil
+}
+
+// Bytes - Returns the message in the RFC 5322 format
+func (msg *Message) Bytes() ([]byte, error) {
+	var b bytes.Buffer
+
+	b.WriteString("From: " + msg.From + "\r\n")
+	b.WriteString("To: " + strings.Join(msg.To, ", ") + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	for k, v := range msg.Headers {
+		b.WriteString(k + ": " + v + "\r\n")
+	}
+
+	if msg.HTML == "" {
+		b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+		b.WriteString(msg.Text)
+		return b.Bytes(), nil
+	}
+
+	w := multipart.NewWriter(&b)
+	b.WriteString("Content-Type: multipart/alternative; boundary=" + w.Boundary() + "\r\n\r\n")
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		if part.body == "" {
+			continue
+		}
+
+		pw, err := w.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := pw.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
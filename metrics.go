@@ -0,0 +1,248 @@
+package bolo
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+// Core metric names, plugins can register their own collectors in app.GetMetrics()
+const (
+	MetricHTTPRequests              = "bolo_http_requests_total"
+	MetricHTTPRequestDuration       = "bolo_http_request_duration_seconds"
+	MetricDBQueryDuration           = "bolo_db_query_duration_seconds"
+	MetricDBQueryErrors             = "bolo_db_query_errors_total"
+	MetricTemplateRenderDuration    = "bolo_template_render_duration_seconds"
+	MetricHTTPClientRequests        = "bolo_http_client_requests_total"
+	MetricHTTPClientRequestDuration = "bolo_http_client_request_duration_seconds"
+)
+
+// coreMetrics - Core collectors registered in one app registry
+type coreMetrics struct {
+	httpRequests              *prometheus.CounterVec
+	httpRequestDuration       *prometheus.HistogramVec
+	dbQueryDuration           *prometheus.HistogramVec
+	dbQueryErrors             *prometheus.CounterVec
+	templateRenderDuration    *prometheus.HistogramVec
+	httpClientRequests        *prometheus.CounterVec
+	httpClientRequestDuration *prometheus.HistogramVec
+}
+
+// registriesMetrics - Core collectors by registry, apps with the same registry share the collectors
+var registriesMetrics sync.Map
+
+func getCoreMetrics(r *prometheus.Registry) *coreMetrics {
+	if m, ok := registriesMetrics.Load(r); ok {
+		return m.(*coreMetrics)
+	}
+
+	m := &coreMetrics{
+		httpRequests: registerMetric(r, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: MetricHTTPRequests,
+			Help: "Number of HTTP requests by route and status code",
+		}, []string{"method", "route", "status"})),
+		httpRequestDuration: registerMetric(r, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: MetricHTTPRequestDuration,
+			Help: "HTTP request latency by route",
+		}, []string{"method", "route"})),
+		dbQueryDuration: registerMetric(r, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: MetricDBQueryDuration,
+			Help: "Database query duration by database and operation",
+		}, []string{"db", "operation"})),
+		dbQueryErrors: registerMetric(r, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: MetricDBQueryErrors,
+			Help: "Number of database query errors by database and operation",
+		}, []string{"db", "operation"})),
+		templateRenderDuration: registerMetric(r, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: MetricTemplateRenderDuration,
+			Help: "Template render duration by template",
+		}, []string{"template"})),
+		httpClientRequests: registerMetric(r, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: MetricHTTPClientRequests,
+			Help: "Number of outgoing HTTP requests by host and status code",
+		}, []string{"method", "host", "status"})),
+		httpClientRequestDuration: registerMetric(r, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: MetricHTTPClientRequestDuration,
+			Help: "Outgoing HTTP request latency by host",
+		}, []string{"method", "host"})),
+	}
+
+	actual, _ := registriesMetrics.LoadOrStore(r, m)
+	return actual.(*coreMetrics)
+}
+
+// registerMetric - Register one collector or returns the same collector already registered in the registry.
+// Panics if the name is already registered with other type or labels
+func registerMetric[T prometheus.Collector](r *prometheus.Registry, c T) T {
+	err := r.Register(c)
+	if err == nil {
+		return c
+	}
+
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		if existing, ok := are.ExistingCollector.(T); ok {
+			return existing
+		}
+	}
+
+	panic(err)
+}
+
+// MetricsMiddleware - Count the requests and observe the latency by route name and status code
+func MetricsMiddleware(app App) echo.MiddlewareFunc {
+	m := getCoreMetrics(app.GetMetrics())
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+
+			// write the error response to get the final status
+			err := commitError(c, next(c))
+
+			method := c.Request().Method
+			route := metricsRouteLabel(c)
+
+			m.httpRequests.WithLabelValues(method, route, strconv.Itoa(c.Response().Status)).Inc()
+			m.httpRequestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+
+			return err
+		}
+	}
+}
+
+// metricsRouteLabel - Route name from app.Routes, the path pattern for other routes
+func metricsRouteLabel(c echo.Context) string {
+	if name, ok := c.Get("route_name").(string); ok && name != "" {
+		return name
+	}
+
+	if p := c.Path(); p != "" && c.Response().Status != http.StatusNotFound {
+		return p
+	}
+
+	return "unmatched"
+}
+
+// BindMetricsRoutes - Register the metrics endpoint, protected with METRICS_TOKEN.
+// Without token the endpoint is only registered with METRICS_PUBLIC=true, the metrics are public in this case
+func BindMetricsRoutes(app App) error {
+	cfg := app.GetConfiguration()
+	if cfg.GetBool(METRICS_DISABLE) {
+		return nil
+	}
+
+	token := cfg.Get(METRICS_TOKEN)
+	if token == "" && !cfg.GetBool(METRICS_PUBLIC) {
+		app.GetLogger().Info("metrics: endpoint disabled, set METRICS_TOKEN or METRICS_PUBLIC=true to expose the metrics")
+		return nil
+	}
+	handler := promhttp.HandlerFor(app.GetMetrics(), promhttp.HandlerOpts{})
+
+	app.GetRouter().GET(cfg.GetF(METRICS_PATH, "/metrics"), func(c echo.Context) error {
+		if token != "" {
+			auth := c.Request().Header.Get(echo.HeaderAuthorization)
+			if subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+token)) != 1 {
+				return &HTTPError{Code: http.StatusUnauthorized, Message: "Unauthorized"}
+			}
+		}
+
+		handler.ServeHTTP(c.Response(), c.Request())
+		return nil
+	})
+
+	return nil
+}
+
+// InstrumentDB - Add the query duration and error metrics in one database connection
+func InstrumentDB(app App, name string, db *gorm.DB) error {
+	m := getCoreMetrics(app.GetMetrics())
+
+	before := func(tx *gorm.DB) {
+		tx.InstanceSet("bolo:metrics_start", time.Now())
+	}
+
+	after := func(operation string) func(tx *gorm.DB) {
+		return func(tx *gorm.DB) {
+			v, ok := tx.InstanceGet("bolo:metrics_start")
+			if !ok {
+				return
+			}
+
+			m.dbQueryDuration.WithLabelValues(name, operation).Observe(time.Since(v.(time.Time)).Seconds())
+
+			if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+				m.dbQueryErrors.WithLabelValues(name, operation).Inc()
+			}
+		}
+	}
+
+	cb := db.Callback()
+
+	registers := []struct {
+		operation string
+		before    error
+		after     error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register("bolo:metrics_before_create", before), cb.Create().After("gorm:create").Register("bolo:metrics_after_create", after("create"))},
+		{"query", cb.Query().Before("gorm:query").Register("bolo:metrics_before_query", before), cb.Query().After("gorm:query").Register("bolo:metrics_after_query", after("query"))},
+		{"update", cb.Update().Before("gorm:update").Register("bolo:metrics_before_update", before), cb.Update().After("gorm:update").Register("bolo:metrics_after_update", after("update"))},
+		{"delete", cb.Delete().Before("gorm:delete").Register("bolo:metrics_before_delete", before), cb.Delete().After("gorm:delete").Register("bolo:metrics_after_delete", after("delete"))},
+		{"row", cb.Row().Before("gorm:row").Register("bolo:metrics_before_row", before), cb.Row().After("gorm:row").Register("bolo:metrics_after_row", after("row"))},
+		{"raw", cb.Raw().Before("gorm:raw").Register("bolo:metrics_before_raw", before), cb.Raw().After("gorm:raw").Register("bolo:metrics_after_raw", after("raw"))},
+	}
+
+	for _, r := range registers {
+		if err := errors.Join(r.before, r.after); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MetricsTransport - http.RoundTripper that records the outgoing requests metrics
+type MetricsTransport struct {
+	// Base transport, if nil the http.DefaultTransport is used
+	Base    http.RoundTripper
+	Metrics *prometheus.Registry
+}
+
+func (t *MetricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	start := time.Now()
+	res, err := base.RoundTrip(req)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(res.StatusCode)
+	}
+
+	m := getCoreMetrics(t.Metrics)
+	m.httpClientRequests.WithLabelValues(req.Method, req.URL.Host, status).Inc()
+	m.httpClientRequestDuration.WithLabelValues(req.Method, req.URL.Host).Observe(time.Since(start).Seconds())
+
+	return res, err
+}
+
+// InstrumentHttpClient - Add the MetricsTransport in the HttpClient if it is one *http.Client
+func InstrumentHttpClient(app App) {
+	client, ok := HttpClient.(*http.Client)
+	if !ok {
+		return
+	}
+
+	client.Transport = &MetricsTransport{Base: client.Transport, Metrics: app.GetMetrics()}
+}
@@ -0,0 +1,129 @@
+package bolo_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	bolo "github.com/go-bolo/bolo"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMetrics(t *testing.T) {
+	os.Setenv("METRICS_TOKEN", "secret")
+	defer os.Unsetenv("METRICS_TOKEN")
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer upstream.Close()
+
+	app := GetTestApp()
+	app.SetRoute("metrics_check", &bolo.Route{
+		Method: http.MethodGet,
+		Path:   "/metrics-check/:id",
+		Action: func(c echo.Context) (bolo.Response, error) {
+			var count int64
+			err := c.Get("app").(bolo.App).GetDB().Raw("SELECT 1").Scan(&count).Error
+			if err != nil {
+				return nil, err
+			}
+
+			res, err := bolo.GetWithContext(c.Request().Context(), upstream.URL, nil)
+			if err != nil {
+				return nil, err
+			}
+			res.Body.Close()
+
+			return &bolo.DefaultResponse{Data: map[string]int64{"count": count}}, nil
+		},
+	})
+
+	err := app.Bootstrap()
+	assert.Nil(t, err)
+
+	jobs := promauto.With(app.GetMetrics()).NewCounterVec(prometheus.CounterOpts{
+		Name: "plugin_jobs_total",
+		Help: "Jobs processed",
+	}, []string{"queue"})
+	jobs.WithLabelValues("default").Inc()
+
+	request := func(url string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	reports, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "reports.sqlite")), &gorm.Config{})
+	require.Nil(t, err)
+	require.Nil(t, app.SetDB("reports", reports))
+	var count int64
+	require.Nil(t, app.GetDBByName("reports").Raw("SELECT 1").Scan(&count).Error)
+
+	rec := request("/metrics-check/1", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	request("/metrics-check/2", nil)
+	request("/unknown", nil)
+
+	t.Run("should require the metrics token", func(t *testing.T) {
+		rec := request("/metrics", nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("should return the metrics in the prometheus format", func(t *testing.T) {
+		rec := request("/metrics", map[string]string{"Authorization": "Bearer secret"})
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		body := rec.Body.String()
+		assert.Contains(t, body, "# TYPE bolo_http_requests_total counter")
+		assert.Contains(t, body, `bolo_http_requests_total{method="GET",route="metrics_check",status="200"} 2`)
+		assert.Contains(t, body, `bolo_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+		assert.Contains(t, body, `bolo_http_request_duration_seconds_count{method="GET",route="metrics_check"} 2`)
+		assert.Contains(t, body, `bolo_db_query_duration_seconds_count{db="default",operation="row"} 2`)
+		assert.Contains(t, body, `bolo_db_query_duration_seconds_count{db="reports",operation="row"} 1`)
+		assert.Contains(t, body, `bolo_http_client_requests_total{host="`+upstream.Listener.Addr().String()+`",method="GET",status="202"} 2`)
+		assert.Contains(t, body, `plugin_jobs_total{queue="default"} 1`)
+	})
+
+	t.Run("should keep the metrics of each app in its registry", func(t *testing.T) {
+		other := GetTestApp()
+		require.Nil(t, other.Bootstrap())
+
+		families, err := other.GetMetrics().Gather()
+		require.Nil(t, err)
+		for _, f := range families {
+			assert.NotEqual(t, "plugin_jobs_total", f.GetName())
+			assert.NotEqual(t, "bolo_http_requests_total", f.GetName())
+		}
+	})
+
+	t.Run("should write the error responses once", func(t *testing.T) {
+		rec := request("/unknown", map[string]string{"Accept": "application/json"})
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.True(t, json.Valid(rec.Body.Bytes()), rec.Body.String())
+	})
+}
+
+func TestMetricsWithoutToken(t *testing.T) {
+	app := GetTestApp()
+	require.Nil(t, app.Bootstrap())
+
+	// not public by default
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rec := httptest.NewRecorder()
+	app.GetRouter().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
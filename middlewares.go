@@ -19,14 +19,25 @@ func BindMiddlewares(app App, p Plugin) {
 		RedirectCode: http.StatusMovedPermanently,
 	}))
 
+	router.Pre(FeedSuffixMiddleware(app))
 	router.Pre(AcceptResolverMiddleware(app))
 
+	router.Use(TracingMiddleware(app))
+	if !app.GetConfiguration().GetBool(METRICS_DISABLE) {
+		router.Use(MetricsMiddleware(app))
+	}
+	router.Use(AccessLogMiddleware(app))
+	router.Use(SecurityHeadersMiddleware(app))
 	router.Use(middleware.Gzip())
 	router.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowCredentials: app.GetConfiguration().GetBoolF(CORS_ALLOW_CREDENTIALS, true),
 		MaxAge:           app.GetConfiguration().GetIntF(CORS_MAX_AGE, 18000), // secconds
 	}))
 
+	if !app.GetConfiguration().GetBool(CSRF_DISABLE) {
+		router.Use(CSRFMiddleware(app))
+	}
+
 	if goEnv == "dev" {
 		router.Debug = true
 	}
@@ -0,0 +1,197 @@
+package oidc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/go-bolo/bolo/helpers"
+	"github.com/go-bolo/bolo/users"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUserNotLinked = errors.New("no user linked to this identity")
+)
+
+// Identity - The authenticated identity returned by the provider
+type Identity struct {
+	Provider          string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+	Groups            []string
+	Claims            map[string]interface{}
+}
+
+// NewIdentity - Build the identity from the id_token or userinfo claims
+func NewIdentity(p *Provider, claims map[string]interface{}) *Identity {
+	return &Identity{
+		Provider:          p.Name,
+		Subject:           claimString(claims, "sub"),
+		Email:             claimString(claims, "email"),
+		EmailVerified:     claimBool(claims, "email_verified"),
+		Name:              claimString(claims, "name"),
+		PreferredUsername: claimString(claims, "preferred_username"),
+		Groups:            claimStrings(claims, p.GroupsClaim),
+		Claims:            claims,
+	}
+}
+
+// UserIdentity - Link between one provider identity and one local user
+type UserIdentity struct {
+	ID          uint64     `gorm:"primary_key;column:id;" json:"id"`
+	Provider    string     `gorm:"column:provider;type:varchar(100);uniqueIndex:idx_user_identities_provider_subject" json:"provider"`
+	Subject     string     `gorm:"column:subject;type:varchar(255);uniqueIndex:idx_user_identities_provider_subject" json:"subject"`
+	UserID      string     `gorm:"column:userId;type:varchar(64);index" json:"userId"`
+	Email       string     `gorm:"column:email;type:varchar(255)" json:"email"`
+	LastLoginAt *time.Time `gorm:"column:lastLoginAt" json:"lastLoginAt"`
+	CreatedAt   time.Time  `gorm:"column:createdAt" json:"createdAt"`
+}
+
+func (r *UserIdentity) TableName() string {
+	return "user_identities"
+}
+
+func (r *UserIdentity) GetID() string {
+	return strconv.FormatUint(r.ID, 10)
+}
+
+func (r *UserIdentity) LoadData() error {
+	return nil
+}
+
+func (r *UserIdentity) LoadTeaserData() error {
+	return nil
+}
+
+func (r *UserIdentity) Save(app bolo.App) error {
+	if r.ID == 0 {
+		r.CreatedAt = app.GetClock().Now()
+		return app.GetDB().Create(r).Error
+	}
+
+	return app.GetDB().Save(r).Error
+}
+
+// ResolveUserFunc - Hook that links or provisions the local user for one identity
+type ResolveUserFunc func(c echo.Context, identity *Identity) (bolo.User, error)
+
+// NewUsersResolver - Default resolver backed by the users plugin.
+// Identities are linked by provider subject, then by verified email, and new users are created if autoProvision is true
+func NewUsersResolver(autoProvision bool) ResolveUserFunc {
+	return func(c echo.Context, identity *Identity) (bolo.User, error) {
+		app := bolo.GetApp(c)
+		now := app.GetClock().Now()
+
+		link := UserIdentity{}
+		err := app.GetDB().First(&link, "provider = ? AND subject = ?", identity.Provider, identity.Subject).Error
+		if err == nil {
+			user, err := users.FindOneByID(app, link.UserID)
+			if err != nil {
+				return nil, fmt.Errorf("oidc.UsersResolver error on load linked user: %w", err)
+			}
+
+			link.LastLoginAt = &now
+			link.Email = identity.Email
+
+			return user, link.Save(app)
+		}
+
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("oidc.UsersResolver: %w", err)
+		}
+
+		var user *users.User
+
+		// only trust emails verified by the provider to link existing accounts
+		if identity.Email != "" && identity.EmailVerified {
+			user, err = users.FindOneByLogin(app, identity.Email)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && !errors.Is(err, users.ErrUserNotFound) {
+				return nil, fmt.Errorf("oidc.UsersResolver: %w", err)
+			}
+		}
+
+		if user == nil {
+			if !autoProvision || identity.Email == "" {
+				return nil, ErrUserNotLinked
+			}
+
+			user = users.NewUser(app)
+			user.SetEmail(identity.Email)
+			user.SetFullName(identity.Name)
+			user.SetDisplayName(firstNonEmpty(identity.PreferredUsername, identity.Name))
+			if identity.EmailVerified {
+				user.EmailConfirmedAt = &now
+			}
+
+			err = user.Save(app)
+			if err != nil {
+				return nil, fmt.Errorf("oidc.UsersResolver error on create user: %w", err)
+			}
+		}
+
+		link = UserIdentity{
+			Provider:    identity.Provider,
+			Subject:     identity.Subject,
+			UserID:      user.GetID(),
+			Email:       identity.Email,
+			LastLoginAt: &now,
+		}
+
+		err = link.Save(app)
+		if err != nil {
+			return nil, fmt.Errorf("oidc.UsersResolver error on link identity: %w", err)
+		}
+
+		return user, nil
+	}
+}
+
+// ApplyRoleMapping - Sync the roles managed by the mapping with the identity groups.
+// Roles not present in the mapping are never changed. Returns true if the user roles changed
+func ApplyRoleMapping(user bolo.User, groups []string, mapping map[string][]string) bool {
+	if len(mapping) == 0 {
+		return false
+	}
+
+	granted := map[string]bool{}
+	managed := map[string]bool{}
+
+	for group, roles := range mapping {
+		for _, role := range roles {
+			managed[role] = true
+			if helpers.SliceContains(groups, group) {
+				granted[role] = true
+			}
+		}
+	}
+
+	roles := make([]string, 0, len(managed))
+	for role := range managed {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	changed := false
+
+	for _, role := range roles {
+		has := helpers.SliceContains(user.GetRoles(), role)
+
+		if granted[role] && !has {
+			user.AddRole(role)
+			changed = true
+		} else if !granted[role] && has {
+			user.RemoveRole(role)
+			changed = true
+		}
+	}
+
+	return changed
+}
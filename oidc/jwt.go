@@ -0,0 +1,192 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidIDToken = errors.New("invalid id_token")
+)
+
+// clock skew tolerance for exp and iat checks
+const allowedClockSkew = 2 * time.Minute
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// VerifyIDToken - Verify the id_token signature with the provider JWKS and validate the iss, aud, exp and nonce claims
+func (p *Provider) VerifyIDToken(ctx context.Context, raw, nonce string, now time.Time) (map[string]interface{}, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidIDToken)
+	}
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid header", ErrInvalidIDToken)
+	}
+
+	header := jwtHeader{}
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, fmt.Errorf("%w: invalid header", ErrInvalidIDToken)
+	}
+
+	var hash crypto.Hash
+	switch header.Alg {
+	case "RS256":
+		hash = crypto.SHA256
+	case "RS384":
+		hash = crypto.SHA384
+	case "RS512":
+		hash = crypto.SHA512
+	default:
+		return nil, fmt.Errorf("%w: unsupported alg: %s", ErrInvalidIDToken, header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid signature encoding", ErrInvalidIDToken)
+	}
+
+	key, err := p.getKey(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	err = rsa.VerifyPKCS1v15(key, hash, digest(hash, parts[0]+"."+parts[1]), signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid signature", ErrInvalidIDToken)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid payload", ErrInvalidIDToken)
+	}
+
+	claims := map[string]interface{}{}
+	decoder := json.NewDecoder(strings.NewReader(string(payload)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&claims); err != nil {
+		return nil, fmt.Errorf("%w: invalid payload", ErrInvalidIDToken)
+	}
+
+	if p.Issuer != "" && claimString(claims, "iss") != p.Issuer {
+		return nil, fmt.Errorf("%w: issuer mismatch", ErrInvalidIDToken)
+	}
+
+	if !audienceContains(claims["aud"], p.ClientID) {
+		return nil, fmt.Errorf("%w: audience mismatch", ErrInvalidIDToken)
+	}
+
+	exp, ok := claimTime(claims, "exp")
+	if !ok || now.After(exp.Add(allowedClockSkew)) {
+		return nil, fmt.Errorf("%w: token expired", ErrInvalidIDToken)
+	}
+
+	if iat, ok := claimTime(claims, "iat"); ok && iat.After(now.Add(allowedClockSkew)) {
+		return nil, fmt.Errorf("%w: token issued in the future", ErrInvalidIDToken)
+	}
+
+	if claimString(claims, "nonce") != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	return claims, nil
+}
+
+func digest(hash crypto.Hash, data string) []byte {
+	switch hash {
+	case crypto.SHA384:
+		sum := sha512.Sum384([]byte(data))
+		return sum[:]
+	case crypto.SHA512:
+		sum := sha512.Sum512([]byte(data))
+		return sum[:]
+	default:
+		sum := sha256.Sum256([]byte(data))
+		return sum[:]
+	}
+}
+
+func audienceContains(aud interface{}, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok && s == clientID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func claimString(claims map[string]interface{}, name string) string {
+	if v, ok := claims[name].(string); ok {
+		return v
+	}
+
+	return ""
+}
+
+func claimTime(claims map[string]interface{}, name string) (time.Time, bool) {
+	switch v := claims[name].(type) {
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			f, err := v.Float64()
+			if err != nil {
+				return time.Time{}, false
+			}
+			n = int64(f)
+		}
+		return time.Unix(n, 0), true
+	case float64:
+		return time.Unix(int64(v), 0), true
+	}
+
+	return time.Time{}, false
+}
+
+// claimStrings - Returns one string or list of strings claim, like groups
+func claimStrings(claims map[string]interface{}, name string) []string {
+	values := []string{}
+
+	switch v := claims[name].(type) {
+	case string:
+		values = append(values, v)
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	return values
+}
+
+func claimBool(claims map[string]interface{}, name string) bool {
+	switch v := claims[name].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+
+	return false
+}
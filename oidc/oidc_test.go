@@ -0,0 +1,329 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/go-bolo/bolo/oidc"
+	"github.com/go-bolo/bolo/users"
+	"github.com/go-bolo/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockIssuer - Minimal OpenID Connect provider for tests
+type mockIssuer struct {
+	Server   *httptest.Server
+	Key      *rsa.PrivateKey
+	ClientID string
+	Now      time.Time
+
+	mu        sync.Mutex
+	nonce     string
+	challenge string
+	Claims    map[string]interface{}
+	// override the id_token nonce to test nonce validation
+	BadNonce bool
+}
+
+func newMockIssuer(t *testing.T, now time.Time) *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+
+	m := &mockIssuer{Key: key, ClientID: "client-1", Now: now}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.Server.URL,
+			"authorization_endpoint": m.Server.URL + "/authorize",
+			"token_endpoint":         m.Server.URL + "/token",
+			"userinfo_endpoint":      m.Server.URL + "/userinfo",
+			"jwks_uri":               m.Server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "key-1",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != "valid-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != m.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+
+		claims := map[string]interface{}{
+			"iss":   m.Server.URL,
+			"aud":   m.ClientID,
+			"exp":   m.Now.Add(time.Hour).Unix(),
+			"iat":   m.Now.Unix(),
+			"nonce": m.nonce,
+		}
+		if m.BadNonce {
+			claims["nonce"] = "other"
+		}
+		for k, v := range m.Claims {
+			claims[k] = v
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+			"id_token":     m.sign(t, claims),
+		})
+	})
+
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Server.Close)
+
+	return m
+}
+
+func (m *mockIssuer) sign(t *testing.T, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "key-1", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signingInput))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, m.Key, crypto.SHA256, sum[:])
+	require.Nil(t, err)
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// authorize - Simulate the user approval in the provider, returns the callback URL
+func (m *mockIssuer) authorize(t *testing.T, location string) string {
+	u, err := url.Parse(location)
+	require.Nil(t, err)
+
+	q := u.Query()
+	assert.Equal(t, "code", q.Get("response_type"))
+	assert.Equal(t, "S256", q.Get("code_challenge_method"))
+	assert.Equal(t, m.ClientID, q.Get("client_id"))
+
+	m.mu.Lock()
+	m.nonce = q.Get("nonce")
+	m.challenge = q.Get("code_challenge")
+	m.mu.Unlock()
+
+	callback, err := url.Parse(q.Get("redirect_uri"))
+	require.Nil(t, err)
+
+	return callback.Path + "?code=valid-code&state=" + url.QueryEscape(q.Get("state"))
+}
+
+func newTestApp(t *testing.T, autoProvision bool) (bolo.App, *mockIssuer) {
+	os.Setenv("TEMPLATE_DISABLE", "true")
+	os.Setenv("DB_URI", filepath.Join(t.TempDir(), "oidc.sqlite"))
+	t.Cleanup(func() {
+		os.Unsetenv("TEMPLATE_DISABLE")
+		os.Unsetenv("DB_URI")
+	})
+
+	now, _ := time.Parse("2006-01-02", "2023-07-16")
+	issuer := newMockIssuer(t, now)
+
+	app := bolo.NewApp(&bolo.DefaultAppOptions{})
+	c := clock.NewMock()
+	c.Set(now)
+	app.SetClock(c)
+
+	app.AddPlugin(users.NewPlugin(&users.PluginOpts{PasswordHasher: &users.BcryptHasher{Cost: 4}}))
+
+	p := oidc.NewPlugin(&oidc.PluginOpts{
+		ResolveUser: oidc.NewUsersResolver(autoProvision),
+		Providers: []*oidc.Provider{{
+			Name:        "mock",
+			Issuer:      issuer.Server.URL,
+			ClientID:    issuer.ClientID,
+			Scopes:      []string{"openid", "email"},
+			GroupsClaim: "groups",
+			RoleMapping: map[string][]string{"admins": {"administrator"}, "writers": {"editor"}},
+			HTTPClient:  issuer.Server.Client(),
+		}},
+	})
+	app.AddPlugin(p)
+
+	require.Nil(t, app.Bootstrap())
+	require.Nil(t, app.SyncDB())
+
+	return app, issuer
+}
+
+func get(app bolo.App, path string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Accept", "application/json")
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+
+	rec := httptest.NewRecorder()
+	app.GetRouter().ServeHTTP(rec, req)
+	return rec
+}
+
+func findCookie(rec *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == name && c.Value != "" {
+			return c
+		}
+	}
+
+	return nil
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	app, issuer := newTestApp(t, true)
+	issuer.Claims = map[string]interface{}{
+		"sub":            "user-123",
+		"email":          "Alice@Example.com",
+		"email_verified": true,
+		"name":           "Alice",
+		"groups":         []string{"writers"},
+	}
+
+	rec := get(app, "/auth/oidc/mock/login?redirectTo=/dashboard")
+	require.Equal(t, http.StatusFound, rec.Code, rec.Body.String())
+
+	location := rec.Header().Get("Location")
+	assert.True(t, strings.HasPrefix(location, issuer.Server.URL+"/authorize?"))
+	stateCookie := findCookie(rec, "bolo_oidc_state")
+	require.NotNil(t, stateCookie)
+
+	callback := issuer.authorize(t, location)
+
+	t.Run("Should reject callbacks without the state cookie", func(t *testing.T) {
+		rec := get(app, callback)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	rec = get(app, callback, stateCookie)
+	require.Equal(t, http.StatusSeeOther, rec.Code, rec.Body.String())
+	assert.Equal(t, "/dashboard", rec.Header().Get("Location"))
+	require.NotNil(t, findCookie(rec, "bolo_session"))
+
+	user, err := users.FindOneByLogin(app, "alice@example.com")
+	require.Nil(t, err)
+	assert.Equal(t, "Alice", user.GetFullName())
+	assert.True(t, user.IsEmailConfirmed())
+	assert.Equal(t, []string{"editor"}, user.GetRoles())
+
+	t.Run("Should reuse the linked user and sync the mapped roles", func(t *testing.T) {
+		issuer.Claims["groups"] = []string{"admins"}
+
+		rec := get(app, "/auth/oidc/mock/login")
+		callback := issuer.authorize(t, rec.Header().Get("Location"))
+
+		rec = get(app, callback, findCookie(rec, "bolo_oidc_state"))
+		require.Equal(t, http.StatusSeeOther, rec.Code, rec.Body.String())
+		assert.Equal(t, "/", rec.Header().Get("Location"))
+
+		var count int64
+		app.GetDB().Model(&users.User{}).Count(&count)
+		assert.Equal(t, int64(1), count)
+
+		saved, err := users.FindOneByID(app, user.GetID())
+		require.Nil(t, err)
+		assert.Equal(t, []string{"administrator"}, saved.GetRoles())
+	})
+
+	t.Run("Should reject id tokens with invalid nonce", func(t *testing.T) {
+		issuer.BadNonce = true
+		defer func() { issuer.BadNonce = false }()
+
+		rec := get(app, "/auth/oidc/mock/login")
+		callback := issuer.authorize(t, rec.Header().Get("Location"))
+
+		rec = get(app, callback, findCookie(rec, "bolo_oidc_state"))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("Should reject unknown providers", func(t *testing.T) {
+		rec := get(app, "/auth/oidc/unknown/login")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestAuthorizationCodeFlowWithoutAutoProvision(t *testing.T) {
+	app, issuer := newTestApp(t, false)
+	issuer.Claims = map[string]interface{}{
+		"sub":            "user-456",
+		"email":          "bob@example.com",
+		"email_verified": true,
+	}
+
+	rec := get(app, "/auth/oidc/mock/login")
+	callback := issuer.authorize(t, rec.Header().Get("Location"))
+
+	rec = get(app, callback, findCookie(rec, "bolo_oidc_state"))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// existing users are linked by verified email:
+	u := users.NewUser(app)
+	u.SetEmail("bob@example.com")
+	require.Nil(t, u.Save(app))
+
+	rec = get(app, "/auth/oidc/mock/login")
+	callback = issuer.authorize(t, rec.Header().Get("Location"))
+
+	rec = get(app, callback, findCookie(rec, "bolo_oidc_state"))
+	assert.Equal(t, http.StatusSeeOther, rec.Code, rec.Body.String())
+
+	link := oidc.UserIdentity{}
+	require.Nil(t, app.GetDB().First(&link, "subject = ?", "user-456").Error)
+	assert.Equal(t, u.GetID(), link.UserID)
+}
+
+func TestParseRoleMapping(t *testing.T) {
+	assert.Equal(t, map[string][]string{
+		"admins":  {"administrator"},
+		"editors": {"editor", "reviewer"},
+	}, oidc.ParseRoleMapping("admins:administrator, editors:editor|reviewer,invalid"))
+}
+
+func TestDiscoverResponseLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"issuer":"` + strings.Repeat("a", 2<<20) + `"}`))
+	}))
+	defer server.Close()
+
+	p := &oidc.Provider{Name: "large", Issuer: server.URL, HTTPClient: server.Client()}
+	err := p.Discover(context.Background())
+	require.NotNil(t, err)
+
+	var maxErr *http.MaxBytesError
+	assert.True(t, errors.As(err, &maxErr), err.Error())
+}
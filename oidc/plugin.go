@@ -0,0 +1,373 @@
+package oidc
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/go-bolo/bolo/users"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const stateCookieName = "bolo_oidc_state"
+
+var (
+	ErrInvalidState = errors.New("invalid oidc state")
+)
+
+// LoginFunc - Hook that starts the local session for the resolved user
+type LoginFunc func(c echo.Context, user bolo.User) error
+
+// Plugin - OpenID Connect / OAuth2 login with the authorization code flow and PKCE
+type Plugin struct {
+	bolo.Plugin
+
+	Name      string
+	App       bolo.App
+	Providers map[string]*Provider
+	// Link or provision the local user, default: NewUsersResolver with OIDC_AUTO_PROVISION
+	ResolveUser ResolveUserFunc
+	// Start the user session, default: users plugin Login
+	Login LoginFunc
+	// Secret used to sign the state cookie, default: OIDC_STATE_SECRET, SESSION_SECRET or one random secret
+	StateSecret []byte
+	// Max time between the login redirect and the callback
+	StateTTL time.Duration
+}
+
+type PluginOpts struct {
+	Providers   []*Provider
+	ResolveUser ResolveUserFunc
+	Login       LoginFunc
+}
+
+func NewPlugin(opts *PluginOpts) *Plugin {
+	p := &Plugin{
+		Name:        "oidc",
+		Providers:   make(map[string]*Provider),
+		ResolveUser: opts.ResolveUser,
+		Login:       opts.Login,
+	}
+
+	for _, provider := range opts.Providers {
+		p.Providers[provider.Name] = provider
+	}
+
+	return p
+}
+
+func (p *Plugin) GetName() string {
+	return p.Name
+}
+
+func (p *Plugin) Init(app bolo.App) error {
+	app.GetLogger().Debug(p.GetName() + " Init")
+
+	p.App = app
+	cfg := app.GetConfiguration()
+
+	for _, provider := range NewProvidersFromConfiguration(cfg) {
+		if _, ok := p.Providers[provider.Name]; !ok {
+			p.Providers[provider.Name] = provider
+		}
+	}
+
+	if p.ResolveUser == nil {
+		p.ResolveUser = NewUsersResolver(cfg.GetBool("OIDC_AUTO_PROVISION"))
+	}
+
+	if p.Login == nil {
+		p.Login = func(c echo.Context, user bolo.User) error {
+			up, ok := app.GetPlugin("users").(*users.Plugin)
+			if !ok {
+				return errors.New("oidc: users plugin not found, set the Login hook")
+			}
+
+			return up.Login(c, user)
+		}
+	}
+
+	if len(p.StateSecret) == 0 {
+		secret, err := bolo.SigningSecret(app, "OIDC_STATE_SECRET")
+		if err != nil {
+			return err
+		}
+		p.StateSecret = secret
+	}
+
+	if p.StateTTL == 0 {
+		p.StateTTL = 10 * time.Minute
+	}
+
+	app.SetModel("user_identity", &UserIdentity{})
+
+	app.SetRoute("oidc_login", &bolo.Route{
+		Method: http.MethodGet,
+		Path:   "/auth/oidc/:provider/login",
+		Action: p.LoginHandler,
+	})
+	app.SetRoute("oidc_callback", &bolo.Route{
+		Method:   http.MethodGet,
+		Path:     "/auth/oidc/:provider/callback",
+		Action:   p.CallbackHandler,
+		Template: "oidc/callback",
+	})
+
+	return nil
+}
+
+func (p *Plugin) GetProvider(name string) (*Provider, error) {
+	provider, ok := p.Providers[name]
+	if !ok {
+		return nil, ErrProviderNotFound
+	}
+
+	return provider, nil
+}
+
+// state - Data stored in the signed cookie between the login redirect and the callback
+type state struct {
+	Provider     string `json:"p"`
+	State        string `json:"s"`
+	Nonce        string `json:"n"`
+	CodeVerifier string `json:"v"`
+	RedirectTo   string `json:"r"`
+	ExpiresAt    int64  `json:"e"`
+}
+
+// LoginHandler - Redirect to the provider authorization endpoint
+func (p *Plugin) LoginHandler(c echo.Context) (bolo.Response, error) {
+	provider, err := p.GetProvider(c.Param("provider"))
+	if err != nil {
+		return nil, &bolo.HTTPError{Code: http.StatusNotFound, Message: "Not found", Internal: err}
+	}
+
+	err = provider.Discover(c.Request().Context())
+	if err != nil {
+		return nil, fmt.Errorf("oidc.LoginHandler: %w", err)
+	}
+
+	s := state{
+		Provider:     provider.Name,
+		State:        randomString(),
+		Nonce:        randomString(),
+		CodeVerifier: randomString() + randomString(),
+		RedirectTo:   safeRedirectTo(c.QueryParam("redirectTo")),
+		ExpiresAt:    p.App.GetClock().Now().Add(p.StateTTL).Unix(),
+	}
+
+	err = p.writeState(c, &s)
+	if err != nil {
+		return nil, fmt.Errorf("oidc.LoginHandler: %w", err)
+	}
+
+	url := provider.AuthCodeURL(p.redirectURL(c, provider), s.State, s.Nonce, codeChallengeS256(s.CodeVerifier))
+
+	return nil, c.Redirect(http.StatusFound, url)
+}
+
+// CallbackHandler - Validate the state, exchange the code, verify the id_token and start the user session
+func (p *Plugin) CallbackHandler(c echo.Context) (bolo.Response, error) {
+	l := bolo.GetLogger(c)
+	ctx := c.Request().Context()
+
+	provider, err := p.GetProvider(c.Param("provider"))
+	if err != nil {
+		return nil, &bolo.HTTPError{Code: http.StatusNotFound, Message: "Not found", Internal: err}
+	}
+
+	s, err := p.readState(c)
+	p.clearState(c)
+	if err != nil || s.Provider != provider.Name || !hmac.Equal([]byte(s.State), []byte(c.QueryParam("state"))) {
+		return nil, &bolo.HTTPError{Code: http.StatusBadRequest, Message: ErrInvalidState.Error(), Internal: err}
+	}
+
+	if e := c.QueryParam("error"); e != "" {
+		l.Info("oidc.CallbackHandler provider returned error", zap.String("provider", provider.Name), zap.String("error", e), zap.String("description", c.QueryParam("error_description")))
+		return nil, &bolo.HTTPError{Code: http.StatusUnauthorized, Message: "Unauthorized"}
+	}
+
+	err = provider.Discover(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("oidc.CallbackHandler: %w", err)
+	}
+
+	token, err := provider.Exchange(ctx, c.QueryParam("code"), p.redirectURL(c, provider), s.CodeVerifier)
+	if err != nil {
+		return nil, &bolo.HTTPError{Code: http.StatusUnauthorized, Message: "Unauthorized", Internal: err}
+	}
+
+	claims, err := p.getClaims(c, provider, token, s.Nonce)
+	if err != nil {
+		return nil, &bolo.HTTPError{Code: http.StatusUnauthorized, Message: "Unauthorized", Internal: err}
+	}
+
+	identity := NewIdentity(provider, claims)
+	if identity.Subject == "" {
+		return nil, &bolo.HTTPError{Code: http.StatusUnauthorized, Message: "Unauthorized", Internal: errors.New("oidc: identity without subject")}
+	}
+
+	user, err := p.ResolveUser(c, identity)
+	if err != nil {
+		if errors.Is(err, ErrUserNotLinked) {
+			return nil, &bolo.HTTPError{Code: http.StatusForbidden, Message: err.Error()}
+		}
+
+		return nil, fmt.Errorf("oidc.CallbackHandler: %w", err)
+	}
+
+	if ApplyRoleMapping(user, identity.Groups, provider.RoleMapping) {
+		if m, ok := user.(bolo.Model); ok {
+			err = m.Save(p.App)
+			if err != nil {
+				return nil, fmt.Errorf("oidc.CallbackHandler error on save user roles: %w", err)
+			}
+		}
+	}
+
+	err = p.Login(c, user)
+	if err != nil {
+		if errors.Is(err, users.ErrUserBlocked) || errors.Is(err, users.ErrUserInactive) {
+			return nil, &bolo.HTTPError{Code: http.StatusForbidden, Message: err.Error()}
+		}
+
+		return nil, fmt.Errorf("oidc.CallbackHandler: %w", err)
+	}
+
+	return nil, c.Redirect(http.StatusSeeOther, s.RedirectTo)
+}
+
+func (p *Plugin) getClaims(c echo.Context, provider *Provider, token *TokenResponse, nonce string) (map[string]interface{}, error) {
+	ctx := c.Request().Context()
+
+	if provider.DisableIDToken {
+		return provider.UserInfo(ctx, token.AccessToken)
+	}
+
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("%w: missing id_token", ErrInvalidIDToken)
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, token.IDToken, nonce, p.App.GetClock().Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// some providers only return groups in the userinfo endpoint
+	if provider.GroupsClaim != "" && claims[provider.GroupsClaim] == nil && provider.UserInfoEndpoint != "" && token.AccessToken != "" {
+		info, err := provider.UserInfo(ctx, token.AccessToken)
+		if err == nil && claimString(info, "sub") == claimString(claims, "sub") {
+			if groups, ok := info[provider.GroupsClaim]; ok {
+				claims[provider.GroupsClaim] = groups
+			}
+		}
+	}
+
+	return claims, nil
+}
+
+func (p *Plugin) redirectURL(c echo.Context, provider *Provider) string {
+	if provider.RedirectURL != "" {
+		return provider.RedirectURL
+	}
+
+	return bolo.GetBaseURL(c) + "/auth/oidc/" + provider.Name + "/callback"
+}
+
+func (p *Plugin) writeState(c echo.Context, s *state) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	value := base64.RawURLEncoding.EncodeToString(data)
+
+	c.SetCookie(&http.Cookie{
+		Name:     stateCookieName,
+		Value:    value + "." + p.sign(value),
+		Path:     "/auth/oidc",
+		Expires:  time.Unix(s.ExpiresAt, 0),
+		HttpOnly: true,
+		Secure:   c.Scheme() == "https",
+		// Lax is required to receive the cookie in the top level redirect from the provider
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return nil
+}
+
+func (p *Plugin) readState(c echo.Context) (*state, error) {
+	cookie, err := c.Cookie(stateCookieName)
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+
+	value, signature, found := strings.Cut(cookie.Value, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(p.sign(value))) {
+		return nil, ErrInvalidState
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+
+	s := state{}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, ErrInvalidState
+	}
+
+	if p.App.GetClock().Now().Unix() > s.ExpiresAt {
+		return nil, ErrInvalidState
+	}
+
+	return &s, nil
+}
+
+func (p *Plugin) clearState(c echo.Context) {
+	c.SetCookie(&http.Cookie{
+		Name:     stateCookieName,
+		Value:    "",
+		Path:     "/auth/oidc",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+}
+
+func (p *Plugin) sign(value string) string {
+	mac := hmac.New(sha256.New, p.StateSecret)
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func codeChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// safeRedirectTo - only allow relative redirects to avoid open redirects
+func safeRedirectTo(redirectTo string) string {
+	if redirectTo == "" || !strings.HasPrefix(redirectTo, "/") || strings.HasPrefix(redirectTo, "//") || strings.Contains(redirectTo, "\\") {
+		return "/"
+	}
+
+	return redirectTo
+}
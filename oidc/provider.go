@@ -0,0 +1,360 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/go-bolo/bolo"
+	"github.com/go-bolo/bolo/configuration"
+)
+
+var (
+	ErrProviderNotFound  = errors.New("oidc provider not found")
+	ErrDiscoveryRequired = errors.New("oidc provider without issuer or endpoints")
+)
+
+// maxResponseSize - Max body size of the discovery, jwks, token and userinfo responses
+const maxResponseSize = 1 << 20
+
+// Provider - One OpenID Connect / OAuth2 identity provider
+type Provider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// Callback URL registered in the provider, default: BASE_URL/auth/oidc/<name>/callback
+	RedirectURL string
+	Scopes      []string
+	// Claim with the user groups, used to map groups to ACL roles
+	GroupsClaim string
+	// Provider group -> ACL roles
+	RoleMapping map[string][]string
+	// Skip id_token validation and use the userinfo endpoint, for OAuth2 only providers
+	DisableIDToken bool
+
+	// Endpoints, loaded from the issuer discovery document if empty:
+	AuthorizationEndpoint string
+	TokenEndpoint         string
+	UserInfoEndpoint      string
+	JWKSURI               string
+
+	// HTTP client, if nil the bolo.HttpClient is used
+	HTTPClient bolo.CustomHTTPClient
+
+	mu         sync.Mutex
+	discovered bool
+	keys       map[string]*rsa.PublicKey
+}
+
+// DiscoveryDocument - The fields used from the /.well-known/openid-configuration document
+type DiscoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// TokenResponse - OAuth2 token endpoint response
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	IDToken      string `json:"id_token"`
+}
+
+// NewProvidersFromConfiguration - Load the providers listed in OIDC_PROVIDERS.
+// Each provider is configured with OIDC_<NAME>_* variables, like OIDC_GOOGLE_CLIENT_ID
+func NewProvidersFromConfiguration(cfg configuration.ConfigurationInterface) []*Provider {
+	providers := []*Provider{}
+
+	for _, name := range splitList(cfg.Get("OIDC_PROVIDERS")) {
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+
+		p := &Provider{
+			Name:                  name,
+			Issuer:                cfg.Get(prefix + "ISSUER"),
+			ClientID:              cfg.Get(prefix + "CLIENT_ID"),
+			ClientSecret:          cfg.Get(prefix + "CLIENT_SECRET"),
+			RedirectURL:           cfg.Get(prefix + "REDIRECT_URL"),
+			Scopes:                splitList(cfg.GetF(prefix+"SCOPES", "openid,email,profile")),
+			GroupsClaim:           cfg.GetF(prefix+"GROUPS_CLAIM", "groups"),
+			RoleMapping:           ParseRoleMapping(cfg.Get(prefix + "ROLE_MAP")),
+			DisableIDToken:        cfg.GetBool(prefix + "DISABLE_ID_TOKEN"),
+			AuthorizationEndpoint: cfg.Get(prefix + "AUTH_URL"),
+			TokenEndpoint:         cfg.Get(prefix + "TOKEN_URL"),
+			UserInfoEndpoint:      cfg.Get(prefix + "USERINFO_URL"),
+			JWKSURI:               cfg.Get(prefix + "JWKS_URL"),
+		}
+
+		providers = append(providers, p)
+	}
+
+	return providers
+}
+
+// ParseRoleMapping - Parse one mapping in the format: group1:role1|role2,group2:role3
+func ParseRoleMapping(v string) map[string][]string {
+	mapping := map[string][]string{}
+
+	for _, item := range splitList(v) {
+		group, roles, found := strings.Cut(item, ":")
+		if !found {
+			continue
+		}
+
+		for _, role := range strings.Split(roles, "|") {
+			role = strings.TrimSpace(role)
+			if role != "" {
+				mapping[strings.TrimSpace(group)] = append(mapping[strings.TrimSpace(group)], role)
+			}
+		}
+	}
+
+	return mapping
+}
+
+func (p *Provider) client() bolo.CustomHTTPClient {
+	if p.HTTPClient != nil {
+		return p.HTTPClient
+	}
+
+	if bolo.HttpClient != nil {
+		return bolo.HttpClient
+	}
+
+	return http.DefaultClient
+}
+
+// Discover - Load the provider endpoints from the issuer discovery document, only once
+func (p *Provider) Discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovered {
+		return nil
+	}
+
+	if p.AuthorizationEndpoint != "" && p.TokenEndpoint != "" && (p.JWKSURI != "" || p.DisableIDToken) {
+		p.discovered = true
+		return nil
+	}
+
+	if p.Issuer == "" {
+		return ErrDiscoveryRequired
+	}
+
+	doc := DiscoveryDocument{}
+	err := p.getJSON(ctx, strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", "", &doc)
+	if err != nil {
+		return fmt.Errorf("oidc.Discover %s: %w", p.Name, err)
+	}
+
+	if doc.Issuer != "" && doc.Issuer != p.Issuer {
+		return fmt.Errorf("oidc.Discover %s: issuer mismatch: %s", p.Name, doc.Issuer)
+	}
+
+	p.AuthorizationEndpoint = firstNonEmpty(p.AuthorizationEndpoint, doc.AuthorizationEndpoint)
+	p.TokenEndpoint = firstNonEmpty(p.TokenEndpoint, doc.TokenEndpoint)
+	p.UserInfoEndpoint = firstNonEmpty(p.UserInfoEndpoint, doc.UserInfoEndpoint)
+	p.JWKSURI = firstNonEmpty(p.JWKSURI, doc.JWKSURI)
+	p.discovered = true
+
+	return nil
+}
+
+// AuthCodeURL - Build the authorization request URL with state, nonce and the PKCE S256 challenge
+func (p *Provider) AuthCodeURL(redirectURL, state, nonce, codeChallenge string) string {
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", redirectURL)
+	q.Set("scope", strings.Join(p.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", codeChallenge)
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(p.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return p.AuthorizationEndpoint + sep + q.Encode()
+}
+
+// Exchange - Exchange the authorization code for tokens
+func (p *Provider) Exchange(ctx context.Context, code, redirectURL, codeVerifier string) (*TokenResponse, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("code_verifier", codeVerifier)
+	if p.ClientSecret != "" {
+		form.Set("client_secret", p.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	token := TokenResponse{}
+	err = p.doJSON(req, &token)
+	if err != nil {
+		return nil, fmt.Errorf("oidc.Exchange %s: %w", p.Name, err)
+	}
+
+	if token.AccessToken == "" && token.IDToken == "" {
+		return nil, fmt.Errorf("oidc.Exchange %s: empty token response", p.Name)
+	}
+
+	return &token, nil
+}
+
+// UserInfo - Load the claims from the userinfo endpoint
+func (p *Provider) UserInfo(ctx context.Context, accessToken string) (map[string]interface{}, error) {
+	if p.UserInfoEndpoint == "" {
+		return nil, fmt.Errorf("oidc.UserInfo %s: userinfo endpoint not configured", p.Name)
+	}
+
+	claims := map[string]interface{}{}
+	err := p.getJSON(ctx, p.UserInfoEndpoint, accessToken, &claims)
+	if err != nil {
+		return nil, fmt.Errorf("oidc.UserInfo %s: %w", p.Name, err)
+	}
+
+	return claims, nil
+}
+
+// getKey - Returns the JWKS key by kid, reloading the key set once for unknown kids (key rotation)
+func (p *Provider) getKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	p.mu.Unlock()
+
+	if ok {
+		return key, nil
+	}
+
+	keys, err := p.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+
+	// tokens without kid are valid if the provider has only one key
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("oidc: signing key not found: %s", kid)
+}
+
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+func (p *Provider) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	set := jwks{}
+	err := p.getJSON(ctx, p.JWKSURI, "", &set)
+	if err != nil {
+		return nil, fmt.Errorf("oidc.fetchKeys %s: %w", p.Name, err)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url, bearer string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	return p.doJSON(req, target)
+}
+
+func (p *Provider) doJSON(req *http.Request, target interface{}) error {
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return err
+	}
+	// the provider documents and tokens are small, larger bodies fail with one *http.MaxBytesError
+	resp.Body = http.MaxBytesReader(nil, resp.Body, maxResponseSize)
+
+	return bolo.DecodeJSON(resp, target)
+}
+
+func splitList(v string) []string {
+	items := []string{}
+	for _, item := range strings.Split(v, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
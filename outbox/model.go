@@ -0,0 +1,99 @@
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"gorm.io/gorm"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	// StatusDead - The async listeners failed in all attempts
+	StatusDead = "dead"
+)
+
+var (
+	ErrEventNotFound = errors.New("outbox event not found")
+)
+
+// Event - One domain event stored in the same transaction of the change that emitted it
+type Event struct {
+	ID          string     `gorm:"primary_key;column:id;type:varchar(36)" json:"id"`
+	Name        string     `gorm:"column:name;type:varchar(150);index" json:"name"`
+	Model       string     `gorm:"column:model;type:varchar(150)" json:"model"`
+	RecordID    string     `gorm:"column:recordId;type:varchar(100)" json:"recordId"`
+	Data        string     `gorm:"column:data;type:text" json:"data"`
+	RequestID   string     `gorm:"column:requestId;type:varchar(128)" json:"requestId"`
+	OccurredAt  time.Time  `gorm:"column:occurredAt" json:"occurredAt"`
+	Status      string     `gorm:"column:status;type:varchar(20);index:idx_outbox_events_relay,priority:1" json:"status"`
+	Attempts    int        `gorm:"column:attempts;default:0" json:"attempts"`
+	AvailableAt time.Time  `gorm:"column:availableAt;index:idx_outbox_events_relay,priority:2" json:"availableAt"`
+	LockedAt    *time.Time `gorm:"column:lockedAt" json:"lockedAt"`
+	LockedBy    string     `gorm:"column:lockedBy;type:varchar(100)" json:"lockedBy"`
+	LastError   string     `gorm:"column:lastError;type:text" json:"lastError"`
+	DeliveredAt *time.Time `gorm:"column:deliveredAt;index" json:"deliveredAt"`
+	CreatedAt   time.Time  `gorm:"column:createdAt" json:"createdAt"`
+}
+
+func (r *Event) TableName() string {
+	return "outbox_events"
+}
+
+func (r *Event) GetID() string {
+	return r.ID
+}
+
+func (r *Event) LoadData() error {
+	return nil
+}
+
+func (r *Event) LoadTeaserData() error {
+	return nil
+}
+
+func (r *Event) Save(app bolo.App) error {
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = app.GetClock().Now()
+	}
+
+	return app.GetDB().Save(r).Error
+}
+
+// ToDomainEvent - Returns the stored bolo.DomainEvent
+func (r *Event) ToDomainEvent() *bolo.DomainEvent {
+	e := bolo.DomainEvent{
+		ID:         r.ID,
+		Name:       r.Name,
+		Model:      r.Model,
+		RecordID:   r.RecordID,
+		RequestID:  r.RequestID,
+		OccurredAt: r.OccurredAt,
+	}
+
+	if r.Data != "" {
+		e.Data = json.RawMessage(r.Data)
+	}
+
+	return &e
+}
+
+// FindOne - Find one outbox event by id
+func FindOne(app bolo.App, id string) (*Event, error) {
+	record := Event{}
+
+	err := app.GetDB().First(&record, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEventNotFound
+		}
+
+		return nil, fmt.Errorf("outbox.FindOne: %w", err)
+	}
+
+	return &record, nil
+}
@@ -0,0 +1,316 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRelayStarted = errors.New("outbox relay already started")
+)
+
+// Outbox - bolo.DomainEventOutbox stored in the outbox_events table.
+// The relay delivers the stored events to the async listeners, at least once and with retries
+type Outbox struct {
+	App          bolo.App
+	PollInterval time.Duration
+	// Max events claimed in each poll
+	BatchSize   int
+	MaxAttempts int
+	// Events locked for more than LockTimeout are claimed again, like after one crash
+	LockTimeout time.Duration
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// Delivered events are deleted after the Retention in Cleanup
+	Retention time.Duration
+	WorkerID  string
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	notify  chan struct{}
+	running bool
+}
+
+// NewOutboxFromConfiguration - Build one outbox with the OUTBOX_* variables
+func NewOutboxFromConfiguration(app bolo.App) *Outbox {
+	cfg := app.GetConfiguration()
+
+	hostname, _ := os.Hostname()
+
+	o := Outbox{
+		App:          app,
+		PollInterval: time.Duration(cfg.GetInt64F("OUTBOX_POLL_INTERVAL", 1000)) * time.Millisecond,
+		BatchSize:    cfg.GetIntF("OUTBOX_BATCH_SIZE", 100),
+		MaxAttempts:  cfg.GetIntF("OUTBOX_MAX_ATTEMPTS", 10),
+		LockTimeout:  time.Duration(cfg.GetInt64F("OUTBOX_LOCK_TIMEOUT", 300)) * time.Second,
+		BackoffBase:  time.Duration(cfg.GetInt64F("OUTBOX_BACKOFF_BASE", 5)) * time.Second,
+		BackoffMax:   time.Duration(cfg.GetInt64F("OUTBOX_BACKOFF_MAX", 3600)) * time.Second,
+		Retention:    time.Duration(cfg.GetInt64F("OUTBOX_RETENTION", 168)) * time.Hour,
+		WorkerID:     hostname + ":" + uuid.New().String()[:8],
+	}
+
+	return &o
+}
+
+// Add - Store one event with the tx
+func (o *Outbox) Add(tx *gorm.DB, e *bolo.DomainEvent) error {
+	now := o.App.GetClock().Now()
+
+	record := Event{
+		ID:          e.ID,
+		Name:        e.Name,
+		Model:       e.Model,
+		RecordID:    e.RecordID,
+		Data:        string(e.Data),
+		RequestID:   e.RequestID,
+		OccurredAt:  e.OccurredAt,
+		Status:      StatusPending,
+		AvailableAt: now,
+		CreatedAt:   now,
+	}
+
+	if err := tx.Create(&record).Error; err != nil {
+		return fmt.Errorf("outbox.Add: %w", err)
+	}
+
+	return nil
+}
+
+// Start - Start the relay loop
+func (o *Outbox) Start() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.running {
+		return ErrRelayStarted
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	o.cancel = cancel
+	o.running = true
+	o.notify = make(chan struct{}, 1)
+
+	o.wg.Add(1)
+	go o.relay(ctx, o.notify)
+
+	o.App.GetLogger().Info("outbox: relay started", zap.String("workerID", o.WorkerID))
+
+	return nil
+}
+
+// Stop - Stop the relay and wait the current batch until the ctx deadline
+func (o *Outbox) Stop(ctx context.Context) error {
+	o.mu.Lock()
+	if !o.running {
+		o.mu.Unlock()
+		return nil
+	}
+
+	o.cancel()
+	o.running = false
+	o.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		o.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("outbox.Stop: %w", ctx.Err())
+	}
+}
+
+// Notify - Wake up the relay to deliver the committed events
+func (o *Outbox) Notify() {
+	o.mu.Lock()
+	notify := o.notify
+	o.mu.Unlock()
+
+	if notify == nil {
+		return
+	}
+
+	select {
+	case notify <- struct{}{}:
+	default:
+	}
+}
+
+func (o *Outbox) relay(ctx context.Context, notify chan struct{}) {
+	defer o.wg.Done()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-notify:
+		case <-timer.C:
+		}
+
+		// deliver all the available events before the next poll
+		for ctx.Err() == nil {
+			n, err := o.RelayNext(ctx)
+			if err != nil {
+				o.App.GetLogger().Error("outbox: error on relay events", zap.Error(err))
+				break
+			}
+
+			if n == 0 {
+				break
+			}
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(o.PollInterval)
+	}
+}
+
+// RelayNext - Claim and deliver the next batch of available events, returns the number of claimed events
+func (o *Outbox) RelayNext(ctx context.Context) (int, error) {
+	events, err := o.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	bus := o.App.GetDomainEvents()
+
+	for _, record := range events {
+		e := record.ToDomainEvent()
+		deliveryCtx := bolo.ContextWithRequestID(ctx, e.RequestID)
+
+		err := bus.Dispatch(deliveryCtx, e)
+		if err != nil {
+			o.App.GetLogger().Warn("outbox: event delivery failed", zap.Error(err), zap.String("event", e.Name), zap.String("eventID", e.ID), zap.Int("attempt", record.Attempts))
+		}
+
+		if err := o.finish(record, err); err != nil {
+			return len(events), err
+		}
+	}
+
+	return len(events), nil
+}
+
+// claim - Lock the next batch with one conditional update by event, the attempts column works as the row version
+func (o *Outbox) claim(ctx context.Context) ([]*Event, error) {
+	db := o.App.GetDB().WithContext(ctx)
+	now := o.App.GetClock().Now()
+
+	candidates := []*Event{}
+	err := db.
+		Where("(status = ? AND availableAt <= ? AND lockedBy = ?) OR (status = ? AND lockedAt <= ?)", StatusPending, now, "", StatusPending, now.Add(-o.LockTimeout)).
+		Order("occurredAt ASC, createdAt ASC").
+		Limit(o.BatchSize).
+		Find(&candidates).Error
+	if err != nil {
+		return nil, fmt.Errorf("outbox.claim error on find events: %w", err)
+	}
+
+	claimed := []*Event{}
+	for _, record := range candidates {
+		res := db.Model(&Event{}).
+			Where("id = ? AND status = ? AND attempts = ?", record.ID, StatusPending, record.Attempts).
+			Updates(map[string]interface{}{
+				"attempts": record.Attempts + 1,
+				"lockedAt": now,
+				"lockedBy": o.WorkerID,
+			})
+		if res.Error != nil {
+			return nil, fmt.Errorf("outbox.claim error on lock event: %w", res.Error)
+		}
+
+		// claimed by other relay
+		if res.RowsAffected == 0 {
+			continue
+		}
+
+		record.Attempts++
+		record.LockedAt = &now
+		record.LockedBy = o.WorkerID
+		claimed = append(claimed, record)
+	}
+
+	return claimed, nil
+}
+
+// finish - Save the delivery result, failed events are delivered again with backoff or moved to the dead state
+func (o *Outbox) finish(record *Event, deliveryErr error) error {
+	now := o.App.GetClock().Now()
+
+	values := map[string]interface{}{
+		"lockedAt": nil,
+		"lockedBy": "",
+	}
+
+	switch {
+	case deliveryErr == nil:
+		values["status"] = StatusDelivered
+		values["lastError"] = ""
+		values["deliveredAt"] = now
+	case record.Attempts >= o.MaxAttempts:
+		values["status"] = StatusDead
+		values["lastError"] = deliveryErr.Error()
+	default:
+		values["lastError"] = deliveryErr.Error()
+		values["availableAt"] = now.Add(o.Backoff(record.Attempts))
+	}
+
+	err := o.App.GetDB().Model(&Event{}).
+		Where("id = ? AND lockedBy = ?", record.ID, o.WorkerID).
+		Updates(values).Error
+	if err != nil {
+		return fmt.Errorf("outbox.finish error on update event: %w", err)
+	}
+
+	return nil
+}
+
+// Backoff - Exponential delay before the next delivery: BackoffBase * 2^(attempt-1), limited to BackoffMax
+func (o *Outbox) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	d := float64(o.BackoffBase) * math.Pow(2, float64(attempt-1))
+	if d > float64(o.BackoffMax) {
+		return o.BackoffMax
+	}
+
+	return time.Duration(d)
+}
+
+// Cleanup - Delete the events delivered before the Retention
+func (o *Outbox) Cleanup(ctx context.Context) error {
+	before := o.App.GetClock().Now().Add(-o.Retention)
+
+	err := o.App.GetDB().WithContext(ctx).
+		Delete(&Event{}, "status = ? AND deliveredAt <= ?", StatusDelivered, before).Error
+	if err != nil {
+		return fmt.Errorf("outbox.Cleanup: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/go-bolo/bolo/apikeys"
+	"github.com/go-bolo/bolo/outbox"
+	"github.com/go-bolo/bolo/users"
+	"github.com/go-bolo/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newTestApp(t *testing.T) (bolo.App, *outbox.Plugin, *clock.Mock) {
+	os.Setenv("TEMPLATE_DISABLE", "true")
+	os.Setenv("OUTBOX_RELAY_DISABLE", "true")
+	os.Setenv("DB_URI", filepath.Join(t.TempDir(), "outbox.sqlite"))
+	t.Cleanup(func() {
+		os.Unsetenv("TEMPLATE_DISABLE")
+		os.Unsetenv("OUTBOX_RELAY_DISABLE")
+		os.Unsetenv("DB_URI")
+	})
+
+	app := bolo.NewApp(&bolo.DefaultAppOptions{})
+
+	c := clock.NewMock()
+	now, _ := time.Parse("2006-01-02", "2023-07-16")
+	c.Set(now)
+	app.SetClock(c)
+
+	p := outbox.NewPlugin(&outbox.PluginOpts{})
+	app.AddPlugin(users.NewPlugin(&users.PluginOpts{PasswordHasher: &users.BcryptHasher{Cost: 4}}))
+	app.AddPlugin(apikeys.NewPlugin(&apikeys.PluginOpts{}))
+	app.AddPlugin(p)
+
+	require.Nil(t, app.Bootstrap())
+	require.Nil(t, app.SyncDB())
+
+	return app, p, c
+}
+
+func newKey(name string) *apikeys.APIKey {
+	return &apikeys.APIKey{UserID: "1", Name: name, Permissions: []string{"find_content"}}
+}
+
+func countEvents(t *testing.T, app bolo.App, status string) int64 {
+	var count int64
+	require.Nil(t, app.GetDB().Model(&outbox.Event{}).Where("status = ?", status).Count(&count).Error)
+	return count
+}
+
+func TestOutbox(t *testing.T) {
+	app, p, clk := newTestApp(t)
+	o := p.Outbox
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	sync_, async := []string{}, []string{}
+	var asyncErr error
+
+	bus := app.GetDomainEvents()
+	bus.Subscribe(bolo.EventModelCreated, func(ctx context.Context, app bolo.App, e *bolo.DomainEvent) error {
+		sync_ = append(sync_, e.Model+":"+e.RecordID)
+		return nil
+	})
+	bus.SubscribeAsync("*", func(ctx context.Context, app bolo.App, e *bolo.DomainEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if asyncErr != nil {
+			return asyncErr
+		}
+		async = append(async, e.Name+":"+e.RecordID+":"+bolo.RequestIDFromContext(ctx))
+		return nil
+	})
+
+	assert.Contains(t, app.GetScheduledTasks(), "outbox_cleanup")
+
+	t.Run("should store model events in the change transaction", func(t *testing.T) {
+		key := newKey("sync")
+		require.Nil(t, app.GetDB().WithContext(bolo.ContextWithRequestID(ctx, "rid-1")).Create(key).Error)
+
+		assert.Equal(t, []string{"api_key:" + key.GetID()}, sync_)
+		assert.Empty(t, async)
+		assert.Equal(t, int64(1), countEvents(t, app, outbox.StatusPending))
+
+		n, err := o.RelayNext(ctx)
+		require.Nil(t, err)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, []string{"model.created:" + key.GetID() + ":rid-1"}, async)
+		assert.Equal(t, int64(1), countEvents(t, app, outbox.StatusDelivered))
+
+		n, _ = o.RelayNext(ctx)
+		assert.Equal(t, 0, n)
+	})
+
+	t.Run("should not store events of rolled back transactions", func(t *testing.T) {
+		sync_ = nil
+
+		err := app.GetDB().Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(newKey("rollback")).Error; err != nil {
+				return err
+			}
+			return errors.New("rollback")
+		})
+		assert.NotNil(t, err)
+
+		assert.Equal(t, int64(0), countEvents(t, app, outbox.StatusPending))
+	})
+
+	t.Run("should retry failed deliveries with backoff", func(t *testing.T) {
+		async = nil
+		asyncErr = errors.New("search index unavailable")
+		o.MaxAttempts = 2
+
+		e, _ := bolo.NewDomainEvent(app, "search.reindex", nil)
+		require.Nil(t, bus.Publish(ctx, e))
+
+		n, _ := o.RelayNext(ctx)
+		assert.Equal(t, 1, n)
+
+		record, err := outbox.FindOne(app, e.ID)
+		require.Nil(t, err)
+		assert.Equal(t, outbox.StatusPending, record.Status)
+		assert.Equal(t, 1, record.Attempts)
+		assert.Contains(t, record.LastError, "search index unavailable")
+		assert.WithinDuration(t, clk.Now().Add(o.BackoffBase), record.AvailableAt, 0)
+
+		n, _ = o.RelayNext(ctx)
+		assert.Equal(t, 0, n)
+
+		clk.Add(o.BackoffBase)
+		n, _ = o.RelayNext(ctx)
+		assert.Equal(t, 1, n)
+
+		record, _ = outbox.FindOne(app, e.ID)
+		assert.Equal(t, outbox.StatusDead, record.Status)
+		assert.Empty(t, async)
+
+		asyncErr = nil
+		e, _ = bolo.NewDomainEvent(app, "search.reindex", nil)
+		require.Nil(t, bus.Publish(ctx, e))
+		o.RelayNext(ctx)
+		assert.Len(t, async, 1)
+	})
+
+	t.Run("should delete old delivered events", func(t *testing.T) {
+		require.Equal(t, int64(2), countEvents(t, app, outbox.StatusDelivered))
+
+		require.Nil(t, o.Cleanup(ctx))
+		assert.Equal(t, int64(2), countEvents(t, app, outbox.StatusDelivered))
+
+		clk.Add(o.Retention)
+		require.Nil(t, o.Cleanup(ctx))
+		assert.Equal(t, int64(0), countEvents(t, app, outbox.StatusDelivered))
+		assert.Equal(t, int64(1), countEvents(t, app, outbox.StatusDead))
+	})
+
+	t.Run("should roll back the change if the outbox fails", func(t *testing.T) {
+		require.Nil(t, app.GetDB().Migrator().DropTable(&outbox.Event{}))
+		defer app.GetDB().AutoMigrate(&outbox.Event{})
+
+		assert.NotNil(t, app.GetDB().Create(newKey("without-outbox")).Error)
+
+		var count int64
+		app.GetDB().Model(&apikeys.APIKey{}).Where("name = ?", "without-outbox").Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+}
+
+func TestOutboxRelay(t *testing.T) {
+	app, p, _ := newTestApp(t)
+
+	delivered := make(chan string, 1)
+	app.GetDomainEvents().SubscribeAsync(bolo.EventModelCreated, func(ctx context.Context, app bolo.App, e *bolo.DomainEvent) error {
+		delivered <- e.Model
+		return nil
+	})
+
+	p.Outbox.PollInterval = time.Hour
+	require.Nil(t, p.Outbox.Start())
+	assert.ErrorIs(t, p.Outbox.Start(), outbox.ErrRelayStarted)
+
+	// the relay is notified after the commit, without wait the poll interval:
+	require.Nil(t, app.GetDB().Create(newKey("relay")).Error)
+
+	select {
+	case model := <-delivered:
+		assert.Equal(t, "api_key", model)
+	case <-time.After(2 * time.Second):
+		t.Fatal("event not delivered")
+	}
+
+	require.Nil(t, app.Close())
+}
@@ -0,0 +1,82 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/gookit/event"
+)
+
+// Plugin - Store the domain events in the outbox_events table, in the same transaction of the changes,
+// and deliver them to the async listeners
+type Plugin struct {
+	bolo.Plugin
+
+	Name   string
+	App    bolo.App
+	Outbox *Outbox
+	// Max wait for the relay in Close
+	StopTimeout time.Duration
+}
+
+type PluginOpts struct {
+	Outbox *Outbox
+}
+
+func NewPlugin(opts *PluginOpts) *Plugin {
+	return &Plugin{
+		Name:   "outbox",
+		Outbox: opts.Outbox,
+	}
+}
+
+func (p *Plugin) GetName() string {
+	return p.Name
+}
+
+func (p *Plugin) Init(app bolo.App) error {
+	app.GetLogger().Debug(p.GetName() + " Init")
+
+	p.App = app
+	cfg := app.GetConfiguration()
+
+	if p.Outbox == nil {
+		p.Outbox = NewOutboxFromConfiguration(app)
+	}
+
+	if p.StopTimeout == 0 {
+		p.StopTimeout = time.Duration(cfg.GetInt64F("OUTBOX_STOP_TIMEOUT", 30)) * time.Second
+	}
+
+	app.SetModel("outbox_event", &Event{})
+	app.GetDomainEvents().SetOutbox(p.Outbox)
+
+	err := app.SetScheduledTask(&bolo.ScheduledTask{
+		Name:     "outbox_cleanup",
+		Schedule: cfg.GetF("OUTBOX_CLEANUP_SCHEDULE", "@hourly"),
+		Handler: func(ctx context.Context, app bolo.App) error {
+			return p.Outbox.Cleanup(ctx)
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	app.GetEvents().On("bootstrap", bolo.TracedListener(app, "outbox.start", event.ListenerFunc(func(e event.Event) error {
+		if cfg.GetBool("OUTBOX_RELAY_DISABLE") {
+			return nil
+		}
+
+		return p.Outbox.Start()
+	})), event.Normal)
+
+	app.GetEvents().On("close", bolo.TracedListener(app, "outbox.stop", event.ListenerFunc(func(e event.Event) error {
+		ctx, cancel := context.WithTimeout(context.Background(), p.StopTimeout)
+		defer cancel()
+
+		return p.Outbox.Stop(ctx)
+	})), event.High)
+
+	return nil
+}
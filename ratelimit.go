@@ -0,0 +1,178 @@
+package bolo
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-bolo/bolo/ratelimit"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	RateLimitByIP       = "ip"
+	RateLimitByUser     = "user"
+	RateLimitByIdentity = "identity"
+)
+
+// RateLimit - One limit and how requests are grouped: by ip, user or identity (API key, then user, then IP)
+type RateLimit struct {
+	ratelimit.Limit
+	By string
+}
+
+// RateLimiter - App rate limit configuration and shared store
+type RateLimiter struct {
+	Store ratelimit.Store
+	// Limit for all requests, nil to disable
+	Global *RateLimit
+	// Paths without the global limit, like /health
+	SkipPaths []string
+}
+
+// NewRateLimiterFromConfiguration - Build the app rate limiter from the RATE_LIMIT* configurations
+func NewRateLimiterFromConfiguration(app App) (*RateLimiter, error) {
+	cfg := app.GetConfiguration()
+	rl := RateLimiter{
+		SkipPaths: []string{"/health", "/public"},
+	}
+
+	switch cfg.GetF(RATE_LIMIT_STORE, "memory") {
+	case "memory":
+		rl.Store = ratelimit.NewMemoryStore()
+	case "sql":
+		db := app.GetDB()
+		if db == nil {
+			return nil, fmt.Errorf("NewRateLimiterFromConfiguration: the sql store requires one database")
+		}
+
+		store := ratelimit.NewSQLStore(db)
+		if err := store.Migrate(); err != nil {
+			return nil, fmt.Errorf("NewRateLimiterFromConfiguration: %w", err)
+		}
+		rl.Store = store
+	default:
+		return nil, fmt.Errorf("NewRateLimiterFromConfiguration: invalid store: %s", cfg.Get(RATE_LIMIT_STORE))
+	}
+
+	if v := cfg.Get(RATE_LIMIT); v != "" {
+		limit, err := ratelimit.ParseLimit(v, cfg.GetF(RATE_LIMIT_ALGORITHM, ratelimit.AlgorithmTokenBucket))
+		if err != nil {
+			return nil, fmt.Errorf("NewRateLimiterFromConfiguration: %w", err)
+		}
+
+		rl.Global = &RateLimit{Limit: *limit, By: cfg.GetF(RATE_LIMIT_BY, RateLimitByIdentity)}
+	}
+
+	return &rl, nil
+}
+
+// InitRateLimiter - Create the app rate limiter if not set
+func InitRateLimiter(app App) error {
+	if app.GetRateLimiter() != nil {
+		return nil
+	}
+
+	rl, err := NewRateLimiterFromConfiguration(app)
+	if err != nil {
+		return err
+	}
+
+	return app.SetRateLimiter(rl)
+}
+
+// RateLimitMiddleware - Apply the app global limit
+func RateLimitMiddleware(app App) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			rl := app.GetRateLimiter()
+			if rl == nil || rl.Global == nil || rateLimitSkipPath(rl, c.Request().URL.Path) {
+				return next(c)
+			}
+
+			err := CheckRateLimit(c, "global", rl.Global)
+			if err != nil {
+				return err
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// CheckRateLimit - Consume one request from the limit and set the RateLimit-* headers.
+// Returns one 429 HTTPError if the limit is exceeded
+func CheckRateLimit(c echo.Context, name string, limit *RateLimit) error {
+	app := GetApp(c)
+	rl := app.GetRateLimiter()
+	if rl == nil || rl.Store == nil {
+		return nil
+	}
+
+	key := name + ":" + RateLimitIdentity(c, limit.By)
+
+	result, err := rl.Store.Take(c.Request().Context(), key, limit.Limit, app.GetClock().Now())
+	if err != nil {
+		// fail open, one store error should not stop the site
+		GetLogger(c).Warn("CheckRateLimit error on check limit", zap.Error(err), zap.String("key", key))
+		return nil
+	}
+
+	h := c.Response().Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	h.Set("RateLimit-Reset", durationSeconds(result.ResetAfter))
+	h.Set("RateLimit-Policy", limit.Policy())
+
+	if !result.Allowed {
+		h.Set("Retry-After", durationSeconds(result.RetryAfter))
+		return &HTTPError{Code: http.StatusTooManyRequests, Message: http.StatusText(http.StatusTooManyRequests)}
+	}
+
+	return nil
+}
+
+// RateLimitIdentity - Returns the rate limit key of the request client
+func RateLimitIdentity(c echo.Context, by string) string {
+	switch by {
+	case RateLimitByIP:
+		return "ip:" + c.RealIP()
+	case RateLimitByUser:
+		if user := GetAuthenticatedUser(c); user != nil {
+			return "user:" + user.GetID()
+		}
+		return "ip:" + c.RealIP()
+	}
+
+	if identity, ok := c.Get("rate_limit_identity").(string); ok && identity != "" {
+		return identity
+	}
+
+	if user := GetAuthenticatedUser(c); user != nil {
+		return "user:" + user.GetID()
+	}
+
+	return "ip:" + c.RealIP()
+}
+
+// SetRateLimitIdentity - Used by authentication plugins to group the requests by credential, like API keys
+func SetRateLimitIdentity(c echo.Context, identity string) {
+	c.Set("rate_limit_identity", identity)
+}
+
+func rateLimitSkipPath(rl *RateLimiter, path string) bool {
+	for _, p := range rl.SkipPaths {
+		if path == p || (len(path) > len(p) && path[:len(p)] == p && path[len(p)] == '/') {
+			return true
+		}
+	}
+
+	return false
+}
+
+func durationSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
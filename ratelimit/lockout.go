@@ -0,0 +1,38 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Lockout - Brute force protection, block one key after MaxFailures in the Duration, like failed logins
+type Lockout struct {
+	Store       Store
+	MaxFailures int
+	Duration    time.Duration
+}
+
+func (l *Lockout) limit() Limit {
+	return Limit{Requests: l.MaxFailures, Window: l.Duration, Algorithm: AlgorithmSlidingWindow}
+}
+
+// Check - Returns the time to wait if the key is locked
+func (l *Lockout) Check(ctx context.Context, key string, now time.Time) (locked bool, retryAfter time.Duration, err error) {
+	result, err := l.Store.Peek(ctx, "lockout:"+key, l.limit(), now)
+	if err != nil {
+		return false, 0, err
+	}
+
+	return !result.Allowed, result.RetryAfter, nil
+}
+
+// Fail - Record one failure
+func (l *Lockout) Fail(ctx context.Context, key string, now time.Time) error {
+	_, err := l.Store.Take(ctx, "lockout:"+key, l.limit(), now)
+	return err
+}
+
+// Reset - Clear the failures, like after one successful login
+func (l *Lockout) Reset(ctx context.Context, key string) error {
+	return l.Store.Reset(ctx, "lockout:"+key)
+}
@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore - In process store, limits are not shared between instances
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	// run the expired entries cleanup after this number of operations
+	CleanupEvery int
+	operations   int
+}
+
+type memoryEntry struct {
+	State     State
+	ExpiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries:      make(map[string]*memoryEntry),
+		CleanupEvery: 1000,
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (*Result, error) {
+	return s.apply(key, limit, now, true), nil
+}
+
+func (s *MemoryStore) Peek(ctx context.Context, key string, limit Limit, now time.Time) (*Result, error) {
+	return s.apply(key, limit, now, false), nil
+}
+
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *MemoryStore) apply(key string, limit Limit, now time.Time, consume bool) *Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.operations++
+	if s.CleanupEvery > 0 && s.operations%s.CleanupEvery == 0 {
+		s.cleanup(now)
+	}
+
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &memoryEntry{}
+	}
+
+	result := Apply(&entry.State, limit, now, consume)
+
+	if consume {
+		// after two windows the state is equal to one new state
+		entry.ExpiresAt = now.Add(2 * limit.Window)
+		s.entries[key] = entry
+	}
+
+	return result
+}
+
+func (s *MemoryStore) cleanup(now time.Time) {
+	for key, entry := range s.entries {
+		if now.After(entry.ExpiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Package ratelimit implements token bucket and sliding window rate limits with pluggable stores
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AlgorithmTokenBucket   = "token_bucket"
+	AlgorithmSlidingWindow = "sliding_window"
+)
+
+var (
+	ErrInvalidLimit = errors.New("invalid rate limit")
+)
+
+// Limit - Allow Requests per Window.
+// With the token bucket algorithm Burst is the bucket size, default Requests
+type Limit struct {
+	Requests  int
+	Window    time.Duration
+	Burst     int
+	Algorithm string
+}
+
+// Policy - Value for the RateLimit-Policy header, like: 100;w=60
+func (l Limit) Policy() string {
+	return strconv.Itoa(l.Requests) + ";w=" + strconv.FormatInt(int64(math.Ceil(l.Window.Seconds())), 10)
+}
+
+func (l Limit) capacity() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+
+	return float64(l.Requests)
+}
+
+func (l Limit) Validate() error {
+	if l.Requests <= 0 || l.Window <= 0 {
+		return ErrInvalidLimit
+	}
+
+	switch l.Algorithm {
+	case "", AlgorithmTokenBucket, AlgorithmSlidingWindow:
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown algorithm: %s", ErrInvalidLimit, l.Algorithm)
+	}
+}
+
+// ParseLimit - Parse limits like 100/1m, 10/s or 1000/hour
+func ParseLimit(v, algorithm string) (*Limit, error) {
+	requests, window, found := strings.Cut(strings.TrimSpace(v), "/")
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidLimit, v)
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidLimit, v)
+	}
+
+	var d time.Duration
+	switch strings.TrimSpace(window) {
+	case "s", "second":
+		d = time.Second
+	case "m", "minute":
+		d = time.Minute
+	case "h", "hour":
+		d = time.Hour
+	case "d", "day":
+		d = 24 * time.Hour
+	default:
+		d, err = time.ParseDuration(strings.TrimSpace(window))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidLimit, v)
+		}
+	}
+
+	l := Limit{Requests: n, Window: d, Algorithm: algorithm}
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &l, nil
+}
+
+// Result - Rate limit check result, used to build the RateLimit-* headers
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Time until the limit is fully reset
+	ResetAfter time.Duration
+	// Time to wait before the next allowed request, only set if not Allowed
+	RetryAfter time.Duration
+}
+
+// Store - Persist the rate limit state by key
+type Store interface {
+	// Take - Check and consume one request
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (*Result, error)
+	// Peek - Check without consume
+	Peek(ctx context.Context, key string, limit Limit, now time.Time) (*Result, error)
+	// Reset - Remove the key state
+	Reset(ctx context.Context, key string) error
+}
+
+// State - Rate limit state for one key, shared by all algorithms
+type State struct {
+	Tokens      float64
+	Count       int64
+	PrevCount   int64
+	WindowStart time.Time
+	UpdatedAt   time.Time
+}
+
+// Apply - Run the limit algorithm over the state. The state is only changed if consume is true
+func Apply(state *State, limit Limit, now time.Time, consume bool) *Result {
+	if limit.Algorithm == AlgorithmSlidingWindow {
+		return applySlidingWindow(state, limit, now, consume)
+	}
+
+	return applyTokenBucket(state, limit, now, consume)
+}
+
+func applyTokenBucket(state *State, limit Limit, now time.Time, consume bool) *Result {
+	capacity := limit.capacity()
+	rate := float64(limit.Requests) / limit.Window.Seconds()
+
+	tokens := capacity
+	if !state.UpdatedAt.IsZero() {
+		elapsed := now.Sub(state.UpdatedAt).Seconds()
+		if elapsed < 0 {
+			elapsed = 0
+		}
+		tokens = math.Min(capacity, state.Tokens+elapsed*rate)
+	}
+
+	result := &Result{Limit: int(capacity)}
+
+	if tokens >= 1 {
+		result.Allowed = true
+		if consume {
+			tokens--
+		}
+	} else {
+		result.RetryAfter = secondsToDuration((1 - tokens) / rate)
+	}
+
+	result.Remaining = int(math.Floor(tokens))
+	result.ResetAfter = secondsToDuration((capacity - tokens) / rate)
+
+	if consume {
+		state.Tokens = tokens
+		state.UpdatedAt = now
+	}
+
+	return result
+}
+
+func applySlidingWindow(state *State, limit Limit, now time.Time, consume bool) *Result {
+	windowStart := now.Truncate(limit.Window)
+
+	count, prevCount := state.Count, state.PrevCount
+	if !state.WindowStart.Equal(windowStart) {
+		if state.WindowStart.Equal(windowStart.Add(-limit.Window)) {
+			prevCount = count
+		} else {
+			prevCount = 0
+		}
+		count = 0
+	}
+
+	elapsed := float64(now.Sub(windowStart)) / float64(limit.Window)
+	weight := 1 - elapsed
+	estimated := float64(prevCount)*weight + float64(count)
+	windowEnd := windowStart.Add(limit.Window).Sub(now)
+
+	result := &Result{Limit: limit.Requests, ResetAfter: windowEnd}
+
+	if estimated+1 <= float64(limit.Requests) {
+		result.Allowed = true
+		if consume {
+			count++
+			estimated++
+		}
+	} else {
+		result.RetryAfter = slidingRetryAfter(limit, count, prevCount, elapsed, windowEnd)
+	}
+
+	result.Remaining = int(math.Max(0, math.Floor(float64(limit.Requests)-estimated)))
+
+	if consume {
+		state.Count = count
+		state.PrevCount = prevCount
+		state.WindowStart = windowStart
+		state.UpdatedAt = now
+	}
+
+	return result
+}
+
+// slidingRetryAfter - Time until the previous window weight is low enough for one more request
+func slidingRetryAfter(limit Limit, count, prevCount int64, elapsed float64, windowEnd time.Duration) time.Duration {
+	free := float64(limit.Requests) - float64(count) - 1
+	if free < 0 || prevCount == 0 {
+		return windowEnd
+	}
+
+	target := 1 - free/float64(prevCount)
+	if target <= elapsed {
+		return time.Second
+	}
+
+	return time.Duration((target - elapsed) * float64(limit.Window))
+}
+
+func secondsToDuration(s float64) time.Duration {
+	if s <= 0 {
+		return 0
+	}
+
+	return time.Duration(s * float64(time.Second))
+}
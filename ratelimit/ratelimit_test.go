@@ -0,0 +1,136 @@
+package ratelimit_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-bolo/bolo/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var start = time.Date(2023, time.July, 16, 10, 0, 0, 0, time.UTC)
+
+func stores(t *testing.T) map[string]ratelimit.Store {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "ratelimit.sqlite")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.Nil(t, err)
+
+	sqlStore := ratelimit.NewSQLStore(db)
+	require.Nil(t, sqlStore.Migrate())
+
+	return map[string]ratelimit.Store{
+		"memory": ratelimit.NewMemoryStore(),
+		"sql":    sqlStore,
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			limit := ratelimit.Limit{Requests: 2, Window: 10 * time.Second, Algorithm: ratelimit.AlgorithmTokenBucket}
+
+			r, err := store.Take(ctx, "k", limit, start)
+			require.Nil(t, err)
+			assert.True(t, r.Allowed)
+			assert.Equal(t, 1, r.Remaining)
+
+			r, _ = store.Take(ctx, "k", limit, start)
+			assert.True(t, r.Allowed)
+			assert.Equal(t, 0, r.Remaining)
+
+			r, _ = store.Take(ctx, "k", limit, start.Add(time.Second))
+			assert.False(t, r.Allowed)
+			assert.Equal(t, 4*time.Second, r.RetryAfter)
+
+			// one token each 5 seconds:
+			r, _ = store.Take(ctx, "k", limit, start.Add(5*time.Second))
+			assert.True(t, r.Allowed)
+
+			// other keys are not affected:
+			r, _ = store.Take(ctx, "other", limit, start.Add(5*time.Second))
+			assert.True(t, r.Allowed)
+
+			require.Nil(t, store.Reset(ctx, "k"))
+			r, _ = store.Peek(ctx, "k", limit, start.Add(5*time.Second))
+			assert.Equal(t, 2, r.Remaining)
+		})
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			limit := ratelimit.Limit{Requests: 3, Window: time.Minute, Algorithm: ratelimit.AlgorithmSlidingWindow}
+
+			for i := 0; i < 3; i++ {
+				r, err := store.Take(ctx, "k", limit, start.Add(time.Duration(i)*time.Second))
+				require.Nil(t, err)
+				assert.True(t, r.Allowed)
+				assert.Equal(t, 2-i, r.Remaining)
+			}
+
+			r, _ := store.Take(ctx, "k", limit, start.Add(30*time.Second))
+			assert.False(t, r.Allowed)
+			assert.Equal(t, 30*time.Second, r.RetryAfter)
+
+			// 30s in the next window the previous window weight is 50%: 1.5 requests
+			r, _ = store.Take(ctx, "k", limit, start.Add(90*time.Second))
+			assert.True(t, r.Allowed)
+			assert.Equal(t, 0, r.Remaining)
+
+			r, _ = store.Take(ctx, "k", limit, start.Add(91*time.Second))
+			assert.False(t, r.Allowed)
+
+			// after two windows everything is released:
+			r, _ = store.Take(ctx, "k", limit, start.Add(3*time.Minute))
+			assert.True(t, r.Allowed)
+			assert.Equal(t, 2, r.Remaining)
+		})
+	}
+}
+
+func TestLockout(t *testing.T) {
+	ctx := context.Background()
+	lockout := ratelimit.Lockout{Store: ratelimit.NewMemoryStore(), MaxFailures: 2, Duration: time.Minute}
+
+	locked, _, err := lockout.Check(ctx, "alice", start)
+	require.Nil(t, err)
+	assert.False(t, locked)
+
+	lockout.Fail(ctx, "alice", start)
+	lockout.Fail(ctx, "alice", start.Add(time.Second))
+
+	locked, retryAfter, _ := lockout.Check(ctx, "alice", start.Add(2*time.Second))
+	assert.True(t, locked)
+	assert.True(t, retryAfter > 0)
+
+	lockout.Reset(ctx, "alice")
+	locked, _, _ = lockout.Check(ctx, "alice", start.Add(2*time.Second))
+	assert.False(t, locked)
+}
+
+func TestParseLimit(t *testing.T) {
+	l, err := ratelimit.ParseLimit("100/1m", "")
+	require.Nil(t, err)
+	assert.Equal(t, 100, l.Requests)
+	assert.Equal(t, time.Minute, l.Window)
+	assert.Equal(t, "100;w=60", l.Policy())
+
+	l, err = ratelimit.ParseLimit("10/hour", ratelimit.AlgorithmSlidingWindow)
+	require.Nil(t, err)
+	assert.Equal(t, time.Hour, l.Window)
+
+	_, err = ratelimit.ParseLimit("10", "")
+	assert.NotNil(t, err)
+	_, err = ratelimit.ParseLimit("10/m", "leaky")
+	assert.NotNil(t, err)
+}
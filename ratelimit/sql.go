@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Entry - Rate limit state row for the SQLStore
+type Entry struct {
+	Key         string    `gorm:"primary_key;column:rateKey;type:varchar(191)"`
+	Tokens      float64   `gorm:"column:tokens"`
+	Count       int64     `gorm:"column:count"`
+	PrevCount   int64     `gorm:"column:prevCount"`
+	WindowStart time.Time `gorm:"column:windowStart"`
+	UpdatedAt   time.Time `gorm:"column:updatedAt;autoUpdateTime:false"`
+	ExpiresAt   time.Time `gorm:"column:expiresAt;index"`
+}
+
+func (r *Entry) TableName() string {
+	return "rate_limits"
+}
+
+// SQLStore - Database store to share the limits between instances.
+// Each check runs in one transaction with a row lock in databases that support it
+type SQLStore struct {
+	DB *gorm.DB
+}
+
+func NewSQLStore(db *gorm.DB) *SQLStore {
+	return &SQLStore{DB: db}
+}
+
+// Migrate - Create or update the rate_limits table
+func (s *SQLStore) Migrate() error {
+	return s.DB.AutoMigrate(&Entry{})
+}
+
+func (s *SQLStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (*Result, error) {
+	var result *Result
+
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&Entry{Key: key, ExpiresAt: now}).Error
+		if err != nil {
+			return err
+		}
+
+		entry := Entry{}
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&entry, "rateKey = ?", key).Error
+		if err != nil {
+			return err
+		}
+
+		// expired rows are equal to new states
+		if now.After(entry.ExpiresAt) {
+			entry = Entry{Key: key}
+		}
+
+		state := entry.state()
+		result = Apply(&state, limit, now, true)
+
+		entry.setState(state)
+		entry.ExpiresAt = now.Add(2 * limit.Window)
+
+		return tx.Save(&entry).Error
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("ratelimit.SQLStore.Take: %w", err)
+	}
+
+	return result, nil
+}
+
+func (s *SQLStore) Peek(ctx context.Context, key string, limit Limit, now time.Time) (*Result, error) {
+	entry := Entry{}
+	err := s.DB.WithContext(ctx).First(&entry, "rateKey = ?", key).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("ratelimit.SQLStore.Peek: %w", err)
+	}
+
+	if now.After(entry.ExpiresAt) {
+		entry = Entry{Key: key}
+	}
+
+	state := entry.state()
+	return Apply(&state, limit, now, false), nil
+}
+
+func (s *SQLStore) Reset(ctx context.Context, key string) error {
+	return s.DB.WithContext(ctx).Delete(&Entry{}, "rateKey = ?", key).Error
+}
+
+// DeleteExpired - Remove the old rows, can run in one scheduled task
+func (s *SQLStore) DeleteExpired(ctx context.Context, now time.Time) error {
+	return s.DB.WithContext(ctx).Delete(&Entry{}, "expiresAt < ?", now).Error
+}
+
+func (r *Entry) state() State {
+	return State{
+		Tokens:      r.Tokens,
+		Count:       r.Count,
+		PrevCount:   r.PrevCount,
+		WindowStart: r.WindowStart,
+		UpdatedAt:   r.UpdatedAt,
+	}
+}
+
+func (r *Entry) setState(s State) {
+	r.Tokens = s.Tokens
+	r.Count = s.Count
+	r.PrevCount = s.PrevCount
+	r.WindowStart = s.WindowStart
+	r.UpdatedAt = s.UpdatedAt
+}
@@ -0,0 +1,97 @@
+package bolo_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	bolo "github.com/go-bolo/bolo"
+	"github.com/go-bolo/bolo/ratelimit"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	os.Setenv("RATE_LIMIT", "3/m")
+	defer os.Unsetenv("RATE_LIMIT")
+
+	app := GetTestApp()
+	action := func(c echo.Context) (bolo.Response, error) {
+		return &bolo.DefaultResponse{Data: map[string]string{"ok": "true"}}, nil
+	}
+
+	app.SetRoute("limited", &bolo.Route{
+		Method: http.MethodGet,
+		Path:   "/limited",
+		Action: action,
+		RateLimit: &bolo.RateLimit{
+			Limit: ratelimit.Limit{Requests: 1, Window: time.Minute, Algorithm: ratelimit.AlgorithmSlidingWindow},
+			By:    bolo.RateLimitByIP,
+		},
+	})
+	app.SetRoute("open", &bolo.Route{Method: http.MethodGet, Path: "/open", Action: action})
+
+	err := app.Bootstrap()
+	assert.Nil(t, err)
+
+	request := func(path, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderXRealIP, ip)
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("should apply the route limit", func(t *testing.T) {
+		rec := request("/limited", "10.0.0.1")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "1;w=60", rec.Header().Get("RateLimit-Policy"))
+
+		rec = request("/limited", "10.0.0.1")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+		// other clients have their own limits:
+		rec = request("/limited", "10.0.0.2")
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("should render the html page with the retry after header", func(t *testing.T) {
+		var rec *httptest.ResponseRecorder
+		for i := 0; i < 2; i++ {
+			req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+			req.Header.Set(echo.HeaderAccept, echo.MIMETextHTML)
+			req.Header.Set(echo.HeaderXRealIP, "10.0.0.5")
+			rec = httptest.NewRecorder()
+			app.GetRouter().ServeHTTP(rec, req)
+		}
+
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+		assert.Contains(t, rec.Body.String(), "Too many requests")
+	})
+
+	t.Run("should apply the global limit", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			rec := request("/open", "10.0.0.3")
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "3", rec.Header().Get("RateLimit-Limit"))
+		}
+
+		rec := request("/open", "10.0.0.3")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "20", rec.Header().Get("Retry-After"))
+	})
+
+	t.Run("should skip the health check", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			rec := request("/health", "10.0.0.4")
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+	})
+}
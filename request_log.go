@@ -0,0 +1,203 @@
+package bolo
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader - Header used to receive, return and propagate the request id
+var RequestIDHeader = echo.HeaderXRequestID
+
+type requestIDContextKey struct{}
+
+// ContextWithRequestID - Returns one context with the request id, used by the HttpClient to propagate the id
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// RequestIDFromContext - Returns the request id stored in the context or empty string
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// GetRequestID - Returns the current request id
+func GetRequestID(c echo.Context) string {
+	id, _ := c.Get("request_id").(string)
+	return id
+}
+
+// SetRequestID - Set the request id in the echo context, request context and response header
+func SetRequestID(c echo.Context, id string) error {
+	c.Set("request_id", id)
+	c.Response().Header().Set(RequestIDHeader, id)
+
+	req := c.Request()
+	c.SetRequest(req.WithContext(ContextWithRequestID(req.Context(), id)))
+
+	return nil
+}
+
+// resolveRequestID - Reuse the received request id if it is valid or generate a new one
+func resolveRequestID(req *http.Request) string {
+	id := req.Header.Get(RequestIDHeader)
+	if validRequestID(id) {
+		return id
+	}
+
+	return uuid.New().String()
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// RequestIDTransport - http.RoundTripper that adds the request id from the request context in outgoing requests
+type RequestIDTransport struct {
+	// Base transport, if nil the http.DefaultTransport is used
+	Base http.RoundTripper
+}
+
+func (t *RequestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	id := RequestIDFromContext(req.Context())
+	if id == "" || req.Header.Get(RequestIDHeader) != "" {
+		return base.RoundTrip(req)
+	}
+
+	// RoundTrippers should not modify the original request:
+	r := req.Clone(req.Context())
+	r.Header.Set(RequestIDHeader, id)
+
+	return base.RoundTrip(r)
+}
+
+// AccessLog - Access log configuration
+type AccessLog struct {
+	Disabled bool
+	// Ratio of successful requests logged, between 0 and 1. Server errors are always logged
+	SampleRate float64
+	// Request path prefixes without access log
+	SkipPaths []string
+}
+
+// NewAccessLogFromConfiguration - Build the access log configuration from ACCESS_LOG_* variables
+func NewAccessLogFromConfiguration(app App) *AccessLog {
+	cfg := app.GetConfiguration()
+
+	a := AccessLog{
+		Disabled:   cfg.GetBool(ACCESS_LOG_DISABLE),
+		SampleRate: 1,
+		SkipPaths:  []string{},
+	}
+
+	if v := cfg.Get(ACCESS_LOG_SAMPLE_RATE); v != "" {
+		rate, err := strconv.ParseFloat(v, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			app.GetLogger().Warn("NewAccessLogFromConfiguration invalid sample rate, using 1", zap.String("value", v))
+		} else {
+			a.SampleRate = rate
+		}
+	}
+
+	for _, p := range strings.Split(cfg.GetF(ACCESS_LOG_SKIP_PATHS, "/health,/public,/metrics"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			a.SkipPaths = append(a.SkipPaths, p)
+		}
+	}
+
+	return &a
+}
+
+func (a *AccessLog) skip(path string) bool {
+	for _, p := range a.SkipPaths {
+		if path == p || strings.HasPrefix(path, strings.TrimSuffix(p, "/")+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *AccessLog) sampled(status int) bool {
+	if status >= http.StatusInternalServerError || a.SampleRate >= 1 {
+		return true
+	}
+
+	return a.SampleRate > 0 && rand.Float64() < a.SampleRate
+}
+
+// AccessLogMiddleware - Write one structured log entry for each request
+func AccessLogMiddleware(app App) echo.MiddlewareFunc {
+	a := NewAccessLogFromConfiguration(app)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if a.Disabled || a.skip(req.URL.Path) {
+				return next(c)
+			}
+
+			start := time.Now()
+
+			// write the error response to log the final status
+			err := commitError(c, next(c))
+
+			res := c.Response()
+			if !a.sampled(res.Status) {
+				return err
+			}
+
+			routeName, _ := c.Get("route_name").(string)
+
+			fields := []zap.Field{
+				zap.String("method", req.Method),
+				zap.String("path", req.URL.Path),
+				zap.String("route", routeName),
+				zap.Int("status", res.Status),
+				zap.Duration("latency", time.Since(start)),
+				zap.Int64("size", res.Size),
+				zap.String("ip", c.RealIP()),
+				zap.String("userAgent", req.UserAgent()),
+				zap.Strings("roles", GetRoles(c)),
+			}
+
+			if user := GetAuthenticatedUser(c); user != nil {
+				fields = append(fields, zap.String("userID", user.GetID()))
+			}
+
+			if err != nil {
+				fields = append(fields, zap.Error(err))
+			}
+
+			GetLogger(c).Info("access", fields...)
+
+			return err
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package bolo_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	bolo "github.com/go-bolo/bolo"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAccessLogAndRequestID(t *testing.T) {
+	os.Setenv("ACCESS_LOG_SKIP_PATHS", "/health,/public")
+	defer os.Unsetenv("ACCESS_LOG_SKIP_PATHS")
+
+	core, logs := observer.New(zapcore.InfoLevel)
+
+	app := GetTestApp()
+	app.SetLogger(zap.New(core))
+
+	var outgoingRequestID string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outgoingRequestID = r.Header.Get("X-Request-ID")
+	}))
+	defer upstream.Close()
+
+	app.SetRoute("upstream", &bolo.Route{
+		Method: http.MethodGet,
+		Path:   "/upstream",
+		Action: func(c echo.Context) (bolo.Response, error) {
+			res, err := bolo.GetWithContext(c.Request().Context(), upstream.URL, nil)
+			if err != nil {
+				return nil, err
+			}
+			res.Body.Close()
+
+			return &bolo.DefaultResponse{Data: map[string]string{"ok": "true"}}, nil
+		},
+	})
+
+	err := app.Bootstrap()
+	assert.Nil(t, err)
+
+	accessLogs := func() []observer.LoggedEntry {
+		entries := []observer.LoggedEntry{}
+		for _, e := range logs.TakeAll() {
+			if e.Message == "access" {
+				entries = append(entries, e)
+			}
+		}
+		return entries
+	}
+
+	t.Run("should reuse the received request id and propagate it", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/upstream", nil)
+		req.Header.Set("X-Request-ID", "abc-123")
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "abc-123", rec.Header().Get("X-Request-ID"))
+		assert.Equal(t, "abc-123", outgoingRequestID)
+
+		entries := accessLogs()
+		if assert.Len(t, entries, 1) {
+			fields := entries[0].ContextMap()
+			assert.Equal(t, "abc-123", fields["RID"])
+			assert.Equal(t, "GET", fields["method"])
+			assert.Equal(t, "upstream", fields["route"])
+			assert.Equal(t, int64(200), fields["status"])
+			assert.NotNil(t, fields["latency"])
+			assert.NotNil(t, fields["size"])
+		}
+	})
+
+	t.Run("should generate one request id for invalid values", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/not-found", nil)
+		req.Header.Set("X-Request-ID", "invalid id\nwith new line")
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		id := rec.Header().Get("X-Request-ID")
+		assert.Len(t, id, 36)
+
+		entries := accessLogs()
+		if assert.Len(t, entries, 1) {
+			assert.Equal(t, int64(404), entries[0].ContextMap()["status"])
+		}
+	})
+
+	t.Run("should skip the excluded paths", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotEmpty(t, rec.Header().Get("X-Request-ID"))
+		assert.Empty(t, accessLogs())
+	})
+}
@@ -8,11 +8,28 @@ type Resource struct {
 	Model      Model
 	Prefix     string
 	Path       string
+	// Optional sitemap urls provider for this resource records
+	Sitemap SitemapSource
+	// Enable the RSS and Atom formats in the find route
+	Feed bool
+	// Disable the model.created, model.updated and model.deleted domain events of this resource records
+	SkipEvents bool
+	// Skip the create_page_ and update_page_ routes, used by API only resources. These routes share the
+	// find and findOne paths
+	SkipHTMLEndpoints bool
 }
 
 func (r *Resource) BindRoutes(app App) error {
-	addHTMLEndpoints := true
+	addHTMLEndpoints := !r.SkipHTMLEndpoints
 	enablePutUpdate := true
+
+	if r.Sitemap != nil {
+		err := app.AddSitemapSource(r.Name, r.Sitemap)
+		if err != nil {
+			return err
+		}
+	}
+
 	// query:
 	app.SetRoute("find_"+r.Name, &Route{
 		Method:     http.MethodGet,
@@ -20,6 +37,7 @@ func (r *Resource) BindRoutes(app App) error {
 		Action:     r.Controller.Find,
 		Template:   r.Name + "/query",
 		Permission: "find_" + r.Name,
+		Feed:       r.Feed,
 	})
 	// findOne:
 	app.SetRoute("findOne_"+r.Name, &Route{
@@ -41,6 +41,15 @@ type Route struct {
 	Layout     string
 	Theme      string
 	Model      interface{}
+	// Add this route in the sitemap, only used in GET routes without params
+	Sitemap *SitemapURL
+	// Disable the CSRF check, for routes called by external services
+	SkipCSRF bool
+	// Optional limit for this route, checked before the action
+	RateLimit *RateLimit
+	// Enable the RSS and Atom formats with content negotiation and the .rss and .atom suffixes, used in GET routes
+	// with FeedItem records
+	Feed bool
 }
 
 type responseFormatter func(app App, c echo.Context, r *Route, resp Response) error
@@ -101,9 +110,13 @@ type CustomBinder struct{}
 func (cb *CustomBinder) Bind(i interface{}, c echo.Context) (err error) {
 	// You may use default binder
 	db := &echo.DefaultBinder{}
-	if err = db.Bind(i, c); err != echo.ErrUnsupportedMediaType {
+	if err = db.Bind(i, c); err != nil {
 		return
 	}
 
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		return BindUploads(c, i)
+	}
+
 	return
 }
@@ -0,0 +1,97 @@
+package bolo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+var (
+	ErrSchedulerNotConfigured = errors.New("scheduler not configured")
+	ErrScheduledTaskNotFound  = errors.New("scheduled task not found")
+)
+
+// ScheduledTaskHandler - Run one scheduled task
+type ScheduledTaskHandler func(ctx context.Context, app App) error
+
+// ScheduledTask - Periodic task registered by plugins in Init with app.SetScheduledTask
+type ScheduledTask struct {
+	Name string
+	// Cron expression with 5 fields or one descriptor, like: */15 * * * *, @daily or @every 1h
+	Schedule string
+	Handler  ScheduledTaskHandler
+	// Max duration of one run, 0 uses the scheduler default
+	Timeout time.Duration
+
+	schedule cron.Schedule
+}
+
+// Next - Returns the next run time after t
+func (t *ScheduledTask) Next(after time.Time) time.Time {
+	return t.schedule.Next(after)
+}
+
+// Scheduler - Runs the scheduled tasks, set by the scheduler plugin
+type Scheduler interface {
+	// Start - Start the scheduler loop, ran after Bootstrap
+	Start() error
+	// Stop - Stop the loop and wait the running tasks, ran in Close
+	Stop(ctx context.Context) error
+	// RunTask - Run one task now, outside its schedule
+	RunTask(ctx context.Context, name string) error
+}
+
+// ParseSchedule - Parse one cron expression in the ScheduledTask.Schedule format
+func ParseSchedule(expression string) (cron.Schedule, error) {
+	return cron.ParseStandard(expression)
+}
+
+// ScheduledTaskInfo - Current scheduled task metadata, available in handlers with GetScheduledTaskInfo
+type ScheduledTaskInfo struct {
+	Name string
+	// Scheduled time of this run, zero in runs triggered on demand
+	ScheduledAt time.Time
+}
+
+type scheduledTaskInfoContextKey struct{}
+
+func ContextWithScheduledTaskInfo(ctx context.Context, info *ScheduledTaskInfo) context.Context {
+	return context.WithValue(ctx, scheduledTaskInfoContextKey{}, info)
+}
+
+// GetScheduledTaskInfo - Returns the running task metadata or nil outside scheduled task handlers
+func GetScheduledTaskInfo(ctx context.Context) *ScheduledTaskInfo {
+	info, _ := ctx.Value(scheduledTaskInfoContextKey{}).(*ScheduledTaskInfo)
+	return info
+}
+
+// RunScheduledTask - Run one scheduled task now with the app scheduler
+func RunScheduledTask(ctx context.Context, app App, name string) error {
+	s := app.GetScheduler()
+	if s == nil {
+		return ErrSchedulerNotConfigured
+	}
+
+	return s.RunTask(ctx, name)
+}
+
+func validateScheduledTask(t *ScheduledTask) error {
+	if t.Name == "" {
+		return fmt.Errorf("SetScheduledTask: name is required")
+	}
+
+	if t.Handler == nil {
+		return fmt.Errorf("SetScheduledTask: handler is required: %s", t.Name)
+	}
+
+	schedule, err := ParseSchedule(t.Schedule)
+	if err != nil {
+		return fmt.Errorf("SetScheduledTask: invalid schedule %q in %s: %w", t.Schedule, t.Name, err)
+	}
+
+	t.schedule = schedule
+	return nil
+}
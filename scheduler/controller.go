@@ -0,0 +1,144 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-bolo/bolo"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+type TaskController struct {
+	Plugin *Plugin
+}
+
+type ListJSONResponse struct {
+	bolo.BaseListReponse
+	Tasks []*TaskState `json:"scheduledTask"`
+}
+
+type FindOneJSONResponse struct {
+	Task *TaskState `json:"scheduledTask"`
+}
+
+// Find - List the registered tasks with the last and next runs
+func (ctl *TaskController) Find(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	app := bolo.GetApp(c)
+
+	states, err := FindAll(app)
+	if err != nil {
+		return nil, fmt.Errorf("TaskController.Find: %w", err)
+	}
+
+	byName := map[string]*TaskState{}
+	for _, state := range states {
+		byName[state.Name] = state
+	}
+
+	records := []*TaskState{}
+	for _, name := range ctl.Plugin.Scheduler.Tasks() {
+		state, ok := byName[name]
+		if !ok {
+			state = newTaskState(app, name)
+		}
+
+		records = append(records, state)
+	}
+
+	resp := ListJSONResponse{Tasks: records}
+	resp.Meta.Count = int64(len(records))
+
+	return &bolo.DefaultResponse{Data: &resp}, nil
+}
+
+func (ctl *TaskController) FindOne(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	record, err := loadRecord(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bolo.DefaultResponse{Data: &FindOneJSONResponse{Task: record}}, nil
+}
+
+// Run - Run one task now and returns its state with the run result
+func (ctl *TaskController) Run(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	name := c.Param("name")
+
+	err := ctl.Plugin.Scheduler.RunTask(c.Request().Context(), name)
+	if err != nil {
+		switch {
+		case errors.Is(err, bolo.ErrScheduledTaskNotFound):
+			return nil, &bolo.HTTPError{Code: http.StatusNotFound, Message: "Not found", Internal: err}
+		case errors.Is(err, ErrTaskRunning):
+			return nil, &bolo.HTTPError{Code: http.StatusConflict, Message: err.Error()}
+		}
+
+		// task errors are saved in the state lastError
+		bolo.GetLogger(c).Debug("TaskController.Run task error", zap.String("task", name), zap.Error(err))
+	}
+
+	record, err := loadRecord(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bolo.DefaultResponse{Data: &FindOneJSONResponse{Task: record}}, nil
+}
+
+// newTaskState - State of one task not synced yet, like when the scheduler is disabled in this instance
+func newTaskState(app bolo.App, name string) *TaskState {
+	t := app.GetScheduledTasks()[name]
+
+	return &TaskState{
+		Name:      name,
+		Schedule:  t.Schedule,
+		NextRunAt: t.Next(app.GetClock().Now()),
+	}
+}
+
+func loadRecord(c echo.Context) (*TaskState, error) {
+	app := bolo.GetApp(c)
+	name := c.Param("name")
+
+	if _, ok := app.GetScheduledTasks()[name]; !ok {
+		return nil, &bolo.HTTPError{Code: http.StatusNotFound, Message: "Not found"}
+	}
+
+	record, err := FindOne(app, name)
+	if err != nil {
+		if errors.Is(err, ErrTaskStateNotFound) {
+			return newTaskState(app, name), nil
+		}
+
+		return nil, err
+	}
+
+	return record, nil
+}
+
+func checkAccess(c echo.Context) error {
+	if !bolo.IsAuthenticated(c) {
+		return &bolo.HTTPError{Code: http.StatusUnauthorized, Message: "Unauthorized"}
+	}
+
+	route := bolo.GetRoute(c)
+	if route.Permission != "" && !bolo.Can(c, route.Permission) {
+		return &bolo.HTTPError{Code: http.StatusForbidden, Message: "Forbidden"}
+	}
+
+	return nil
+}
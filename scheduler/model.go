@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"gorm.io/gorm"
+)
+
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+var (
+	ErrTaskStateNotFound = errors.New("scheduled task state not found")
+)
+
+// TaskState - Shared state of one scheduled task, the row is also the lock used by all app instances
+type TaskState struct {
+	Name           string     `gorm:"primary_key;column:name;type:varchar(150)" json:"name"`
+	Schedule       string     `gorm:"column:schedule;type:varchar(100)" json:"schedule"`
+	NextRunAt      time.Time  `gorm:"column:nextRunAt;index" json:"nextRunAt"`
+	LastRunAt      *time.Time `gorm:"column:lastRunAt" json:"lastRunAt"`
+	LastFinishedAt *time.Time `gorm:"column:lastFinishedAt" json:"lastFinishedAt"`
+	// Duration of the last run in milliseconds
+	LastDuration int64      `gorm:"column:lastDuration;default:0" json:"lastDuration"`
+	LastStatus   string     `gorm:"column:lastStatus;type:varchar(20)" json:"lastStatus"`
+	LastError    string     `gorm:"column:lastError;type:text" json:"lastError"`
+	Runs         int64      `gorm:"column:runs;default:0" json:"runs"`
+	LockedAt     *time.Time `gorm:"column:lockedAt" json:"lockedAt"`
+	LockedBy     string     `gorm:"column:lockedBy;type:varchar(100)" json:"lockedBy"`
+	CreatedAt    time.Time  `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt    time.Time  `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+func (r *TaskState) TableName() string {
+	return "scheduled_tasks"
+}
+
+func (r *TaskState) GetID() string {
+	return r.Name
+}
+
+func (r *TaskState) LoadData() error {
+	return nil
+}
+
+func (r *TaskState) LoadTeaserData() error {
+	return nil
+}
+
+func (r *TaskState) Save(app bolo.App) error {
+	now := app.GetClock().Now()
+	r.UpdatedAt = now
+
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = now
+	}
+
+	return app.GetDB().Save(r).Error
+}
+
+// IsRunning - Returns true if one instance holds the task lock
+func (r *TaskState) IsRunning() bool {
+	return r.LockedBy != ""
+}
+
+// FindOne - Find the state of one task by name
+func FindOne(app bolo.App, name string) (*TaskState, error) {
+	record := TaskState{}
+
+	err := app.GetDB().First(&record, "name = ?", name).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTaskStateNotFound
+		}
+
+		return nil, fmt.Errorf("scheduler.FindOne: %w", err)
+	}
+
+	return &record, nil
+}
+
+// FindAll - Find the states of all tasks ordered by name
+func FindAll(app bolo.App) ([]*TaskState, error) {
+	records := []*TaskState{}
+
+	err := app.GetDB().Order("name ASC").Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("scheduler.FindAll: %w", err)
+	}
+
+	return records, nil
+}
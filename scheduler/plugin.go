@@ -0,0 +1,95 @@
+package scheduler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/gookit/event"
+)
+
+// Plugin - Run the scheduled tasks registered with app.SetScheduledTask, with admin routes to inspect and run tasks
+type Plugin struct {
+	bolo.Plugin
+
+	Name       string
+	App        bolo.App
+	Scheduler  *Scheduler
+	Controller *TaskController
+	// Max wait for the running tasks in Close
+	StopTimeout time.Duration
+}
+
+type PluginOpts struct {
+	Scheduler *Scheduler
+}
+
+func NewPlugin(opts *PluginOpts) *Plugin {
+	return &Plugin{
+		Name:      "scheduler",
+		Scheduler: opts.Scheduler,
+	}
+}
+
+func (p *Plugin) GetName() string {
+	return p.Name
+}
+
+func (p *Plugin) Init(app bolo.App) error {
+	app.GetLogger().Debug(p.GetName() + " Init")
+
+	p.App = app
+	cfg := app.GetConfiguration()
+
+	if p.Scheduler == nil {
+		p.Scheduler = NewSchedulerFromConfiguration(app)
+	}
+
+	if p.StopTimeout == 0 {
+		p.StopTimeout = time.Duration(cfg.GetInt64F("SCHEDULER_STOP_TIMEOUT", 30)) * time.Second
+	}
+
+	p.Controller = &TaskController{Plugin: p}
+
+	app.SetModel("scheduled_task", &TaskState{})
+	app.SetScheduler(p.Scheduler)
+
+	app.SetRoute("find_scheduled_task", &bolo.Route{
+		Method:     http.MethodGet,
+		Path:       "/api/v1/scheduled-tasks",
+		Action:     p.Controller.Find,
+		Permission: "find_scheduled_task",
+	})
+
+	app.SetRoute("findOne_scheduled_task", &bolo.Route{
+		Method:     http.MethodGet,
+		Path:       "/api/v1/scheduled-tasks/:name",
+		Action:     p.Controller.FindOne,
+		Permission: "findOne_scheduled_task",
+	})
+
+	app.SetRoute("run_scheduled_task", &bolo.Route{
+		Method:     http.MethodPost,
+		Path:       "/api/v1/scheduled-tasks/:name/run",
+		Action:     p.Controller.Run,
+		Permission: "run_scheduled_task",
+	})
+
+	app.GetEvents().On("bootstrap", bolo.TracedListener(app, "scheduler.start", event.ListenerFunc(func(e event.Event) error {
+		if cfg.GetBool("SCHEDULER_DISABLE") {
+			return nil
+		}
+
+		return p.Scheduler.Start()
+	})), event.Normal)
+
+	app.GetEvents().On("close", bolo.TracedListener(app, "scheduler.stop", event.ListenerFunc(func(e event.Event) error {
+		ctx, cancel := context.WithTimeout(context.Background(), p.StopTimeout)
+		defer cancel()
+
+		return p.Scheduler.Stop(ctx)
+	})), event.High)
+
+	return nil
+}
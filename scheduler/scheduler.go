@@ -0,0 +1,401 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrSchedulerStarted = errors.New("scheduler already started")
+	ErrTaskRunning      = errors.New("scheduled task already running")
+)
+
+// Scheduler - Database backed bolo.Scheduler, each due run is claimed by only one app instance
+type Scheduler struct {
+	App bolo.App
+	// Interval between the checks for due tasks
+	Interval       time.Duration
+	DefaultTimeout time.Duration
+	// Tasks locked for more than LockTimeout are claimed again, like after one crash.
+	// The lock of the running tasks is refreshed every LockTimeout/3
+	LockTimeout time.Duration
+	InstanceID  string
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	running bool
+}
+
+// NewSchedulerFromConfiguration - Build one scheduler with the SCHEDULER_* variables
+func NewSchedulerFromConfiguration(app bolo.App) *Scheduler {
+	cfg := app.GetConfiguration()
+
+	hostname, _ := os.Hostname()
+
+	s := Scheduler{
+		App:            app,
+		Interval:       time.Duration(cfg.GetInt64F("SCHEDULER_INTERVAL", 1000)) * time.Millisecond,
+		DefaultTimeout: time.Duration(cfg.GetInt64F("SCHEDULER_TIMEOUT", 3600)) * time.Second,
+		LockTimeout:    time.Duration(cfg.GetInt64F("SCHEDULER_LOCK_TIMEOUT", 7200)) * time.Second,
+		InstanceID:     hostname + ":" + uuid.New().String()[:8],
+	}
+
+	return &s
+}
+
+// Tasks - Returns the registered task names, sorted
+func (s *Scheduler) Tasks() []string {
+	names := []string{}
+	for name := range s.App.GetScheduledTasks() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Sync - Create the missing task states and move the next run of tasks with one changed schedule
+func (s *Scheduler) Sync(ctx context.Context) error {
+	db := s.App.GetDB().WithContext(ctx)
+	now := s.App.GetClock().Now()
+
+	for _, name := range s.Tasks() {
+		t := s.App.GetScheduledTasks()[name]
+
+		state := TaskState{
+			Name:      name,
+			Schedule:  t.Schedule,
+			NextRunAt: t.Next(now),
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+
+		// other instances can create the same state at the same time
+		err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&state).Error
+		if err != nil {
+			return fmt.Errorf("scheduler.Sync error on create %s state: %w", name, err)
+		}
+
+		err = db.Model(&TaskState{}).
+			Where("name = ? AND schedule <> ?", name, t.Schedule).
+			Updates(map[string]interface{}{
+				"schedule":  t.Schedule,
+				"nextRunAt": t.Next(now),
+				"updatedAt": now,
+			}).Error
+		if err != nil {
+			return fmt.Errorf("scheduler.Sync error on update %s schedule: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Start - Start the scheduler loop. The database is not used in Start, the loop syncs the task states before the
+// first run and retries the sync until the tables exist, Start can run before the migrations
+func (s *Scheduler) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return ErrSchedulerStarted
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.running = true
+
+	s.wg.Add(1)
+	go s.loop(ctx)
+
+	s.App.GetLogger().Info("scheduler: started", zap.String("instanceID", s.InstanceID), zap.Strings("tasks", s.Tasks()))
+
+	return nil
+}
+
+// Stop - Stop the loop and wait the running tasks until the ctx deadline
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return nil
+	}
+
+	s.cancel()
+	s.running = false
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("scheduler.Stop: %w", ctx.Err())
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	synced := false
+	syncFailures := 0
+
+	for {
+		if !synced {
+			if err := s.Sync(ctx); err != nil {
+				// like before the migrations, only the first error is logged as warning
+				l := s.App.GetLogger().Debug
+				if syncFailures == 0 {
+					l = s.App.GetLogger().Warn
+				}
+				l("scheduler: error on sync the tasks, retrying", zap.Error(err))
+				syncFailures++
+			} else {
+				synced = true
+			}
+		}
+
+		if synced {
+			if _, err := s.dispatch(ctx, &s.wg); err != nil {
+				s.App.GetLogger().Error("scheduler: error on run due tasks", zap.Error(err))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue - Claim and run all due tasks, waits the runs and returns the number of tasks ran by this instance
+func (s *Scheduler) RunDue(ctx context.Context) (int, error) {
+	wg := sync.WaitGroup{}
+	n, err := s.dispatch(ctx, &wg)
+	wg.Wait()
+
+	return n, err
+}
+
+// dispatch - Claim the due tasks and run each one in one goroutine added in the wg,
+// long tasks don't delay the other ones
+func (s *Scheduler) dispatch(ctx context.Context, wg *sync.WaitGroup) (int, error) {
+	now := s.App.GetClock().Now()
+	tasks := s.App.GetScheduledTasks()
+
+	states := []*TaskState{}
+	err := s.App.GetDB().WithContext(ctx).
+		Where("name IN ? AND nextRunAt <= ?", s.Tasks(), now).
+		Find(&states).Error
+	if err != nil {
+		return 0, fmt.Errorf("scheduler.RunDue error on find due tasks: %w", err)
+	}
+
+	n := 0
+	for _, state := range states {
+		t := tasks[state.Name]
+
+		claimed, err := s.claim(ctx, t, now)
+		if err != nil {
+			return n, err
+		}
+
+		if !claimed {
+			continue
+		}
+
+		n++
+		wg.Add(1)
+		go func(t *bolo.ScheduledTask, scheduledAt time.Time) {
+			defer wg.Done()
+			s.run(ctx, t, scheduledAt)
+		}(t, state.NextRunAt)
+	}
+
+	return n, nil
+}
+
+// claim - Lock one due task and move its next run with one conditional update,
+// the update only matches in one instance
+func (s *Scheduler) claim(ctx context.Context, t *bolo.ScheduledTask, now time.Time) (bool, error) {
+	res := s.App.GetDB().WithContext(ctx).Model(&TaskState{}).
+		Where("name = ? AND nextRunAt <= ? AND (lockedBy = ? OR lockedAt <= ?)", t.Name, now, "", now.Add(-s.LockTimeout)).
+		Updates(map[string]interface{}{
+			"nextRunAt": t.Next(now),
+			"lockedAt":  now,
+			"lockedBy":  s.InstanceID,
+			"updatedAt": now,
+		})
+	if res.Error != nil {
+		return false, fmt.Errorf("scheduler.claim error on lock %s: %w", t.Name, res.Error)
+	}
+
+	return res.RowsAffected > 0, nil
+}
+
+// RunTask - Run one task now, the next scheduled run is not changed. Returns ErrTaskRunning if the task is locked
+func (s *Scheduler) RunTask(ctx context.Context, name string) error {
+	t, ok := s.App.GetScheduledTasks()[name]
+	if !ok {
+		return fmt.Errorf("scheduler.RunTask %s: %w", name, bolo.ErrScheduledTaskNotFound)
+	}
+
+	if err := s.Sync(ctx); err != nil {
+		return err
+	}
+
+	now := s.App.GetClock().Now()
+
+	res := s.App.GetDB().WithContext(ctx).Model(&TaskState{}).
+		Where("name = ? AND (lockedBy = ? OR lockedAt <= ?)", name, "", now.Add(-s.LockTimeout)).
+		Updates(map[string]interface{}{
+			"lockedAt":  now,
+			"lockedBy":  s.InstanceID,
+			"updatedAt": now,
+		})
+	if res.Error != nil {
+		return fmt.Errorf("scheduler.RunTask error on lock %s: %w", name, res.Error)
+	}
+
+	if res.RowsAffected == 0 {
+		return ErrTaskRunning
+	}
+
+	return s.run(ctx, t, time.Time{})
+}
+
+func (s *Scheduler) run(ctx context.Context, t *bolo.ScheduledTask, scheduledAt time.Time) error {
+	l := s.App.GetLogger().With(zap.String("task", t.Name))
+
+	timeout := t.Timeout
+	if timeout == 0 {
+		timeout = s.DefaultTimeout
+	}
+
+	taskCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	taskCtx = bolo.ContextWithScheduledTaskInfo(taskCtx, &bolo.ScheduledTaskInfo{
+		Name:        t.Name,
+		ScheduledAt: scheduledAt,
+	})
+
+	startedAt := s.App.GetClock().Now()
+	start := time.Now()
+
+	stopLock := s.keepLocked(t)
+	err := s.handle(taskCtx, t)
+	stopLock()
+	if err != nil {
+		l.Warn("scheduler: task failed", zap.Error(err), zap.Duration("duration", time.Since(start)))
+	} else {
+		l.Debug("scheduler: task succeeded", zap.Duration("duration", time.Since(start)))
+	}
+
+	if finishErr := s.finish(t, startedAt, time.Since(start), err); finishErr != nil {
+		l.Error("scheduler: error on save task result", zap.Error(finishErr))
+		return finishErr
+	}
+
+	return err
+}
+
+// keepLocked - Refresh the lock of one running task until the returned stop function is called,
+// tasks that run for more than the LockTimeout are not claimed by other instances
+func (s *Scheduler) keepLocked(t *bolo.ScheduledTask) func() {
+	if s.LockTimeout <= 0 {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(s.LockTimeout / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				err := s.App.GetDB().Model(&TaskState{}).
+					Where("name = ? AND lockedBy = ?", t.Name, s.InstanceID).
+					Update("lockedAt", s.App.GetClock().Now()).Error
+				if err != nil {
+					s.App.GetLogger().Warn("scheduler: error on refresh task lock", zap.String("task", t.Name), zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+func (s *Scheduler) handle(ctx context.Context, t *bolo.ScheduledTask) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+
+	return t.Handler(ctx, s.App)
+}
+
+// finish - Save the run result and release the lock
+func (s *Scheduler) finish(t *bolo.ScheduledTask, startedAt time.Time, duration time.Duration, taskErr error) error {
+	now := s.App.GetClock().Now()
+
+	values := map[string]interface{}{
+		"lastRunAt":      startedAt,
+		"lastFinishedAt": now,
+		"lastDuration":   duration.Milliseconds(),
+		"lastStatus":     StatusSucceeded,
+		"lastError":      "",
+		"runs":           clause.Expr{SQL: "runs + 1"},
+		"lockedAt":       nil,
+		"lockedBy":       "",
+		"updatedAt":      now,
+	}
+
+	if taskErr != nil {
+		values["lastStatus"] = StatusFailed
+		values["lastError"] = taskErr.Error()
+	}
+
+	// the ctx can be canceled in Stop, the result is saved anyway
+	err := s.App.GetDB().Model(&TaskState{}).
+		Where("name = ? AND lockedBy = ?", t.Name, s.InstanceID).
+		Updates(values).Error
+	if err != nil {
+		return fmt.Errorf("scheduler.finish error on update %s: %w", t.Name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,381 @@
+package scheduler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/go-bolo/bolo/acl"
+	"github.com/go-bolo/bolo/outbox"
+	"github.com/go-bolo/bolo/scheduler"
+	"github.com/go-bolo/bolo/users"
+	"github.com/go-bolo/clock"
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestApp(t *testing.T, setup func(app bolo.App)) (bolo.App, *scheduler.Plugin, *clock.Mock) {
+	os.Setenv("TEMPLATE_DISABLE", "true")
+	os.Setenv("SCHEDULER_DISABLE", "true")
+	os.Setenv("DB_URI", filepath.Join(t.TempDir(), "scheduler.sqlite"))
+	t.Cleanup(func() {
+		os.Unsetenv("TEMPLATE_DISABLE")
+		os.Unsetenv("SCHEDULER_DISABLE")
+		os.Unsetenv("DB_URI")
+	})
+
+	app := bolo.NewApp(&bolo.DefaultAppOptions{})
+
+	c := clock.NewMock()
+	now, _ := time.Parse("2006-01-02", "2023-07-16")
+	c.Set(now)
+	app.SetClock(c)
+
+	p := scheduler.NewPlugin(&scheduler.PluginOpts{})
+	app.AddPlugin(p)
+
+	if setup != nil {
+		setup(app)
+	}
+
+	require.Nil(t, app.Bootstrap())
+	require.Nil(t, app.SyncDB())
+
+	return app, p, c
+}
+
+func TestSetScheduledTask(t *testing.T) {
+	app := bolo.NewApp(&bolo.DefaultAppOptions{})
+	handler := func(ctx context.Context, app bolo.App) error { return nil }
+
+	assert.Nil(t, app.SetScheduledTask(&bolo.ScheduledTask{Name: "hourly", Schedule: "@hourly", Handler: handler}))
+	assert.Nil(t, app.SetScheduledTask(&bolo.ScheduledTask{Name: "every", Schedule: "@every 10m", Handler: handler}))
+	assert.NotNil(t, app.SetScheduledTask(&bolo.ScheduledTask{Name: "invalid", Schedule: "* * *", Handler: handler}))
+	assert.NotNil(t, app.SetScheduledTask(&bolo.ScheduledTask{Name: "no_handler", Schedule: "@daily"}))
+	assert.Len(t, app.GetScheduledTasks(), 2)
+
+	now, _ := time.Parse(time.RFC3339, "2023-07-16T10:20:00Z")
+	assert.Equal(t, "2023-07-16T11:00:00Z", app.GetScheduledTasks()["hourly"].Next(now).Format(time.RFC3339))
+}
+
+func TestScheduler(t *testing.T) {
+	ctx := context.Background()
+
+	var runs []*bolo.ScheduledTaskInfo
+	var taskErr error
+
+	app, p, clk := newTestApp(t, func(app bolo.App) {
+		app.SetScheduledTask(&bolo.ScheduledTask{
+			Name:     "clean_sessions",
+			Schedule: "*/15 * * * *",
+			Handler: func(ctx context.Context, app bolo.App) error {
+				runs = append(runs, bolo.GetScheduledTaskInfo(ctx))
+				return taskErr
+			},
+		})
+	})
+
+	s := p.Scheduler
+	require.Nil(t, s.Sync(ctx))
+
+	start := clk.Now()
+
+	state, err := scheduler.FindOne(app, "clean_sessions")
+	require.Nil(t, err)
+	assert.WithinDuration(t, start.Add(15*time.Minute), state.NextRunAt, 0)
+	assert.Nil(t, state.LastRunAt)
+
+	t.Run("should run due tasks only", func(t *testing.T) {
+		n, err := s.RunDue(ctx)
+		require.Nil(t, err)
+		assert.Equal(t, 0, n)
+
+		clk.Add(15 * time.Minute)
+
+		n, err = s.RunDue(ctx)
+		require.Nil(t, err)
+		assert.Equal(t, 1, n)
+		require.Len(t, runs, 1)
+		assert.Equal(t, "clean_sessions", runs[0].Name)
+		assert.WithinDuration(t, start.Add(15*time.Minute), runs[0].ScheduledAt, 0)
+
+		state, _ := scheduler.FindOne(app, "clean_sessions")
+		assert.WithinDuration(t, start.Add(30*time.Minute), state.NextRunAt, 0)
+		assert.WithinDuration(t, clk.Now(), *state.LastRunAt, 0)
+		assert.Equal(t, scheduler.StatusSucceeded, state.LastStatus)
+		assert.Equal(t, int64(1), state.Runs)
+		assert.False(t, state.IsRunning())
+
+		n, _ = s.RunDue(ctx)
+		assert.Equal(t, 0, n)
+	})
+
+	t.Run("should run one due task in only one instance", func(t *testing.T) {
+		runs = nil
+		other := &scheduler.Scheduler{
+			App:            app,
+			DefaultTimeout: time.Minute,
+			LockTimeout:    time.Hour,
+			InstanceID:     "other-instance",
+		}
+
+		clk.Add(15 * time.Minute)
+
+		n, _ := s.RunDue(ctx)
+		assert.Equal(t, 1, n)
+		n, _ = other.RunDue(ctx)
+		assert.Equal(t, 0, n)
+		assert.Len(t, runs, 1)
+	})
+
+	t.Run("should skip locked tasks until the lock timeout", func(t *testing.T) {
+		runs = nil
+		require.Nil(t, app.GetDB().Model(&scheduler.TaskState{}).Where("name = ?", "clean_sessions").Updates(map[string]interface{}{
+			"lockedBy": "crashed", "lockedAt": clk.Now(),
+		}).Error)
+
+		clk.Add(15 * time.Minute)
+		n, _ := s.RunDue(ctx)
+		assert.Equal(t, 0, n)
+
+		err := s.RunTask(ctx, "clean_sessions")
+		assert.ErrorIs(t, err, scheduler.ErrTaskRunning)
+
+		clk.Add(s.LockTimeout)
+		n, _ = s.RunDue(ctx)
+		assert.Equal(t, 1, n)
+		assert.Len(t, runs, 1)
+	})
+
+	t.Run("should save failed runs", func(t *testing.T) {
+		taskErr = errors.New("redis unavailable")
+		defer func() { taskErr = nil }()
+
+		clk.Add(15 * time.Minute)
+		n, _ := s.RunDue(ctx)
+		assert.Equal(t, 1, n)
+
+		state, _ := scheduler.FindOne(app, "clean_sessions")
+		assert.Equal(t, scheduler.StatusFailed, state.LastStatus)
+		assert.Equal(t, "redis unavailable", state.LastError)
+	})
+
+	t.Run("should run tasks on demand without change the schedule", func(t *testing.T) {
+		runs = nil
+		before, _ := scheduler.FindOne(app, "clean_sessions")
+
+		require.Nil(t, s.RunTask(ctx, "clean_sessions"))
+		require.Len(t, runs, 1)
+		assert.True(t, runs[0].ScheduledAt.IsZero())
+
+		state, _ := scheduler.FindOne(app, "clean_sessions")
+		assert.WithinDuration(t, before.NextRunAt, state.NextRunAt, 0)
+		assert.Equal(t, before.Runs+1, state.Runs)
+		assert.Equal(t, scheduler.StatusSucceeded, state.LastStatus)
+
+		assert.ErrorIs(t, s.RunTask(ctx, "unknown"), bolo.ErrScheduledTaskNotFound)
+	})
+}
+
+func TestSchedulerLoop(t *testing.T) {
+	var runs int32
+
+	app, p, clk := newTestApp(t, func(app bolo.App) {
+		app.SetScheduledTask(&bolo.ScheduledTask{
+			Name:     "rebuild_sitemap",
+			Schedule: "@every 1h",
+			Handler: func(ctx context.Context, app bolo.App) error {
+				atomic.AddInt32(&runs, 1)
+				return nil
+			},
+		})
+	})
+
+	s := p.Scheduler
+	s.Interval = 5 * time.Millisecond
+
+	require.Nil(t, s.Start())
+	assert.ErrorIs(t, s.Start(), scheduler.ErrSchedulerStarted)
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&runs))
+
+	clk.Add(time.Hour)
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&runs) == 1 }, 2*time.Second, 5*time.Millisecond)
+
+	require.Nil(t, app.Close())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&runs))
+}
+
+func TestSchedulerLockRefresh(t *testing.T) {
+	ctx := context.Background()
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	app, p, clk := newTestApp(t, func(app bolo.App) {
+		app.SetScheduledTask(&bolo.ScheduledTask{
+			Name:     "import",
+			Schedule: "@every 1m",
+			Handler: func(ctx context.Context, app bolo.App) error {
+				close(started)
+				<-release
+				return nil
+			},
+		})
+	})
+
+	s := p.Scheduler
+	s.LockTimeout = 30 * time.Millisecond
+
+	other := &scheduler.Scheduler{
+		App:            app,
+		DefaultTimeout: time.Minute,
+		LockTimeout:    s.LockTimeout,
+		InstanceID:     "other-instance",
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.RunTask(ctx, "import") }()
+	<-started
+
+	clk.Add(time.Hour)
+	assert.Eventually(t, func() bool {
+		state := scheduler.TaskState{}
+		err := app.GetDB().First(&state, "name = ?", "import").Error
+		return err == nil && state.LockedAt != nil && state.LockedAt.Equal(clk.Now())
+	}, 2*time.Second, 5*time.Millisecond)
+
+	assert.ErrorIs(t, other.RunTask(ctx, "import"), scheduler.ErrTaskRunning)
+
+	close(release)
+	require.Nil(t, <-done)
+}
+
+func TestSchedulerBootstrap(t *testing.T) {
+	os.Setenv("TEMPLATE_DISABLE", "true")
+	os.Setenv("SCHEDULER_INTERVAL", "5")
+	os.Setenv("DB_URI", filepath.Join(t.TempDir(), "scheduler.sqlite"))
+	t.Cleanup(func() {
+		os.Unsetenv("TEMPLATE_DISABLE")
+		os.Unsetenv("SCHEDULER_INTERVAL")
+		os.Unsetenv("DB_URI")
+	})
+
+	var runs int32
+
+	app := bolo.NewApp(&bolo.DefaultAppOptions{})
+	clk := clock.NewMock()
+	now, _ := time.Parse("2006-01-02", "2023-07-16")
+	clk.Set(now)
+	app.SetClock(clk)
+
+	// the outbox registers the outbox_cleanup task
+	app.AddPlugin(outbox.NewPlugin(&outbox.PluginOpts{}))
+	app.AddPlugin(scheduler.NewPlugin(&scheduler.PluginOpts{}))
+	app.SetScheduledTask(&bolo.ScheduledTask{
+		Name:     "rebuild_sitemap",
+		Schedule: "@every 1h",
+		Handler: func(ctx context.Context, app bolo.App) error {
+			atomic.AddInt32(&runs, 1)
+			return nil
+		},
+	})
+
+	// the scheduler starts before the tables exist
+	require.Nil(t, app.Bootstrap())
+	defer app.Close()
+	require.Nil(t, app.SyncDB())
+
+	require.Eventually(t, func() bool {
+		_, err := scheduler.FindOne(app, "rebuild_sitemap")
+		return err == nil
+	}, 2*time.Second, 5*time.Millisecond)
+
+	clk.Add(time.Hour)
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&runs) == 1 }, 2*time.Second, 5*time.Millisecond)
+}
+
+func TestScheduledTaskRoutes(t *testing.T) {
+	var runs int32
+
+	app, _, _ := newTestApp(t, func(app bolo.App) {
+		app.SetScheduledTask(&bolo.ScheduledTask{
+			Name:     "sync_products",
+			Schedule: "0 3 * * *",
+			Handler: func(ctx context.Context, app bolo.App) error {
+				atomic.AddInt32(&runs, 1)
+				return nil
+			},
+		})
+
+		// authenticate the requests with the X-Test-User header:
+		app.GetEvents().On("bindMiddlewares", event.ListenerFunc(func(e event.Event) error {
+			app.GetRouter().Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+				return func(c echo.Context) error {
+					if c.Request().Header.Get("X-Test-User") != "" {
+						u := users.NewUser(app)
+						u.ID = 1
+						bolo.SetAuthenticatedUser(c, u)
+						bolo.SetRoles(c, []string{"authenticated"})
+					}
+					return next(c)
+				}
+			})
+			return nil
+		}), event.Low)
+	})
+
+	app.GetAcl().SetRole("authenticated", acl.Role{
+		Name:        "authenticated",
+		Permissions: []string{"find_scheduled_task", "findOne_scheduled_task", "run_scheduled_task"},
+	})
+
+	do := func(method, path string, auth bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(""))
+		req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+		if auth {
+			req.Header.Set("X-Test-User", "1")
+			req.Header.Set(echo.HeaderAuthorization, "Test")
+		}
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/api/v1/scheduled-tasks", false)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = do(http.MethodGet, "/api/v1/scheduled-tasks", true)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	list := scheduler.ListJSONResponse{}
+	require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Tasks, 1)
+	assert.Equal(t, "sync_products", list.Tasks[0].Name)
+	assert.Equal(t, "2023-07-16T03:00:00Z", list.Tasks[0].NextRunAt.UTC().Format(time.RFC3339))
+	assert.Nil(t, list.Tasks[0].LastRunAt)
+
+	rec = do(http.MethodPost, "/api/v1/scheduled-tasks/sync_products/run", true)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	found := scheduler.FindOneJSONResponse{}
+	require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &found))
+	assert.Equal(t, int64(1), found.Task.Runs)
+	assert.NotNil(t, found.Task.LastRunAt)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&runs))
+
+	rec = do(http.MethodGet, "/api/v1/scheduled-tasks/sync_products", true)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = do(http.MethodPost, "/api/v1/scheduled-tasks/unknown/run", true)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
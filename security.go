@@ -0,0 +1,363 @@
+package bolo
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	CSPModeOff        = "off"
+	CSPModeReportOnly = "report-only"
+	CSPModeEnforce    = "enforce"
+
+	// Replaced by the request nonce in the policy sources
+	CSPNoncePlaceholder = "'nonce'"
+)
+
+// randomSigningSecrets - Random signing secret of each app without configured secrets
+var randomSigningSecrets sync.Map
+
+// SigningSecret - Secret of the name configuration, with fallback to SESSION_SECRET. Without both the app
+// random secret is returned, generated and logged with one warning on the first call. The signatures with
+// the random secret are only valid in this process
+func SigningSecret(app App, name string) ([]byte, error) {
+	cfg := app.GetConfiguration()
+
+	if secret := cfg.GetF(name, cfg.Get(SESSION_SECRET)); secret != "" {
+		return []byte(secret), nil
+	}
+
+	if secret, ok := randomSigningSecrets.Load(app); ok {
+		return secret.([]byte), nil
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	stored, loaded := randomSigningSecrets.LoadOrStore(app, secret)
+	if !loaded {
+		app.GetLogger().Warn("signing secret not configured, using one random secret valid only in this process",
+			zap.String("configuration", name), zap.String("fallback", SESSION_SECRET))
+	}
+
+	return stored.([]byte), nil
+}
+
+// DefaultCSPPolicy - Used if CSP_POLICY is empty, inline scripts need the cspNonce
+var DefaultCSPPolicy = "default-src 'self'; script-src 'self' 'nonce'; style-src 'self' 'unsafe-inline'; img-src 'self' data: https:; font-src 'self' data:; object-src 'none'; base-uri 'self'; frame-ancestors 'self'; form-action 'self'"
+
+// CSP - Content-Security-Policy builder
+type CSP struct {
+	directives map[string][]string
+	order      []string
+}
+
+func NewCSP() *CSP {
+	return &CSP{directives: make(map[string][]string)}
+}
+
+// ParseCSP - Parse one policy string like: default-src 'self'; img-src *
+func ParseCSP(policy string) *CSP {
+	csp := NewCSP()
+
+	for _, item := range strings.Split(policy, ";") {
+		fields := strings.Fields(item)
+		if len(fields) == 0 {
+			continue
+		}
+
+		csp.Add(fields[0], fields[1:]...)
+	}
+
+	return csp
+}
+
+// Add - Add sources to one directive
+func (p *CSP) Add(directive string, sources ...string) *CSP {
+	directive = strings.ToLower(directive)
+
+	if _, ok := p.directives[directive]; !ok {
+		p.order = append(p.order, directive)
+		p.directives[directive] = []string{}
+	}
+
+	for _, s := range sources {
+		found := false
+		for _, current := range p.directives[directive] {
+			if current == s {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			p.directives[directive] = append(p.directives[directive], s)
+		}
+	}
+
+	return p
+}
+
+// Set - Replace the directive sources
+func (p *CSP) Set(directive string, sources ...string) *CSP {
+	p.Remove(directive)
+	return p.Add(directive, sources...)
+}
+
+func (p *CSP) Remove(directive string) *CSP {
+	directive = strings.ToLower(directive)
+
+	if _, ok := p.directives[directive]; !ok {
+		return p
+	}
+
+	delete(p.directives, directive)
+	for i, d := range p.order {
+		if d == directive {
+			p.order = append(p.order[:i], p.order[i+1:]...)
+			break
+		}
+	}
+
+	return p
+}
+
+func (p *CSP) Get(directive string) []string {
+	return p.directives[strings.ToLower(directive)]
+}
+
+// Build - Returns the header value with the nonce placeholders replaced
+func (p *CSP) Build(nonce string) string {
+	parts := make([]string, 0, len(p.order))
+
+	for _, d := range p.order {
+		sources := make([]string, 0, len(p.directives[d]))
+		for _, s := range p.directives[d] {
+			if s == CSPNoncePlaceholder {
+				if nonce == "" {
+					continue
+				}
+				s = "'nonce-" + nonce + "'"
+			}
+			sources = append(sources, s)
+		}
+
+		if len(sources) == 0 {
+			parts = append(parts, d)
+		} else {
+			parts = append(parts, d+" "+strings.Join(sources, " "))
+		}
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// SecurityHeaders - Security response headers configuration
+type SecurityHeaders struct {
+	// Strict-Transport-Security max-age in seconds, only sent in https requests. 0 to disable
+	HSTSMaxAge            int
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	ContentTypeNosniff    bool
+	ReferrerPolicy        string
+	PermissionsPolicy     string
+	FrameOptions          string
+	// CSP mode: off, report-only or enforce
+	CSPMode string
+	CSP     *CSP
+	// Path of the violation reports endpoint, empty to disable
+	CSPReportPath string
+}
+
+// NewSecurityHeadersFromConfiguration - Build the security headers from the app configuration
+func NewSecurityHeadersFromConfiguration(app App) *SecurityHeaders {
+	cfg := app.GetConfiguration()
+
+	h := SecurityHeaders{
+		HSTSMaxAge:            cfg.GetIntF(HSTS_MAX_AGE, 15552000),
+		HSTSIncludeSubdomains: cfg.GetBool(HSTS_INCLUDE_SUBDOMAINS),
+		HSTSPreload:           cfg.GetBool(HSTS_PRELOAD),
+		ContentTypeNosniff:    true,
+		ReferrerPolicy:        cfg.GetF(REFERRER_POLICY, "strict-origin-when-cross-origin"),
+		PermissionsPolicy:     cfg.GetF(PERMISSIONS_POLICY, "camera=(), microphone=(), geolocation=(), interest-cohort=()"),
+		FrameOptions:          cfg.GetF(FRAME_OPTIONS, "SAMEORIGIN"),
+		CSPMode:               cfg.GetF(CSP_MODE, CSPModeReportOnly),
+		CSP:                   ParseCSP(cfg.GetF(CSP_POLICY, DefaultCSPPolicy)),
+		CSPReportPath:         cfg.GetF(CSP_REPORT_PATH, "/csp-report"),
+	}
+
+	if h.CSPReportPath != "" && len(h.CSP.Get("report-uri")) == 0 {
+		h.CSP.Add("report-uri", h.CSPReportPath)
+	}
+
+	return &h
+}
+
+// SecurityHeadersMiddleware - Set the security headers and the Content-Security-Policy with one nonce per request
+func SecurityHeadersMiddleware(app App) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			s := app.GetSecurityHeaders()
+			if s == nil {
+				return next(c)
+			}
+
+			h := c.Response().Header()
+
+			if s.ContentTypeNosniff {
+				h.Set("X-Content-Type-Options", "nosniff")
+			}
+			if s.ReferrerPolicy != "" {
+				h.Set("Referrer-Policy", s.ReferrerPolicy)
+			}
+			if s.PermissionsPolicy != "" {
+				h.Set("Permissions-Policy", s.PermissionsPolicy)
+			}
+			if s.FrameOptions != "" {
+				h.Set(echo.HeaderXFrameOptions, s.FrameOptions)
+			}
+			if s.HSTSMaxAge > 0 && c.Scheme() == "https" {
+				h.Set(echo.HeaderStrictTransportSecurity, s.hsts())
+			}
+
+			switch s.CSPMode {
+			case CSPModeEnforce:
+				h.Set(echo.HeaderContentSecurityPolicy, s.CSP.Build(GetCSPNonce(c)))
+			case CSPModeReportOnly:
+				h.Set(echo.HeaderContentSecurityPolicyReportOnly, s.CSP.Build(GetCSPNonce(c)))
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func (s *SecurityHeaders) hsts() string {
+	v := "max-age=" + strconv.Itoa(s.HSTSMaxAge)
+	if s.HSTSIncludeSubdomains {
+		v += "; includeSubDomains"
+	}
+	if s.HSTSPreload {
+		v += "; preload"
+	}
+
+	return v
+}
+
+// GetCSPNonce - Returns the request CSP nonce, used in inline scripts with the cspNonce template function
+func GetCSPNonce(c echo.Context) string {
+	if nonce, ok := c.Get("csp_nonce").(string); ok {
+		return nonce
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+
+	nonce := base64.StdEncoding.EncodeToString(b)
+	c.Set("csp_nonce", nonce)
+
+	return nonce
+}
+
+// CSPReportHandler - Log the CSP violation reports with the request logger.
+// Supports the report-uri (application/csp-report) and Reporting API (application/reports+json) formats
+func CSPReportHandler(c echo.Context) error {
+	l := GetLogger(c)
+
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, 64*1024))
+	if err != nil {
+		return &HTTPError{Code: http.StatusBadRequest, Message: "Invalid report", Internal: err}
+	}
+
+	reports := []map[string]interface{}{}
+
+	legacy := struct {
+		Report map[string]interface{} `json:"csp-report"`
+	}{}
+
+	if err := json.Unmarshal(body, &legacy); err == nil && legacy.Report != nil {
+		reports = append(reports, legacy.Report)
+	} else {
+		items := []struct {
+			Type string                 `json:"type"`
+			Body map[string]interface{} `json:"body"`
+		}{}
+
+		if err := json.Unmarshal(body, &items); err != nil {
+			return &HTTPError{Code: http.StatusBadRequest, Message: "Invalid report", Internal: err}
+		}
+
+		for _, item := range items {
+			if item.Type == "csp-violation" && item.Body != nil {
+				reports = append(reports, item.Body)
+			}
+		}
+	}
+
+	for _, r := range reports {
+		l.Warn("CSP violation",
+			zap.String("documentURI", reportField(r, "document-uri", "documentURL")),
+			zap.String("violatedDirective", reportField(r, "violated-directive", "effectiveDirective")),
+			zap.String("blockedURI", reportField(r, "blocked-uri", "blockedURL")),
+			zap.String("sourceFile", reportField(r, "source-file", "sourceFile")),
+			zap.Any("lineNumber", firstNotNil(r["line-number"], r["lineNumber"])),
+			zap.String("disposition", reportField(r, "disposition", "disposition")),
+			zap.String("userAgent", c.Request().UserAgent()),
+		)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// BindSecurityRoutes - Register the CSP report endpoint
+func BindSecurityRoutes(app App) error {
+	s := app.GetSecurityHeaders()
+	if !s.cspReportEnabled() {
+		return nil
+	}
+
+	app.GetRouter().POST(s.CSPReportPath, CSPReportHandler)
+
+	return nil
+}
+
+func (s *SecurityHeaders) cspReportEnabled() bool {
+	return s != nil && s.CSPReportPath != "" && s.CSPMode != CSPModeOff
+}
+
+func cspNonceTemplateFunc(c echo.Context) string {
+	return GetCSPNonce(c)
+}
+
+func reportField(r map[string]interface{}, legacyName, name string) string {
+	for _, key := range []string{legacyName, name} {
+		if v, ok := r[key].(string); ok {
+			return v
+		}
+	}
+
+	return ""
+}
+
+func firstNotNil(values ...interface{}) interface{} {
+	for _, v := range values {
+		if v != nil {
+			return v
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package bolo_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	bolo "github.com/go-bolo/bolo"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestCSP(t *testing.T) {
+	csp := bolo.ParseCSP("default-src 'self'; script-src 'self' 'nonce'; upgrade-insecure-requests")
+	csp.Add("script-src", "https://cdn.example.com", "'self'")
+	csp.Set("img-src", "*")
+
+	assert.Equal(t, "default-src 'self'; script-src 'self' 'nonce-abc' https://cdn.example.com; upgrade-insecure-requests; img-src *", csp.Build("abc"))
+	assert.Equal(t, "default-src 'self'; script-src 'self' https://cdn.example.com; upgrade-insecure-requests; img-src *", csp.Build(""))
+
+	csp.Remove("img-src")
+	assert.Nil(t, csp.Get("img-src"))
+}
+
+func TestSigningSecret(t *testing.T) {
+	// the storage of new apps already use the random secret, the warning is logged once in NewApp
+	app := GetTestApp()
+	core, logs := observer.New(zapcore.WarnLevel)
+	app.SetLogger(zap.New(core))
+
+	secret, err := bolo.SigningSecret(app, "IMAGES_SIGNING_SECRET")
+	require.Nil(t, err)
+	assert.Len(t, secret, 32)
+
+	other, err := bolo.SigningSecret(app, "STORAGE_SIGNING_SECRET")
+	require.Nil(t, err)
+	assert.Equal(t, secret, other)
+	assert.Equal(t, 0, logs.Len())
+
+	other, _ = bolo.SigningSecret(GetTestApp(), "IMAGES_SIGNING_SECRET")
+	assert.NotEqual(t, secret, other)
+
+	t.Setenv("SESSION_SECRET", "session")
+	secret, _ = bolo.SigningSecret(app, "IMAGES_SIGNING_SECRET")
+	assert.Equal(t, "session", string(secret))
+
+	t.Setenv("IMAGES_SIGNING_SECRET", "images")
+	secret, _ = bolo.SigningSecret(app, "IMAGES_SIGNING_SECRET")
+	assert.Equal(t, "images", string(secret))
+}
+
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	os.Setenv("CSP_MODE", "enforce")
+	defer os.Unsetenv("CSP_MODE")
+
+	app := GetTestApp()
+
+	var nonce string
+	app.GetRouter().GET("/page", func(c echo.Context) error {
+		nonce = bolo.GetCSPNonce(c)
+		return c.String(http.StatusOK, "ok")
+	})
+
+	err := app.Bootstrap()
+	assert.Nil(t, err)
+
+	t.Run("should set the security headers with the request nonce", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/page", nil)
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+		assert.Equal(t, "strict-origin-when-cross-origin", rec.Header().Get("Referrer-Policy"))
+		assert.Equal(t, "SAMEORIGIN", rec.Header().Get("X-Frame-Options"))
+		assert.NotEmpty(t, rec.Header().Get("Permissions-Policy"))
+		// only in https requests:
+		assert.Empty(t, rec.Header().Get("Strict-Transport-Security"))
+
+		csp := rec.Header().Get("Content-Security-Policy")
+		assert.NotEmpty(t, nonce)
+		assert.Contains(t, csp, "script-src 'self' 'nonce-"+nonce+"'")
+		assert.Contains(t, csp, "report-uri /csp-report")
+		assert.Empty(t, rec.Header().Get("Content-Security-Policy-Report-Only"))
+	})
+
+	t.Run("should use one nonce per request", func(t *testing.T) {
+		first := nonce
+
+		req := httptest.NewRequest(http.MethodGet, "/page", nil)
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		assert.NotEqual(t, first, nonce)
+	})
+
+	t.Run("should set HSTS in https requests", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/page", nil)
+		req.Header.Set("X-Forwarded-Proto", "https")
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		assert.Equal(t, "max-age=15552000", rec.Header().Get("Strict-Transport-Security"))
+	})
+
+	t.Run("should accept csp reports without the CSRF token", func(t *testing.T) {
+		body := `{"csp-report":{"document-uri":"http://localhost:8080/page","violated-directive":"script-src","blocked-uri":"inline"}}`
+		req := httptest.NewRequest(http.MethodPost, "/csp-report", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, "application/csp-report")
+		req.AddCookie(&http.Cookie{Name: "bolo_session", Value: "abc"})
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		body = `[{"type":"csp-violation","body":{"documentURL":"http://localhost:8080/page","effectiveDirective":"img-src"}}]`
+		req = httptest.NewRequest(http.MethodPost, "/csp-report", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, "application/reports+json")
+		rec = httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+	})
+}
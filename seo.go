@@ -0,0 +1,313 @@
+package bolo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// SEO - Structured SEO data used to render the page head tags with the seoTags template function.
+// Empty fields fall back to the request Metadata ("title", "description") and then to the site defaults
+type SEO struct {
+	Title string
+	// fmt pattern used to build the page title. Ex: "%s | My site"
+	TitleTemplate string
+	Description   string
+	// Canonical URL, relative paths are prefixed with the request base url
+	Canonical string
+	Image     string
+	SiteName  string
+	// OpenGraph type, default is "website"
+	Type string
+	// Robots directives. Ex: noindex, nofollow
+	Robots []string
+	// Extra or overriden OpenGraph properties without the "og:" prefix. Ex: image:width, article:author
+	OpenGraph map[string]string
+	// Twitter card type, default is "summary_large_image" with image and "summary" without
+	TwitterCard string
+	// Twitter account of the site. Ex: @bolo
+	TwitterSite string
+	// Extra or overriden Twitter properties without the "twitter:" prefix
+	Twitter map[string]string
+	// JSON-LD structured data objects
+	JSONLD []interface{}
+}
+
+// NewSEOFromConfiguration - Build the site-wide SEO defaults from the app configuration
+func NewSEOFromConfiguration(app App) *SEO {
+	cfg := app.GetConfiguration()
+
+	seo := &SEO{
+		SiteName:      cfg.Get(SITE_NAME),
+		TitleTemplate: cfg.Get(SEO_TITLE_TEMPLATE),
+		Description:   cfg.Get(SEO_DESCRIPTION),
+		Image:         cfg.Get(SEO_IMAGE),
+		TwitterSite:   cfg.Get(SEO_TWITTER_SITE),
+		Type:          "website",
+	}
+
+	if seo.TitleTemplate == "" && seo.SiteName != "" {
+		seo.TitleTemplate = "%s | " + seo.SiteName
+	}
+
+	if robots := cfg.Get(SEO_ROBOTS); robots != "" {
+		for _, r := range strings.Split(robots, ",") {
+			seo.Robots = append(seo.Robots, strings.TrimSpace(r))
+		}
+	}
+
+	return seo
+}
+
+// WithDefaults - Returns a copy with the empty fields filled with the defaults values
+func (s *SEO) WithDefaults(defaults *SEO) *SEO {
+	r := *s
+
+	r.Title = firstNonEmpty(s.Title, defaults.Title)
+	r.TitleTemplate = firstNonEmpty(s.TitleTemplate, defaults.TitleTemplate)
+	r.Description = firstNonEmpty(s.Description, defaults.Description)
+	r.Canonical = firstNonEmpty(s.Canonical, defaults.Canonical)
+	r.Image = firstNonEmpty(s.Image, defaults.Image)
+	r.SiteName = firstNonEmpty(s.SiteName, defaults.SiteName)
+	r.Type = firstNonEmpty(s.Type, defaults.Type)
+	r.TwitterCard = firstNonEmpty(s.TwitterCard, defaults.TwitterCard)
+	r.TwitterSite = firstNonEmpty(s.TwitterSite, defaults.TwitterSite)
+
+	if len(s.Robots) == 0 {
+		r.Robots = defaults.Robots
+	}
+
+	r.OpenGraph = make(map[string]string)
+	r.Twitter = make(map[string]string)
+	for _, m := range []map[string]string{defaults.OpenGraph, s.OpenGraph} {
+		for k, v := range m {
+			r.OpenGraph[k] = v
+		}
+	}
+	for _, m := range []map[string]string{defaults.Twitter, s.Twitter} {
+		for k, v := range m {
+			r.Twitter[k] = v
+		}
+	}
+
+	r.JSONLD = append(append([]interface{}{}, defaults.JSONLD...), s.JSONLD...)
+
+	return &r
+}
+
+func (s *SEO) SetOpenGraph(property, content string) {
+	if s.OpenGraph == nil {
+		s.OpenGraph = make(map[string]string)
+	}
+
+	s.OpenGraph[property] = content
+}
+
+func (s *SEO) SetTwitter(name, content string) {
+	if s.Twitter == nil {
+		s.Twitter = make(map[string]string)
+	}
+
+	s.Twitter[name] = content
+}
+
+func (s *SEO) AddJSONLD(data interface{}) {
+	s.JSONLD = append(s.JSONLD, data)
+}
+
+// NoIndex - Set the robots noindex and nofollow directives
+func (s *SEO) NoIndex() {
+	s.Robots = []string{"noindex", "nofollow"}
+}
+
+// GetSEO - Returns the request SEO data, used by actions to set the page title, description, structured data...
+func GetSEO(c echo.Context) *SEO {
+	if seo, ok := c.Get("seo").(*SEO); ok && seo != nil {
+		return seo
+	}
+
+	seo := &SEO{}
+	SetSEO(c, seo)
+
+	return seo
+}
+
+func SetSEO(c echo.Context, seo *SEO) {
+	c.Set("seo", seo)
+}
+
+// GetResolvedSEO - Returns the request SEO data merged with the request metadata and the site defaults
+func GetResolvedSEO(c echo.Context) *SEO {
+	metadata := GetMetadata(c)
+
+	seo := GetSEO(c).WithDefaults(&SEO{
+		Title:       metadata.Get("title"),
+		Description: metadata.Get("description"),
+	})
+
+	return seo.WithDefaults(GetApp(c).GetSEODefaults())
+}
+
+// GetCanonicalURL - Returns the request canonical url built with the base url, without query params
+func GetCanonicalURL(c echo.Context) string {
+	seo := GetResolvedSEO(c)
+	if seo.Canonical != "" {
+		return absoluteURL(c, seo.Canonical)
+	}
+
+	return absoluteURL(c, c.Request().URL.Path)
+}
+
+// GetPageTitle - Returns the page title formated with the title template or the site name
+func GetPageTitle(c echo.Context) string {
+	return GetResolvedSEO(c).GetPageTitle()
+}
+
+func (s *SEO) GetPageTitle() string {
+	if s.Title == "" {
+		return s.SiteName
+	}
+
+	if strings.Contains(s.TitleTemplate, "%s") {
+		return fmt.Sprintf(s.TitleTemplate, s.Title)
+	}
+
+	return s.Title
+}
+
+// SEOTags - Render the title, meta, link and JSON-LD tags for the layout head
+func SEOTags(c echo.Context) template.HTML {
+	seo := GetResolvedSEO(c)
+	l := GetLogger(c)
+
+	title := seo.GetPageTitle()
+	canonical := GetCanonicalURL(c)
+	image := ""
+	if seo.Image != "" {
+		image = absoluteURL(c, seo.Image)
+	}
+
+	var b bytes.Buffer
+
+	if title != "" {
+		b.WriteString("<title>" + html.EscapeString(title) + "</title>\n")
+	}
+	writeMetaTag(&b, "name", "description", seo.Description)
+	b.WriteString(`<link rel="canonical" href="` + html.EscapeString(canonical) + `">` + "\n")
+	if len(seo.Robots) > 0 {
+		writeMetaTag(&b, "name", "robots", strings.Join(seo.Robots, ", "))
+	}
+
+	og := map[string]string{
+		"title":       title,
+		"description": seo.Description,
+		"url":         canonical,
+		"type":        firstNonEmpty(seo.Type, "website"),
+		"site_name":   seo.SiteName,
+		"image":       image,
+		"locale":      strings.Replace(GetLocale(c), "-", "_", 1),
+	}
+	for k, v := range seo.OpenGraph {
+		og[k] = v
+	}
+	for _, k := range sortedKeys(og) {
+		property := "og:" + k
+		if isOpenGraphNamespace(k) {
+			property = k
+		}
+		writeMetaTag(&b, "property", property, og[k])
+	}
+
+	card := seo.TwitterCard
+	if card == "" {
+		card = "summary"
+		if image != "" {
+			card = "summary_large_image"
+		}
+	}
+
+	twitter := map[string]string{
+		"card":        card,
+		"site":        seo.TwitterSite,
+		"title":       title,
+		"description": seo.Description,
+		"image":       image,
+	}
+	for k, v := range seo.Twitter {
+		twitter[k] = v
+	}
+	for _, k := range sortedKeys(twitter) {
+		writeMetaTag(&b, "name", "twitter:"+k, twitter[k])
+	}
+
+	for _, data := range seo.JSONLD {
+		// json.Marshal escapes <, > and & so the content is safe inside the script tag
+		j, err := json.Marshal(data)
+		if err != nil {
+			l.Error("SEOTags error on marshal JSON-LD", zap.Error(err))
+			continue
+		}
+
+		b.WriteString(`<script type="application/ld+json">` + string(j) + "</script>\n")
+	}
+
+	return template.HTML(b.String())
+}
+
+// object type properties like article:author are not prefixed with og:
+func isOpenGraphNamespace(property string) bool {
+	for _, ns := range []string{"article:", "book:", "profile:", "music:", "video:"} {
+		if strings.HasPrefix(property, ns) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func writeMetaTag(b *bytes.Buffer, attr, name, content string) {
+	if content == "" {
+		return
+	}
+
+	b.WriteString(`<meta ` + attr + `="` + html.EscapeString(name) + `" content="` + html.EscapeString(content) + `">` + "\n")
+}
+
+func absoluteURL(c echo.Context, url string) string {
+	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") || strings.HasPrefix(url, "//") {
+		return url
+	}
+
+	if !strings.HasPrefix(url, "/") {
+		url = "/" + url
+	}
+
+	return strings.TrimSuffix(GetBaseURL(c), "/") + url
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,76 @@
+package bolo_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	bolo "github.com/go-bolo/bolo"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSEOTags(t *testing.T) {
+	app := GetTestApp()
+	app.SetSEODefaults(&bolo.SEO{
+		SiteName:      "Bolo",
+		TitleTemplate: "%s - Bolo",
+		Description:   "Default description",
+		TwitterSite:   "@bolo",
+		Robots:        []string{"index", "follow"},
+	})
+	err := app.Bootstrap()
+	assert.Nil(t, err)
+
+	newContext := func() echo.Context {
+		req := httptest.NewRequest(http.MethodGet, "/urls/1?page=2", nil)
+		c := app.GetRouter().NewContext(req, httptest.NewRecorder())
+		bolo.SetDefaultValues(c, app)
+		bolo.SetBaseURL(c, "https://example.com")
+		return c
+	}
+
+	t.Run("should use the site defaults", func(t *testing.T) {
+		c := newContext()
+		html := string(bolo.SEOTags(c))
+
+		assert.Contains(t, html, "<title>Bolo</title>")
+		assert.Contains(t, html, `<meta name="description" content="Default description">`)
+		assert.Contains(t, html, `<link rel="canonical" href="https://example.com/urls/1">`)
+		assert.Contains(t, html, `<meta name="robots" content="index, follow">`)
+		assert.Contains(t, html, `<meta property="og:type" content="website">`)
+		assert.Contains(t, html, `<meta name="twitter:card" content="summary">`)
+		assert.Contains(t, html, `<meta name="twitter:site" content="@bolo">`)
+	})
+
+	t.Run("should fall back to the request metadata", func(t *testing.T) {
+		c := newContext()
+		bolo.GetMetadata(c).Set("title", "Not found")
+
+		assert.Contains(t, string(bolo.SEOTags(c)), "<title>Not found - Bolo</title>")
+	})
+
+	t.Run("should render the data set by actions", func(t *testing.T) {
+		c := newContext()
+		seo := bolo.GetSEO(c)
+		seo.Title = `Google <search>`
+		seo.Description = "Search engine"
+		seo.Image = "/public/google.png"
+		seo.Type = "article"
+		seo.NoIndex()
+		seo.SetOpenGraph("article:author", "Larry")
+		seo.AddJSONLD(map[string]string{"@context": "https://schema.org", "@type": "Article", "headline": "</script>"})
+
+		html := string(bolo.SEOTags(c))
+
+		assert.Contains(t, html, "<title>Google &lt;search&gt; - Bolo</title>")
+		assert.Contains(t, html, `<meta name="robots" content="noindex, nofollow">`)
+		assert.Contains(t, html, `<meta property="og:image" content="https://example.com/public/google.png">`)
+		assert.Contains(t, html, `<meta property="og:type" content="article">`)
+		assert.Contains(t, html, `<meta property="article:author" content="Larry">`)
+		assert.Contains(t, html, `<meta name="twitter:card" content="summary_large_image">`)
+		assert.Contains(t, html, `<script type="application/ld+json">`)
+		assert.False(t, strings.Contains(html, `"</script>"`))
+	})
+}
@@ -82,7 +82,22 @@ type ValidationFieldError struct {
 	Message string `json:"message"`
 }
 
+// commitError - Write the error response once and return the error, used by the middlewares that need the final
+// status. The outer middlewares still get the error and the router error handler skips the committed response
+func commitError(c echo.Context, err error) error {
+	if err != nil && !c.Response().Committed {
+		c.Error(err)
+	}
+
+	return err
+}
+
 func CustomHTTPErrorHandler(err error, c echo.Context) {
+	// already written by one middleware with commitError
+	if c.Response().Committed {
+		return
+	}
+
 	l := GetLogger(c)
 	accept := GetAccept(c)
 
@@ -121,6 +136,10 @@ func CustomHTTPErrorHandler(err error, c echo.Context) {
 		forbiddenErrorHandler(err, c)
 	case 404:
 		notFoundErrorHandler(err, c)
+	case 406:
+		notAcceptableErrorHandler(err, c)
+	case 429:
+		tooManyRequestsErrorHandler(err, c)
 	case 500:
 		internalServerErrorHandler(err, c)
 	default:
@@ -200,6 +219,53 @@ func notFoundErrorHandler(err error, c echo.Context) error {
 	}
 }
 
+func notAcceptableErrorHandler(err error, c echo.Context) error {
+	accept := GetAccept(c)
+	metadata := GetMetadata(c)
+	l := GetLogger(c)
+
+	l.Debug("notAcceptableErrorHandler running", zap.Error(err), zap.String("accept", accept), zap.String("path", c.Path()), zap.String("method", c.Request().Method))
+
+	switch accept {
+	case "text/html":
+		metadata.Set("title", "Not acceptable")
+
+		if err := c.Render(http.StatusNotAcceptable, "406", &TemplateCTX{
+			Ctx: c,
+		}); err != nil {
+			l.Error("notAcceptableErrorHandler error rendering template", zap.Error(err), zap.String("accept", accept), zap.String("path", c.Path()), zap.String("method", c.Request().Method))
+		}
+		return nil
+	default:
+		c.JSON(http.StatusNotAcceptable, &HTTPError{Code: http.StatusNotAcceptable, Message: "Not Acceptable"})
+		return nil
+	}
+}
+
+// tooManyRequestsErrorHandler - The Retry-After header is set by the rate limiters before returning the error
+func tooManyRequestsErrorHandler(err error, c echo.Context) error {
+	accept := GetAccept(c)
+	metadata := GetMetadata(c)
+	l := GetLogger(c)
+
+	l.Debug("tooManyRequestsErrorHandler running", zap.Error(err), zap.String("accept", accept), zap.String("path", c.Path()), zap.String("method", c.Request().Method))
+
+	switch accept {
+	case "text/html":
+		metadata.Set("title", "Too many requests")
+
+		if err := c.Render(http.StatusTooManyRequests, "429", &TemplateCTX{
+			Ctx: c,
+		}); err != nil {
+			l.Error("tooManyRequestsErrorHandler error rendering template", zap.Error(err), zap.String("accept", accept), zap.String("path", c.Path()), zap.String("method", c.Request().Method))
+		}
+		return nil
+	default:
+		c.JSON(http.StatusTooManyRequests, &HTTPError{Code: http.StatusTooManyRequests, Message: "Too Many Requests"})
+		return nil
+	}
+}
+
 func validationError(ve validator.ValidationErrors, err error, c echo.Context) error {
 	accept := GetAccept(c)
 	l := GetLogger(c)
@@ -215,7 +281,7 @@ func validationError(ve validator.ValidationErrors, err error, c echo.Context) e
 			el.Field = err.Field()
 			el.Tag = err.Tag()
 			el.Value = err.Param()
-			el.Message = err.Error()
+			el.Message = TranslateValidationError(c, err)
 			resp.Errors = append(resp.Errors, &el)
 		}
 	}
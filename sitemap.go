@@ -0,0 +1,449 @@
+package bolo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	// SitemapMaxURLs - Max number of urls in one sitemap file, sources with more urls are splited
+	SitemapMaxURLs = 50000
+	// SitemapMaxSize - Max uncompressed size in bytes of one sitemap file, sources with bigger files are splited
+	SitemapMaxSize = 50 * 1024 * 1024
+)
+
+// SitemapURL - One sitemap entry. Loc can be one path that will be prefixed with the request base url
+type SitemapURL struct {
+	Loc        string
+	LastMod    *time.Time
+	ChangeFreq string
+	// Priority between 0.1 and 1, 0 omits the priority
+	Priority float64
+}
+
+// SitemapSource - Sitemap urls provider registered by resources and plugins
+type SitemapSource interface {
+	GetSitemapURLs(app App, yield func(u *SitemapURL) error) error
+}
+
+// SitemapSourceFunc - Function adapter for SitemapSource
+type SitemapSourceFunc func(app App, yield func(u *SitemapURL) error) error
+
+func (f SitemapSourceFunc) GetSitemapURLs(app App, yield func(u *SitemapURL) error) error {
+	return f(app, yield)
+}
+
+// RoutesSitemapSource - Add the GET routes with Sitemap configuration and without params in the sitemap
+func RoutesSitemapSource(app App, yield func(u *SitemapURL) error) error {
+	routes := []*Route{}
+	for _, r := range app.GetRoutes() {
+		if r.Sitemap != nil && r.Method == http.MethodGet && !strings.Contains(r.Path, ":") && !strings.Contains(r.Path, "*") {
+			routes = append(routes, r)
+		}
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Path < routes[j].Path
+	})
+
+	for _, r := range routes {
+		u := *r.Sitemap
+		if u.Loc == "" {
+			u.Loc = r.Path
+		}
+
+		if err := yield(&u); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type sitemapURLXML struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod,omitempty"`
+	ChangeFreq string `xml:"changefreq,omitempty"`
+	Priority   string `xml:"priority,omitempty"`
+}
+
+type sitemapIndexXML struct {
+	XMLName  xml.Name          `xml:"sitemapindex"`
+	XMLNS    string            `xml:"xmlns,attr"`
+	Sitemaps []sitemapEntryXML `xml:"sitemap"`
+}
+
+type sitemapEntryXML struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+const sitemapXMLNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// Sitemap - Sitemap files generated from the registered sources
+type Sitemap struct {
+	Index []byte
+	// Sitemap files by name. Ex: urls-1.xml
+	Files map[string][]byte
+	// Gzipped sitemap files by the same names, only generated when the sitemap is built with gzip
+	GzipFiles map[string][]byte
+	BaseURL   string
+	CreatedAt time.Time
+}
+
+// BuildSitemap - Generate the sitemap index and the sitemap files from all the app sitemap sources
+func BuildSitemap(app App, baseURL string, gzipFiles bool) (*Sitemap, error) {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	sources := app.GetSitemapSources()
+
+	names := []string{}
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	s := &Sitemap{
+		Files:     make(map[string][]byte),
+		GzipFiles: make(map[string][]byte),
+		BaseURL:   baseURL,
+		CreatedAt: app.GetClock().Now(),
+	}
+
+	index := sitemapIndexXML{XMLNS: sitemapXMLNS}
+	ext := ".xml"
+	if gzipFiles {
+		ext = ".xml.gz"
+	}
+
+	for _, name := range names {
+		files := []*sitemapFileWriter{}
+		current := newSitemapFileWriter()
+
+		err := sources[name].GetSitemapURLs(app, func(u *SitemapURL) error {
+			entry, err := marshalSitemapURL(sitemapURLToXML(baseURL, u))
+			if err != nil {
+				return err
+			}
+
+			if current.count > 0 && (current.count >= SitemapMaxURLs || current.size()+len(entry) > SitemapMaxSize) {
+				files = append(files, current)
+				current = newSitemapFileWriter()
+			}
+
+			current.add(entry, u.LastMod)
+
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("BuildSitemap: error on get urls from source %s: %w", name, err)
+		}
+
+		if current.count > 0 || len(files) == 0 {
+			files = append(files, current)
+		}
+
+		for i, file := range files {
+			fileName := name + "-" + strconv.Itoa(i+1)
+			data := file.bytes()
+
+			s.Files[fileName+".xml"] = data
+
+			if gzipFiles {
+				gzipped, err := gzipSitemap(data)
+				if err != nil {
+					return nil, fmt.Errorf("BuildSitemap: error on gzip file %s: %w", fileName, err)
+				}
+				s.GzipFiles[fileName+".xml"] = gzipped
+			}
+
+			entry := sitemapEntryXML{Loc: baseURL + "/sitemaps/" + fileName + ext}
+			if file.lastMod != nil {
+				entry.LastMod = file.lastMod.UTC().Format(time.RFC3339)
+			}
+			index.Sitemaps = append(index.Sitemaps, entry)
+		}
+	}
+
+	data, err := marshalSitemapXML(&index)
+	if err != nil {
+		return nil, err
+	}
+	s.Index = data
+
+	return s, nil
+}
+
+func sitemapURLToXML(baseURL string, u *SitemapURL) sitemapURLXML {
+	loc := u.Loc
+	if !strings.HasPrefix(loc, "http://") && !strings.HasPrefix(loc, "https://") {
+		if !strings.HasPrefix(loc, "/") {
+			loc = "/" + loc
+		}
+		loc = baseURL + loc
+	}
+
+	x := sitemapURLXML{
+		Loc:        loc,
+		ChangeFreq: u.ChangeFreq,
+	}
+
+	if u.LastMod != nil {
+		x.LastMod = u.LastMod.UTC().Format(time.RFC3339)
+	}
+
+	if u.Priority > 0 {
+		x.Priority = strconv.FormatFloat(u.Priority, 'f', 1, 64)
+	}
+
+	return x
+}
+
+const (
+	sitemapURLSetStart = xml.Header + `<urlset xmlns="` + sitemapXMLNS + `">`
+	sitemapURLSetEnd   = "</urlset>"
+)
+
+// sitemapFileWriter - One urlset file, the urls are encoded on add to check the file size
+type sitemapFileWriter struct {
+	buf     bytes.Buffer
+	count   int
+	lastMod *time.Time
+}
+
+func newSitemapFileWriter() *sitemapFileWriter {
+	w := &sitemapFileWriter{}
+	w.buf.WriteString(sitemapURLSetStart)
+	return w
+}
+
+func (w *sitemapFileWriter) add(entry []byte, lastMod *time.Time) {
+	w.buf.Write(entry)
+	w.count++
+
+	if lastMod != nil && (w.lastMod == nil || lastMod.After(*w.lastMod)) {
+		w.lastMod = lastMod
+	}
+}
+
+// size - File size with the closing tag
+func (w *sitemapFileWriter) size() int {
+	return w.buf.Len() + len(sitemapURLSetEnd)
+}
+
+func (w *sitemapFileWriter) bytes() []byte {
+	w.buf.WriteString(sitemapURLSetEnd)
+	return w.buf.Bytes()
+}
+
+func marshalSitemapURL(u sitemapURLXML) ([]byte, error) {
+	var b bytes.Buffer
+
+	err := xml.NewEncoder(&b).EncodeElement(u, xml.StartElement{Name: xml.Name{Local: "url"}})
+	if err != nil {
+		return nil, fmt.Errorf("error on encode sitemap url: %w", err)
+	}
+
+	return b.Bytes(), nil
+}
+
+func gzipSitemap(data []byte) ([]byte, error) {
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+func marshalSitemapXML(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+
+	err := xml.NewEncoder(&b).Encode(v)
+	if err != nil {
+		return nil, fmt.Errorf("error on encode sitemap xml: %w", err)
+	}
+
+	return b.Bytes(), nil
+}
+
+// SitemapHandlers - Sitemap and robots.txt request handlers with cache
+type SitemapHandlers struct {
+	App App
+	// Cache time to live, 0 disables the cache
+	CacheTTL time.Duration
+	Gzip     bool
+
+	mu      sync.Mutex
+	sitemap *Sitemap
+	// concurrent requests wait for the same build
+	builds singleflight.Group
+}
+
+func NewSitemapHandlers(app App) *SitemapHandlers {
+	cfg := app.GetConfiguration()
+
+	return &SitemapHandlers{
+		App:      app,
+		CacheTTL: time.Duration(cfg.GetInt64F(SITEMAP_CACHE_TTL, 3600)) * time.Second,
+		Gzip:     cfg.GetBool(SITEMAP_GZIP),
+	}
+}
+
+// BindSitemapRoutes - Register the /sitemap.xml, /sitemaps/:file and /robots.txt routes
+func BindSitemapRoutes(app App) error {
+	h := NewSitemapHandlers(app)
+	router := app.GetRouter()
+
+	if !app.GetConfiguration().GetBool(SITEMAP_DISABLE) {
+		router.GET("/sitemap.xml", h.Index)
+		router.GET("/sitemaps/:file", h.File)
+	}
+
+	router.GET("/robots.txt", h.Robots)
+
+	return nil
+}
+
+// getSitemap - Returns the cached sitemap or build one new sitemap outside the lock
+func (h *SitemapHandlers) getSitemap(c echo.Context) (*Sitemap, error) {
+	baseURL := strings.TrimSuffix(GetBaseURL(c), "/")
+
+	if s := h.getCachedSitemap(baseURL); s != nil {
+		return s, nil
+	}
+
+	v, err, _ := h.builds.Do(baseURL, func() (interface{}, error) {
+		s, err := BuildSitemap(h.App, baseURL, h.Gzip)
+		if err != nil {
+			return nil, err
+		}
+
+		h.mu.Lock()
+		h.sitemap = s
+		h.mu.Unlock()
+
+		return s, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*Sitemap), nil
+}
+
+func (h *SitemapHandlers) getCachedSitemap(baseURL string) *Sitemap {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.App.GetClock().Now()
+
+	if h.sitemap != nil && h.CacheTTL > 0 && h.sitemap.BaseURL == baseURL && now.Sub(h.sitemap.CreatedAt) < h.CacheTTL {
+		return h.sitemap
+	}
+
+	return nil
+}
+
+func (h *SitemapHandlers) Index(c echo.Context) error {
+	s, err := h.getSitemap(c)
+	if err != nil {
+		GetLogger(c).Error("SitemapHandlers.Index error on build sitemap", zap.Error(err))
+		return err
+	}
+
+	return h.blob(c, s.Index, false)
+}
+
+func (h *SitemapHandlers) File(c echo.Context) error {
+	name := c.Param("file")
+
+	gzipped := strings.HasSuffix(name, ".gz")
+	name = strings.TrimSuffix(name, ".gz")
+
+	s, err := h.getSitemap(c)
+	if err != nil {
+		GetLogger(c).Error("SitemapHandlers.File error on build sitemap", zap.Error(err))
+		return err
+	}
+
+	files := s.Files
+	if gzipped {
+		files = s.GzipFiles
+	}
+
+	data, ok := files[name]
+	if !ok {
+		return &HTTPError{Code: http.StatusNotFound, Message: "Not Found"}
+	}
+
+	return h.blob(c, data, gzipped)
+}
+
+func (h *SitemapHandlers) blob(c echo.Context, data []byte, gzipped bool) error {
+	if h.CacheTTL > 0 {
+		c.Response().Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(h.CacheTTL.Seconds())))
+	}
+
+	if !gzipped {
+		return c.Blob(http.StatusOK, echo.MIMEApplicationXMLCharsetUTF8, data)
+	}
+
+	return c.Blob(http.StatusOK, "application/gzip", data)
+}
+
+// Robots - Serve the robots.txt from the ROBOTS_TXT_FILE or the default rules with the sitemap url
+func (h *SitemapHandlers) Robots(c echo.Context) error {
+	cfg := h.App.GetConfiguration()
+
+	var content string
+
+	if file := cfg.Get(ROBOTS_TXT_FILE); file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			GetLogger(c).Error("SitemapHandlers.Robots error on read robots file", zap.Error(err), zap.String("file", file))
+			return err
+		}
+		content = string(b)
+	} else {
+		content = "User-agent: *\n"
+
+		disallow := cfg.Get(ROBOTS_DISALLOW)
+		if disallow == "" {
+			content += "Disallow:\n"
+		}
+
+		for _, path := range strings.Split(disallow, ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				content += "Disallow: " + path + "\n"
+			}
+		}
+	}
+
+	if !cfg.GetBool(SITEMAP_DISABLE) && !strings.Contains(strings.ToLower(content), "sitemap:") {
+		content = strings.TrimSuffix(content, "\n") + "\n\nSitemap: " + strings.TrimSuffix(GetBaseURL(c), "/") + "/sitemap.xml\n"
+	}
+
+	if h.CacheTTL > 0 {
+		c.Response().Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(h.CacheTTL.Seconds())))
+	}
+
+	return c.String(http.StatusOK, content)
+}
@@ -0,0 +1,160 @@
+package bolo_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	bolo "github.com/go-bolo/bolo"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSitemap(t *testing.T) {
+	os.Setenv("SITEMAP_GZIP", "true")
+	defer os.Unsetenv("SITEMAP_GZIP")
+
+	maxURLs := bolo.SitemapMaxURLs
+	bolo.SitemapMaxURLs = 2
+	defer func() { bolo.SitemapMaxURLs = maxURLs }()
+
+	app := GetTestApp()
+	app.SetRoute("about", &bolo.Route{
+		Method:  http.MethodGet,
+		Path:    "/about",
+		Action:  func(c echo.Context) (bolo.Response, error) { return &bolo.DefaultResponse{}, nil },
+		Sitemap: &bolo.SitemapURL{ChangeFreq: "monthly", Priority: 0.5},
+	})
+
+	lastMod := time.Date(2023, time.July, 16, 0, 0, 0, 0, time.UTC)
+	app.AddSitemapSource("urls", bolo.SitemapSourceFunc(func(app bolo.App, yield func(u *bolo.SitemapURL) error) error {
+		for i := 1; i <= 3; i++ {
+			err := yield(&bolo.SitemapURL{Loc: "/urls/" + strconv.Itoa(i), LastMod: &lastMod})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	err := app.Bootstrap()
+	assert.Nil(t, err)
+
+	request := func(url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		rec := httptest.NewRecorder()
+		app.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("should return the sitemap index", func(t *testing.T) {
+		rec := request("/sitemap.xml")
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		body := rec.Body.String()
+		assert.Contains(t, body, "<sitemapindex")
+		assert.Contains(t, body, "<loc>http://localhost:8080/sitemaps/routes-1.xml.gz</loc>")
+		assert.Contains(t, body, "<loc>http://localhost:8080/sitemaps/urls-2.xml.gz</loc><lastmod>2023-07-16T00:00:00Z</lastmod>")
+		assert.NotContains(t, body, "urls-3.xml")
+	})
+
+	t.Run("should return one sitemap file", func(t *testing.T) {
+		rec := request("/sitemaps/routes-1.xml")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "<url><loc>http://localhost:8080/about</loc><changefreq>monthly</changefreq><priority>0.5</priority></url>")
+	})
+
+	t.Run("should return one gzipped sitemap file", func(t *testing.T) {
+		rec := request("/sitemaps/urls-1.xml.gz")
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		zr, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+		assert.Nil(t, err)
+		data, err := io.ReadAll(zr)
+		assert.Nil(t, err)
+		assert.Contains(t, string(data), "<loc>http://localhost:8080/urls/2</loc>")
+		assert.NotContains(t, string(data), "/urls/3")
+	})
+
+	t.Run("should return 404 for unknown sitemap files", func(t *testing.T) {
+		rec := request("/sitemaps/unknown-1.xml")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("should return the robots.txt with the sitemap url", func(t *testing.T) {
+		rec := request("/robots.txt")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "User-agent: *\nDisallow:\n\nSitemap: http://localhost:8080/sitemap.xml\n", rec.Body.String())
+	})
+}
+
+func TestBuildSitemapMaxSize(t *testing.T) {
+	maxSize := bolo.SitemapMaxSize
+	// the xml header, the urlset tags and two urls:
+	bolo.SitemapMaxSize = 250
+	defer func() { bolo.SitemapMaxSize = maxSize }()
+
+	app := GetTestApp()
+	app.AddSitemapSource("urls", bolo.SitemapSourceFunc(func(app bolo.App, yield func(u *bolo.SitemapURL) error) error {
+		for i := 1; i <= 3; i++ {
+			err := yield(&bolo.SitemapURL{Loc: "/urls/" + strconv.Itoa(i)})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	s, err := bolo.BuildSitemap(app, "http://localhost:8080", true)
+	assert.Nil(t, err)
+
+	assert.Contains(t, string(s.Files["urls-1.xml"]), "<loc>http://localhost:8080/urls/2</loc>")
+	assert.NotContains(t, string(s.Files["urls-1.xml"]), "/urls/3")
+	assert.LessOrEqual(t, len(s.Files["urls-1.xml"]), bolo.SitemapMaxSize)
+	assert.Contains(t, string(s.Files["urls-2.xml"]), "<loc>http://localhost:8080/urls/3</loc>")
+	assert.Contains(t, string(s.Index), "<loc>http://localhost:8080/sitemaps/urls-2.xml.gz</loc>")
+
+	zr, err := gzip.NewReader(bytes.NewReader(s.GzipFiles["urls-2.xml"]))
+	assert.Nil(t, err)
+	data, err := io.ReadAll(zr)
+	assert.Nil(t, err)
+	assert.Equal(t, s.Files["urls-2.xml"], data)
+}
+
+func TestSitemapConcurrentBuilds(t *testing.T) {
+	app := GetTestApp()
+
+	var builds int32
+	app.AddSitemapSource("urls", bolo.SitemapSourceFunc(func(app bolo.App, yield func(u *bolo.SitemapURL) error) error {
+		atomic.AddInt32(&builds, 1)
+		time.Sleep(50 * time.Millisecond)
+		return yield(&bolo.SitemapURL{Loc: "/urls/1"})
+	}))
+
+	err := app.Bootstrap()
+	assert.Nil(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			req := httptest.NewRequest(http.MethodGet, "/sitemap.xml", nil)
+			rec := httptest.NewRecorder()
+			app.GetRouter().ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&builds))
+}
@@ -0,0 +1,72 @@
+package bolo
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-bolo/bolo/storage"
+	"github.com/labstack/echo/v4"
+)
+
+// NewStorageFromConfiguration - Build the app file storage from the STORAGE_* configurations
+func NewStorageFromConfiguration(app App) (storage.Storage, error) {
+	cfg := app.GetConfiguration()
+	now := func() time.Time { return app.GetClock().Now() }
+
+	switch cfg.GetF(STORAGE_DRIVER, "local") {
+	case "local":
+		secret, err := SigningSecret(app, STORAGE_SIGNING_SECRET)
+		if err != nil {
+			return nil, fmt.Errorf("NewStorageFromConfiguration: %w", err)
+		}
+
+		s := storage.NewLocalStorage(cfg.GetF(STORAGE_LOCAL_DIR, "./uploads"), cfg.GetF(STORAGE_LOCAL_URL, "/storage"), secret)
+		s.Now = now
+		return s, nil
+	case "s3":
+		s := storage.S3Storage{
+			Endpoint:  cfg.GetF(STORAGE_S3_ENDPOINT, "https://s3.amazonaws.com"),
+			Region:    cfg.GetF(STORAGE_S3_REGION, "us-east-1"),
+			Bucket:    cfg.Get(STORAGE_S3_BUCKET),
+			AccessKey: cfg.Get(STORAGE_S3_ACCESS_KEY),
+			SecretKey: cfg.Get(STORAGE_S3_SECRET_KEY),
+			PathStyle: cfg.GetBool(STORAGE_S3_PATH_STYLE),
+			Client:    app.GetHTTPClient(),
+			Now:       now,
+		}
+
+		if s.Bucket == "" {
+			return nil, fmt.Errorf("NewStorageFromConfiguration: %s is required", STORAGE_S3_BUCKET)
+		}
+
+		return &s, nil
+	default:
+		return nil, fmt.Errorf("NewStorageFromConfiguration: invalid driver: %s", cfg.Get(STORAGE_DRIVER))
+	}
+}
+
+// storageLocalPath - Router path of the local storage signed URLs
+func storageLocalPath(app App) string {
+	u, err := url.Parse(app.GetConfiguration().GetF(STORAGE_LOCAL_URL, "/storage"))
+	if err != nil || u.Path == "" {
+		return "/storage"
+	}
+
+	return strings.TrimSuffix(u.Path, "/")
+}
+
+// StorageHandler - Serve the local storage signed URLs, other drivers sign URLs in their own domain
+func StorageHandler(c echo.Context) error {
+	app := GetApp(c)
+
+	local, ok := app.GetStorage().(*storage.LocalStorage)
+	if !ok {
+		return echo.ErrNotFound
+	}
+
+	http.StripPrefix(storageLocalPath(app), local).ServeHTTP(c.Response(), c.Request())
+	return nil
+}
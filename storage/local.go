@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid storage url signature")
+	ErrURLExpired       = errors.New("storage url expired")
+)
+
+// LocalStorage - Files in Dir, signed URLs are served by ServeHTTP in the BaseURL path
+type LocalStorage struct {
+	Dir string
+	// Public URL prefix of the ServeHTTP handler, like: https://example.com/storage
+	BaseURL string
+	// HMAC key of the signed URLs
+	Secret []byte
+	// Current time, default time.Now
+	Now func() time.Time
+}
+
+func NewLocalStorage(dir, baseURL string, secret []byte) *LocalStorage {
+	return &LocalStorage{Dir: dir, BaseURL: strings.TrimSuffix(baseURL, "/"), Secret: secret, Now: time.Now}
+}
+
+func (s *LocalStorage) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+
+	return time.Now()
+}
+
+func (s *LocalStorage) path(key string) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+
+	return filepath.Join(s.Dir, filepath.FromSlash(key)), nil
+}
+
+// Put - Write in one temporary file renamed after the copy
+func (s *LocalStorage) Put(ctx context.Context, key string, r io.Reader, opts *PutOptions) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("storage.LocalStorage.Put: %w", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("storage.LocalStorage.Put: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := io.Copy(f, &contextReader{ctx: ctx, r: r}); err != nil {
+		f.Close()
+		return fmt.Errorf("storage.LocalStorage.Put: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("storage.LocalStorage.Put: %w", err)
+	}
+
+	if err := os.Rename(f.Name(), p); err != nil {
+		return fmt.Errorf("storage.LocalStorage.Put: %w", err)
+	}
+
+	return nil
+}
+
+func (s *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("storage.LocalStorage.Get: %w", err)
+	}
+
+	return f, nil
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(p)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("storage.LocalStorage.Delete: %w", err)
+	}
+
+	return nil
+}
+
+// SignedURL - <BaseURL>/<key>?expires=<unix>&signature=<hmac>
+func (s *LocalStorage) SignedURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+
+	exp := strconv.FormatInt(s.now().Add(expires).Unix(), 10)
+	query := url.Values{"expires": []string{exp}, "signature": []string{s.sign(key, exp)}}
+
+	return s.BaseURL + "/" + (&url.URL{Path: key}).EscapedPath() + "?" + query.Encode(), nil
+}
+
+// Verify - Check one signed URL key, expires and signature
+func (s *LocalStorage) Verify(key, expires, signature string) error {
+	if !hmac.Equal([]byte(s.sign(key, expires)), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	if s.now().Unix() > exp {
+		return ErrURLExpired
+	}
+
+	return nil
+}
+
+func (s *LocalStorage) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, s.Secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ServeHTTP - Serve the signed URLs, the request path is the key after the BaseURL path is stripped
+func (s *LocalStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	query := r.URL.Query()
+
+	if err := s.Verify(key, query.Get("expires"), query.Get("signature")); err != nil {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	p, err := s.path(key)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.r.Read(p)
+}
@@ -0,0 +1,53 @@
+// Package storage implements one file storage interface with local filesystem and S3 compatible drivers
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("storage object not found")
+	ErrInvalidKey = errors.New("invalid storage key")
+)
+
+// PutOptions - Object metadata, all optional
+type PutOptions struct {
+	ContentType string
+	// Object size in bytes, -1 or 0 if unknown. Required by the S3 driver to stream the body
+	Size int64
+}
+
+// Storage - Object storage, keys are slash separated relative paths like 2023/07/file.png
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, opts *PutOptions) error
+	// Get - Returns ErrNotFound for missing objects
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete - Missing objects are not errors
+	Delete(ctx context.Context, key string) error
+	// SignedURL - Temporary URL to read the object without credentials
+	SignedURL(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+// ValidateKey - Reject empty, absolute or parent directory keys
+func ValidateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") || strings.ContainsRune(key, 0) {
+		return ErrInvalidKey
+	}
+
+	if path.Clean(key) != key {
+		return ErrInvalidKey
+	}
+
+	for _, part := range strings.Split(key, "/") {
+		if part == ".." || part == "." {
+			return ErrInvalidKey
+		}
+	}
+
+	return nil
+}
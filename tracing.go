@@ -0,0 +1,326 @@
+package bolo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	TracingExporterNone   = "none"
+	TracingExporterStdout = "stdout"
+	TracingExporterFile   = "file"
+	TracingExporterOTLP   = "otlp"
+
+	tracerName = "github.com/go-bolo/bolo"
+)
+
+// TracePropagator - W3C traceparent and baggage propagation used in incoming and outgoing requests
+var TracePropagator propagation.TextMapPropagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Tracer - Returns the bolo tracer from the app tracer provider
+func Tracer(app App) trace.Tracer {
+	return app.GetTracerProvider().Tracer(tracerName)
+}
+
+// InitTracing - Configure the app tracer provider with the TRACING_EXPORTER.
+// With the none exporter the current provider is kept, by default one noop provider
+func InitTracing(app App) error {
+	cfg := app.GetConfiguration()
+	otel.SetTextMapPropagator(TracePropagator)
+
+	exporterName := cfg.GetF(TRACING_EXPORTER, TracingExporterNone)
+
+	var exporter sdktrace.SpanExporter
+	var file *os.File
+	var err error
+
+	switch exporterName {
+	case TracingExporterNone, "":
+		return nil
+	case TracingExporterStdout:
+		exporter, err = stdouttrace.New()
+	case TracingExporterFile:
+		file, err = os.OpenFile(cfg.GetF(TRACING_FILE, "traces.json"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("InitTracing error on open traces file: %w", err)
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+	case TracingExporterOTLP:
+		// configured with the standard OTEL_EXPORTER_OTLP_* variables
+		exporter, err = otlptracehttp.New(context.Background())
+	default:
+		return fmt.Errorf("InitTracing invalid exporter: %s", exporterName)
+	}
+
+	if err != nil {
+		return fmt.Errorf("InitTracing error on create exporter: %w", err)
+	}
+
+	ratio := 1.0
+	if v := cfg.Get(TRACING_SAMPLE_RATIO); v != "" {
+		ratio, err = strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("InitTracing invalid sample ratio %s: %w", v, err)
+		}
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", cfg.GetF(TRACING_SERVICE_NAME, "bolo")))),
+	)
+
+	app.SetTracerProvider(tp)
+
+	app.GetEvents().On("close", event.ListenerFunc(func(e event.Event) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		err := tp.Shutdown(ctx)
+		if file != nil {
+			file.Close()
+		}
+
+		return err
+	}), event.Min)
+
+	return nil
+}
+
+// TracingMiddleware - Start one server span for each request with the incoming trace context
+// and add the trace ids in the request logger
+func TracingMiddleware(app App) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+
+			ctx := TracePropagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+			ctx, span := Tracer(app).Start(ctx, "HTTP "+req.Method,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.method", req.Method),
+					attribute.String("http.target", req.URL.Path),
+					attribute.String("http.request_id", GetRequestID(c)),
+				),
+			)
+			defer span.End()
+
+			c.SetRequest(req.WithContext(ctx))
+
+			if sc := span.SpanContext(); sc.IsValid() {
+				c.Set("logger", GetLogger(c).With(zap.String("traceID", sc.TraceID().String()), zap.String("spanID", sc.SpanID().String())))
+			}
+
+			// write the error response to get the final status
+			err := commitError(c, next(c))
+			if err != nil {
+				span.RecordError(err)
+			}
+
+			status := c.Response().Status
+			span.SetAttributes(attribute.Int("http.status_code", status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+
+			if p := c.Path(); p != "" {
+				span.SetAttributes(attribute.String("http.route", p))
+				span.SetName(req.Method + " " + p)
+			}
+
+			if name, ok := c.Get("route_name").(string); ok && name != "" {
+				span.SetAttributes(attribute.String("bolo.route", name))
+				span.SetName(name)
+			}
+
+			if r, ok := c.Get("route").(*Route); ok && r.Permission != "" {
+				span.SetAttributes(attribute.String("bolo.route.permission", r.Permission))
+			}
+
+			return err
+		}
+	}
+}
+
+// TraceDB - Add one child span for each gorm operation, the parent is the db.WithContext(ctx) context.
+// Use bolo.GetDB(c) in the request handlers to trace the queries as children of the request span
+func TraceDB(app App, name string, db *gorm.DB) error {
+	before := func(operation string) func(tx *gorm.DB) {
+		return func(tx *gorm.DB) {
+			ctx := tx.Statement.Context
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			ctx, span := Tracer(app).Start(ctx, "gorm."+operation, trace.WithSpanKind(trace.SpanKindClient))
+			tx.Statement.Context = ctx
+			tx.InstanceSet("bolo:tracing_span", span)
+		}
+	}
+
+	after := func(tx *gorm.DB) {
+		v, ok := tx.InstanceGet("bolo:tracing_span")
+		if !ok {
+			return
+		}
+
+		span := v.(trace.Span)
+		defer span.End()
+
+		span.SetAttributes(
+			attribute.String("db.system", tx.Dialector.Name()),
+			attribute.String("db.name", name),
+			attribute.String("db.sql.table", tx.Statement.Table),
+			attribute.String("db.statement", tx.Statement.SQL.String()),
+			attribute.Int64("db.rows_affected", tx.RowsAffected),
+		)
+
+		if tx.Error != nil && tx.Error != gorm.ErrRecordNotFound {
+			span.RecordError(tx.Error)
+			span.SetStatus(codes.Error, tx.Error.Error())
+		}
+	}
+
+	cb := db.Callback()
+
+	errs := []error{
+		cb.Create().Before("gorm:create").Register("bolo:tracing_before_create", before("create")),
+		cb.Create().After("gorm:create").Register("bolo:tracing_after_create", after),
+		cb.Query().Before("gorm:query").Register("bolo:tracing_before_query", before("query")),
+		cb.Query().After("gorm:query").Register("bolo:tracing_after_query", after),
+		cb.Update().Before("gorm:update").Register("bolo:tracing_before_update", before("update")),
+		cb.Update().After("gorm:update").Register("bolo:tracing_after_update", after),
+		cb.Delete().Before("gorm:delete").Register("bolo:tracing_before_delete", before("delete")),
+		cb.Delete().After("gorm:delete").Register("bolo:tracing_after_delete", after),
+		cb.Row().Before("gorm:row").Register("bolo:tracing_before_row", before("row")),
+		cb.Row().After("gorm:row").Register("bolo:tracing_after_row", after),
+		cb.Raw().Before("gorm:raw").Register("bolo:tracing_before_raw", before("raw")),
+		cb.Raw().After("gorm:raw").Register("bolo:tracing_after_raw", after),
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// TracingTransport - http.RoundTripper that adds one client span and the traceparent header in outgoing requests
+type TracingTransport struct {
+	// Base transport, if nil the http.DefaultTransport is used
+	Base http.RoundTripper
+	App  App
+}
+
+func (t *TracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	ctx, span := Tracer(t.App).Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.method", req.Method),
+			attribute.String("http.url", req.URL.Redacted()),
+			attribute.String("net.peer.name", req.URL.Host),
+		),
+	)
+	defer span.End()
+
+	// RoundTrippers should not modify the original request:
+	r := req.Clone(ctx)
+	TracePropagator.Inject(ctx, propagation.HeaderCarrier(r.Header))
+
+	res, err := base.RoundTrip(r)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return res, err
+	}
+
+	span.SetAttributes(attribute.Int("http.status_code", res.StatusCode))
+	if res.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(res.StatusCode))
+	}
+
+	return res, nil
+}
+
+// TraceHttpClient - Add the TracingTransport in the HttpClient if it is one *http.Client
+func TraceHttpClient(app App) {
+	client, ok := HttpClient.(*http.Client)
+	if !ok {
+		return
+	}
+
+	client.Transport = &TracingTransport{Base: client.Transport, App: app}
+}
+
+// TracedListener - Wrap one event listener to run it in one child span.
+// The parent span is taken from the "ctx" (context.Context) or "context" (echo.Context) event data
+func TracedListener(app App, name string, l event.Listener) event.Listener {
+	return event.ListenerFunc(func(e event.Event) error {
+		_, span := Tracer(app).Start(eventContext(e), "event.listener "+name)
+		defer span.End()
+
+		err := l.Handle(e)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		return err
+	})
+}
+
+// FireEvent - Fire one app event in one span, listeners can get the span context from the "ctx" event data
+func FireEvent(app App, ctx context.Context, name string, params event.M) error {
+	ctx, span := Tracer(app).Start(ctx, "event "+name, trace.WithAttributes(attribute.String("event.name", name)))
+	defer span.End()
+
+	if params == nil {
+		params = event.M{}
+	}
+	params["ctx"] = ctx
+
+	err, _ := app.GetEvents().Fire(name, params)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
+
+func eventContext(e event.Event) context.Context {
+	if ctx, ok := e.Get("ctx").(context.Context); ok {
+		return ctx
+	}
+
+	if c, ok := e.Get("context").(echo.Context); ok {
+		return c.Request().Context()
+	}
+
+	return context.Background()
+}
@@ -0,0 +1,139 @@
+package bolo_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	bolo "github.com/go-bolo/bolo"
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+
+	app := GetTestApp()
+	app.SetLogger(zap.New(core))
+	app.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	var upstreamTraceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamTraceparent = r.Header.Get("traceparent")
+	}))
+	defer upstream.Close()
+
+	app.GetEvents().On("content-created", bolo.TracedListener(app, "content-created", event.ListenerFunc(func(e event.Event) error {
+		return nil
+	})))
+
+	app.SetRoute("traced", &bolo.Route{
+		Method:     http.MethodGet,
+		Path:       "/traced",
+		Permission: "find_content",
+		Action: func(c echo.Context) (bolo.Response, error) {
+			ctx := c.Request().Context()
+			app := bolo.GetApp(c)
+
+			var count int64
+			err := bolo.GetDB(c).Raw("SELECT 1").Scan(&count).Error
+			if err != nil {
+				return nil, err
+			}
+
+			res, err := bolo.GetWithContext(ctx, upstream.URL, nil)
+			if err != nil {
+				return nil, err
+			}
+			res.Body.Close()
+
+			err = bolo.FireEvent(app, ctx, "content-created", event.M{"id": 1})
+			if err != nil {
+				return nil, err
+			}
+
+			bolo.GetLogger(c).Info("traced action")
+
+			return &bolo.DefaultResponse{}, nil
+		},
+	})
+
+	require.Nil(t, app.Bootstrap())
+
+	req := httptest.NewRequest(http.MethodGet, "/traced", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	app.GetRouter().ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+
+	server, ok := spans["traced"]
+	require.True(t, ok, "server span should use the route name")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+	assert.Contains(t, server.Attributes(), attribute.String("bolo.route.permission", "find_content"))
+	assert.Contains(t, server.Attributes(), attribute.Int("http.status_code", 200))
+
+	for _, name := range []string{"gorm.row", "HTTP GET", "event content-created", "event.listener content-created"} {
+		s, ok := spans[name]
+		if assert.True(t, ok, name) {
+			assert.Equal(t, server.SpanContext().TraceID(), s.SpanContext().TraceID(), name)
+		}
+	}
+
+	assert.Equal(t, server.SpanContext().SpanID(), spans["gorm.row"].Parent().SpanID())
+
+	// the bootstrap events run in the bootstrap span
+	for _, name := range []string{"event bindMiddlewares", "event bindRoutes", "event bootstrap"} {
+		if assert.Contains(t, spans, name) {
+			assert.Equal(t, spans["bolo.Bootstrap"].SpanContext().SpanID(), spans[name].Parent().SpanID(), name)
+		}
+	}
+	assert.Equal(t, spans["event content-created"].SpanContext().SpanID(), spans["event.listener content-created"].Parent().SpanID())
+
+	client := spans["HTTP GET"]
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+client.SpanContext().SpanID().String()+"-01", upstreamTraceparent)
+
+	entries := logs.FilterMessage("traced action").All()
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", entries[0].ContextMap()["traceID"])
+		assert.Equal(t, server.SpanContext().SpanID().String(), entries[0].ContextMap()["spanID"])
+	}
+}
+
+func TestTracingFileExporter(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "traces.json")
+
+	os.Setenv("TRACING_EXPORTER", "file")
+	os.Setenv("TRACING_FILE", file)
+	defer os.Unsetenv("TRACING_EXPORTER")
+	defer os.Unsetenv("TRACING_FILE")
+
+	app := GetTestApp()
+	require.Nil(t, app.Bootstrap())
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	app.GetRouter().ServeHTTP(httptest.NewRecorder(), req)
+
+	require.Nil(t, app.Close())
+
+	data, err := os.ReadFile(file)
+	require.Nil(t, err)
+	assert.Contains(t, string(data), `"Name":"GET /health"`)
+}
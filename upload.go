@@ -0,0 +1,235 @@
+package bolo
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// DefaultUploadMaxSize - Max file size in bytes of the upload fields without one maxSize, override with UPLOAD_MAX_SIZE
+const DefaultUploadMaxSize = 10 << 20
+
+// UploadedFile - One multipart file, bound by CustomBinder in the *UploadedFile and []*UploadedFile fields
+// with one form tag. Limits are set in the upload tag, like:
+//
+//	Image *bolo.UploadedFile `form:"image" upload:"maxSize=5MB,types=image/png|image/jpeg"`
+type UploadedFile struct {
+	Field    string
+	Filename string
+	Size     int64
+	// Type detected from the file content, the client Content-Type header is ignored
+	ContentType string
+	Header      *multipart.FileHeader
+}
+
+// Open - Open the file content
+func (f *UploadedFile) Open() (multipart.File, error) {
+	return f.Header.Open()
+}
+
+// Ext - Lower case file extension of the original filename, like: .png
+func (f *UploadedFile) Ext() string {
+	return strings.ToLower(filepath.Ext(f.Filename))
+}
+
+// UploadLimits - Parsed upload tag
+type UploadLimits struct {
+	MaxSize int64
+	// Allowed types, with wildcards like image/*. Empty allows all types
+	Types []string
+}
+
+// ParseUploadLimits - Parse one upload tag like maxSize=5MB,types=image/*|application/pdf
+func ParseUploadLimits(tag string, defaultMaxSize int64) (*UploadLimits, error) {
+	limits := UploadLimits{MaxSize: defaultMaxSize}
+
+	for _, part := range strings.Split(tag, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch name {
+		case "":
+		case "maxSize":
+			size, err := ParseByteSize(value)
+			if err != nil {
+				return nil, err
+			}
+			limits.MaxSize = size
+		case "types":
+			for _, t := range strings.Split(value, "|") {
+				if t = strings.TrimSpace(t); t != "" {
+					limits.Types = append(limits.Types, strings.ToLower(t))
+				}
+			}
+		default:
+			return nil, fmt.Errorf("invalid upload tag option: %s", name)
+		}
+	}
+
+	return &limits, nil
+}
+
+// Allows - Check if the content type is allowed
+func (l *UploadLimits) Allows(contentType string) bool {
+	if len(l.Types) == 0 {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, t := range l.Types {
+		if t == mediaType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(t, "*"))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseByteSize - Parse sizes like 512, 100KB, 5MB or 1GB
+func ParseByteSize(v string) (int64, error) {
+	v = strings.ToUpper(strings.TrimSpace(v))
+
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		value  int64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"B", 1}} {
+		if strings.HasSuffix(v, unit.suffix) {
+			multiplier = unit.value
+			v = strings.TrimSpace(strings.TrimSuffix(v, unit.suffix))
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid byte size: %s", v)
+	}
+
+	return n * multiplier, nil
+}
+
+var uploadedFileType = reflect.TypeOf(&UploadedFile{})
+
+// BindUploads - Bind the multipart files in the struct fields of type *UploadedFile or []*UploadedFile.
+// Files over the limit return 413 and files with types not allowed return 415
+func BindUploads(c echo.Context, i interface{}) error {
+	val := reflect.ValueOf(i)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	val = val.Elem()
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return &HTTPError{Code: http.StatusBadRequest, Message: "Invalid multipart body", Internal: err}
+	}
+
+	defaultMaxSize := GetApp(c).GetConfiguration().GetInt64F(UPLOAD_MAX_SIZE, DefaultUploadMaxSize)
+
+	for n := 0; n < val.NumField(); n++ {
+		field := val.Type().Field(n)
+		name := field.Tag.Get("form")
+		if name == "" || !val.Field(n).CanSet() {
+			continue
+		}
+
+		isSlice := field.Type == reflect.SliceOf(uploadedFileType)
+		if field.Type != uploadedFileType && !isSlice {
+			continue
+		}
+
+		headers := form.File[name]
+		if len(headers) == 0 {
+			continue
+		}
+
+		limits, err := ParseUploadLimits(field.Tag.Get("upload"), defaultMaxSize)
+		if err != nil {
+			return fmt.Errorf("BindUploads: %s: %w", field.Name, err)
+		}
+
+		files := []*UploadedFile{}
+		for _, h := range headers {
+			f, err := newUploadedFile(name, h, limits)
+			if err != nil {
+				return err
+			}
+			files = append(files, f)
+
+			if !isSlice {
+				break
+			}
+		}
+
+		if isSlice {
+			val.Field(n).Set(reflect.ValueOf(files))
+		} else {
+			val.Field(n).Set(reflect.ValueOf(files[0]))
+		}
+	}
+
+	return nil
+}
+
+// Check - Returns one 413 or 415 *HTTPError if the file is over the size limit or the type is not allowed
+func (l *UploadLimits) Check(f *UploadedFile) error {
+	if l.MaxSize > 0 && f.Size > l.MaxSize {
+		return &HTTPError{
+			Code:    http.StatusRequestEntityTooLarge,
+			Message: fmt.Sprintf("%s: file too large, max %d bytes", f.Field, l.MaxSize),
+		}
+	}
+
+	if !l.Allows(f.ContentType) {
+		return &HTTPError{
+			Code:    http.StatusUnsupportedMediaType,
+			Message: fmt.Sprintf("%s: file type not allowed: %s", f.Field, f.ContentType),
+		}
+	}
+
+	return nil
+}
+
+func newUploadedFile(field string, h *multipart.FileHeader, limits *UploadLimits) (*UploadedFile, error) {
+	uf := UploadedFile{
+		Field:    field,
+		Filename: filepath.Base(h.Filename),
+		Size:     h.Size,
+		Header:   h,
+	}
+
+	// check the size before the file read
+	if err := (&UploadLimits{MaxSize: limits.MaxSize}).Check(&uf); err != nil {
+		return nil, err
+	}
+
+	f, err := h.Open()
+	if err != nil {
+		return nil, fmt.Errorf("BindUploads: %w", err)
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("BindUploads: %w", err)
+	}
+	uf.ContentType = http.DetectContentType(head[:n])
+
+	if err := limits.Check(&uf); err != nil {
+		return nil, err
+	}
+
+	return &uf, nil
+}
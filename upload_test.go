@@ -0,0 +1,39 @@
+package bolo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseByteSize(t *testing.T) {
+	for v, expected := range map[string]int64{"512": 512, "10B": 10, "100KB": 100 << 10, "5mb": 5 << 20, " 1 GB ": 1 << 30} {
+		size, err := ParseByteSize(v)
+		require.Nil(t, err, v)
+		assert.Equal(t, expected, size, v)
+	}
+
+	for _, v := range []string{"", "MB", "-1", "1TB", "1.5MB"} {
+		_, err := ParseByteSize(v)
+		assert.NotNil(t, err, v)
+	}
+}
+
+func TestParseUploadLimits(t *testing.T) {
+	limits, err := ParseUploadLimits("maxSize=5MB, types=image/*|Application/PDF", 100)
+	require.Nil(t, err)
+	assert.Equal(t, int64(5<<20), limits.MaxSize)
+	assert.True(t, limits.Allows("image/png"))
+	assert.True(t, limits.Allows("application/pdf"))
+	assert.False(t, limits.Allows("text/html; charset=utf-8"))
+	assert.False(t, limits.Allows("imagex/png"))
+
+	limits, err = ParseUploadLimits("", 100)
+	require.Nil(t, err)
+	assert.Equal(t, int64(100), limits.MaxSize)
+	assert.True(t, limits.Allows("text/html; charset=utf-8"))
+
+	_, err = ParseUploadLimits("size=1MB", 100)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,342 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-bolo/bolo"
+	"github.com/go-bolo/bolo/mailer"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// AuthController - Login, logout, password reset and email confirmation actions
+type AuthController struct {
+	Plugin *Plugin
+}
+
+type LoginBody struct {
+	Login      string `json:"login" form:"login" validate:"required"`
+	Password   string `json:"password" form:"password" validate:"required"`
+	RedirectTo string `json:"redirectTo" form:"redirectTo" query:"redirectTo"`
+}
+
+type ForgotPasswordBody struct {
+	Email string `json:"email" form:"email" validate:"required,email"`
+}
+
+type ResetPasswordBody struct {
+	Token    string `json:"token" form:"token" query:"token" validate:"required"`
+	Password string `json:"password" form:"password" validate:"required"`
+}
+
+type SendEmailConfirmationBody struct {
+	Email string `json:"email" form:"email" validate:"required,email"`
+}
+
+// LoginResponse - JSON response for the login action
+type LoginResponse struct {
+	User *User `json:"user"`
+}
+
+func (ctl *AuthController) LoginPage(c echo.Context) (bolo.Response, error) {
+	bolo.GetMetadata(c).Set("title", bolo.Translate(c, "Login"))
+
+	return &bolo.DefaultResponse{
+		Data: map[string]interface{}{
+			"redirectTo": c.QueryParam("redirectTo"),
+		},
+	}, nil
+}
+
+func (ctl *AuthController) Login(c echo.Context) (bolo.Response, error) {
+	p := ctl.Plugin
+
+	body := LoginBody{}
+	if err := c.Bind(&body); err != nil {
+		return nil, bolo.NewHTTPError(http.StatusBadRequest, "invalid body", err)
+	}
+
+	if err := c.Validate(&body); err != nil {
+		return nil, err
+	}
+
+	lockoutKey := "login:" + strings.ToLower(strings.TrimSpace(body.Login))
+
+	if p.Lockout != nil {
+		locked, retryAfter, err := p.Lockout.Check(c.Request().Context(), lockoutKey, p.App.GetClock().Now())
+		if err != nil {
+			return nil, fmt.Errorf("AuthController.Login: %w", err)
+		}
+
+		if locked {
+			c.Response().Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(retryAfter.Seconds())), 10))
+			return nil, bolo.NewHTTPError(http.StatusTooManyRequests, ErrTooManyLoginFailures.Error(), nil)
+		}
+	}
+
+	user, err := FindOneByLogin(p.App, body.Login)
+	if err != nil {
+		// run one verify anyway to not leak the existence of the login with the response time
+		p.PasswordHasher.Verify(dummyPasswordHash, body.Password)
+		ctl.loginFailed(c, lockoutKey)
+		return nil, bolo.NewHTTPError(http.StatusUnauthorized, ErrInvalidLogin.Error(), err)
+	}
+
+	valid, err := p.PasswordHasher.Verify(user.PasswordHash, body.Password)
+	if err != nil && !errors.Is(err, ErrInvalidPasswordHash) {
+		return nil, fmt.Errorf("AuthController.Login: %w", err)
+	}
+
+	if !valid {
+		ctl.loginFailed(c, lockoutKey)
+		return nil, bolo.NewHTTPError(http.StatusUnauthorized, ErrInvalidLogin.Error(), nil)
+	}
+
+	if p.Lockout != nil {
+		err = p.Lockout.Reset(c.Request().Context(), lockoutKey)
+		if err != nil {
+			bolo.GetLogger(c).Warn("AuthController.Login error on reset lockout", zap.Error(err))
+		}
+	}
+
+	if user.IsBlocked() {
+		return nil, bolo.NewHTTPError(http.StatusForbidden, ErrUserBlocked.Error(), nil)
+	}
+
+	if !user.IsActive() {
+		return nil, bolo.NewHTTPError(http.StatusForbidden, ErrUserInactive.Error(), nil)
+	}
+
+	err = p.Login(c, user)
+	if err != nil {
+		return nil, fmt.Errorf("AuthController.Login: %w", err)
+	}
+
+	if bolo.GetAccept(c) == "text/html" {
+		return nil, c.Redirect(http.StatusSeeOther, safeRedirectTo(body.RedirectTo))
+	}
+
+	return &bolo.DefaultResponse{
+		Data: &LoginResponse{User: user},
+	}, nil
+}
+
+func (ctl *AuthController) Logout(c echo.Context) (bolo.Response, error) {
+	err := ctl.Plugin.Logout(c)
+	if err != nil {
+		return nil, fmt.Errorf("AuthController.Logout: %w", err)
+	}
+
+	if bolo.GetAccept(c) == "text/html" {
+		return nil, c.Redirect(http.StatusSeeOther, "/")
+	}
+
+	return &bolo.DefaultResponse{Status: http.StatusNoContent}, nil
+}
+
+func (ctl *AuthController) ForgotPasswordPage(c echo.Context) (bolo.Response, error) {
+	bolo.GetMetadata(c).Set("title", bolo.Translate(c, "Forgot password"))
+	return &bolo.DefaultResponse{}, nil
+}
+
+// ForgotPassword - Send the password reset email. The response is the same if the email isn't registered
+func (ctl *AuthController) ForgotPassword(c echo.Context) (bolo.Response, error) {
+	p := ctl.Plugin
+
+	body := ForgotPasswordBody{}
+	if err := c.Bind(&body); err != nil {
+		return nil, bolo.NewHTTPError(http.StatusBadRequest, "invalid body", err)
+	}
+
+	if err := c.Validate(&body); err != nil {
+		return nil, err
+	}
+
+	user, err := FindOneByLogin(p.App, body.Email)
+	if err == nil && !user.IsBlocked() {
+		err = p.SendPasswordResetEmail(c, user)
+		if err != nil {
+			return nil, fmt.Errorf("AuthController.ForgotPassword: %w", err)
+		}
+	}
+
+	bolo.SetResponseMessage(c, "forgot_password", &bolo.ResponseMessage{
+		Type:    "success",
+		Message: bolo.Translate(c, "If the email is registered you will receive a link to reset your password"),
+	})
+
+	return &bolo.DefaultResponse{Status: http.StatusAccepted}, nil
+}
+
+func (ctl *AuthController) ResetPasswordPage(c echo.Context) (bolo.Response, error) {
+	bolo.GetMetadata(c).Set("title", bolo.Translate(c, "Reset password"))
+
+	return &bolo.DefaultResponse{
+		Data: map[string]interface{}{
+			"token": c.QueryParam("token"),
+		},
+	}, nil
+}
+
+func (ctl *AuthController) ResetPassword(c echo.Context) (bolo.Response, error) {
+	p := ctl.Plugin
+
+	body := ResetPasswordBody{}
+	if err := c.Bind(&body); err != nil {
+		return nil, bolo.NewHTTPError(http.StatusBadRequest, "invalid body", err)
+	}
+
+	if err := c.Validate(&body); err != nil {
+		return nil, err
+	}
+
+	if len(body.Password) < p.MinPasswordLength {
+		return nil, bolo.NewHTTPError(http.StatusBadRequest, ErrPasswordTooShort.Error(), nil)
+	}
+
+	user, err := ConsumeToken(p.App, TokenTypePasswordReset, body.Token)
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			return nil, bolo.NewHTTPError(http.StatusBadRequest, err.Error(), nil)
+		}
+
+		return nil, fmt.Errorf("AuthController.ResetPassword: %w", err)
+	}
+
+	err = user.SetPassword(p.PasswordHasher, body.Password)
+	if err != nil {
+		return nil, fmt.Errorf("AuthController.ResetPassword: %w", err)
+	}
+
+	// the user proved the email ownership with the reset token
+	if user.EmailConfirmedAt == nil {
+		now := p.App.GetClock().Now()
+		user.EmailConfirmedAt = &now
+	}
+
+	err = user.Save(p.App)
+	if err != nil {
+		return nil, fmt.Errorf("AuthController.ResetPassword: %w", err)
+	}
+
+	err = p.SessionStore.DestroyUserSessions(user.GetID())
+	if err != nil {
+		return nil, fmt.Errorf("AuthController.ResetPassword: %w", err)
+	}
+
+	if bolo.GetAccept(c) == "text/html" {
+		return nil, c.Redirect(http.StatusSeeOther, "/auth/login")
+	}
+
+	return &bolo.DefaultResponse{Status: http.StatusNoContent}, nil
+}
+
+func (ctl *AuthController) ConfirmEmail(c echo.Context) (bolo.Response, error) {
+	p := ctl.Plugin
+
+	user, err := ConsumeToken(p.App, TokenTypeEmailConfirmation, c.QueryParam("token"))
+	if err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			return nil, bolo.NewHTTPError(http.StatusBadRequest, err.Error(), nil)
+		}
+
+		return nil, fmt.Errorf("AuthController.ConfirmEmail: %w", err)
+	}
+
+	now := p.App.GetClock().Now()
+	user.EmailConfirmedAt = &now
+
+	err = user.Save(p.App)
+	if err != nil {
+		return nil, fmt.Errorf("AuthController.ConfirmEmail: %w", err)
+	}
+
+	bolo.GetMetadata(c).Set("title", bolo.Translate(c, "Email confirmed"))
+
+	return &bolo.DefaultResponse{
+		Data: &LoginResponse{User: user},
+	}, nil
+}
+
+// SendEmailConfirmation - Resend the email confirmation link
+func (ctl *AuthController) SendEmailConfirmation(c echo.Context) (bolo.Response, error) {
+	p := ctl.Plugin
+
+	body := SendEmailConfirmationBody{}
+	if err := c.Bind(&body); err != nil {
+		return nil, bolo.NewHTTPError(http.StatusBadRequest, "invalid body", err)
+	}
+
+	if err := c.Validate(&body); err != nil {
+		return nil, err
+	}
+
+	user, err := FindOneByLogin(p.App, body.Email)
+	if err == nil && !user.IsEmailConfirmed() && !user.IsBlocked() {
+		err = p.SendEmailConfirmation(c, user)
+		if err != nil {
+			return nil, fmt.Errorf("AuthController.SendEmailConfirmation: %w", err)
+		}
+	}
+
+	return &bolo.DefaultResponse{Status: http.StatusAccepted}, nil
+}
+
+// SendPasswordResetEmail - Create one password reset token and send the reset link to the user
+func (p *Plugin) SendPasswordResetEmail(c echo.Context, user *User) error {
+	token, err := CreateToken(p.App, user.GetID(), TokenTypePasswordReset, p.TokenTTL)
+	if err != nil {
+		return err
+	}
+
+	link := bolo.GetBaseURL(c) + "/auth/reset-password?token=" + token
+
+	return p.Mailer.Send(c.Request().Context(), &mailer.Message{
+		From:    p.App.GetConfiguration().Get("MAILER_FROM"),
+		To:      []string{user.GetEmail()},
+		Subject: bolo.Translate(c, "Reset your password"),
+		Text:    bolo.Translate(c, "Use the link below to reset your password:") + "\n\n" + link + "\n",
+	})
+}
+
+// SendEmailConfirmation - Create one email confirmation token and send the confirmation link to the user
+func (p *Plugin) SendEmailConfirmation(c echo.Context, user *User) error {
+	token, err := CreateToken(p.App, user.GetID(), TokenTypeEmailConfirmation, p.TokenTTL)
+	if err != nil {
+		return err
+	}
+
+	link := bolo.GetBaseURL(c) + "/auth/confirm-email?token=" + token
+
+	return p.Mailer.Send(c.Request().Context(), &mailer.Message{
+		From:    p.App.GetConfiguration().Get("MAILER_FROM"),
+		To:      []string{user.GetEmail()},
+		Subject: bolo.Translate(c, "Confirm your email"),
+		Text:    bolo.Translate(c, "Use the link below to confirm your email:") + "\n\n" + link + "\n",
+	})
+}
+
+func (ctl *AuthController) loginFailed(c echo.Context, lockoutKey string) {
+	if ctl.Plugin.Lockout == nil {
+		return
+	}
+
+	err := ctl.Plugin.Lockout.Fail(c.Request().Context(), lockoutKey, ctl.Plugin.App.GetClock().Now())
+	if err != nil {
+		bolo.GetLogger(c).Warn("AuthController.Login error on register login failure", zap.Error(err))
+	}
+}
+
+// safeRedirectTo - only allow relative redirects to avoid open redirects
+func safeRedirectTo(redirectTo string) string {
+	if redirectTo == "" || !strings.HasPrefix(redirectTo, "/") || strings.HasPrefix(redirectTo, "//") || strings.Contains(redirectTo, "\\") {
+		return "/"
+	}
+
+	return redirectTo
+}
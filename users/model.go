@@ -0,0 +1,260 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/go-bolo/bolo/helpers"
+)
+
+var (
+	ErrUserNotFound      = errors.New("user not found")
+	ErrUserWithoutApp    = errors.New("user model without app, use NewUser(app)")
+	ErrEmailAlreadyInUse = errors.New("email already in use")
+	ErrUserBlocked       = errors.New("user is blocked")
+	ErrUserInactive      = errors.New("user is inactive")
+	ErrInvalidLogin      = errors.New("invalid login or password")
+	// Too many failed logins, the login is locked for some time
+	ErrTooManyLoginFailures = errors.New("too many failed login attempts")
+)
+
+// User - Database user model that implements bolo.User and bolo.Model
+type User struct {
+	ID               uint64     `gorm:"primary_key;column:id;" json:"id"`
+	Username         string     `gorm:"column:username;type:varchar(100);uniqueIndex" json:"username"`
+	DisplayName      string     `gorm:"column:displayName;type:varchar(255)" json:"displayName"`
+	FullName         string     `gorm:"column:fullName;type:varchar(255)" json:"fullName"`
+	Email            string     `gorm:"column:email;type:varchar(255);uniqueIndex;not null" json:"email" validate:"required,email"`
+	Language         string     `gorm:"column:language;type:varchar(12)" json:"language"`
+	Roles            []string   `gorm:"column:roles;serializer:json" json:"roles"`
+	Active           bool       `gorm:"column:active;default:true" json:"active"`
+	Blocked          bool       `gorm:"column:blocked;default:false" json:"blocked"`
+	PasswordHash     string     `gorm:"column:passwordHash;type:varchar(255)" json:"-"`
+	EmailConfirmedAt *time.Time `gorm:"column:emailConfirmedAt" json:"emailConfirmedAt"`
+	CreatedAt        time.Time  `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt        time.Time  `gorm:"column:updatedAt" json:"updatedAt"`
+
+	app bolo.App `gorm:"-"`
+}
+
+func NewUser(app bolo.App) *User {
+	return &User{
+		Active: true,
+		Roles:  []string{},
+		app:    app,
+	}
+}
+
+func (r *User) TableName() string {
+	return "users"
+}
+
+func (r *User) SetApp(app bolo.App) {
+	r.app = app
+}
+
+func (r *User) GetID() string {
+	if r.ID == 0 {
+		return ""
+	}
+
+	return strconv.FormatUint(r.ID, 10)
+}
+
+func (r *User) SetID(id string) error {
+	v, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return fmt.Errorf("User.SetID invalid id: %w", err)
+	}
+
+	r.ID = v
+	return nil
+}
+
+func (r *User) GetDisplayName() string {
+	if r.DisplayName != "" {
+		return r.DisplayName
+	}
+
+	if r.FullName != "" {
+		return r.FullName
+	}
+
+	return r.Username
+}
+
+func (r *User) SetDisplayName(name string) error {
+	r.DisplayName = name
+	return nil
+}
+
+func (r *User) GetRoles() []string {
+	return r.Roles
+}
+
+func (r *User) SetRoles(v []string) error {
+	r.Roles = v
+	return nil
+}
+
+func (r *User) AddRole(role string) error {
+	if !helpers.SliceContains(r.Roles, role) {
+		r.Roles = append(r.Roles, role)
+	}
+
+	return nil
+}
+
+func (r *User) RemoveRole(role string) error {
+	r.Roles, _ = helpers.SliceRemove(r.Roles, role)
+	return nil
+}
+
+func (r *User) GetEmail() string {
+	return r.Email
+}
+
+func (r *User) SetEmail(v string) error {
+	r.Email = strings.ToLower(strings.TrimSpace(v))
+	return nil
+}
+
+func (r *User) GetUsername() string {
+	return r.Username
+}
+
+func (r *User) SetUsername(v string) error {
+	r.Username = v
+	return nil
+}
+
+func (r *User) GetFullName() string {
+	return r.FullName
+}
+
+func (r *User) SetFullName(v string) error {
+	r.FullName = v
+	return nil
+}
+
+func (r *User) GetLanguage() string {
+	return r.Language
+}
+
+func (r *User) SetLanguage(v string) error {
+	r.Language = v
+	return nil
+}
+
+func (r *User) IsActive() bool {
+	return r.Active
+}
+
+func (r *User) SetActive(active bool) error {
+	r.Active = active
+	return nil
+}
+
+func (r *User) IsBlocked() bool {
+	return r.Blocked
+}
+
+func (r *User) SetBlocked(blocked bool) error {
+	r.Blocked = blocked
+	return nil
+}
+
+func (r *User) IsEmailConfirmed() bool {
+	return r.EmailConfirmedAt != nil
+}
+
+func (r *User) FillById(id string) error {
+	if r.app == nil {
+		return ErrUserWithoutApp
+	}
+
+	err := r.app.GetDB().First(r, "id = ?", id).Error
+	if err != nil {
+		return fmt.Errorf("User.FillById: %w", err)
+	}
+
+	return nil
+}
+
+func (r *User) LoadData() error {
+	return nil
+}
+
+func (r *User) LoadTeaserData() error {
+	return nil
+}
+
+func (r *User) Save(app bolo.App) error {
+	db := app.GetDB()
+	r.app = app
+
+	r.UpdatedAt = app.GetClock().Now()
+
+	if r.ID == 0 {
+		r.CreatedAt = app.GetClock().Now()
+		// username is unique, users created only with email use the email as username
+		if r.Username == "" {
+			r.Username = r.Email
+		}
+
+		var count int64
+		err := db.Model(&User{}).Where("email = ?", r.Email).Count(&count).Error
+		if err != nil {
+			return err
+		}
+
+		if count > 0 {
+			return ErrEmailAlreadyInUse
+		}
+
+		return db.Create(r).Error
+	}
+
+	return db.Save(r).Error
+}
+
+// SetPassword - Hash and set the user password with the hasher
+func (r *User) SetPassword(hasher PasswordHasher, password string) error {
+	hash, err := hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	r.PasswordHash = hash
+	return nil
+}
+
+func FindOneByID(app bolo.App, id string) (*User, error) {
+	record := NewUser(app)
+	err := app.GetDB().First(record, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// FindOneByLogin - Find one user by email or username
+func FindOneByLogin(app bolo.App, login string) (*User, error) {
+	login = strings.TrimSpace(login)
+	if login == "" {
+		return nil, ErrUserNotFound
+	}
+
+	record := NewUser(app)
+	err := app.GetDB().Where("email = ? OR username = ?", strings.ToLower(login), login).First(record).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
@@ -0,0 +1,158 @@
+package users
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidPasswordHash = errors.New("invalid password hash")
+	ErrPasswordTooShort    = errors.New("password too short")
+)
+
+// PasswordHasher - Hash and verify user passwords
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify returns false without error for wrong passwords
+	Verify(hash, password string) (bool, error)
+}
+
+// NewPasswordHasher - Returns the hasher for one algorithm: bcrypt or argon2id
+func NewPasswordHasher(algorithm string) (PasswordHasher, error) {
+	switch algorithm {
+	case "", "bcrypt":
+		return &BcryptHasher{Cost: bcrypt.DefaultCost}, nil
+	case "argon2id", "argon2":
+		return NewArgon2Hasher(), nil
+	default:
+		return nil, fmt.Errorf("NewPasswordHasher: unknown algorithm: %s", algorithm)
+	}
+}
+
+// MultiHasher - Hash with one hasher and verify hashes from all supported algorithms.
+// Used to migrate password algorithms without reset all passwords
+type MultiHasher struct {
+	Default PasswordHasher
+}
+
+func (h *MultiHasher) Hash(password string) (string, error) {
+	return h.Default.Hash(password)
+}
+
+func (h *MultiHasher) Verify(hash, password string) (bool, error) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return NewArgon2Hasher().Verify(hash, password)
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return (&BcryptHasher{}).Verify(hash, password)
+	default:
+		return false, ErrInvalidPasswordHash
+	}
+}
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	cost := h.Cost
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+
+	b, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+func (h *BcryptHasher) Verify(hash, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err == nil {
+		return true, nil
+	}
+
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+
+	return false, err
+}
+
+// Argon2Hasher - argon2id hasher with the PHC string format: $argon2id$v=19$m=65536,t=1,p=4$salt$hash
+type Argon2Hasher struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	KeyLen  uint32
+	SaltLen uint32
+}
+
+func NewArgon2Hasher() *Argon2Hasher {
+	return &Argon2Hasher{
+		Time:    1,
+		Memory:  64 * 1024,
+		Threads: 4,
+		KeyLen:  32,
+		SaltLen: 16,
+	}
+}
+
+func (h *Argon2Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, h.KeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Time, h.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Argon2Hasher) Verify(hash, password string) (bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, ErrInvalidPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, ErrInvalidPasswordHash
+	}
+
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, ErrInvalidPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, ErrInvalidPasswordHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, ErrInvalidPasswordHash
+	}
+
+	other := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+// dummyPasswordHash - Verified on logins with unknown users to keep the response time similar
+const dummyPasswordHash = "$2a$10$hoJa8Q.YP81nE5PJvaQRcebzC6ykIhIOexHq6nFL0VNds7WkUBN9W"
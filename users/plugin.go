@@ -0,0 +1,268 @@
+package users
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/go-bolo/bolo/mailer"
+	"github.com/go-bolo/bolo/ratelimit"
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// Plugin - Optional users plugin with database users, sessions and credential flows
+type Plugin struct {
+	bolo.Plugin
+
+	Name           string
+	App            bolo.App
+	Mailer         mailer.Mailer
+	PasswordHasher PasswordHasher
+	SessionStore   SessionStore
+	Controller     *AuthController
+	// Password reset and email confirmation tokens lifetime
+	TokenTTL time.Duration
+	// Minimum password length used in password reset
+	MinPasswordLength int
+	// Failed logins lockout by login, default: USERS_LOGIN_MAX_FAILURES in USERS_LOGIN_LOCKOUT seconds
+	Lockout *ratelimit.Lockout
+	// Login requests limit by IP, default: USERS_LOGIN_RATE_LIMIT
+	LoginRateLimit *bolo.RateLimit
+}
+
+type PluginOpts struct {
+	Mailer         mailer.Mailer
+	PasswordHasher PasswordHasher
+	SessionStore   SessionStore
+}
+
+func NewPlugin(opts *PluginOpts) *Plugin {
+	return &Plugin{
+		Name:           "users",
+		Mailer:         opts.Mailer,
+		PasswordHasher: opts.PasswordHasher,
+		SessionStore:   opts.SessionStore,
+	}
+}
+
+func (p *Plugin) GetName() string {
+	return p.Name
+}
+
+func (p *Plugin) Init(app bolo.App) error {
+	app.GetLogger().Debug(p.GetName() + " Init")
+
+	p.App = app
+	cfg := app.GetConfiguration()
+
+	if p.PasswordHasher == nil {
+		hasher, err := NewPasswordHasher(cfg.GetF(bolo.USERS_PASSWORD_HASHER, "bcrypt"))
+		if err != nil {
+			return err
+		}
+
+		p.PasswordHasher = &MultiHasher{Default: hasher}
+	}
+
+	if p.SessionStore == nil {
+		cookie := NewSessionCookieOptsFromConfiguration(app)
+
+		switch cfg.GetF(bolo.SESSION_STORE, "db") {
+		case "cookie":
+			secret, err := bolo.SigningSecret(app, bolo.SESSION_SECRET)
+			if err != nil {
+				return fmt.Errorf("users.Plugin.Init: %w", err)
+			}
+
+			p.SessionStore = &CookieSessionStore{App: app, Cookie: cookie, Secret: secret}
+		default:
+			p.SessionStore = &DBSessionStore{App: app, Cookie: cookie}
+		}
+	}
+
+	if p.Mailer == nil {
+		if dir := cfg.Get("MAILER_DIR"); dir != "" {
+			p.Mailer = &mailer.FileMailer{Dir: dir}
+		} else {
+			p.Mailer = &mailer.LogMailer{Logger: app.GetLogger()}
+		}
+	}
+
+	if p.TokenTTL == 0 {
+		p.TokenTTL = time.Duration(cfg.GetInt64F(bolo.USERS_TOKEN_TTL, 60*60*24)) * time.Second
+	}
+
+	if p.MinPasswordLength == 0 {
+		p.MinPasswordLength = cfg.GetIntF(bolo.USERS_MIN_PASSWORD_LENGTH, 8)
+	}
+
+	if p.LoginRateLimit == nil {
+		limit, err := ratelimit.ParseLimit(cfg.GetF(bolo.USERS_LOGIN_RATE_LIMIT, "20/m"), ratelimit.AlgorithmSlidingWindow)
+		if err != nil {
+			return fmt.Errorf("users.Plugin.Init: %w", err)
+		}
+
+		p.LoginRateLimit = &bolo.RateLimit{Limit: *limit, By: bolo.RateLimitByIP}
+	}
+
+	p.Controller = &AuthController{Plugin: p}
+
+	app.SetModel("user", &User{})
+	app.SetModel("user_session", &Session{})
+	app.SetModel("user_token", &Token{})
+
+	app.GetEvents().On("bindMiddlewares", event.ListenerFunc(func(e event.Event) error {
+		if p.Lockout == nil && app.GetRateLimiter() != nil {
+			p.Lockout = &ratelimit.Lockout{
+				Store:       app.GetRateLimiter().Store,
+				MaxFailures: cfg.GetIntF(bolo.USERS_LOGIN_MAX_FAILURES, 5),
+				Duration:    time.Duration(cfg.GetInt64F(bolo.USERS_LOGIN_LOCKOUT, 15*60)) * time.Second,
+			}
+		}
+
+		app.GetRouter().Use(p.SessionMiddleware())
+		return nil
+	}), event.Normal)
+
+	p.setRoutes(app)
+
+	return nil
+}
+
+func (p *Plugin) setRoutes(app bolo.App) {
+	ctl := p.Controller
+
+	app.SetRoute("users_login_page", &bolo.Route{
+		Method:   "GET",
+		Path:     "/auth/login",
+		Action:   ctl.LoginPage,
+		Template: "users/login",
+	})
+	app.SetRoute("users_login", &bolo.Route{
+		Method:    "POST",
+		Path:      "/auth/login",
+		Action:    ctl.Login,
+		Template:  "users/login",
+		RateLimit: p.LoginRateLimit,
+	})
+	app.SetRoute("users_logout", &bolo.Route{
+		Method:   "POST",
+		Path:     "/auth/logout",
+		Action:   ctl.Logout,
+		Template: "users/logout",
+	})
+	app.SetRoute("users_forgot_password_page", &bolo.Route{
+		Method:   "GET",
+		Path:     "/auth/forgot-password",
+		Action:   ctl.ForgotPasswordPage,
+		Template: "users/forgot-password",
+	})
+	app.SetRoute("users_forgot_password", &bolo.Route{
+		Method:    "POST",
+		Path:      "/auth/forgot-password",
+		Action:    ctl.ForgotPassword,
+		Template:  "users/forgot-password",
+		RateLimit: p.LoginRateLimit,
+	})
+	app.SetRoute("users_reset_password_page", &bolo.Route{
+		Method:   "GET",
+		Path:     "/auth/reset-password",
+		Action:   ctl.ResetPasswordPage,
+		Template: "users/reset-password",
+	})
+	app.SetRoute("users_reset_password", &bolo.Route{
+		Method:   "POST",
+		Path:     "/auth/reset-password",
+		Action:   ctl.ResetPassword,
+		Template: "users/reset-password",
+	})
+	app.SetRoute("users_confirm_email", &bolo.Route{
+		Method:   "GET",
+		Path:     "/auth/confirm-email",
+		Action:   ctl.ConfirmEmail,
+		Template: "users/confirm-email",
+	})
+	app.SetRoute("users_send_confirmation", &bolo.Route{
+		Method:   "POST",
+		Path:     "/auth/confirm-email",
+		Action:   ctl.SendEmailConfirmation,
+		Template: "users/confirm-email",
+	})
+}
+
+// SessionMiddleware - Load the session user and set the request user and roles
+func (p *Plugin) SessionMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user, err := p.LoadSessionUser(c)
+			if err != nil {
+				bolo.GetLogger(c).Warn("users.SessionMiddleware error on load session", zap.Error(err))
+			}
+
+			if user == nil {
+				bolo.AddRole(c, "unAuthenticated")
+				return next(c)
+			}
+
+			bolo.SetAuthenticatedUser(c, user)
+			bolo.SetRoles(c, append([]string{"authenticated"}, user.GetRoles()...))
+
+			return next(c)
+		}
+	}
+}
+
+// LoadSessionUser - Returns the active user from the request session or nil
+func (p *Plugin) LoadSessionUser(c echo.Context) (*User, error) {
+	session, err := p.SessionStore.Load(c)
+	if err != nil || session == nil {
+		return nil, err
+	}
+
+	user, err := FindOneByID(p.App, session.UserID)
+	if err != nil {
+		return nil, nil
+	}
+
+	if user.IsBlocked() || !user.IsActive() {
+		return nil, nil
+	}
+
+	return user, nil
+}
+
+// Login - Start one session for the user, can be used by other authentication plugins
+func (p *Plugin) Login(c echo.Context, user bolo.User) error {
+	if user.IsBlocked() {
+		return ErrUserBlocked
+	}
+
+	if !user.IsActive() {
+		return ErrUserInactive
+	}
+
+	_, err := p.SessionStore.Create(c, user.GetID())
+	if err != nil {
+		return err
+	}
+
+	bolo.SetAuthenticatedUser(c, user)
+	bolo.SetRoles(c, append([]string{"authenticated"}, user.GetRoles()...))
+
+	return nil
+}
+
+// Logout - Destroy the current request session
+func (p *Plugin) Logout(c echo.Context) error {
+	err := p.SessionStore.Destroy(c)
+	if err != nil {
+		return err
+	}
+
+	bolo.SetAuthenticatedUser(c, nil)
+	bolo.SetRoles(c, []string{"unAuthenticated"})
+
+	return nil
+}
@@ -0,0 +1,298 @@
+package users
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidSession = errors.New("invalid session")
+	ErrSessionSecret  = errors.New("SESSION_SECRET is required for the cookie session store")
+)
+
+// Session - Authenticated user session
+type Session struct {
+	// sha256 of the session token, the token is only stored in the client cookie
+	ID        string    `gorm:"primary_key;column:id;type:varchar(64)" json:"-"`
+	UserID    string    `gorm:"column:userId;type:varchar(64);index" json:"userId"`
+	IP        string    `gorm:"column:ip;type:varchar(64)" json:"ip"`
+	UserAgent string    `gorm:"column:userAgent;type:varchar(255)" json:"userAgent"`
+	ExpiresAt time.Time `gorm:"column:expiresAt;index" json:"expiresAt"`
+	CreatedAt time.Time `gorm:"column:createdAt" json:"createdAt"`
+}
+
+func (r *Session) TableName() string {
+	return "user_sessions"
+}
+
+func (r *Session) GetID() string {
+	return r.ID
+}
+
+func (r *Session) LoadData() error {
+	return nil
+}
+
+func (r *Session) LoadTeaserData() error {
+	return nil
+}
+
+func (r *Session) Save(app bolo.App) error {
+	return app.GetDB().Save(r).Error
+}
+
+// SessionStore - Persist sessions and manage the session cookie
+type SessionStore interface {
+	// Create one session for the user and write the session cookie
+	Create(c echo.Context, userID string) (*Session, error)
+	// Load returns the current request session or nil
+	Load(c echo.Context) (*Session, error)
+	// Destroy the current request session and remove the cookie
+	Destroy(c echo.Context) error
+	// DestroyUserSessions remove all sessions of one user, used after password changes
+	DestroyUserSessions(userID string) error
+	// DeleteExpired remove the expired sessions from storage
+	DeleteExpired() error
+}
+
+// SessionCookieOpts - Session cookie configuration
+type SessionCookieOpts struct {
+	Name     string
+	TTL      time.Duration
+	Secure   bool
+	Domain   string
+	SameSite http.SameSite
+}
+
+func NewSessionCookieOptsFromConfiguration(app bolo.App) *SessionCookieOpts {
+	cfg := app.GetConfiguration()
+
+	return &SessionCookieOpts{
+		Name:     cfg.GetF(bolo.SESSION_COOKIE_NAME, "bolo_session"),
+		TTL:      time.Duration(cfg.GetInt64F(bolo.SESSION_TTL, 60*60*24*14)) * time.Second,
+		Secure:   cfg.GetBoolF(bolo.SESSION_COOKIE_SECURE, cfg.Get("PROTOCOL") == "https"),
+		Domain:   cfg.Get(bolo.SESSION_COOKIE_DOMAIN),
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func (o *SessionCookieOpts) write(c echo.Context, value string, expires time.Time) {
+	cookie := &http.Cookie{
+		Name:     o.Name,
+		Value:    value,
+		Path:     "/",
+		Domain:   o.Domain,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   o.Secure,
+		SameSite: o.SameSite,
+	}
+
+	if value == "" {
+		cookie.MaxAge = -1
+	}
+
+	c.SetCookie(cookie)
+}
+
+// DBSessionStore - Sessions stored in the app default database
+type DBSessionStore struct {
+	App    bolo.App
+	Cookie *SessionCookieOpts
+}
+
+func (s *DBSessionStore) Create(c echo.Context, userID string) (*Session, error) {
+	token, err := newRandomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.App.GetClock().Now()
+
+	session := &Session{
+		ID:        hashToken(token),
+		UserID:    userID,
+		IP:        c.RealIP(),
+		UserAgent: truncate(c.Request().UserAgent(), 255),
+		ExpiresAt: now.Add(s.Cookie.TTL),
+		CreatedAt: now,
+	}
+
+	err = s.App.GetDB().WithContext(c.Request().Context()).Create(session).Error
+	if err != nil {
+		return nil, fmt.Errorf("DBSessionStore.Create: %w", err)
+	}
+
+	s.Cookie.write(c, token, session.ExpiresAt)
+
+	return session, nil
+}
+
+func (s *DBSessionStore) Load(c echo.Context) (*Session, error) {
+	cookie, err := c.Cookie(s.Cookie.Name)
+	if err != nil || cookie.Value == "" {
+		return nil, nil
+	}
+
+	session := Session{}
+	err = s.App.GetDB().WithContext(c.Request().Context()).First(&session, "id = ?", hashToken(cookie.Value)).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("DBSessionStore.Load: %w", err)
+	}
+
+	if !session.ExpiresAt.After(s.App.GetClock().Now()) {
+		return nil, nil
+	}
+
+	return &session, nil
+}
+
+func (s *DBSessionStore) Destroy(c echo.Context) error {
+	cookie, err := c.Cookie(s.Cookie.Name)
+	if err == nil && cookie.Value != "" {
+		err = s.App.GetDB().WithContext(c.Request().Context()).Delete(&Session{}, "id = ?", hashToken(cookie.Value)).Error
+		if err != nil {
+			return fmt.Errorf("DBSessionStore.Destroy: %w", err)
+		}
+	}
+
+	s.Cookie.write(c, "", time.Unix(0, 0))
+
+	return nil
+}
+
+func (s *DBSessionStore) DestroyUserSessions(userID string) error {
+	return s.App.GetDB().Delete(&Session{}, "userId = ?", userID).Error
+}
+
+func (s *DBSessionStore) DeleteExpired() error {
+	return s.App.GetDB().Delete(&Session{}, "expiresAt <= ?", s.App.GetClock().Now()).Error
+}
+
+// CookieSessionStore - Stateless sessions stored in one signed cookie.
+// Sessions can't be revoked before expiration, DestroyUserSessions only works with the DBSessionStore
+type CookieSessionStore struct {
+	App    bolo.App
+	Cookie *SessionCookieOpts
+	Secret []byte
+}
+
+type cookieSessionPayload struct {
+	UserID    string `json:"u"`
+	ExpiresAt int64  `json:"e"`
+}
+
+func (s *CookieSessionStore) Create(c echo.Context, userID string) (*Session, error) {
+	if len(s.Secret) == 0 {
+		return nil, ErrSessionSecret
+	}
+
+	now := s.App.GetClock().Now()
+	session := &Session{
+		UserID:    userID,
+		IP:        c.RealIP(),
+		ExpiresAt: now.Add(s.Cookie.TTL),
+		CreatedAt: now,
+	}
+
+	payload, err := json.Marshal(&cookieSessionPayload{UserID: userID, ExpiresAt: session.ExpiresAt.Unix()})
+	if err != nil {
+		return nil, err
+	}
+
+	value := base64.RawURLEncoding.EncodeToString(payload)
+	s.Cookie.write(c, value+"."+s.sign(value), session.ExpiresAt)
+
+	return session, nil
+}
+
+func (s *CookieSessionStore) Load(c echo.Context) (*Session, error) {
+	if len(s.Secret) == 0 {
+		return nil, ErrSessionSecret
+	}
+
+	cookie, err := c.Cookie(s.Cookie.Name)
+	if err != nil || cookie.Value == "" {
+		return nil, nil
+	}
+
+	value, signature, found := strings.Cut(cookie.Value, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(s.sign(value))) {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, nil
+	}
+
+	payload := cookieSessionPayload{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, nil
+	}
+
+	expiresAt := time.Unix(payload.ExpiresAt, 0)
+	if !expiresAt.After(s.App.GetClock().Now()) {
+		return nil, nil
+	}
+
+	return &Session{UserID: payload.UserID, ExpiresAt: expiresAt}, nil
+}
+
+func (s *CookieSessionStore) Destroy(c echo.Context) error {
+	s.Cookie.write(c, "", time.Unix(0, 0))
+	return nil
+}
+
+func (s *CookieSessionStore) DestroyUserSessions(userID string) error {
+	return nil
+}
+
+func (s *CookieSessionStore) DeleteExpired() error {
+	return nil
+}
+
+func (s *CookieSessionStore) sign(value string) string {
+	mac := hmac.New(sha256.New, s.Secret)
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func newRandomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func truncate(s string, length int) string {
+	if len(s) > length {
+		return s[:length]
+	}
+
+	return s
+}
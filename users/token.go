@@ -0,0 +1,128 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"gorm.io/gorm"
+)
+
+const (
+	TokenTypePasswordReset     = "password_reset"
+	TokenTypeEmailConfirmation = "email_confirmation"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid or expired token")
+)
+
+// Token - One time use tokens for password reset and email confirmation
+type Token struct {
+	ID     uint64 `gorm:"primary_key;column:id;" json:"id"`
+	UserID string `gorm:"column:userId;type:varchar(64);index" json:"userId"`
+	Type   string `gorm:"column:type;type:varchar(40)" json:"type"`
+	// sha256 of the token sent to the user
+	TokenHash string     `gorm:"column:tokenHash;type:varchar(64);uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"column:expiresAt" json:"expiresAt"`
+	UsedAt    *time.Time `gorm:"column:usedAt" json:"usedAt"`
+	CreatedAt time.Time  `gorm:"column:createdAt" json:"createdAt"`
+}
+
+func (r *Token) TableName() string {
+	return "user_tokens"
+}
+
+func (r *Token) GetID() string {
+	return strconv.FormatUint(r.ID, 10)
+}
+
+func (r *Token) LoadData() error {
+	return nil
+}
+
+func (r *Token) LoadTeaserData() error {
+	return nil
+}
+
+func (r *Token) Save(app bolo.App) error {
+	return app.GetDB().Save(r).Error
+}
+
+// CreateToken - Create one token for the user and returns the plain token, only the token hash is stored
+func CreateToken(app bolo.App, userID, tokenType string, ttl time.Duration) (string, error) {
+	plain, err := newRandomToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := app.GetClock().Now()
+
+	record := Token{
+		UserID:    userID,
+		Type:      tokenType,
+		TokenHash: hashToken(plain),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+
+	err = app.GetDB().Create(&record).Error
+	if err != nil {
+		return "", fmt.Errorf("CreateToken: %w", err)
+	}
+
+	return plain, nil
+}
+
+// ConsumeToken - Validate and mark one token as used, returns the token user
+func ConsumeToken(app bolo.App, tokenType, plain string) (*User, error) {
+	if plain == "" {
+		return nil, ErrInvalidToken
+	}
+
+	var user *User
+
+	err := app.GetDB().Transaction(func(tx *gorm.DB) error {
+		record := Token{}
+		err := tx.First(&record, "tokenHash = ? AND type = ?", hashToken(plain), tokenType).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidToken
+			}
+			return err
+		}
+
+		now := app.GetClock().Now()
+		if record.UsedAt != nil || !record.ExpiresAt.After(now) {
+			return ErrInvalidToken
+		}
+
+		// conditional update to avoid concurrent use of the same token
+		result := tx.Model(&Token{}).Where("id = ? AND usedAt IS NULL", record.ID).Update("usedAt", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidToken
+		}
+
+		user = NewUser(app)
+		err = tx.First(user, "id = ?", record.UserID).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidToken
+			}
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
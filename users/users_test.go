@@ -0,0 +1,327 @@
+package users_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/go-bolo/bolo/mailer"
+	"github.com/go-bolo/bolo/users"
+	"github.com/go-bolo/clock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type captureMailer struct {
+	mu       sync.Mutex
+	Messages []*mailer.Message
+}
+
+func (m *captureMailer) Send(ctx context.Context, msg *mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Messages = append(m.Messages, msg)
+	return nil
+}
+
+func newTestApp(t *testing.T) (bolo.App, *users.Plugin, *captureMailer) {
+	os.Setenv("TEMPLATE_DISABLE", "true")
+	os.Setenv("DB_URI", filepath.Join(t.TempDir(), "users.sqlite"))
+	t.Cleanup(func() {
+		os.Unsetenv("TEMPLATE_DISABLE")
+		os.Unsetenv("DB_URI")
+	})
+
+	app := bolo.NewApp(&bolo.DefaultAppOptions{})
+
+	c := clock.NewMock()
+	now, _ := time.Parse("2006-01-02", "2023-07-16")
+	c.Set(now)
+	app.SetClock(c)
+
+	m := &captureMailer{}
+	p := users.NewPlugin(&users.PluginOpts{
+		Mailer:         m,
+		PasswordHasher: &users.BcryptHasher{Cost: 4},
+	})
+	app.AddPlugin(p)
+
+	require.Nil(t, app.Bootstrap())
+	require.Nil(t, app.SyncDB())
+
+	return app, p, m
+}
+
+func createUser(t *testing.T, app bolo.App, p *users.Plugin, email, password string) *users.User {
+	u := users.NewUser(app)
+	u.SetEmail(email)
+	u.SetUsername(strings.Split(email, "@")[0])
+	u.AddRole("editor")
+	require.Nil(t, u.SetPassword(p.PasswordHasher, password))
+	require.Nil(t, u.Save(app))
+	return u
+}
+
+func doJSON(app bolo.App, method, path string, body interface{}, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	var b []byte
+	if body != nil {
+		b, _ = json.Marshal(body)
+	}
+
+	req := httptest.NewRequest(method, path, strings.NewReader(string(b)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+	// same as one JavaScript client that sends the CSRF cookie value in the header:
+	req.Header.Set(bolo.CSRFHeaderName, "test-csrf-token")
+	req.AddCookie(&http.Cookie{Name: bolo.CSRFCookieName, Value: "test-csrf-token"})
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+
+	rec := httptest.NewRecorder()
+	app.GetRouter().ServeHTTP(rec, req)
+	return rec
+}
+
+func sessionCookie(rec *httptest.ResponseRecorder) *http.Cookie {
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "bolo_session" {
+			return c
+		}
+	}
+
+	return nil
+}
+
+func TestLoginAndLogout(t *testing.T) {
+	app, p, _ := newTestApp(t)
+	createUser(t, app, p, "alice@example.com", "a-strong-password")
+
+	var currentUser bolo.User
+	var currentRoles []string
+	app.GetRouter().GET("/me", func(c echo.Context) error {
+		currentUser = bolo.GetAuthenticatedUser(c)
+		currentRoles = bolo.GetRoles(c)
+		return c.NoContent(http.StatusOK)
+	})
+
+	t.Run("Should reject wrong passwords", func(t *testing.T) {
+		rec := doJSON(app, http.MethodPost, "/auth/login", map[string]string{"login": "alice@example.com", "password": "wrong-password"})
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Nil(t, sessionCookie(rec))
+	})
+
+	t.Run("Should reject unknown users", func(t *testing.T) {
+		rec := doJSON(app, http.MethodPost, "/auth/login", map[string]string{"login": "bob@example.com", "password": "a-strong-password"})
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	rec := doJSON(app, http.MethodPost, "/auth/login", map[string]string{"login": "alice", "password": "a-strong-password"})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.NotContains(t, rec.Body.String(), "passwordHash")
+
+	cookie := sessionCookie(rec)
+	require.NotNil(t, cookie)
+	assert.True(t, cookie.HttpOnly)
+
+	doJSON(app, http.MethodGet, "/me", nil, cookie)
+	require.NotNil(t, currentUser)
+	assert.Equal(t, "alice@example.com", currentUser.GetEmail())
+	assert.Equal(t, []string{"authenticated", "editor"}, currentRoles)
+
+	rec = doJSON(app, http.MethodPost, "/auth/logout", nil, cookie)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	doJSON(app, http.MethodGet, "/me", nil, cookie)
+	assert.Nil(t, currentUser)
+	assert.Equal(t, []string{"unAuthenticated"}, currentRoles)
+}
+
+func TestLoginBlockedAndInactiveUsers(t *testing.T) {
+	app, p, _ := newTestApp(t)
+
+	blocked := createUser(t, app, p, "blocked@example.com", "a-strong-password")
+	blocked.SetBlocked(true)
+	require.Nil(t, blocked.Save(app))
+
+	inactive := createUser(t, app, p, "inactive@example.com", "a-strong-password")
+	inactive.SetActive(false)
+	require.Nil(t, inactive.Save(app))
+
+	rec := doJSON(app, http.MethodPost, "/auth/login", map[string]string{"login": "blocked@example.com", "password": "a-strong-password"})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Nil(t, sessionCookie(rec))
+
+	rec = doJSON(app, http.MethodPost, "/auth/login", map[string]string{"login": "inactive@example.com", "password": "a-strong-password"})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Nil(t, sessionCookie(rec))
+}
+
+func TestPasswordResetFlow(t *testing.T) {
+	app, p, m := newTestApp(t)
+	createUser(t, app, p, "alice@example.com", "old-password")
+
+	rec := doJSON(app, http.MethodPost, "/auth/forgot-password", map[string]string{"email": "unknown@example.com"})
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Len(t, m.Messages, 0)
+
+	rec = doJSON(app, http.MethodPost, "/auth/forgot-password", map[string]string{"email": "alice@example.com"})
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	require.Len(t, m.Messages, 1)
+	assert.Equal(t, []string{"alice@example.com"}, m.Messages[0].To)
+
+	start := strings.Index(m.Messages[0].Text, "http")
+	require.True(t, start >= 0)
+	link, err := url.Parse(strings.TrimSpace(m.Messages[0].Text[start:]))
+	require.Nil(t, err)
+	assert.Equal(t, "/auth/reset-password", link.Path)
+	token := link.Query().Get("token")
+
+	rec = doJSON(app, http.MethodPost, "/auth/reset-password", map[string]string{"token": token, "password": "short"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doJSON(app, http.MethodPost, "/auth/reset-password", map[string]string{"token": token, "password": "new-password"})
+	assert.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+	// tokens are single use:
+	rec = doJSON(app, http.MethodPost, "/auth/reset-password", map[string]string{"token": token, "password": "other-password"})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doJSON(app, http.MethodPost, "/auth/login", map[string]string{"login": "alice@example.com", "password": "old-password"})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = doJSON(app, http.MethodPost, "/auth/login", map[string]string{"login": "alice@example.com", "password": "new-password"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestEmailConfirmation(t *testing.T) {
+	app, p, m := newTestApp(t)
+	u := createUser(t, app, p, "alice@example.com", "a-strong-password")
+
+	rec := doJSON(app, http.MethodPost, "/auth/confirm-email", map[string]string{"email": "alice@example.com"})
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	require.Len(t, m.Messages, 1)
+
+	link, err := url.Parse(strings.TrimSpace(m.Messages[0].Text[strings.Index(m.Messages[0].Text, "http"):]))
+	require.Nil(t, err)
+
+	rec = doJSON(app, http.MethodGet, "/auth/confirm-email?token=invalid", nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = doJSON(app, http.MethodGet, link.RequestURI(), nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	saved, err := users.FindOneByID(app, u.GetID())
+	require.Nil(t, err)
+	assert.True(t, saved.IsEmailConfirmed())
+}
+
+func TestPasswordHashers(t *testing.T) {
+	for _, algorithm := range []string{"bcrypt", "argon2id"} {
+		t.Run(algorithm, func(t *testing.T) {
+			hasher, err := users.NewPasswordHasher(algorithm)
+			require.Nil(t, err)
+
+			hash, err := hasher.Hash("secret-password")
+			require.Nil(t, err)
+
+			valid, err := hasher.Verify(hash, "secret-password")
+			assert.Nil(t, err)
+			assert.True(t, valid)
+
+			valid, err = hasher.Verify(hash, "other-password")
+			assert.Nil(t, err)
+			assert.False(t, valid)
+
+			// MultiHasher should verify hashes from all algorithms:
+			multi := &users.MultiHasher{Default: &users.BcryptHasher{Cost: 4}}
+			valid, err = multi.Verify(hash, "secret-password")
+			assert.Nil(t, err)
+			assert.True(t, valid)
+		})
+	}
+
+	_, err := users.NewPasswordHasher("md5")
+	assert.NotNil(t, err)
+}
+
+func TestCookieSessionStore(t *testing.T) {
+	app, _, _ := newTestApp(t)
+
+	store := &users.CookieSessionStore{
+		App:    app,
+		Cookie: users.NewSessionCookieOptsFromConfiguration(app),
+		Secret: []byte("test-secret"),
+	}
+
+	c := bolo.NewContext(app)
+	_, err := store.Create(c, "10")
+	require.Nil(t, err)
+
+	cookie := sessionCookie(c.Response().Writer.(*httptest.ResponseRecorder))
+	require.NotNil(t, cookie)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	c = app.GetRouter().NewContext(req, httptest.NewRecorder())
+
+	session, err := store.Load(c)
+	require.Nil(t, err)
+	require.NotNil(t, session)
+	assert.Equal(t, "10", session.UserID)
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value + "x"})
+	c = app.GetRouter().NewContext(req, httptest.NewRecorder())
+
+	session, err = store.Load(c)
+	assert.Nil(t, err)
+	assert.Nil(t, session)
+}
+
+func TestCookieSessionStoreSecret(t *testing.T) {
+	t.Setenv("SESSION_STORE", "cookie")
+
+	app, p, _ := newTestApp(t)
+
+	store, ok := p.SessionStore.(*users.CookieSessionStore)
+	require.True(t, ok)
+
+	secret, err := bolo.SigningSecret(app, bolo.SESSION_SECRET)
+	require.Nil(t, err)
+	assert.Len(t, store.Secret, 32)
+	assert.Equal(t, secret, store.Secret)
+}
+
+func TestLoginLockout(t *testing.T) {
+	os.Setenv("USERS_LOGIN_MAX_FAILURES", "2")
+	defer os.Unsetenv("USERS_LOGIN_MAX_FAILURES")
+
+	app, p, _ := newTestApp(t)
+	createUser(t, app, p, "alice@example.com", "a-strong-password")
+
+	for i := 0; i < 2; i++ {
+		rec := doJSON(app, http.MethodPost, "/auth/login", map[string]string{"login": "alice@example.com", "password": "wrong-password"})
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+
+	// locked even with the right password:
+	rec := doJSON(app, http.MethodPost, "/auth/login", map[string]string{"login": "Alice@example.com", "password": "a-strong-password"})
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	app.GetClock().(*clock.Mock).Add(31 * time.Minute)
+
+	rec = doJSON(app, http.MethodPost, "/auth/login", map[string]string{"login": "alice@example.com", "password": "a-strong-password"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
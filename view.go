@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-bolo/bolo/pagination"
 	"github.com/labstack/echo/v4"
@@ -76,6 +77,13 @@ func (t *TemplateRenderer) Render(w io.Writer, name string, data interface{}, c
 		// v is a string here, so e.g. v + " Yeah!" is possible.
 		fmt.Printf("String: %v", v)
 	default:
+		start := time.Now()
+		_, span := Tracer(app).Start(c.Request().Context(), "template.render "+name)
+		defer func() {
+			span.End()
+			getCoreMetrics(app.GetMetrics()).templateRenderDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+		}()
+
 		htmlContext := data.(*TemplateCTX)
 		theme := GetTheme(c)
 		layout := GetLayout(c)
@@ -263,6 +271,18 @@ func SetCoreTemplateFunctions(app App) error {
 	app.SetTemplateFunction("currentDate", currentDate)
 	app.SetTemplateFunction("responseMessagesRender", ResponseMessagesRender)
 	app.SetTemplateFunction("partial", partial)
+	app.SetTemplateFunction("t", translateTemplateFunc)
+	app.SetTemplateFunction("tn", translatePluralTemplateFunc)
+	app.SetTemplateFunction("locale", localeTemplateFunc)
+	app.SetTemplateFunction("formatNumber", FormatNumber)
+	app.SetTemplateFunction("formatCurrency", FormatCurrency)
+	app.SetTemplateFunction("formatPercent", FormatPercent)
+	app.SetTemplateFunction("formatDate", FormatDate)
+	app.SetTemplateFunction("timeAgo", FormatRelativeTime)
+	app.SetTemplateFunction("seoTags", SEOTags)
+	app.SetTemplateFunction("csrfField", CSRFField)
+	app.SetTemplateFunction("csrfToken", GetCSRFToken)
+	app.SetTemplateFunction("cspNonce", cspNonceTemplateFunc)
 
 	return nil
 }
@@ -0,0 +1,312 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/go-bolo/bolo"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// PermissionManageAll - Allow to manage the webhooks from all users
+const PermissionManageAll = "manage_all_webhook"
+
+type EndpointController struct {
+	Plugin *Plugin
+}
+
+type ListJSONResponse struct {
+	bolo.BaseListReponse
+	Endpoints []*Endpoint `json:"webhook"`
+}
+
+type CountJSONResponse struct {
+	bolo.BaseMetaResponse
+}
+
+type FindOneJSONResponse struct {
+	Endpoint *Endpoint `json:"webhook"`
+}
+
+// CreateJSONResponse - The signing Secret is only returned on create
+type CreateJSONResponse struct {
+	Endpoint *Endpoint `json:"webhook"`
+	Secret   string    `json:"secret"`
+}
+
+type DeliveriesJSONResponse struct {
+	bolo.BaseListReponse
+	Deliveries []*Delivery `json:"webhookDelivery"`
+}
+
+type BodyRequest struct {
+	Endpoint *EndpointBody `json:"webhook"`
+}
+
+type EndpointBody struct {
+	URL         string   `json:"url" validate:"required,url"`
+	Description string   `json:"description"`
+	Events      []string `json:"events" validate:"required,min=1"`
+	Active      *bool    `json:"active"`
+}
+
+func (ctl *EndpointController) Find(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	limit := bolo.GetLimit(c)
+	if limit <= 0 {
+		limit = 50
+	}
+
+	var count int64
+	records := []*Endpoint{}
+
+	query := scopedQuery(c, bolo.GetDB(c).Model(&Endpoint{}))
+
+	err := query.Count(&count).Error
+	if err != nil {
+		return nil, fmt.Errorf("EndpointController.Find: %w", err)
+	}
+
+	err = query.Order("id DESC").Limit(limit).Offset(bolo.GetOffset(c)).Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("EndpointController.Find: %w", err)
+	}
+
+	bolo.GetPager(c).Count = count
+
+	resp := ListJSONResponse{Endpoints: records}
+	resp.Meta.Count = count
+
+	return &bolo.DefaultResponse{Data: &resp}, nil
+}
+
+func (ctl *EndpointController) Count(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	err := scopedQuery(c, bolo.GetDB(c).Model(&Endpoint{})).Count(&count).Error
+	if err != nil {
+		return nil, fmt.Errorf("EndpointController.Count: %w", err)
+	}
+
+	return &bolo.DefaultResponse{
+		Data: &CountJSONResponse{BaseMetaResponse: bolo.BaseMetaResponse{Count: count}},
+	}, nil
+}
+
+func (ctl *EndpointController) FindOne(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	record, err := loadRecord(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bolo.DefaultResponse{Data: &FindOneJSONResponse{Endpoint: record}}, nil
+}
+
+func (ctl *EndpointController) Create(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	body, err := ctl.bindBody(c)
+	if err != nil {
+		return nil, err
+	}
+
+	record := Endpoint{
+		UserID:      bolo.GetAuthenticatedUser(c).GetID(),
+		URL:         body.URL,
+		Description: body.Description,
+		Events:      body.Events,
+		Active:      true,
+	}
+
+	if body.Active != nil {
+		record.Active = *body.Active
+	}
+
+	secret, err := record.GenerateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("EndpointController.Create: %w", err)
+	}
+
+	err = record.Save(bolo.GetApp(c))
+	if err != nil {
+		return nil, fmt.Errorf("EndpointController.Create: %w", err)
+	}
+
+	return &bolo.DefaultResponse{
+		Status: http.StatusCreated,
+		Data:   &CreateJSONResponse{Endpoint: &record, Secret: secret},
+	}, nil
+}
+
+func (ctl *EndpointController) Update(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	record, err := loadRecord(c)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := ctl.bindBody(c)
+	if err != nil {
+		return nil, err
+	}
+
+	record.URL = body.URL
+	record.Description = body.Description
+	record.Events = body.Events
+	if body.Active != nil {
+		record.Active = *body.Active
+	}
+
+	err = record.Save(bolo.GetApp(c))
+	if err != nil {
+		return nil, fmt.Errorf("EndpointController.Update: %w", err)
+	}
+
+	return &bolo.DefaultResponse{Data: &FindOneJSONResponse{Endpoint: record}}, nil
+}
+
+func (ctl *EndpointController) Delete(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	record, err := loadRecord(c)
+	if err != nil {
+		return nil, err
+	}
+
+	err = bolo.GetDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&Delivery{}, "endpointId = ?", record.ID).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(record).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("EndpointController.Delete: %w", err)
+	}
+
+	return &bolo.DefaultResponse{Status: http.StatusNoContent}, nil
+}
+
+// FindDeliveries - Delivery log of one endpoint, newest first
+func (ctl *EndpointController) FindDeliveries(c echo.Context) (bolo.Response, error) {
+	if err := checkAccess(c); err != nil {
+		return nil, err
+	}
+
+	record, err := loadRecord(c)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := bolo.GetLimit(c)
+	if limit <= 0 {
+		limit = 50
+	}
+
+	var count int64
+	records := []*Delivery{}
+
+	query := bolo.GetDB(c).Model(&Delivery{}).Where("endpointId = ?", record.ID)
+
+	err = query.Count(&count).Error
+	if err != nil {
+		return nil, fmt.Errorf("EndpointController.FindDeliveries: %w", err)
+	}
+
+	err = query.Order("id DESC").Limit(limit).Offset(bolo.GetOffset(c)).Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("EndpointController.FindDeliveries: %w", err)
+	}
+
+	bolo.GetPager(c).Count = count
+
+	resp := DeliveriesJSONResponse{Deliveries: records}
+	resp.Meta.Count = count
+
+	return &bolo.DefaultResponse{Data: &resp}, nil
+}
+
+// scopedQuery - Users only access their own endpoints
+func scopedQuery(c echo.Context, query *gorm.DB) *gorm.DB {
+	if bolo.Can(c, PermissionManageAll) {
+		return query
+	}
+
+	return query.Where("userId = ?", bolo.GetAuthenticatedUser(c).GetID())
+}
+
+func loadRecord(c echo.Context) (*Endpoint, error) {
+	record := Endpoint{}
+	err := scopedQuery(c, bolo.GetDB(c)).First(&record, "id = ?", c.Param("id")).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &bolo.HTTPError{Code: http.StatusNotFound, Message: "Not found", Internal: err}
+		}
+
+		return nil, fmt.Errorf("EndpointController.loadRecord: %w", err)
+	}
+
+	return &record, nil
+}
+
+func checkAccess(c echo.Context) error {
+	if !bolo.IsAuthenticated(c) {
+		return &bolo.HTTPError{Code: http.StatusUnauthorized, Message: "Unauthorized"}
+	}
+
+	route := bolo.GetRoute(c)
+	if route.Permission != "" && !bolo.Can(c, route.Permission) {
+		return &bolo.HTTPError{Code: http.StatusForbidden, Message: "Forbidden"}
+	}
+
+	return nil
+}
+
+// bindBody - Parse and validate the body, only http and https URLs of public hosts are accepted
+func (ctl *EndpointController) bindBody(c echo.Context) (*EndpointBody, error) {
+	body := BodyRequest{}
+	if err := c.Bind(&body); err != nil {
+		return nil, &bolo.HTTPError{Code: http.StatusBadRequest, Message: "Invalid body data", Internal: err}
+	}
+
+	if body.Endpoint == nil {
+		return nil, &bolo.HTTPError{Code: http.StatusBadRequest, Message: "webhook is required"}
+	}
+
+	if err := c.Validate(body.Endpoint); err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(body.Endpoint.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, &bolo.HTTPError{Code: http.StatusBadRequest, Message: "url must be one http or https URL"}
+	}
+
+	if !ctl.Plugin.AllowPrivateNetworks {
+		if err := CheckHost(c.Request().Context(), u.Hostname()); err != nil {
+			return nil, &bolo.HTTPError{Code: http.StatusBadRequest, Message: "url must be one public host", Internal: err}
+		}
+	}
+
+	return body.Endpoint, nil
+}
@@ -0,0 +1,115 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"go.uber.org/zap"
+)
+
+// Deliver - Send one signed event to the endpoint and save the attempt in the delivery log.
+// Returns one error if the request fails or the response status is not 2xx
+func (p *Plugin) Deliver(ctx context.Context, endpoint *Endpoint, e *bolo.DomainEvent, attempt int) (*Delivery, error) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("webhooks.Deliver error on encode event: %w", err)
+	}
+
+	delivery := Delivery{
+		EndpointID: endpoint.ID,
+		EventID:    e.ID,
+		EventName:  e.Name,
+		Attempt:    attempt,
+		URL:        endpoint.URL,
+	}
+
+	start := time.Now()
+	deliveryErr := p.send(ctx, endpoint, e, body, &delivery)
+	delivery.Duration = time.Since(start).Milliseconds()
+
+	if deliveryErr != nil {
+		delivery.Error = deliveryErr.Error()
+	} else {
+		delivery.Success = true
+	}
+
+	if err := delivery.Save(p.App); err != nil {
+		return nil, fmt.Errorf("webhooks.Deliver error on save delivery: %w", err)
+	}
+
+	p.App.GetLogger().Debug("webhooks: delivery",
+		zap.String("endpointID", endpoint.GetID()),
+		zap.String("eventID", e.ID),
+		zap.Int("attempt", attempt),
+		zap.Int("status", delivery.ResponseCode),
+		zap.Error(deliveryErr),
+	)
+
+	return &delivery, deliveryErr
+}
+
+func (p *Plugin) send(ctx context.Context, endpoint *Endpoint, e *bolo.DomainEvent, body []byte, delivery *Delivery) error {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := p.App.GetClock().Now().Unix()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "bolo-webhooks")
+	req.Header.Set(HeaderID, e.ID)
+	req.Header.Set(HeaderEvent, e.Name)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(endpoint.Secret, timestamp, body))
+
+	res, err := p.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	delivery.ResponseCode = res.StatusCode
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, int64(p.ResponseBodyLimit)))
+	if err == nil {
+		delivery.ResponseBody = string(data)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// client - Delivery client, without private networks the client only connects to public addresses
+func (p *Plugin) client() bolo.CustomHTTPClient {
+	if p.HTTPClient != nil {
+		return p.HTTPClient
+	}
+
+	if !p.AllowPrivateNetworks {
+		return p.publicClient
+	}
+
+	if c := p.App.GetHTTPClient(); c != nil {
+		return c
+	}
+
+	if bolo.HttpClient != nil {
+		return bolo.HttpClient
+	}
+
+	return http.DefaultClient
+}
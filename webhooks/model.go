@@ -0,0 +1,170 @@
+package webhooks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrEndpointNotFound = errors.New("webhook endpoint not found")
+)
+
+// Endpoint - One URL registered by one user to receive the selected events
+type Endpoint struct {
+	ID          uint64 `gorm:"primary_key;column:id;" json:"id"`
+	UserID      string `gorm:"column:userId;type:varchar(64);index" json:"userId"`
+	URL         string `gorm:"column:url;type:varchar(2048)" json:"url"`
+	Description string `gorm:"column:description;type:varchar(255)" json:"description"`
+	// Event patterns like model.created, api_key.* or one custom event name
+	Events []string `gorm:"column:events;serializer:json" json:"events"`
+	// HMAC-SHA256 key of the deliveries signature, only returned on create
+	Secret    string    `gorm:"column:secret;type:varchar(100)" json:"-"`
+	Active    bool      `gorm:"column:active;default:true" json:"active"`
+	CreatedAt time.Time `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt time.Time `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+func (r *Endpoint) TableName() string {
+	return "webhook_endpoints"
+}
+
+func (r *Endpoint) GetID() string {
+	return strconv.FormatUint(r.ID, 10)
+}
+
+func (r *Endpoint) LoadData() error {
+	return nil
+}
+
+func (r *Endpoint) LoadTeaserData() error {
+	return nil
+}
+
+func (r *Endpoint) Save(app bolo.App) error {
+	now := app.GetClock().Now()
+	r.UpdatedAt = now
+
+	if r.ID == 0 {
+		r.CreatedAt = now
+		return app.GetDB().Create(r).Error
+	}
+
+	return app.GetDB().Save(r).Error
+}
+
+// GenerateSecret - Set one new random signing secret and returns it
+func (r *Endpoint) GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	r.Secret = "whsec_" + hex.EncodeToString(b)
+	return r.Secret, nil
+}
+
+// Matches - Check if the endpoint is subscribed to the event.
+// Model events also match the <model>.<action> patterns, like api_key.created
+func (r *Endpoint) Matches(e *bolo.DomainEvent) bool {
+	names := []string{e.Name}
+	if e.Model != "" && strings.HasPrefix(e.Name, "model.") {
+		names = append(names, e.Model+"."+strings.TrimPrefix(e.Name, "model."))
+	}
+
+	for _, pattern := range r.Events {
+		for _, name := range names {
+			if bolo.MatchDomainEventName(pattern, name) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Delivery - Log of one delivery attempt with the endpoint response
+type Delivery struct {
+	ID         uint64 `gorm:"primary_key;column:id;" json:"id"`
+	EndpointID uint64 `gorm:"column:endpointId;index" json:"endpointId"`
+	EventID    string `gorm:"column:eventId;type:varchar(36);index" json:"eventId"`
+	EventName  string `gorm:"column:eventName;type:varchar(150)" json:"eventName"`
+	Attempt    int    `gorm:"column:attempt" json:"attempt"`
+	URL        string `gorm:"column:url;type:varchar(2048)" json:"url"`
+	// Response status code, 0 if the request failed
+	ResponseCode int `gorm:"column:responseCode" json:"responseCode"`
+	// Response body, limited to the plugin ResponseBodyLimit
+	ResponseBody string `gorm:"column:responseBody;type:text" json:"responseBody"`
+	Error        string `gorm:"column:error;type:text" json:"error"`
+	Success      bool   `gorm:"column:success" json:"success"`
+	// Duration in milliseconds
+	Duration  int64     `gorm:"column:duration" json:"duration"`
+	CreatedAt time.Time `gorm:"column:createdAt" json:"createdAt"`
+}
+
+func (r *Delivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+func (r *Delivery) GetID() string {
+	return strconv.FormatUint(r.ID, 10)
+}
+
+func (r *Delivery) LoadData() error {
+	return nil
+}
+
+func (r *Delivery) LoadTeaserData() error {
+	return nil
+}
+
+func (r *Delivery) Save(app bolo.App) error {
+	if r.ID == 0 {
+		r.CreatedAt = app.GetClock().Now()
+		return app.GetDB().Create(r).Error
+	}
+
+	return app.GetDB().Save(r).Error
+}
+
+// FindOneEndpoint - Find one endpoint by id
+func FindOneEndpoint(app bolo.App, id string) (*Endpoint, error) {
+	record := Endpoint{}
+
+	err := app.GetDB().First(&record, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEndpointNotFound
+		}
+
+		return nil, fmt.Errorf("webhooks.FindOneEndpoint: %w", err)
+	}
+
+	return &record, nil
+}
+
+// FindActiveEndpoints - Find the active endpoints subscribed to the event
+func FindActiveEndpoints(app bolo.App, e *bolo.DomainEvent) ([]*Endpoint, error) {
+	records := []*Endpoint{}
+
+	err := app.GetDB().Where("active = ?", true).Order("id ASC").Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("webhooks.FindActiveEndpoints: %w", err)
+	}
+
+	endpoints := []*Endpoint{}
+	for _, r := range records {
+		if r.Matches(e) {
+			endpoints = append(endpoints, r)
+		}
+	}
+
+	return endpoints, nil
+}
@@ -0,0 +1,86 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/go-bolo/bolo"
+)
+
+// ErrPrivateAddress - The endpoint host is one loopback, link-local, private or unspecified address
+var ErrPrivateAddress = errors.New("webhooks: private network addresses are not allowed")
+
+// IsPrivateIP - Check if the ip is one loopback, link-local, private or unspecified address
+func IsPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsPrivate() ||
+		ip.IsUnspecified()
+}
+
+// CheckHost - Resolve the host and return ErrPrivateAddress if one of the addresses is private.
+// The addresses can change after this check, the delivery client also checks the address of each connection
+func CheckHost(ctx context.Context, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if IsPrivateIP(ip) {
+			return ErrPrivateAddress
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+
+	for _, addr := range addrs {
+		if IsPrivateIP(addr.IP) {
+			return ErrPrivateAddress
+		}
+	}
+
+	return nil
+}
+
+// dialControl - Reject connections to private addresses, checked after the DNS resolution to avoid DNS rebinding
+func dialControl(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || IsPrivateIP(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, address)
+	}
+
+	return nil
+}
+
+// NewPublicHTTPClient - HTTP client that only connects to public addresses, including the redirects.
+// The proxy environment variables are ignored, the requests are sent direct to the endpoints
+func NewPublicHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   dialControl,
+	}
+
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	return &http.Client{Timeout: timeout, Transport: &bolo.RequestIDTransport{Base: transport}}
+}
@@ -0,0 +1,207 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/go-bolo/bolo/users"
+	"go.uber.org/zap"
+)
+
+// JobTypeDelivery - Job type of one event delivery to one endpoint
+const JobTypeDelivery = "webhook_delivery"
+
+// UserLoader - Load the owner of one endpoint
+type UserLoader func(app bolo.App, id string) (bolo.User, error)
+
+// Plugin - Outgoing webhooks, users register endpoints for the domain events with the webhook resource.
+// Deliveries run in the jobs queue, the jobs plugin is required.
+// Model events are only delivered to endpoints of owners with the find_<model> permission
+type Plugin struct {
+	bolo.Plugin
+
+	Name       string
+	App        bolo.App
+	Controller *EndpointController
+	// HTTP client, if nil the app HTTP client is used
+	HTTPClient bolo.CustomHTTPClient
+	LoadUser   UserLoader
+	// Max duration of one delivery request
+	Timeout     time.Duration
+	MaxAttempts int
+	// Max response body bytes stored in the delivery log
+	ResponseBodyLimit int
+	// Allow endpoints in loopback, link-local and private networks, default: WEBHOOKS_ALLOW_PRIVATE_NETWORKS
+	AllowPrivateNetworks bool
+
+	publicClient *http.Client
+}
+
+type PluginOpts struct {
+	HTTPClient bolo.CustomHTTPClient
+	LoadUser   UserLoader
+}
+
+func NewPlugin(opts *PluginOpts) *Plugin {
+	return &Plugin{
+		Name:       "webhooks",
+		HTTPClient: opts.HTTPClient,
+		LoadUser:   opts.LoadUser,
+	}
+}
+
+func (p *Plugin) GetName() string {
+	return p.Name
+}
+
+func (p *Plugin) Init(app bolo.App) error {
+	app.GetLogger().Debug(p.GetName() + " Init")
+
+	p.App = app
+	cfg := app.GetConfiguration()
+
+	if p.Timeout == 0 {
+		p.Timeout = time.Duration(cfg.GetInt64F("WEBHOOKS_TIMEOUT", 10)) * time.Second
+	}
+
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = cfg.GetIntF("WEBHOOKS_MAX_ATTEMPTS", 8)
+	}
+
+	if p.ResponseBodyLimit == 0 {
+		p.ResponseBodyLimit = cfg.GetIntF("WEBHOOKS_RESPONSE_BODY_LIMIT", 2048)
+	}
+
+	if p.LoadUser == nil {
+		p.LoadUser = func(app bolo.App, id string) (bolo.User, error) {
+			return users.FindOneByID(app, id)
+		}
+	}
+
+	if !p.AllowPrivateNetworks {
+		p.AllowPrivateNetworks = cfg.GetBool("WEBHOOKS_ALLOW_PRIVATE_NETWORKS")
+	}
+	p.publicClient = NewPublicHTTPClient(p.Timeout)
+
+	p.Controller = &EndpointController{Plugin: p}
+
+	app.SetModel("webhook_endpoint", &Endpoint{})
+	app.SetModel("webhook_delivery", &Delivery{})
+
+	app.SetResource(&bolo.Resource{
+		Name:       "webhook",
+		Prefix:     "/api/v1",
+		Path:       "/webhooks",
+		Controller: p.Controller,
+		Model:      &Endpoint{},
+		// the endpoints of one user are not events of the other users
+		SkipEvents: true,
+		// webhooks don't have HTML pages
+		SkipHTMLEndpoints: true,
+	})
+
+	app.SetRoute("find_webhook_delivery", &bolo.Route{
+		Method:     http.MethodGet,
+		Path:       "/api/v1/webhooks/:id/deliveries",
+		Action:     p.Controller.FindDeliveries,
+		Permission: "find_webhook_delivery",
+	})
+
+	err := app.SetJobType(&bolo.JobType{
+		Name:        JobTypeDelivery,
+		Handler:     bolo.NewJobHandler(p.handleDeliveryJob),
+		Queue:       cfg.GetF("WEBHOOKS_QUEUE", "webhooks"),
+		MaxAttempts: p.MaxAttempts,
+		Timeout:     p.Timeout + 10*time.Second,
+	})
+	if err != nil {
+		return err
+	}
+
+	app.GetDomainEvents().SubscribeAsync("*", p.handleEvent)
+
+	return nil
+}
+
+// DeliveryJob - Payload of the webhook_delivery jobs
+type DeliveryJob struct {
+	EndpointID string            `json:"endpointId"`
+	Event      *bolo.DomainEvent `json:"event"`
+}
+
+// handleEvent - Enqueue one delivery for each endpoint subscribed to the event
+func (p *Plugin) handleEvent(ctx context.Context, app bolo.App, e *bolo.DomainEvent) error {
+	endpoints, err := FindActiveEndpoints(app, e)
+	if err != nil {
+		return err
+	}
+
+	// owners permissions, loaded once for each owner
+	allowed := map[string]bool{}
+
+	for _, endpoint := range endpoints {
+		can, ok := allowed[endpoint.UserID]
+		if !ok {
+			can = p.CanReceive(app, endpoint.UserID, e)
+			allowed[endpoint.UserID] = can
+		}
+
+		if !can {
+			continue
+		}
+
+		_, err := bolo.EnqueueJob(ctx, app, JobTypeDelivery, &DeliveryJob{EndpointID: endpoint.GetID(), Event: e}, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CanReceive - Check if the endpoint owner can read the event. Model events require the find_<model> permission,
+// the other events are published by the application and are delivered to all subscribed endpoints
+func (p *Plugin) CanReceive(app bolo.App, userID string, e *bolo.DomainEvent) bool {
+	if e.Model == "" {
+		return true
+	}
+
+	user, err := p.LoadUser(app, userID)
+	if err != nil || user == nil {
+		app.GetLogger().Debug("webhooks: endpoint owner not found", zap.String("userID", userID), zap.Error(err))
+		return false
+	}
+
+	if user.IsBlocked() || !user.IsActive() {
+		return false
+	}
+
+	return app.GetAcl().Can("find_"+e.Model, append([]string{"authenticated"}, user.GetRoles()...))
+}
+
+func (p *Plugin) handleDeliveryJob(ctx context.Context, app bolo.App, job *DeliveryJob) error {
+	endpoint, err := FindOneEndpoint(app, job.EndpointID)
+	if err != nil {
+		if errors.Is(err, ErrEndpointNotFound) {
+			app.GetLogger().Debug("webhooks: endpoint deleted, delivery canceled", zap.String("endpointID", job.EndpointID), zap.String("eventID", job.Event.ID))
+			return nil
+		}
+
+		return err
+	}
+
+	if !endpoint.Active {
+		return nil
+	}
+
+	attempt := 1
+	if info := bolo.GetJobInfo(ctx); info != nil {
+		attempt = info.Attempt
+	}
+
+	_, err = p.Deliver(ctx, endpoint, job.Event, attempt)
+	return err
+}
@@ -0,0 +1,382 @@
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-bolo/bolo"
+	"github.com/go-bolo/bolo/acl"
+	"github.com/go-bolo/bolo/jobs"
+	"github.com/go-bolo/bolo/users"
+	"github.com/go-bolo/bolo/webhooks"
+	"github.com/go-bolo/clock"
+	"github.com/gookit/event"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type clientFunc func(req *http.Request) (*http.Response, error)
+
+func (f clientFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func newTestApp(t *testing.T) (bolo.App, *webhooks.Plugin, *jobs.Queue, *clock.Mock) {
+	os.Setenv("TEMPLATE_DISABLE", "true")
+	os.Setenv("JOBS_WORKERS_DISABLE", "true")
+	os.Setenv("DB_URI", filepath.Join(t.TempDir(), "webhooks.sqlite"))
+	// the test servers listen in the loopback
+	os.Setenv("WEBHOOKS_ALLOW_PRIVATE_NETWORKS", "true")
+	t.Cleanup(func() {
+		os.Unsetenv("TEMPLATE_DISABLE")
+		os.Unsetenv("JOBS_WORKERS_DISABLE")
+		os.Unsetenv("DB_URI")
+		os.Unsetenv("WEBHOOKS_ALLOW_PRIVATE_NETWORKS")
+	})
+
+	app := bolo.NewApp(&bolo.DefaultAppOptions{})
+
+	c := clock.NewMock()
+	now, _ := time.Parse("2006-01-02", "2023-07-16")
+	c.Set(now)
+	app.SetClock(c)
+
+	p := webhooks.NewPlugin(&webhooks.PluginOpts{
+		// the user 2 is one editor
+		LoadUser: func(app bolo.App, id string) (bolo.User, error) {
+			u := users.NewUser(app)
+			u.SetID(id)
+			if id == "2" {
+				u.Roles = []string{"editor"}
+			}
+			return u, nil
+		},
+	})
+	jobsPlugin := jobs.NewPlugin(&jobs.PluginOpts{})
+	app.AddPlugin(jobsPlugin)
+	app.AddPlugin(p)
+
+	// authenticate the requests with the X-Test-User header:
+	app.GetEvents().On("bindMiddlewares", event.ListenerFunc(func(e event.Event) error {
+		app.GetRouter().Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				if id := c.Request().Header.Get("X-Test-User"); id != "" {
+					u := users.NewUser(app)
+					u.ID = 1
+					if id == "2" {
+						u.ID = 2
+					}
+					bolo.SetAuthenticatedUser(c, u)
+					bolo.SetRoles(c, []string{"authenticated"})
+				}
+				return next(c)
+			}
+		})
+		return nil
+	}), event.Low)
+
+	require.Nil(t, app.Bootstrap())
+	require.Nil(t, app.SyncDB())
+
+	app.GetAcl().SetRole("authenticated", acl.Role{
+		Name: "authenticated",
+		Permissions: []string{
+			"find_webhook", "findOne_webhook", "create_webhook", "update_webhook", "delete_webhook", "find_webhook_delivery",
+		},
+	})
+	app.GetAcl().SetRole("editor", acl.Role{Name: "editor", Permissions: []string{"find_post"}})
+
+	return app, p, jobsPlugin.Queue, c
+}
+
+func do(app bolo.App, method, path, body, userID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+	if userID != "" {
+		req.Header.Set("X-Test-User", userID)
+	}
+
+	rec := httptest.NewRecorder()
+	app.GetRouter().ServeHTTP(rec, req)
+	return rec
+}
+
+// publish - Publish one event and wait the async listener enqueue the deliveries
+func publish(t *testing.T, app bolo.App, name string, data interface{}, deliveries int64) *bolo.DomainEvent {
+	var before int64
+	app.GetDB().Model(&jobs.Job{}).Where("type = ?", webhooks.JobTypeDelivery).Count(&before)
+
+	e, err := bolo.NewDomainEvent(app, name, data)
+	require.Nil(t, err)
+	require.Nil(t, app.GetDomainEvents().Publish(context.Background(), e))
+
+	require.Eventually(t, func() bool {
+		var count int64
+		app.GetDB().Model(&jobs.Job{}).Where("type = ?", webhooks.JobTypeDelivery).Count(&count)
+		return count == before+deliveries
+	}, 2*time.Second, 5*time.Millisecond)
+
+	return e
+}
+
+func TestSignature(t *testing.T) {
+	now := time.Unix(1689465600, 0)
+	body := []byte(`{"id":"1"}`)
+
+	signature := webhooks.Sign("whsec_test", now.Unix(), body)
+	assert.True(t, strings.HasPrefix(signature, "sha256="))
+
+	assert.Nil(t, webhooks.Verify("whsec_test", "1689465600", signature, body, now.Add(time.Minute), 5*time.Minute))
+	assert.ErrorIs(t, webhooks.Verify("other", "1689465600", signature, body, now, 5*time.Minute), webhooks.ErrInvalidSignature)
+	assert.ErrorIs(t, webhooks.Verify("whsec_test", "1689465600", signature, []byte(`{"id":"2"}`), now, 5*time.Minute), webhooks.ErrInvalidSignature)
+	assert.ErrorIs(t, webhooks.Verify("whsec_test", "1689465600", signature, body, now.Add(10*time.Minute), 5*time.Minute), webhooks.ErrInvalidTimestamp)
+	assert.ErrorIs(t, webhooks.Verify("whsec_test", "invalid", signature, body, now, 5*time.Minute), webhooks.ErrInvalidTimestamp)
+}
+
+func TestEndpointMatches(t *testing.T) {
+	created := &bolo.DomainEvent{Name: bolo.EventModelCreated, Model: "content"}
+	paid := &bolo.DomainEvent{Name: "order.paid"}
+
+	assert.True(t, (&webhooks.Endpoint{Events: []string{"model.created"}}).Matches(created))
+	assert.True(t, (&webhooks.Endpoint{Events: []string{"content.*"}}).Matches(created))
+	assert.True(t, (&webhooks.Endpoint{Events: []string{"content.created"}}).Matches(created))
+	assert.False(t, (&webhooks.Endpoint{Events: []string{"content.deleted", "user.*"}}).Matches(created))
+	assert.True(t, (&webhooks.Endpoint{Events: []string{"*"}}).Matches(paid))
+	assert.True(t, (&webhooks.Endpoint{Events: []string{"order.*"}}).Matches(paid))
+	assert.False(t, (&webhooks.Endpoint{Events: []string{"content.*"}}).Matches(paid))
+}
+
+func TestPrivateNetworks(t *testing.T) {
+	for _, ip := range []string{"127.0.0.1", "::1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "fe80::1", "fd00::1", "0.0.0.0", "::"} {
+		assert.True(t, webhooks.IsPrivateIP(net.ParseIP(ip)), ip)
+	}
+	for _, ip := range []string{"93.184.216.34", "2606:2800:220:1:248:1893:25c8:1946"} {
+		assert.False(t, webhooks.IsPrivateIP(net.ParseIP(ip)), ip)
+	}
+
+	app, p, _, _ := newTestApp(t)
+	p.AllowPrivateNetworks = false
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("internal"))
+	}))
+	defer server.Close()
+
+	t.Run("should reject private hosts", func(t *testing.T) {
+		for _, u := range []string{server.URL, "http://localhost/hook", "http://10.0.0.1/hook", "http://[::1]:8080/hook", "http://169.254.169.254/latest/meta-data", "http://0.0.0.0/hook"} {
+			rec := do(app, http.MethodPost, "/api/v1/webhooks", `{"webhook":{"url":"`+u+`","events":["*"]}}`, "1")
+			assert.Equal(t, http.StatusBadRequest, rec.Code, u)
+		}
+	})
+
+	t.Run("should not connect to private addresses", func(t *testing.T) {
+		// saved before the host resolved to one private address
+		endpoint := &webhooks.Endpoint{UserID: "1", URL: server.URL + "/hook", Events: []string{"*"}, Secret: "whsec_test", Active: true}
+		require.Nil(t, endpoint.Save(app))
+
+		e, _ := bolo.NewDomainEvent(app, "order.paid", nil)
+		delivery, err := p.Deliver(context.Background(), endpoint, e, 1)
+		assert.ErrorIs(t, err, webhooks.ErrPrivateAddress)
+		assert.Equal(t, 0, delivery.ResponseCode)
+		assert.Empty(t, delivery.ResponseBody)
+		assert.Equal(t, 0, requests)
+	})
+}
+
+func TestWebhooks(t *testing.T) {
+	app, p, queue, clk := newTestApp(t)
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	statuses := []int{http.StatusOK}
+	received := []*http.Request{}
+	bodies := [][]byte{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r)
+		bodies = append(bodies, body)
+
+		status := statuses[0]
+		if len(statuses) > 1 {
+			statuses = statuses[1:]
+		}
+
+		w.WriteHeader(status)
+		w.Write([]byte(http.StatusText(status)))
+	}))
+	defer server.Close()
+
+	t.Run("should validate the endpoint URL", func(t *testing.T) {
+		rec := do(app, http.MethodPost, "/api/v1/webhooks", `{"webhook":{"url":"ftp://example.com/hook","events":["order.*"]}}`, "1")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	rec := do(app, http.MethodPost, "/api/v1/webhooks", `{"webhook":{"url":"`+server.URL+`/hook","events":["order.*"]}}`, "1")
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.NotContains(t, rec.Body.String(), `"secret":""`)
+
+	created := webhooks.CreateJSONResponse{}
+	require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	endpointID := created.Endpoint.GetID()
+	secret := created.Secret
+	assert.True(t, strings.HasPrefix(secret, "whsec_"))
+
+	t.Run("should deliver signed events", func(t *testing.T) {
+		e := publish(t, app, "order.paid", map[string]int{"total": 10}, 1)
+
+		processed, err := queue.RunNext(ctx, "webhooks")
+		require.Nil(t, err)
+		require.True(t, processed)
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.Len(t, received, 1)
+
+		r := received[0]
+		assert.Equal(t, "/hook", r.URL.Path)
+		assert.Equal(t, e.ID, r.Header.Get(webhooks.HeaderID))
+		assert.Equal(t, "order.paid", r.Header.Get(webhooks.HeaderEvent))
+		assert.Nil(t, webhooks.Verify(secret, r.Header.Get(webhooks.HeaderTimestamp), r.Header.Get(webhooks.HeaderSignature), bodies[0], clk.Now(), 5*time.Minute))
+
+		delivered := bolo.DomainEvent{}
+		require.Nil(t, json.Unmarshal(bodies[0], &delivered))
+		assert.Equal(t, e.ID, delivered.ID)
+		assert.JSONEq(t, `{"total":10}`, string(delivered.Data))
+	})
+
+	t.Run("should not deliver events without subscription", func(t *testing.T) {
+		publish(t, app, "user.created", nil, 0)
+	})
+
+	t.Run("should retry failed deliveries with backoff and log the responses", func(t *testing.T) {
+		mu.Lock()
+		statuses = []int{http.StatusServiceUnavailable, http.StatusOK}
+		mu.Unlock()
+
+		publish(t, app, "order.refunded", nil, 1)
+
+		processed, _ := queue.RunNext(ctx, "webhooks")
+		require.True(t, processed)
+
+		processed, _ = queue.RunNext(ctx, "webhooks")
+		assert.False(t, processed)
+
+		clk.Add(queue.Backoff(1))
+		processed, _ = queue.RunNext(ctx, "webhooks")
+		require.True(t, processed)
+
+		rec := do(app, http.MethodGet, "/api/v1/webhooks/"+endpointID+"/deliveries", "", "1")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		list := webhooks.DeliveriesJSONResponse{}
+		require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &list))
+		require.Len(t, list.Deliveries, 3)
+
+		assert.Equal(t, http.StatusOK, list.Deliveries[0].ResponseCode)
+		assert.Equal(t, 2, list.Deliveries[0].Attempt)
+		assert.True(t, list.Deliveries[0].Success)
+
+		assert.Equal(t, http.StatusServiceUnavailable, list.Deliveries[1].ResponseCode)
+		assert.Equal(t, 1, list.Deliveries[1].Attempt)
+		assert.Equal(t, "Service Unavailable", list.Deliveries[1].ResponseBody)
+		assert.Equal(t, "unexpected response status 503", list.Deliveries[1].Error)
+		assert.False(t, list.Deliveries[1].Success)
+	})
+
+	t.Run("should use the swappable HTTP client", func(t *testing.T) {
+		p.HTTPClient = clientFunc(func(req *http.Request) (*http.Response, error) {
+			return nil, errors.New("connection refused")
+		})
+		defer func() { p.HTTPClient = nil }()
+
+		endpoint, err := webhooks.FindOneEndpoint(app, endpointID)
+		require.Nil(t, err)
+
+		e, _ := bolo.NewDomainEvent(app, "order.paid", nil)
+		delivery, err := p.Deliver(ctx, endpoint, e, 1)
+		assert.ErrorContains(t, err, "connection refused")
+		assert.Equal(t, 0, delivery.ResponseCode)
+		assert.Contains(t, delivery.Error, "connection refused")
+	})
+
+	t.Run("should scope the endpoints by user", func(t *testing.T) {
+		rec := do(app, http.MethodGet, "/api/v1/webhooks/"+endpointID, "", "2")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		rec = do(app, http.MethodGet, "/api/v1/webhooks", "", "2")
+		list := webhooks.ListJSONResponse{}
+		require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &list))
+		assert.Empty(t, list.Endpoints)
+	})
+
+	t.Run("should cancel deliveries of disabled endpoints", func(t *testing.T) {
+		rec := do(app, http.MethodPut, "/api/v1/webhooks/"+endpointID, `{"webhook":{"url":"`+server.URL+`/hook","events":["order.*"],"active":false}}`, "1")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		publish(t, app, "order.paid", nil, 0)
+
+		rec = do(app, http.MethodDelete, "/api/v1/webhooks/"+endpointID, "", "1")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		var count int64
+		app.GetDB().Model(&webhooks.Delivery{}).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+	t.Run("should only deliver the model events the owner can read", func(t *testing.T) {
+		models := make(chan string, 10)
+		app.GetDomainEvents().Subscribe("*", func(ctx context.Context, app bolo.App, e *bolo.DomainEvent) error {
+			models <- e.Model
+			return nil
+		})
+
+		rec := do(app, http.MethodPost, "/api/v1/webhooks", `{"webhook":{"url":"`+server.URL+`/user-1","events":["post.*","webhook.*"]}}`, "1")
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		rec = do(app, http.MethodPost, "/api/v1/webhooks", `{"webhook":{"url":"`+server.URL+`/user-2","events":["post.*","webhook.*"]}}`, "2")
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		// the webhook resource don't emit model events
+		assert.Empty(t, models)
+
+		var before int64
+		app.GetDB().Model(&jobs.Job{}).Where("type = ?", webhooks.JobTypeDelivery).Count(&before)
+
+		e, err := bolo.NewDomainEvent(app, bolo.EventModelCreated, map[string]string{"title": "draft"})
+		require.Nil(t, err)
+		e.Model = "post"
+		e.RecordID = "1"
+		require.Nil(t, app.GetDomainEvents().Publish(ctx, e))
+
+		require.Eventually(t, func() bool {
+			var count int64
+			app.GetDB().Model(&jobs.Job{}).Where("type = ?", webhooks.JobTypeDelivery).Count(&count)
+			return count == before+1
+		}, 2*time.Second, 5*time.Millisecond)
+
+		processed, err := queue.RunNext(ctx, "webhooks")
+		require.Nil(t, err)
+		require.True(t, processed)
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, "/user-2", received[len(received)-1].URL.Path)
+	})
+}